@@ -0,0 +1,114 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clock implements the clocks used by time-dependent pipeline logic.
+package clock
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// ConfigKey is the configuration key selecting the clock mode of a pipeline stage.
+const ConfigKey string = "clock"
+
+// SchemaKey declares the clock configuration key in plugin configuration schemas.
+var SchemaKey = schema.Key{Name: ConfigKey, Type: schema.Enum, Default: WallMode.String(), Values: []string{WallMode.String(), EventMode.String()}}
+
+// Mode defines a clock mode.
+type Mode int
+
+// Clock modes.
+const (
+	WallMode  Mode = iota // time is read from the system clock
+	EventMode             // time advances with the timestamps of processed records
+)
+
+func (m Mode) String() string {
+	return [...]string{"wall", "event"}[m]
+}
+
+// ParseMode parses a clock mode, defaulting to WallMode.
+func ParseMode(s string) Mode {
+	if s == EventMode.String() {
+		return EventMode
+	}
+	return WallMode
+}
+
+// Clock tells the time to time-dependent pipeline logic (e.g., rate limiters, flush timers).
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// Since returns the time elapsed since t.
+	Since(t time.Time) time.Duration
+	// Advance informs the clock that a record with timestamp ts (in nanoseconds) is being processed.
+	Advance(ts int64)
+}
+
+// New creates a clock for mode m.
+func New(m Mode) Clock {
+	if m == EventMode {
+		return NewEventClock()
+	}
+	return Wall
+}
+
+// Wall is the system clock.
+var Wall Clock = wallClock{}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time                  { return time.Now() }
+func (wallClock) Since(t time.Time) time.Duration { return time.Since(t) }
+func (wallClock) Advance(ts int64)                {}
+
+// EventClock is a clock whose time is the largest record timestamp seen so far, so that
+// results computed over a trace do not depend on how fast the trace is replayed.
+// It is safe for concurrent use.
+type EventClock struct {
+	now int64
+}
+
+// NewEventClock creates an event clock starting at the Unix epoch.
+func NewEventClock() *EventClock {
+	return &EventClock{}
+}
+
+// Now returns the largest record timestamp seen so far.
+func (c *EventClock) Now() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&c.now))
+}
+
+// Since returns the event time elapsed since t.
+func (c *EventClock) Since(t time.Time) time.Duration {
+	return c.Now().Sub(t)
+}
+
+// Advance moves the clock forward to ts. Timestamps older than the current time are ignored.
+func (c *EventClock) Advance(ts int64) {
+	for {
+		now := atomic.LoadInt64(&c.now)
+		if ts <= now || atomic.CompareAndSwapInt64(&c.now, now, ts) {
+			return
+		}
+	}
+}
@@ -0,0 +1,60 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+package clock_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+)
+
+func TestEventClock(t *testing.T) {
+	c := clock.NewEventClock()
+	assert.Equal(t, int64(0), c.Now().UnixNano())
+	c.Advance(int64(10 * time.Second))
+	start := c.Now()
+	c.Advance(int64(5 * time.Second))
+	assert.Equal(t, start, c.Now(), "older timestamps must not move the clock back")
+	c.Advance(int64(70 * time.Second))
+	assert.Equal(t, time.Minute, c.Since(start))
+}
+
+func TestEventClockConcurrentAdvance(t *testing.T) {
+	c := clock.NewEventClock()
+	var wg sync.WaitGroup
+	for i := 1; i <= 100; i++ {
+		wg.Add(1)
+		go func(ts int64) {
+			defer wg.Done()
+			c.Advance(ts)
+		}(int64(i))
+	}
+	wg.Wait()
+	assert.Equal(t, int64(100), c.Now().UnixNano())
+}
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, clock.Wall, clock.New(clock.ParseMode("wall")))
+	assert.Equal(t, clock.Wall, clock.New(clock.ParseMode("bogus")))
+	assert.IsType(t, &clock.EventClock{}, clock.New(clock.ParseMode("event")))
+}
@@ -21,9 +21,13 @@
 package commons
 
 import (
+	"os"
+	"path/filepath"
 	"strconv"
 
 	"github.com/sysflow-telemetry/sf-apis/go/secrets"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
@@ -41,6 +45,34 @@ const (
 	ClusterIDKey           string = "cluster.id"
 )
 
+// defaultVaultPath is the mount path of container secrets.
+const defaultVaultPath = "/run/secrets"
+
+// ConfigSchema declares the configuration keys accepted by the exporter.
+var ConfigSchema = schema.New("exporter", configKeys, fileConfigKeys, syslogConfigKeys, esConfigKeys, findingsConfigKeys, sfConfigKeys, pqConfigKeys, otlpConfigKeys, httpConfigKeys, splunkConfigKeys, streamConfigKeys, fieldsConfigKeys, occConfigKeys, dedupeConfigKeys)
+
+func init() {
+	schema.Register(ConfigSchema)
+}
+
+// configKeys declares the general exporter configuration keys.
+var configKeys = []schema.Key{
+	{Name: TransportConfigKey, Type: schema.Enum, Default: StdOutTransport.String(),
+		Values: []string{StdOutTransport.String(), FileTransport.String(), SyslogTransport.String(), ESTransport.String(), FindingsTransport.String(), NullTransport.String(), SysFlowTransport.String(), ParquetTransport.String(), OTLPTransport.String(), HTTPTransport.String(), OpenSearchTransport.String(), SplunkTransport.String(), StreamTransport.String()}},
+	{Name: FormatConfigKey, Type: schema.Enum, Default: JSONFormat.String(),
+		Values: []string{JSONFormat.String(), ECSFormat.String(), OccurrenceFormat.String(), SysFlowFormat.String(), ParquetFormat.String(), OTLPFormat.String(), CEFFormat.String(), LEEFFormat.String(), AvroFormat.String(), ProtobufFormat.String()}},
+	{Name: VaultEnabledConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: VaultPathConfigKey, Type: schema.String},
+	{Name: VaultEncodingConfigKey, Type: schema.Enum, Default: NoneVaultEncoding.String(), Values: []string{NoneVaultEncoding.String(), Base64VaultEncoding.String()}},
+	{Name: EventBufferConfigKey, Type: schema.Int, Default: "0"},
+	{Name: VersionKey, Type: schema.String},
+	{Name: JSONSchemaVersionKey, Type: schema.String},
+	{Name: EcsVersionKey, Type: schema.String},
+	{Name: BuildNumberKey, Type: schema.String},
+	{Name: ClusterIDKey, Type: schema.String},
+	clock.SchemaKey,
+}
+
 // Config defines a configuration object for the exporter.
 type Config struct {
 	Transport         Transport
@@ -55,10 +87,20 @@ type Config struct {
 	EcsVersion        string
 	BuildNumber       string
 	ClusterID         string
+	Clock             clock.Mode
 	FileConfig
 	SyslogConfig
 	ESConfig
+	OccurrenceConfig
+	DedupeConfig
 	FindingsConfig
+	SysFlowConfig
+	ParquetConfig
+	OTLPConfig
+	HTTPConfig
+	SplunkConfig
+	StreamConfig
+	FieldsConfig
 }
 
 // CreateConfig creates a new config object from config dictionary.
@@ -71,13 +113,12 @@ func CreateConfig(conf map[string]interface{}) (c Config, err error) {
 		if e, ok := conf[VaultEncodingConfigKey].(string); ok {
 			c.VaultEncoding = parseVaultEncodingConfig(e)
 		}
-		var s *secrets.Secrets
+		c.VaultMountPath = defaultVaultPath
 		if p, ok := conf[VaultPathConfigKey].(string); ok {
-			s, err = secrets.NewSecretsWithCustomPath(p)
-		} else {
-			s, err = secrets.NewSecrets()
+			c.VaultMountPath = p
 		}
-		if err != nil {
+		var s *secrets.Secrets
+		if s, err = secrets.NewSecretsWithCustomPath(c.VaultMountPath); err != nil {
 			return
 		}
 		c.secrets = s
@@ -111,6 +152,9 @@ func CreateConfig(conf map[string]interface{}) (c Config, err error) {
 	if v, ok := conf[ClusterIDKey].(string); ok {
 		c.ClusterID = v
 	}
+	if v, ok := conf[clock.ConfigKey].(string); ok {
+		c.Clock = clock.ParseMode(v)
+	}
 
 	// parse specialized configs
 	c.FileConfig, err = CreateFileConfig(c, conf)
@@ -125,6 +169,42 @@ func CreateConfig(conf map[string]interface{}) (c Config, err error) {
 	if err != nil {
 		return
 	}
+	c.SysFlowConfig, err = CreateSysFlowConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.ParquetConfig, err = CreateParquetConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.OTLPConfig, err = CreateOTLPConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.HTTPConfig, err = CreateHTTPConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.SplunkConfig, err = CreateSplunkConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.StreamConfig, err = CreateStreamConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.FieldsConfig, err = CreateFieldsConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.OccurrenceConfig, err = CreateOccurrenceConfig(c, conf)
+	if err != nil {
+		return
+	}
+	c.DedupeConfig, err = CreateDedupeConfig(c, conf)
+	if err != nil {
+		return
+	}
 	c.FindingsConfig, err = CreateFindingsConfig(c, conf)
 
 	return
@@ -141,10 +221,17 @@ const (
 	ESTransport
 	FindingsTransport
 	NullTransport
+	SysFlowTransport
+	ParquetTransport
+	OTLPTransport
+	HTTPTransport
+	OpenSearchTransport
+	SplunkTransport
+	StreamTransport
 )
 
 func (s Transport) String() string {
-	return [...]string{"terminal", "file", "syslog", "es", "findings", "null"}[s]
+	return [...]string{"terminal", "file", "syslog", "es", "findings", "null", "sysflow", "parquet", "otlp", "http", "opensearch", "splunk", "stream"}[s]
 }
 
 func parseTransportConfig(s string) Transport {
@@ -163,6 +250,27 @@ func parseTransportConfig(s string) Transport {
 	if NullTransport.String() == s {
 		return NullTransport
 	}
+	if SysFlowTransport.String() == s {
+		return SysFlowTransport
+	}
+	if ParquetTransport.String() == s {
+		return ParquetTransport
+	}
+	if OTLPTransport.String() == s {
+		return OTLPTransport
+	}
+	if HTTPTransport.String() == s {
+		return HTTPTransport
+	}
+	if OpenSearchTransport.String() == s {
+		return OpenSearchTransport
+	}
+	if SplunkTransport.String() == s {
+		return SplunkTransport
+	}
+	if StreamTransport.String() == s {
+		return StreamTransport
+	}
 	return StdOutTransport
 }
 
@@ -173,11 +281,18 @@ type Format int
 const (
 	JSONFormat       Format = iota // JSON schema
 	ECSFormat                      // Elastic Common Schema
-	OccurrenceFormat               // IBM Findings Occurrence
+	OccurrenceFormat               // Occurrence (e.g., IBM Findings)
+	SysFlowFormat                  // SysFlow Avro records
+	ParquetFormat                  // Parquet rows
+	OTLPFormat                     // OpenTelemetry log records
+	CEFFormat                      // ArcSight Common Event Format
+	LEEFFormat                     // QRadar Log Event Extended Format
+	AvroFormat                     // Avro records (single-object encoding)
+	ProtobufFormat                 // Protocol Buffers messages
 )
 
 func (s Format) String() string {
-	return [...]string{"json", "ecs", "occurrence"}[s]
+	return [...]string{"json", "ecs", "occurrence", "sysflow", "parquet", "otlp", "cef", "leef", "avro", "protobuf"}[s]
 }
 
 func parseFormatConfig(s string) Format {
@@ -188,6 +303,20 @@ func parseFormatConfig(s string) Format {
 		return ECSFormat
 	case OccurrenceFormat.String():
 		return OccurrenceFormat
+	case SysFlowFormat.String():
+		return SysFlowFormat
+	case ParquetFormat.String():
+		return ParquetFormat
+	case OTLPFormat.String():
+		return OTLPFormat
+	case CEFFormat.String():
+		return CEFFormat
+	case LEEFFormat.String():
+		return LEEFFormat
+	case AvroFormat.String():
+		return AvroFormat
+	case ProtobufFormat.String():
+		return ProtobufFormat
 	}
 	return JSONFormat
 }
@@ -245,3 +374,13 @@ func parseVaultEncodingConfig(s string) VaultEncoding {
 func (c Config) GetSecret(key string) (string, error) {
 	return [...]func(string) (string, error){c.secrets.Get, c.secrets.GetDecoded}[c.VaultEncoding](key)
 }
+
+// LookupSecret obtains the secret for an optional key. A secret missing from
+// the vault is reported as unset; only failures reading an existing secret are
+// returned as errors.
+func (c Config) LookupSecret(key string) (string, error) {
+	if _, err := os.Stat(filepath.Join(c.VaultMountPath, key)); os.IsNotExist(err) {
+		return "", nil
+	}
+	return c.GetSecret(key)
+}
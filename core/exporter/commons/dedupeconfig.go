@@ -0,0 +1,122 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	DedupeConfigKey           string = "dedupe"
+	DedupeKeysConfigKey       string = "dedupe.keys"
+	DedupeSizeConfigKey       string = "dedupe.size"
+	DedupeFPRateConfigKey     string = "dedupe.fprate"
+	DedupeIntervalConfigKey   string = "dedupe.interval"
+	DedupeContainersConfigKey string = "dedupe.containers"
+)
+
+// DefaultDedupeConfig holds the default deduplication settings. The default dedupe keys are the attributes
+// denoting the semantics of a record.
+var DefaultDedupeConfig = DedupeConfig{
+	DedupeKeys:       []string{engine.SF_PROC_CMDLINE, engine.SF_PROC_UID, engine.SF_FILE_OID, engine.SF_OPFLAGS, engine.SF_PROC_TTY},
+	DedupeSize:       100000,
+	DedupeFPRate:     0.0000001,
+	DedupeContainers: 100,
+}
+
+// dedupeConfigKeys declares the deduplication configuration keys.
+var dedupeConfigKeys = []schema.Key{
+	{Name: DedupeConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: DedupeKeysConfigKey, Type: schema.List, Default: strings.Join(DefaultDedupeConfig.DedupeKeys, ",")},
+	{Name: DedupeSizeConfigKey, Type: schema.Int, Default: "100000"},
+	{Name: DedupeFPRateConfigKey, Type: schema.Float, Default: "0.0000001"},
+	{Name: DedupeIntervalConfigKey, Type: schema.Duration, Default: "0s"},
+	{Name: DedupeContainersConfigKey, Type: schema.Int, Default: "100"},
+}
+
+// DedupeConfig holds the deduplication settings of an exporter. Records are duplicates of records seen
+// before if they have the same values of the dedupe keys, as tracked in a bloom filter of the given size
+// (expected number of distinct records) and false positive rate. At most DedupeContainers filters are kept,
+// evicting the filters of the least recently seen containers.
+type DedupeConfig struct {
+	Dedupe           bool
+	DedupeKeys       []string
+	DedupeSize       uint64
+	DedupeFPRate     float64
+	DedupeInterval   time.Duration
+	DedupeContainers int
+}
+
+// CreateDedupeConfig creates a new config object from config dictionary.
+func CreateDedupeConfig(bc Config, conf map[string]interface{}) (c DedupeConfig, err error) {
+	// default values
+	c = DefaultDedupeConfig
+
+	// parse config map
+	if v, ok := conf[DedupeConfigKey].(string); ok {
+		if c.Dedupe, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, DedupeConfigKey, err)
+		}
+	}
+	if v, ok := conf[DedupeKeysConfigKey].(string); ok {
+		c.DedupeKeys = nil
+		for _, k := range strings.Split(v, ",") {
+			if k = strings.TrimSpace(k); k == "" {
+				continue
+			}
+			if _, ok := engine.Mapper.Mappers[k]; !ok {
+				return c, fmt.Errorf("invalid value '%s' for key '%s': unknown attribute '%s'", v, DedupeKeysConfigKey, k)
+			}
+			c.DedupeKeys = append(c.DedupeKeys, k)
+		}
+		if len(c.DedupeKeys) == 0 {
+			return c, fmt.Errorf("missing value for key '%s'", DedupeKeysConfigKey)
+		}
+	}
+	if v, ok := conf[DedupeSizeConfigKey].(string); ok {
+		if c.DedupeSize, err = strconv.ParseUint(v, 10, 64); err != nil || c.DedupeSize == 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a positive integer", v, DedupeSizeConfigKey)
+		}
+	}
+	if v, ok := conf[DedupeFPRateConfigKey].(string); ok {
+		if c.DedupeFPRate, err = strconv.ParseFloat(v, 64); err != nil || c.DedupeFPRate <= 0 || c.DedupeFPRate >= 1 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a rate between 0 and 1", v, DedupeFPRateConfigKey)
+		}
+	}
+	if v, ok := conf[DedupeIntervalConfigKey].(string); ok {
+		if c.DedupeInterval, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, DedupeIntervalConfigKey, err)
+		}
+	}
+	if v, ok := conf[DedupeContainersConfigKey].(string); ok {
+		if c.DedupeContainers, err = strconv.Atoi(v); err != nil || c.DedupeContainers <= 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a positive integer", v, DedupeContainersConfigKey)
+		}
+	}
+	return
+}
@@ -21,31 +21,63 @@
 package commons
 
 import (
+	"fmt"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
 const (
-	ESAddressesConfigKey string = "es.addresses"
-	ESIndexConfigKey     string = "es.index"
-	ESUsernameConfigKey  string = "es.username"
-	ESPasswordConfigKey  string = "es.password"
-	ESWorkersConfigKey   string = "es.bulk.numWorkers"
-	ESFBufferConfigKey   string = "es.bulk.flushBuffer"
-	ESFTimeoutConfigKey  string = "es.bulk.flushTimeout"
+	ESAddressesConfigKey        string = "es.addresses"
+	ESIndexConfigKey            string = "es.index"
+	ESUsernameConfigKey         string = "es.username"
+	ESPasswordConfigKey         string = "es.password"
+	ESWorkersConfigKey          string = "es.bulk.numWorkers"
+	ESFBufferConfigKey          string = "es.bulk.flushBuffer"
+	ESFTimeoutConfigKey         string = "es.bulk.flushTimeout"
+	ESDataStreamConfigKey       string = "es.datastream"
+	ESTemplateNameConfigKey     string = "es.template.name"
+	ESTemplateMappingsConfigKey string = "es.template.mappings"
+	ESILMPolicyConfigKey        string = "es.ilm.policy"
+	ESILMFileConfigKey          string = "es.ilm.file"
+	ESRetryMaxConfigKey         string = "es.retry.max"
 )
 
+// esConfigKeys declares the Elasticsearch transport configuration keys.
+var esConfigKeys = []schema.Key{
+	{Name: ESAddressesConfigKey, Type: schema.List},
+	{Name: ESIndexConfigKey, Type: schema.String},
+	{Name: ESUsernameConfigKey, Type: schema.String},
+	{Name: ESPasswordConfigKey, Type: schema.String, Secret: true},
+	{Name: ESWorkersConfigKey, Type: schema.Int, Default: "0"},
+	{Name: ESFBufferConfigKey, Type: schema.Int, Default: "5000000"},
+	{Name: ESFTimeoutConfigKey, Type: schema.Duration, Default: "30s"},
+	{Name: ESDataStreamConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: ESTemplateNameConfigKey, Type: schema.String, Default: "sysflow"},
+	{Name: ESTemplateMappingsConfigKey, Type: schema.String},
+	{Name: ESILMPolicyConfigKey, Type: schema.String},
+	{Name: ESILMFileConfigKey, Type: schema.String},
+	{Name: ESRetryMaxConfigKey, Type: schema.Int, Default: "3"},
+}
+
 // ESConfig holds Elastic specific configuration.
 type ESConfig struct {
-	ESAddresses    []string
-	ESIndex        string
-	ESUsername     string
-	ESPassword     string
-	ESNumWorkers   int
-	ESFlushBuffer  int
-	ESFlushTimeout time.Duration
+	ESAddresses        []string
+	ESIndex            string
+	ESUsername         string
+	ESPassword         string
+	ESNumWorkers       int
+	ESFlushBuffer      int
+	ESFlushTimeout     time.Duration
+	ESDataStream       bool
+	ESTemplateName     string
+	ESTemplateMappings string
+	ESILMPolicy        string
+	ESILMFile          string
+	ESRetryMax         int
 }
 
 // CreateElasticConfig creates a new config object from config dictionary.
@@ -54,7 +86,9 @@ func CreateElasticConfig(bc Config, conf map[string]interface{}) (c ESConfig, er
 	c = ESConfig{
 		ESNumWorkers:   0,
 		ESFlushBuffer:  5e+6,
-		ESFlushTimeout: 30 * time.Second}
+		ESFlushTimeout: 30 * time.Second,
+		ESTemplateName: "sysflow",
+		ESRetryMax:     3}
 
 	// parse config map
 	if v, ok := conf[ESAddressesConfigKey].(string); ok {
@@ -65,7 +99,7 @@ func CreateElasticConfig(bc Config, conf map[string]interface{}) (c ESConfig, er
 	}
 	if v, ok := conf[ESUsernameConfigKey].(string); ok {
 		c.ESUsername = v
-	} else if bc.VaultEnabled && bc.Transport == ESTransport {
+	} else if bc.VaultEnabled && (bc.Transport == ESTransport || bc.Transport == OpenSearchTransport) {
 		s, err := bc.GetSecret(ESUsernameConfigKey)
 		if err != nil {
 			return c, err
@@ -74,7 +108,7 @@ func CreateElasticConfig(bc Config, conf map[string]interface{}) (c ESConfig, er
 	}
 	if v, ok := conf[ESPasswordConfigKey].(string); ok {
 		c.ESPassword = v
-	} else if bc.VaultEnabled && bc.Transport == ESTransport {
+	} else if bc.VaultEnabled && (bc.Transport == ESTransport || bc.Transport == OpenSearchTransport) {
 		s, err := bc.GetSecret(ESPasswordConfigKey)
 		if err != nil {
 			return c, err
@@ -99,5 +133,56 @@ func CreateElasticConfig(bc Config, conf map[string]interface{}) (c ESConfig, er
 			return c, err
 		}
 	}
+	if v, ok := conf[ESDataStreamConfigKey].(string); ok {
+		if c.ESDataStream, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, ESDataStreamConfigKey, err)
+		}
+	}
+	if err = checkIndexPattern(c.ESIndex); err != nil {
+		return c, fmt.Errorf("invalid value '%s' for key '%s': %v", c.ESIndex, ESIndexConfigKey, err)
+	}
+	if c.ESDataStream && strings.Contains(c.ESIndex, "%") {
+		return c, fmt.Errorf("key '%s' cannot contain date patterns when '%s' is set", ESIndexConfigKey, ESDataStreamConfigKey)
+	}
+	if v, ok := conf[ESTemplateNameConfigKey].(string); ok {
+		c.ESTemplateName = v
+	}
+	if v, ok := conf[ESTemplateMappingsConfigKey].(string); ok {
+		c.ESTemplateMappings = v
+	}
+	if v, ok := conf[ESILMPolicyConfigKey].(string); ok {
+		c.ESILMPolicy = v
+	}
+	if v, ok := conf[ESILMFileConfigKey].(string); ok {
+		c.ESILMFile = v
+	}
+	if c.ESILMFile != "" && c.ESILMPolicy == "" {
+		return c, fmt.Errorf("missing value for key '%s'", ESILMPolicyConfigKey)
+	}
+	if v, ok := conf[ESRetryMaxConfigKey].(string); ok {
+		if c.ESRetryMax, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, ESRetryMaxConfigKey, err)
+		}
+	}
 	return
 }
+
+// checkIndexPattern checks that an index name contains only supported date patterns
+// (%Y, %m, %d, %H, and %% for a literal percent sign).
+func checkIndexPattern(s string) error {
+	return checkPattern(s, "YmdH%")
+}
+
+// checkPattern checks that s contains only the patterns (a percent sign followed by
+// one of the characters of directives) listed in directives.
+func checkPattern(s string, directives string) error {
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' {
+			continue
+		}
+		if i++; i == len(s) || !strings.ContainsRune(directives, rune(s[i])) {
+			return fmt.Errorf("unsupported pattern at position %d", i-1)
+		}
+	}
+	return nil
+}
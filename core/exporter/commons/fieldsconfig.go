@@ -0,0 +1,157 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	FieldsIncludeConfigKey         string = "fields.include"
+	FieldsExcludeConfigKey         string = "fields.exclude"
+	RedactHashConfigKey            string = "fields.redact.hash"
+	RedactHashSaltConfigKey        string = "fields.redact.hash.salt"
+	RedactTruncateConfigKey        string = "fields.redact.truncate"
+	RedactTruncateLengthConfigKey  string = "fields.redact.truncate.length"
+	RedactMaskConfigKey            string = "fields.redact.mask"
+	RedactMaskPatternConfigKey     string = "fields.redact.mask.pattern"
+	RedactMaskReplacementConfigKey string = "fields.redact.mask.replacement"
+	PtreeConfigKey                 string = "fields.ptree"
+	PtreeDepthConfigKey            string = "fields.ptree.depth"
+)
+
+// fieldsConfigKeys declares the field profile configuration keys.
+var fieldsConfigKeys = []schema.Key{
+	{Name: FieldsIncludeConfigKey, Type: schema.List},
+	{Name: FieldsExcludeConfigKey, Type: schema.List},
+	{Name: RedactHashConfigKey, Type: schema.List},
+	{Name: RedactHashSaltConfigKey, Type: schema.String, Secret: true},
+	{Name: RedactTruncateConfigKey, Type: schema.List},
+	{Name: RedactTruncateLengthConfigKey, Type: schema.Int, Default: "32"},
+	{Name: RedactMaskConfigKey, Type: schema.List},
+	{Name: RedactMaskPatternConfigKey, Type: schema.String},
+	{Name: RedactMaskReplacementConfigKey, Type: schema.String, Default: "***"},
+	{Name: PtreeConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: PtreeDepthConfigKey, Type: schema.Int, Default: "0"},
+}
+
+// FieldsConfig holds the field profile of an exporter, i.e., the record attributes it exports
+// and how their values are redacted. Attributes are given by name (e.g., sf.proc.cmdline) or
+// by section (e.g., sf.proc). The ancestors of processes are exported if Ptree is set, up to
+// PtreeDepth ancestors (all if 0).
+type FieldsConfig struct {
+	FieldsInclude         []string
+	FieldsExclude         []string
+	RedactHash            []string
+	RedactHashSalt        string
+	RedactTruncate        []string
+	RedactTruncateLength  int
+	RedactMask            []string
+	RedactMaskPattern     *regexp.Regexp
+	RedactMaskReplacement string
+	Ptree                 bool
+	PtreeDepth            int
+}
+
+// CreateFieldsConfig creates a new config object from config dictionary.
+func CreateFieldsConfig(bc Config, conf map[string]interface{}) (c FieldsConfig, err error) {
+	// default values
+	c = FieldsConfig{RedactTruncateLength: 32, RedactMaskReplacement: "***"}
+
+	// parse config map
+	for _, l := range []struct {
+		key    string
+		fields *[]string
+	}{
+		{FieldsIncludeConfigKey, &c.FieldsInclude},
+		{FieldsExcludeConfigKey, &c.FieldsExclude},
+		{RedactHashConfigKey, &c.RedactHash},
+		{RedactTruncateConfigKey, &c.RedactTruncate},
+		{RedactMaskConfigKey, &c.RedactMask},
+	} {
+		if v, ok := conf[l.key].(string); ok {
+			if *l.fields, err = parseFieldList(v); err != nil {
+				return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, l.key, err)
+			}
+		}
+	}
+	if v, ok := conf[RedactHashSaltConfigKey].(string); ok {
+		c.RedactHashSalt = v
+	}
+	if v, ok := conf[RedactTruncateLengthConfigKey].(string); ok {
+		if c.RedactTruncateLength, err = strconv.Atoi(v); err != nil || c.RedactTruncateLength < 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a non-negative integer", v, RedactTruncateLengthConfigKey)
+		}
+	}
+	if v, ok := conf[RedactMaskPatternConfigKey].(string); ok {
+		if c.RedactMaskPattern, err = regexp.Compile(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, RedactMaskPatternConfigKey, err)
+		}
+	} else if len(c.RedactMask) > 0 {
+		return c, fmt.Errorf("missing value for key '%s'", RedactMaskPatternConfigKey)
+	}
+	if v, ok := conf[RedactMaskReplacementConfigKey].(string); ok {
+		c.RedactMaskReplacement = v
+	}
+	if v, ok := conf[PtreeConfigKey].(string); ok {
+		if c.Ptree, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, PtreeConfigKey, err)
+		}
+	}
+	if v, ok := conf[PtreeDepthConfigKey].(string); ok {
+		if c.PtreeDepth, err = strconv.Atoi(v); err != nil || c.PtreeDepth < 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a non-negative integer", v, PtreeDepthConfigKey)
+		}
+	}
+	return
+}
+
+// parseFieldList parses a comma-separated list of exported attribute names or sections.
+func parseFieldList(s string) ([]string, error) {
+	var fields []string
+	for _, f := range strings.Split(s, ",") {
+		if f = strings.TrimSpace(f); f == "" {
+			continue
+		}
+		if !isExportedField(f) {
+			return nil, fmt.Errorf("unknown attribute or section '%s'", f)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// isExportedField returns true if name is an exported attribute, or a section of exported attributes.
+func isExportedField(name string) bool {
+	for _, f := range engine.Fields {
+		if f = strings.TrimSuffix(f, "+"); f == name || strings.HasPrefix(f, name+".") {
+			return true
+		}
+	}
+	return false
+}
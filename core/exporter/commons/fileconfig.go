@@ -20,14 +20,46 @@
 // Package commons defines common facilities for exporters.
 package commons
 
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
 // Configuration keys.
 const (
-	PathConfigKey string = "file.path"
+	PathConfigKey            string = "file.path"
+	FileMaxSizeConfigKey     string = "file.maxsize"
+	FileIntervalConfigKey    string = "file.interval"
+	FileCompressionConfigKey string = "file.compression"
+	FileMaxFilesConfigKey    string = "file.maxfiles"
+	FileMaxAgeConfigKey      string = "file.maxage"
+	FileFsyncConfigKey       string = "file.fsync"
 )
 
+// fileConfigKeys declares the file transport configuration keys.
+var fileConfigKeys = []schema.Key{
+	{Name: PathConfigKey, Type: schema.String, Default: "./export.out"},
+	{Name: FileMaxSizeConfigKey, Type: schema.Int, Default: "0"},
+	{Name: FileIntervalConfigKey, Type: schema.Seconds, Default: "0"},
+	{Name: FileCompressionConfigKey, Type: schema.Enum, Default: NoCompression.String(),
+		Values: []string{NoCompression.String(), GzipCompression.String(), ZstdCompression.String()}},
+	{Name: FileMaxFilesConfigKey, Type: schema.Int, Default: "0"},
+	{Name: FileMaxAgeConfigKey, Type: schema.Duration, Default: "0s"},
+	{Name: FileFsyncConfigKey, Type: schema.Bool, Default: "false"},
+}
+
 // FileConfig holds file output specific configuration.
 type FileConfig struct {
-	Path string
+	Path            string
+	FileMaxSize     int64
+	FileInterval    time.Duration
+	FileCompression Compression
+	FileMaxFiles    int
+	FileMaxAge      time.Duration
+	FileFsync       bool
 }
 
 // CreateFileConfig creates a new config object from config dictionary.
@@ -39,5 +71,42 @@ func CreateFileConfig(bc Config, conf map[string]interface{}) (c FileConfig, err
 	if v, ok := conf[PathConfigKey].(string); ok {
 		c.Path = v
 	}
+	if err = checkPattern(c.Path, "YmdHn%"); err != nil {
+		return c, fmt.Errorf("invalid value '%s' for key '%s': %v", c.Path, PathConfigKey, err)
+	}
+	if v, ok := conf[FileMaxSizeConfigKey].(string); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FileMaxSizeConfigKey, err)
+		}
+		c.FileMaxSize = size << 20
+	}
+	if v, ok := conf[FileIntervalConfigKey].(string); ok {
+		interval, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FileIntervalConfigKey, err)
+		}
+		c.FileInterval = time.Duration(interval) * time.Second
+	}
+	if v, ok := conf[FileCompressionConfigKey].(string); ok {
+		if c.FileCompression = parseCompressionConfig(v); c.FileCompression == SnappyCompression {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected one of none, gzip, zstd", v, FileCompressionConfigKey)
+		}
+	}
+	if v, ok := conf[FileMaxFilesConfigKey].(string); ok {
+		if c.FileMaxFiles, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FileMaxFilesConfigKey, err)
+		}
+	}
+	if v, ok := conf[FileMaxAgeConfigKey].(string); ok {
+		if c.FileMaxAge, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FileMaxAgeConfigKey, err)
+		}
+	}
+	if v, ok := conf[FileFsyncConfigKey].(string); ok {
+		if c.FileFsync, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FileFsyncConfigKey, err)
+		}
+	}
 	return
 }
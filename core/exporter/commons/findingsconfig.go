@@ -21,9 +21,8 @@
 package commons
 
 import (
-	"strconv"
-
 	"github.com/IBM/scc-go-sdk/v3/findingsv1"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
@@ -37,38 +36,48 @@ const (
 	FindingsSQLQueryCrnConfigKey  string = "findings.sqlquerycrn"
 	FindingsS3RegionConfigKey     string = "findings.s3region"
 	FindingsS3BucketConfigKey     string = "findings.s3bucket"
-	FindingsS3PrefixConfigKey     string = "findings.s3prefix"
-	FindingsPathConfigKey         string = "findings.path"
-	FindingsPoolCapacityConfigKey string = "findings.pool.capacity"
-	FindingsPoolMaxAgeConfigKey   string = "findings.pool.maxage"
+	FindingsS3PrefixConfigKey     string = "findings.s3prefix"      // deprecated, see OccPrefixConfigKey
+	FindingsPathConfigKey         string = "findings.path"          // deprecated, see OccPathConfigKey
+	FindingsPoolCapacityConfigKey string = "findings.pool.capacity" // deprecated, see OccPoolCapacityConfigKey
+	FindingsPoolMaxAgeConfigKey   string = "findings.pool.maxage"   // deprecated, see OccPoolMaxAgeConfigKey
 )
 
+// findingsConfigKeys declares the IBM Findings API transport configuration keys.
+var findingsConfigKeys = []schema.Key{
+	{Name: FindingsAPIKeyConfigKey, Type: schema.String, Secret: true},
+	{Name: FindingsURLConfigKey, Type: schema.String, Default: findingsv1.DefaultServiceURL},
+	{Name: FindingsAccountIDConfigKey, Type: schema.String},
+	{Name: FindingsProviderIDConfigKey, Type: schema.String},
+	{Name: FindingsRegionConfigKey, Type: schema.String},
+	{Name: FindingsSQLQueryURLConfigKey, Type: schema.String, Default: "https://us.sql-query.cloud.ibm.com/sqlquery"},
+	{Name: FindingsSQLQueryCrnConfigKey, Type: schema.String},
+	{Name: FindingsS3RegionConfigKey, Type: schema.String},
+	{Name: FindingsS3BucketConfigKey, Type: schema.String},
+	{Name: FindingsS3PrefixConfigKey, Type: schema.String},
+	{Name: FindingsPathConfigKey, Type: schema.String},
+	{Name: FindingsPoolCapacityConfigKey, Type: schema.Int},
+	{Name: FindingsPoolMaxAgeConfigKey, Type: schema.Int},
+}
+
 // FindingsConfig holds IBM Findings API specific configuration.
 type FindingsConfig struct {
-	FindingsAPIKey       string
-	FindingsURL          string
-	FindingsAccountID    string
-	FindingsProviderID   string
-	FindingsSQLQueryURL  string
-	FindingsSQLQueryCrn  string
-	FindingsRegion       string
-	FindingsS3Region     string
-	FindingsS3Bucket     string
-	FindingsS3Prefix     string
-	FindingsPath         string
-	FindingsPoolCapacity int
-	FindingsPoolMaxAge   int
+	FindingsAPIKey      string
+	FindingsURL         string
+	FindingsAccountID   string
+	FindingsProviderID  string
+	FindingsSQLQueryURL string
+	FindingsSQLQueryCrn string
+	FindingsRegion      string
+	FindingsS3Region    string
+	FindingsS3Bucket    string
 }
 
 // CreateFindingsConfig creates a new config object from config dictionary.
 func CreateFindingsConfig(bc Config, conf map[string]interface{}) (c FindingsConfig, err error) {
 	// default values
 	c = FindingsConfig{
-		FindingsURL:          findingsv1.DefaultServiceURL,
-		FindingsSQLQueryURL:  "https://us.sql-query.cloud.ibm.com/sqlquery",
-		FindingsPath:         "/mnt/occurrences",
-		FindingsPoolCapacity: 250,
-		FindingsPoolMaxAge:   1440} // 24 hours (specified in minutes)
+		FindingsURL:         findingsv1.DefaultServiceURL,
+		FindingsSQLQueryURL: "https://us.sql-query.cloud.ibm.com/sqlquery"}
 
 	// parse config map
 	if v, ok := conf[FindingsAPIKeyConfigKey].(string); ok {
@@ -101,26 +110,8 @@ func CreateFindingsConfig(bc Config, conf map[string]interface{}) (c FindingsCon
 	if v, ok := conf[FindingsS3RegionConfigKey].(string); ok {
 		c.FindingsS3Region = v
 	}
-	if v, ok := conf[FindingsS3PrefixConfigKey].(string); ok {
-		c.FindingsS3Prefix = v
-	}
 	if v, ok := conf[FindingsS3BucketConfigKey].(string); ok {
 		c.FindingsS3Bucket = v
 	}
-	if v, ok := conf[FindingsPathConfigKey].(string); ok {
-		c.FindingsPath = v
-	}
-	if v, ok := conf[FindingsPoolCapacityConfigKey].(string); ok {
-		c.FindingsPoolCapacity, err = strconv.Atoi(v)
-		if err != nil {
-			return c, err
-		}
-	}
-	if v, ok := conf[FindingsPoolMaxAgeConfigKey].(string); ok {
-		c.FindingsPoolMaxAge, err = strconv.Atoi(v)
-		if err != nil {
-			return c, err
-		}
-	}
 	return
 }
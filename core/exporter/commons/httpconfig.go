@@ -0,0 +1,195 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	HTTPURLConfigKey           string = "http.url"
+	HTTPModeConfigKey          string = "http.mode"
+	HTTPHeadersConfigKey       string = "http.headers"
+	HTTPUsernameConfigKey      string = "http.username"
+	HTTPPasswordConfigKey      string = "http.password"
+	HTTPTokenConfigKey         string = "http.token"
+	HTTPTemplateConfigKey      string = "http.template"
+	HTTPContentTypeConfigKey   string = "http.contenttype"
+	HTTPTLSCAConfigKey         string = "http.tls.ca"
+	HTTPTLSCertConfigKey       string = "http.tls.cert"
+	HTTPTLSKeyConfigKey        string = "http.tls.key"
+	HTTPTLSSkipVerifyConfigKey string = "http.tls.skipverify"
+	HTTPTimeoutConfigKey       string = "http.timeout"
+	HTTPRetryMaxConfigKey      string = "http.retry.max"
+	HTTPRetryBackoffConfigKey  string = "http.retry.backoff"
+)
+
+// httpConfigKeys declares the HTTP transport configuration keys.
+var httpConfigKeys = []schema.Key{
+	{Name: HTTPURLConfigKey, Type: schema.String},
+	{Name: HTTPModeConfigKey, Type: schema.Enum, Default: HTTPBatchMode.String(), Values: []string{HTTPBatchMode.String(), HTTPRecordMode.String()}},
+	{Name: HTTPHeadersConfigKey, Type: schema.List, Secret: true},
+	{Name: HTTPUsernameConfigKey, Type: schema.String},
+	{Name: HTTPPasswordConfigKey, Type: schema.String, Secret: true},
+	{Name: HTTPTokenConfigKey, Type: schema.String, Secret: true},
+	{Name: HTTPTemplateConfigKey, Type: schema.String},
+	{Name: HTTPContentTypeConfigKey, Type: schema.String, Default: "application/json"},
+	{Name: HTTPTLSCAConfigKey, Type: schema.String},
+	{Name: HTTPTLSCertConfigKey, Type: schema.String},
+	{Name: HTTPTLSKeyConfigKey, Type: schema.String},
+	{Name: HTTPTLSSkipVerifyConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: HTTPTimeoutConfigKey, Type: schema.Duration, Default: "10s"},
+	{Name: HTTPRetryMaxConfigKey, Type: schema.Int, Default: "5"},
+	{Name: HTTPRetryBackoffConfigKey, Type: schema.Duration, Default: "1s"},
+}
+
+// HTTPConfig holds HTTP webhook specific configuration.
+type HTTPConfig struct {
+	HTTPURL           string
+	HTTPMode          HTTPMode
+	HTTPHeaders       map[string]string
+	HTTPUsername      string
+	HTTPPassword      string
+	HTTPToken         string
+	HTTPTemplate      string
+	HTTPContentType   string
+	HTTPTLSCA         string
+	HTTPTLSCert       string
+	HTTPTLSKey        string
+	HTTPTLSSkipVerify bool
+	HTTPTimeout       time.Duration
+	HTTPRetryMax      int
+	HTTPRetryBackoff  time.Duration
+}
+
+// CreateHTTPConfig creates a new config object from config dictionary.
+func CreateHTTPConfig(bc Config, conf map[string]interface{}) (c HTTPConfig, err error) {
+	// default values
+	c = HTTPConfig{HTTPContentType: "application/json", HTTPTimeout: 10 * time.Second, HTTPRetryMax: 5, HTTPRetryBackoff: time.Second}
+	vault := bc.VaultEnabled && bc.Transport == HTTPTransport
+
+	// parse config map
+	if v, ok := conf[HTTPURLConfigKey].(string); ok {
+		c.HTTPURL = v
+	} else if bc.Transport == HTTPTransport {
+		return c, fmt.Errorf("missing value for key '%s'", HTTPURLConfigKey)
+	}
+	if v, ok := conf[HTTPModeConfigKey].(string); ok {
+		c.HTTPMode = parseHTTPModeConfig(v)
+	}
+	headers, ok := conf[HTTPHeadersConfigKey].(string)
+	if !ok && vault {
+		if headers, err = bc.LookupSecret(HTTPHeadersConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if c.HTTPHeaders, err = parseHeaders(headers); err != nil {
+		return c, fmt.Errorf("invalid value for key '%s': %v", HTTPHeadersConfigKey, err)
+	}
+	if v, ok := conf[HTTPUsernameConfigKey].(string); ok {
+		c.HTTPUsername = v
+	} else if vault {
+		if c.HTTPUsername, err = bc.LookupSecret(HTTPUsernameConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := conf[HTTPPasswordConfigKey].(string); ok {
+		c.HTTPPassword = v
+	} else if vault {
+		if c.HTTPPassword, err = bc.LookupSecret(HTTPPasswordConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := conf[HTTPTokenConfigKey].(string); ok {
+		c.HTTPToken = v
+	} else if vault {
+		if c.HTTPToken, err = bc.LookupSecret(HTTPTokenConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if c.HTTPToken != "" && c.HTTPUsername != "" {
+		return c, fmt.Errorf("keys '%s' and '%s' are mutually exclusive", HTTPTokenConfigKey, HTTPUsernameConfigKey)
+	}
+	if v, ok := conf[HTTPTemplateConfigKey].(string); ok {
+		c.HTTPTemplate = v
+	}
+	if v, ok := conf[HTTPContentTypeConfigKey].(string); ok {
+		c.HTTPContentType = v
+	}
+	if v, ok := conf[HTTPTLSCAConfigKey].(string); ok {
+		c.HTTPTLSCA = v
+	}
+	if v, ok := conf[HTTPTLSCertConfigKey].(string); ok {
+		c.HTTPTLSCert = v
+	}
+	if v, ok := conf[HTTPTLSKeyConfigKey].(string); ok {
+		c.HTTPTLSKey = v
+	}
+	if (c.HTTPTLSCert == "") != (c.HTTPTLSKey == "") {
+		return c, fmt.Errorf("keys '%s' and '%s' must be set together", HTTPTLSCertConfigKey, HTTPTLSKeyConfigKey)
+	}
+	if v, ok := conf[HTTPTLSSkipVerifyConfigKey].(string); ok {
+		if c.HTTPTLSSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, HTTPTLSSkipVerifyConfigKey, err)
+		}
+	}
+	if v, ok := conf[HTTPTimeoutConfigKey].(string); ok {
+		if c.HTTPTimeout, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, HTTPTimeoutConfigKey, err)
+		}
+	}
+	if v, ok := conf[HTTPRetryMaxConfigKey].(string); ok {
+		if c.HTTPRetryMax, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, HTTPRetryMaxConfigKey, err)
+		}
+	}
+	if v, ok := conf[HTTPRetryBackoffConfigKey].(string); ok {
+		if c.HTTPRetryBackoff, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, HTTPRetryBackoffConfigKey, err)
+		}
+	}
+	return
+}
+
+// HTTPMode denotes how records are sent to HTTP endpoints.
+type HTTPMode int
+
+// HTTPMode config options.
+const (
+	HTTPBatchMode  HTTPMode = iota // one request per batch of records
+	HTTPRecordMode                 // one request per record
+)
+
+func (s HTTPMode) String() string {
+	return [...]string{"batch", "record"}[s]
+}
+
+func parseHTTPModeConfig(s string) HTTPMode {
+	if HTTPRecordMode.String() == s {
+		return HTTPRecordMode
+	}
+	return HTTPBatchMode
+}
@@ -0,0 +1,188 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/s3utils"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	OccStorageConfigKey      string = "occurrence.storage"
+	OccPathConfigKey         string = "occurrence.path"
+	OccPrefixConfigKey       string = "occurrence.prefix"
+	OccPoolCapacityConfigKey string = "occurrence.pool.capacity"
+	OccPoolMaxAgeConfigKey   string = "occurrence.pool.maxage"
+	OccS3EndpointConfigKey   string = "occurrence.s3.endpoint"
+	OccS3RegionConfigKey     string = "occurrence.s3.region"
+	OccS3AccessKeyConfigKey  string = "occurrence.s3.accesskey"
+	OccS3SecretKeyConfigKey  string = "occurrence.s3.secretkey"
+	OccS3SecureConfigKey     string = "occurrence.s3.secure"
+	OccS3BucketConfigKey     string = "occurrence.s3.bucket"
+)
+
+// occConfigKeys declares the occurrence encoder configuration keys.
+var occConfigKeys = []schema.Key{
+	{Name: OccStorageConfigKey, Type: schema.Enum, Default: LocalStorage.String(),
+		Values: []string{NoStorage.String(), LocalStorage.String(), S3Storage.String()}},
+	{Name: OccPathConfigKey, Type: schema.String, Default: "/mnt/occurrences"},
+	{Name: OccPrefixConfigKey, Type: schema.String},
+	{Name: OccPoolCapacityConfigKey, Type: schema.Int, Default: "250"},
+	{Name: OccPoolMaxAgeConfigKey, Type: schema.Int, Default: "1440"},
+	{Name: OccS3EndpointConfigKey, Type: schema.String, Default: "s3.amazonaws.com"},
+	{Name: OccS3RegionConfigKey, Type: schema.String},
+	{Name: OccS3AccessKeyConfigKey, Type: schema.String},
+	{Name: OccS3SecretKeyConfigKey, Type: schema.String, Secret: true},
+	{Name: OccS3SecureConfigKey, Type: schema.Bool, Default: "true"},
+	{Name: OccS3BucketConfigKey, Type: schema.String},
+}
+
+// OccurrenceConfig holds occurrence encoder specific configuration.
+type OccurrenceConfig struct {
+	OccStorage      Storage
+	OccPath         string
+	OccPrefix       string
+	OccPoolCapacity int
+	OccPoolMaxAge   int
+	OccS3Endpoint   string
+	OccS3Region     string
+	OccS3AccessKey  string
+	OccS3SecretKey  string
+	OccS3Secure     bool
+	OccS3Bucket     string
+}
+
+// CreateOccurrenceConfig creates a new config object from config dictionary.
+// The findings.path, findings.s3prefix and findings.pool.* keys are read if their occurrence.* counterparts are not set.
+func CreateOccurrenceConfig(bc Config, conf map[string]interface{}) (c OccurrenceConfig, err error) {
+	// default values
+	c = OccurrenceConfig{
+		OccStorage:      LocalStorage,
+		OccPath:         "/mnt/occurrences",
+		OccPoolCapacity: 250,
+		OccPoolMaxAge:   1440, // 24 hours (specified in minutes)
+		OccS3Endpoint:   "s3.amazonaws.com",
+		OccS3Secure:     true}
+
+	// parse config map
+	if v, ok := conf[OccStorageConfigKey].(string); ok {
+		c.OccStorage = parseStorageConfig(v)
+	}
+	if v, ok := getAliasedValue(conf, OccPathConfigKey, FindingsPathConfigKey); ok {
+		c.OccPath = v
+	}
+	if v, ok := getAliasedValue(conf, OccPrefixConfigKey, FindingsS3PrefixConfigKey); ok {
+		c.OccPrefix = v
+	}
+	if v, ok := getAliasedValue(conf, OccPoolCapacityConfigKey, FindingsPoolCapacityConfigKey); ok {
+		if c.OccPoolCapacity, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OccPoolCapacityConfigKey, err)
+		}
+	}
+	if v, ok := getAliasedValue(conf, OccPoolMaxAgeConfigKey, FindingsPoolMaxAgeConfigKey); ok {
+		if c.OccPoolMaxAge, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OccPoolMaxAgeConfigKey, err)
+		}
+	}
+	if v, ok := conf[OccS3EndpointConfigKey].(string); ok {
+		c.OccS3Endpoint = v
+	}
+	if v, ok := conf[OccS3RegionConfigKey].(string); ok {
+		c.OccS3Region = v
+	}
+	if v, ok := conf[OccS3AccessKeyConfigKey].(string); ok {
+		c.OccS3AccessKey = v
+	}
+	enabled := bc.Format == OccurrenceFormat && c.OccStorage == S3Storage
+	if v, ok := conf[OccS3SecretKeyConfigKey].(string); ok {
+		c.OccS3SecretKey = v
+	} else if enabled && bc.VaultEnabled {
+		if c.OccS3SecretKey, err = bc.LookupSecret(OccS3SecretKeyConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := conf[OccS3SecureConfigKey].(string); ok {
+		if c.OccS3Secure, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OccS3SecureConfigKey, err)
+		}
+	}
+	if v, ok := conf[OccS3BucketConfigKey].(string); ok {
+		c.OccS3Bucket = v
+	} else if enabled {
+		return c, fmt.Errorf("missing value for key '%s'", OccS3BucketConfigKey)
+	}
+	if bc.Format != OccurrenceFormat {
+		return
+	}
+	switch c.OccStorage {
+	case LocalStorage:
+		if c.OccPath == "" {
+			return c, fmt.Errorf("missing value for key '%s'", OccPathConfigKey)
+		}
+	case S3Storage:
+		// the client is created without credentials, so that the endpoint is checked without connecting to it
+		if _, err = minio.New(c.OccS3Endpoint, &minio.Options{Secure: c.OccS3Secure, Region: c.OccS3Region}); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", c.OccS3Endpoint, OccS3EndpointConfigKey, err)
+		}
+		if err = s3utils.CheckValidBucketName(c.OccS3Bucket); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", c.OccS3Bucket, OccS3BucketConfigKey, err)
+		}
+	}
+	return
+}
+
+// getAliasedValue returns the value of key, or the value of its deprecated alias if key is not set.
+func getAliasedValue(conf map[string]interface{}, key string, alias string) (string, bool) {
+	if v, ok := conf[key].(string); ok {
+		return v, true
+	}
+	v, ok := conf[alias].(string)
+	return v, ok
+}
+
+// Storage type.
+type Storage int
+
+// Storage config options.
+const (
+	NoStorage Storage = iota
+	LocalStorage
+	S3Storage
+)
+
+func (s Storage) String() string {
+	return [...]string{"none", "local", "s3"}[s]
+}
+
+func parseStorageConfig(s string) Storage {
+	switch s {
+	case NoStorage.String():
+		return NoStorage
+	case S3Storage.String():
+		return S3Storage
+	}
+	return LocalStorage
+}
@@ -0,0 +1,185 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	OTLPProtocolConfigKey      string = "otlp.protocol"
+	OTLPEndpointConfigKey      string = "otlp.endpoint"
+	OTLPTLSConfigKey           string = "otlp.tls"
+	OTLPTLSCAConfigKey         string = "otlp.tls.ca"
+	OTLPTLSCertConfigKey       string = "otlp.tls.cert"
+	OTLPTLSKeyConfigKey        string = "otlp.tls.key"
+	OTLPTLSSkipVerifyConfigKey string = "otlp.tls.skipverify"
+	OTLPHeadersConfigKey       string = "otlp.headers"
+	OTLPCompressionConfigKey   string = "otlp.compression"
+	OTLPTimeoutConfigKey       string = "otlp.timeout"
+	OTLPRetryMaxConfigKey      string = "otlp.retry.max"
+	OTLPRetryBackoffConfigKey  string = "otlp.retry.backoff"
+)
+
+// otlpConfigKeys declares the OTLP transport configuration keys.
+var otlpConfigKeys = []schema.Key{
+	{Name: OTLPProtocolConfigKey, Type: schema.Enum, Default: GRPCProto.String(), Values: []string{GRPCProto.String(), HTTPProto.String()}},
+	{Name: OTLPEndpointConfigKey, Type: schema.String},
+	{Name: OTLPTLSConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: OTLPTLSCAConfigKey, Type: schema.String},
+	{Name: OTLPTLSCertConfigKey, Type: schema.String},
+	{Name: OTLPTLSKeyConfigKey, Type: schema.String},
+	{Name: OTLPTLSSkipVerifyConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: OTLPHeadersConfigKey, Type: schema.List, Secret: true},
+	{Name: OTLPCompressionConfigKey, Type: schema.Enum, Default: NoCompression.String(), Values: []string{NoCompression.String(), GzipCompression.String()}},
+	{Name: OTLPTimeoutConfigKey, Type: schema.Duration, Default: "10s"},
+	{Name: OTLPRetryMaxConfigKey, Type: schema.Int, Default: "5"},
+	{Name: OTLPRetryBackoffConfigKey, Type: schema.Duration, Default: "1s"},
+}
+
+// OTLPConfig holds OpenTelemetry (OTLP) specific configuration.
+type OTLPConfig struct {
+	OTLPProtocol      OTLPProto
+	OTLPEndpoint      string
+	OTLPTLS           bool
+	OTLPTLSCA         string
+	OTLPTLSCert       string
+	OTLPTLSKey        string
+	OTLPTLSSkipVerify bool
+	OTLPHeaders       map[string]string
+	OTLPCompression   Compression
+	OTLPTimeout       time.Duration
+	OTLPRetryMax      int
+	OTLPRetryBackoff  time.Duration
+}
+
+// CreateOTLPConfig creates a new config object from config dictionary.
+func CreateOTLPConfig(bc Config, conf map[string]interface{}) (c OTLPConfig, err error) {
+	// default values
+	c = OTLPConfig{OTLPTimeout: 10 * time.Second, OTLPRetryMax: 5, OTLPRetryBackoff: time.Second}
+
+	// parse config map
+	if v, ok := conf[OTLPProtocolConfigKey].(string); ok {
+		c.OTLPProtocol = parseOTLPProtoConfig(v)
+	}
+	if v, ok := conf[OTLPEndpointConfigKey].(string); ok {
+		c.OTLPEndpoint = v
+	} else {
+		c.OTLPEndpoint = c.OTLPProtocol.defaultEndpoint()
+	}
+	if v, ok := conf[OTLPTLSConfigKey].(string); ok {
+		if c.OTLPTLS, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OTLPTLSConfigKey, err)
+		}
+	}
+	if v, ok := conf[OTLPTLSCAConfigKey].(string); ok {
+		c.OTLPTLSCA = v
+	}
+	if v, ok := conf[OTLPTLSCertConfigKey].(string); ok {
+		c.OTLPTLSCert = v
+	}
+	if v, ok := conf[OTLPTLSKeyConfigKey].(string); ok {
+		c.OTLPTLSKey = v
+	}
+	if (c.OTLPTLSCert == "") != (c.OTLPTLSKey == "") {
+		return c, fmt.Errorf("keys '%s' and '%s' must be set together", OTLPTLSCertConfigKey, OTLPTLSKeyConfigKey)
+	}
+	if v, ok := conf[OTLPTLSSkipVerifyConfigKey].(string); ok {
+		if c.OTLPTLSSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OTLPTLSSkipVerifyConfigKey, err)
+		}
+	}
+	headers, ok := conf[OTLPHeadersConfigKey].(string)
+	if !ok && bc.VaultEnabled && bc.Transport == OTLPTransport {
+		if headers, err = bc.GetSecret(OTLPHeadersConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if c.OTLPHeaders, err = parseHeaders(headers); err != nil {
+		return c, fmt.Errorf("invalid value for key '%s': %v", OTLPHeadersConfigKey, err)
+	}
+	if v, ok := conf[OTLPCompressionConfigKey].(string); ok {
+		c.OTLPCompression = parseCompressionConfig(v)
+	}
+	if v, ok := conf[OTLPTimeoutConfigKey].(string); ok {
+		if c.OTLPTimeout, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OTLPTimeoutConfigKey, err)
+		}
+	}
+	if v, ok := conf[OTLPRetryMaxConfigKey].(string); ok {
+		if c.OTLPRetryMax, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OTLPRetryMaxConfigKey, err)
+		}
+	}
+	if v, ok := conf[OTLPRetryBackoffConfigKey].(string); ok {
+		if c.OTLPRetryBackoff, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, OTLPRetryBackoffConfigKey, err)
+		}
+	}
+	return
+}
+
+// parseHeaders parses a comma-separated list of name=value request headers.
+func parseHeaders(s string) (map[string]string, error) {
+	headers := make(map[string]string)
+	for _, h := range strings.Split(s, ",") {
+		if h = strings.TrimSpace(h); h == "" {
+			continue
+		}
+		kv := strings.SplitN(h, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("expected name=value, got '%s'", h)
+		}
+		headers[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return headers, nil
+}
+
+// OTLPProto denotes the OTLP protocol type.
+type OTLPProto int
+
+// OTLPProto config options.
+const (
+	GRPCProto OTLPProto = iota
+	HTTPProto
+)
+
+func (s OTLPProto) String() string {
+	return [...]string{"grpc", "http"}[s]
+}
+
+// defaultEndpoint returns the address of a local OpenTelemetry collector for the protocol.
+func (s OTLPProto) defaultEndpoint() string {
+	return [...]string{"localhost:4317", "localhost:4318"}[s]
+}
+
+func parseOTLPProtoConfig(s string) OTLPProto {
+	if HTTPProto.String() == s {
+		return HTTPProto
+	}
+	return GRPCProto
+}
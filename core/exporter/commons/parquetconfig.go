@@ -0,0 +1,126 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	PQDirConfigKey          string = "parquet.dir"
+	PQPrefixConfigKey       string = "parquet.prefix"
+	PQCompressionConfigKey  string = "parquet.compression"
+	PQRowGroupSizeConfigKey string = "parquet.rowgroupsize"
+	PQMaxSizeConfigKey      string = "parquet.maxsize"
+	PQIntervalConfigKey     string = "parquet.interval"
+)
+
+// pqConfigKeys declares the Parquet transport configuration keys.
+var pqConfigKeys = []schema.Key{
+	{Name: PQDirConfigKey, Type: schema.String, Default: "."},
+	{Name: PQPrefixConfigKey, Type: schema.String, Default: "sysflow"},
+	{Name: PQCompressionConfigKey, Type: schema.Enum, Default: SnappyCompression.String(),
+		Values: []string{NoCompression.String(), SnappyCompression.String(), GzipCompression.String(), ZstdCompression.String()}},
+	{Name: PQRowGroupSizeConfigKey, Type: schema.Int, Default: "32"},
+	{Name: PQMaxSizeConfigKey, Type: schema.Int, Default: "0"},
+	{Name: PQIntervalConfigKey, Type: schema.Seconds, Default: "3600"},
+}
+
+// ParquetConfig holds Parquet output specific configuration.
+type ParquetConfig struct {
+	PQDir          string
+	PQPrefix       string
+	PQCompression  Compression
+	PQRowGroupSize int64
+	PQMaxSize      int64
+	PQInterval     time.Duration
+}
+
+// CreateParquetConfig creates a new config object from config dictionary.
+func CreateParquetConfig(bc Config, conf map[string]interface{}) (c ParquetConfig, err error) {
+	// default values
+	c = ParquetConfig{PQDir: ".", PQPrefix: "sysflow", PQCompression: SnappyCompression, PQRowGroupSize: 32 << 20, PQInterval: time.Hour}
+
+	// parse config map
+	if v, ok := conf[PQDirConfigKey].(string); ok {
+		c.PQDir = v
+	}
+	if v, ok := conf[PQPrefixConfigKey].(string); ok {
+		c.PQPrefix = v
+	}
+	if v, ok := conf[PQCompressionConfigKey].(string); ok {
+		c.PQCompression = parseCompressionConfig(v)
+	}
+	if v, ok := conf[PQRowGroupSizeConfigKey].(string); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil || size <= 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a positive size in MB", v, PQRowGroupSizeConfigKey)
+		}
+		c.PQRowGroupSize = size << 20
+	}
+	if v, ok := conf[PQMaxSizeConfigKey].(string); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, PQMaxSizeConfigKey, err)
+		}
+		c.PQMaxSize = size << 20
+	}
+	if v, ok := conf[PQIntervalConfigKey].(string); ok {
+		interval, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, PQIntervalConfigKey, err)
+		}
+		c.PQInterval = time.Duration(interval) * time.Second
+	}
+	return
+}
+
+// Compression type.
+type Compression int
+
+// Compression config options.
+const (
+	NoCompression Compression = iota
+	SnappyCompression
+	GzipCompression
+	ZstdCompression
+)
+
+func (s Compression) String() string {
+	return [...]string{"none", "snappy", "gzip", "zstd"}[s]
+}
+
+func parseCompressionConfig(s string) Compression {
+	switch s {
+	case NoCompression.String():
+		return NoCompression
+	case GzipCompression.String():
+		return GzipCompression
+	case ZstdCompression.String():
+		return ZstdCompression
+	}
+	return SnappyCompression
+}
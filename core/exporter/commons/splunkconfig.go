@@ -0,0 +1,150 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	SplunkURLConfigKey           string = "splunk.url"
+	SplunkTokenConfigKey         string = "splunk.token"
+	SplunkIndexConfigKey         string = "splunk.index"
+	SplunkSourceConfigKey        string = "splunk.source"
+	SplunkSourceTypeConfigKey    string = "splunk.sourcetype"
+	SplunkAckConfigKey           string = "splunk.ack"
+	SplunkAckChannelConfigKey    string = "splunk.ack.channel"
+	SplunkAckTimeoutConfigKey    string = "splunk.ack.timeout"
+	SplunkTLSCAConfigKey         string = "splunk.tls.ca"
+	SplunkTLSSkipVerifyConfigKey string = "splunk.tls.skipverify"
+	SplunkTimeoutConfigKey       string = "splunk.timeout"
+	SplunkRetryMaxConfigKey      string = "splunk.retry.max"
+	SplunkRetryBackoffConfigKey  string = "splunk.retry.backoff"
+)
+
+// splunkConfigKeys declares the Splunk HEC transport configuration keys.
+var splunkConfigKeys = []schema.Key{
+	{Name: SplunkURLConfigKey, Type: schema.String},
+	{Name: SplunkTokenConfigKey, Type: schema.String, Secret: true},
+	{Name: SplunkIndexConfigKey, Type: schema.String},
+	{Name: SplunkSourceConfigKey, Type: schema.String, Default: "sysflow"},
+	{Name: SplunkSourceTypeConfigKey, Type: schema.String, Default: "sysflow"},
+	{Name: SplunkAckConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: SplunkAckChannelConfigKey, Type: schema.String},
+	{Name: SplunkAckTimeoutConfigKey, Type: schema.Duration, Default: "1m"},
+	{Name: SplunkTLSCAConfigKey, Type: schema.String},
+	{Name: SplunkTLSSkipVerifyConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: SplunkTimeoutConfigKey, Type: schema.Duration, Default: "10s"},
+	{Name: SplunkRetryMaxConfigKey, Type: schema.Int, Default: "5"},
+	{Name: SplunkRetryBackoffConfigKey, Type: schema.Duration, Default: "1s"},
+}
+
+// SplunkConfig holds Splunk HTTP Event Collector specific configuration.
+type SplunkConfig struct {
+	SplunkURL           string
+	SplunkToken         string
+	SplunkIndex         string
+	SplunkSource        string
+	SplunkSourceType    string
+	SplunkAck           bool
+	SplunkAckChannel    string
+	SplunkAckTimeout    time.Duration
+	SplunkTLSCA         string
+	SplunkTLSSkipVerify bool
+	SplunkTimeout       time.Duration
+	SplunkRetryMax      int
+	SplunkRetryBackoff  time.Duration
+}
+
+// CreateSplunkConfig creates a new config object from config dictionary.
+func CreateSplunkConfig(bc Config, conf map[string]interface{}) (c SplunkConfig, err error) {
+	// default values
+	c = SplunkConfig{SplunkSource: "sysflow", SplunkSourceType: "sysflow", SplunkAckTimeout: time.Minute,
+		SplunkTimeout: 10 * time.Second, SplunkRetryMax: 5, SplunkRetryBackoff: time.Second}
+	enabled := bc.Transport == SplunkTransport
+
+	// parse config map
+	if v, ok := conf[SplunkURLConfigKey].(string); ok {
+		c.SplunkURL = v
+	} else if enabled {
+		return c, fmt.Errorf("missing value for key '%s'", SplunkURLConfigKey)
+	}
+	if v, ok := conf[SplunkTokenConfigKey].(string); ok {
+		c.SplunkToken = v
+	} else if enabled && bc.VaultEnabled {
+		if c.SplunkToken, err = bc.GetSecret(SplunkTokenConfigKey); err != nil {
+			return c, err
+		}
+	} else if enabled {
+		return c, fmt.Errorf("missing value for key '%s'", SplunkTokenConfigKey)
+	}
+	if v, ok := conf[SplunkIndexConfigKey].(string); ok {
+		c.SplunkIndex = v
+	}
+	if v, ok := conf[SplunkSourceConfigKey].(string); ok {
+		c.SplunkSource = v
+	}
+	if v, ok := conf[SplunkSourceTypeConfigKey].(string); ok {
+		c.SplunkSourceType = v
+	}
+	if v, ok := conf[SplunkAckConfigKey].(string); ok {
+		if c.SplunkAck, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkAckConfigKey, err)
+		}
+	}
+	if v, ok := conf[SplunkAckChannelConfigKey].(string); ok {
+		c.SplunkAckChannel = v
+	}
+	if v, ok := conf[SplunkAckTimeoutConfigKey].(string); ok {
+		if c.SplunkAckTimeout, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkAckTimeoutConfigKey, err)
+		}
+	}
+	if v, ok := conf[SplunkTLSCAConfigKey].(string); ok {
+		c.SplunkTLSCA = v
+	}
+	if v, ok := conf[SplunkTLSSkipVerifyConfigKey].(string); ok {
+		if c.SplunkTLSSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkTLSSkipVerifyConfigKey, err)
+		}
+	}
+	if v, ok := conf[SplunkTimeoutConfigKey].(string); ok {
+		if c.SplunkTimeout, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkTimeoutConfigKey, err)
+		}
+	}
+	if v, ok := conf[SplunkRetryMaxConfigKey].(string); ok {
+		if c.SplunkRetryMax, err = strconv.Atoi(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkRetryMaxConfigKey, err)
+		}
+	}
+	if v, ok := conf[SplunkRetryBackoffConfigKey].(string); ok {
+		if c.SplunkRetryBackoff, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SplunkRetryBackoffConfigKey, err)
+		}
+	}
+	return
+}
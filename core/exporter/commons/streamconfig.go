@@ -0,0 +1,106 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	StreamAddrConfigKey       string = "stream.addr"
+	StreamPathConfigKey       string = "stream.path"
+	StreamTokenConfigKey      string = "stream.token"
+	StreamTLSCertConfigKey    string = "stream.tls.cert"
+	StreamTLSKeyConfigKey     string = "stream.tls.key"
+	StreamBufferConfigKey     string = "stream.buffer"
+	StreamMaxClientsConfigKey string = "stream.maxclients"
+)
+
+// streamConfigKeys declares the live stream transport configuration keys.
+var streamConfigKeys = []schema.Key{
+	{Name: StreamAddrConfigKey, Type: schema.String, Default: "localhost:8090"},
+	{Name: StreamPathConfigKey, Type: schema.String, Default: "/stream"},
+	{Name: StreamTokenConfigKey, Type: schema.String, Secret: true},
+	{Name: StreamTLSCertConfigKey, Type: schema.String},
+	{Name: StreamTLSKeyConfigKey, Type: schema.String},
+	{Name: StreamBufferConfigKey, Type: schema.Int, Default: "1000"},
+	{Name: StreamMaxClientsConfigKey, Type: schema.Int, Default: "16"},
+}
+
+// StreamConfig holds live stream specific configuration.
+type StreamConfig struct {
+	StreamAddr       string
+	StreamPath       string
+	StreamToken      string
+	StreamTLSCert    string
+	StreamTLSKey     string
+	StreamBuffer     int
+	StreamMaxClients int
+}
+
+// CreateStreamConfig creates a new config object from config dictionary.
+func CreateStreamConfig(bc Config, conf map[string]interface{}) (c StreamConfig, err error) {
+	// default values
+	c = StreamConfig{StreamAddr: "localhost:8090", StreamPath: "/stream", StreamBuffer: 1000, StreamMaxClients: 16}
+
+	// parse config map
+	if v, ok := conf[StreamAddrConfigKey].(string); ok {
+		c.StreamAddr = v
+	}
+	if v, ok := conf[StreamPathConfigKey].(string); ok {
+		if !strings.HasPrefix(v, "/") {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': path must start with '/'", v, StreamPathConfigKey)
+		}
+		c.StreamPath = v
+	}
+	if v, ok := conf[StreamTokenConfigKey].(string); ok {
+		c.StreamToken = v
+	} else if bc.VaultEnabled && bc.Transport == StreamTransport {
+		if c.StreamToken, err = bc.GetSecret(StreamTokenConfigKey); err != nil {
+			return c, err
+		}
+	}
+	if v, ok := conf[StreamTLSCertConfigKey].(string); ok {
+		c.StreamTLSCert = v
+	}
+	if v, ok := conf[StreamTLSKeyConfigKey].(string); ok {
+		c.StreamTLSKey = v
+	}
+	if (c.StreamTLSCert == "") != (c.StreamTLSKey == "") {
+		return c, fmt.Errorf("keys '%s' and '%s' must be set together", StreamTLSCertConfigKey, StreamTLSKeyConfigKey)
+	}
+	if v, ok := conf[StreamBufferConfigKey].(string); ok {
+		if c.StreamBuffer, err = strconv.Atoi(v); err != nil || c.StreamBuffer <= 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a positive integer", v, StreamBufferConfigKey)
+		}
+	}
+	if v, ok := conf[StreamMaxClientsConfigKey].(string); ok {
+		if c.StreamMaxClients, err = strconv.Atoi(v); err != nil || c.StreamMaxClients < 0 {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': expected a non-negative integer", v, StreamMaxClientsConfigKey)
+		}
+	}
+	return
+}
@@ -0,0 +1,82 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package commons defines common facilities for exporters.
+package commons
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+// Configuration keys.
+const (
+	SFDirConfigKey      string = "sysflow.dir"
+	SFPrefixConfigKey   string = "sysflow.prefix"
+	SFMaxSizeConfigKey  string = "sysflow.maxsize"
+	SFIntervalConfigKey string = "sysflow.interval"
+)
+
+// sfConfigKeys declares the SysFlow trace transport configuration keys.
+var sfConfigKeys = []schema.Key{
+	{Name: SFDirConfigKey, Type: schema.String, Default: "."},
+	{Name: SFPrefixConfigKey, Type: schema.String, Default: "trace"},
+	{Name: SFMaxSizeConfigKey, Type: schema.Int, Default: "0"},
+	{Name: SFIntervalConfigKey, Type: schema.Seconds, Default: "0"},
+}
+
+// SysFlowConfig holds SysFlow trace output specific configuration.
+type SysFlowConfig struct {
+	SFDir      string
+	SFPrefix   string
+	SFMaxSize  int64
+	SFInterval time.Duration
+}
+
+// CreateSysFlowConfig creates a new config object from config dictionary.
+func CreateSysFlowConfig(bc Config, conf map[string]interface{}) (c SysFlowConfig, err error) {
+	// default values
+	c = SysFlowConfig{SFDir: ".", SFPrefix: "trace"}
+
+	// parse config map
+	if v, ok := conf[SFDirConfigKey].(string); ok {
+		c.SFDir = v
+	}
+	if v, ok := conf[SFPrefixConfigKey].(string); ok {
+		c.SFPrefix = v
+	}
+	if v, ok := conf[SFMaxSizeConfigKey].(string); ok {
+		size, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SFMaxSizeConfigKey, err)
+		}
+		c.SFMaxSize = size << 20
+	}
+	if v, ok := conf[SFIntervalConfigKey].(string); ok {
+		interval, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SFIntervalConfigKey, err)
+		}
+		c.SFInterval = time.Duration(interval) * time.Second
+	}
+	return
+}
@@ -21,34 +21,65 @@
 package commons
 
 import (
+	"fmt"
 	"strconv"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
 const (
-	ProtoConfigKey     string = "syslog.proto"
-	TagConfigKey       string = "syslog.tag"
-	LogSourceConfigKey string = "syslog.source"
-	HostConfigKey      string = "syslog.host"
-	PortConfigKey      string = "syslog.port"
+	ProtoConfigKey               string = "syslog.proto"
+	TagConfigKey                 string = "syslog.tag"
+	LogSourceConfigKey           string = "syslog.source"
+	HostConfigKey                string = "syslog.host"
+	PortConfigKey                string = "syslog.port"
+	FramingConfigKey             string = "syslog.framing"
+	SDIDConfigKey                string = "syslog.sdid"
+	SyslogTLSCAConfigKey         string = "syslog.tls.ca"
+	SyslogTLSCertConfigKey       string = "syslog.tls.cert"
+	SyslogTLSKeyConfigKey        string = "syslog.tls.key"
+	SyslogTLSSkipVerifyConfigKey string = "syslog.tls.skipverify"
 )
 
+// syslogConfigKeys declares the syslog transport configuration keys.
+var syslogConfigKeys = []schema.Key{
+	{Name: ProtoConfigKey, Type: schema.Enum, Default: TCPProto.String(), Values: []string{TCPProto.String(), TCPTLSProto.String(), UDPProto.String()}},
+	{Name: TagConfigKey, Type: schema.String, Default: "sysflow"},
+	{Name: LogSourceConfigKey, Type: schema.String},
+	{Name: HostConfigKey, Type: schema.String, Default: "localhost"},
+	{Name: PortConfigKey, Type: schema.Int, Default: "514"},
+	{Name: FramingConfigKey, Type: schema.Enum, Default: OctetCountingFraming.String(), Values: []string{OctetCountingFraming.String(), NonTransparentFraming.String()}},
+	{Name: SDIDConfigKey, Type: schema.String, Default: "sysflow@32473"},
+	{Name: SyslogTLSCAConfigKey, Type: schema.String},
+	{Name: SyslogTLSCertConfigKey, Type: schema.String},
+	{Name: SyslogTLSKeyConfigKey, Type: schema.String},
+	{Name: SyslogTLSSkipVerifyConfigKey, Type: schema.Bool, Default: "false"},
+}
+
 // SyslogConfig holds rsyslog specific configuration.
 type SyslogConfig struct {
-	Proto     Proto
-	Tag       string
-	LogSource string
-	Host      string
-	Port      int
+	Proto               Proto
+	Tag                 string
+	LogSource           string
+	Host                string
+	Port                int
+	SyslogFraming       Framing
+	SyslogSDID          string
+	SyslogTLSCA         string
+	SyslogTLSCert       string
+	SyslogTLSKey        string
+	SyslogTLSSkipVerify bool
 }
 
 // CreateSyslogConfig creates a new config object from config dictionary.
 func CreateSyslogConfig(bc Config, conf map[string]interface{}) (c SyslogConfig, err error) {
 	// default values
 	c = SyslogConfig{
-		Host: "localhost",
-		Port: 514,
-		Tag:  "sysflow"}
+		Host:       "localhost",
+		Port:       514,
+		Tag:        "sysflow",
+		SyslogSDID: "sysflow@32473"}
 
 	// parse config map
 	if v, ok := conf[ProtoConfigKey].(string); ok {
@@ -69,5 +100,48 @@ func CreateSyslogConfig(bc Config, conf map[string]interface{}) (c SyslogConfig,
 			return c, err
 		}
 	}
+	if v, ok := conf[FramingConfigKey].(string); ok {
+		c.SyslogFraming = parseFramingConfig(v)
+	}
+	if v, ok := conf[SDIDConfigKey].(string); ok {
+		c.SyslogSDID = v
+	}
+	if v, ok := conf[SyslogTLSCAConfigKey].(string); ok {
+		c.SyslogTLSCA = v
+	}
+	if v, ok := conf[SyslogTLSCertConfigKey].(string); ok {
+		c.SyslogTLSCert = v
+	}
+	if v, ok := conf[SyslogTLSKeyConfigKey].(string); ok {
+		c.SyslogTLSKey = v
+	}
+	if (c.SyslogTLSCert == "") != (c.SyslogTLSKey == "") {
+		return c, fmt.Errorf("keys '%s' and '%s' must be set together", SyslogTLSCertConfigKey, SyslogTLSKeyConfigKey)
+	}
+	if v, ok := conf[SyslogTLSSkipVerifyConfigKey].(string); ok {
+		if c.SyslogTLSSkipVerify, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SyslogTLSSkipVerifyConfigKey, err)
+		}
+	}
 	return
 }
+
+// Framing denotes the framing of syslog messages over TCP (RFC 6587).
+type Framing int
+
+// Framing config options.
+const (
+	OctetCountingFraming  Framing = iota // messages are prefixed with their length
+	NonTransparentFraming                // messages are terminated by a newline
+)
+
+func (s Framing) String() string {
+	return [...]string{"octet-counting", "non-transparent"}[s]
+}
+
+func parseFramingConfig(s string) Framing {
+	if NonTransparentFraming.String() == s {
+		return NonTransparentFraming
+	}
+	return OctetCountingFraming
+}
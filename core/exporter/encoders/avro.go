@@ -0,0 +1,223 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+
+	"github.com/linkedin/goavro"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// avroMarker is the marker of Avro single-object encoded records.
+var avroMarker = []byte{0xc3, 0x01}
+
+// AvroEncoder encodes records into binary Avro records. Records hold the JSON schema version, and the
+// attributes exported by the Parquet encoder: top-level attributes are fields, and record sections are
+// optional records, which are null for records without the section. Records are single-object encoded:
+// they start with a marker and the fingerprint of their schema, by which consumers look the schema up.
+type AvroEncoder struct {
+	version string
+	codec   *goavro.Codec
+	header  []byte
+	err     error
+	batch   []commons.EncodedData
+}
+
+// NewAvroEncoder instantiates an Avro encoder.
+func NewAvroEncoder(config commons.Config) Encoder {
+	t := &AvroEncoder{version: config.JSONSchemaVersion, batch: make([]commons.EncodedData, 0, config.EventBuffer)}
+	schema := newAvroSchema(config.JSONSchemaVersion)
+	t.codec, t.err = goavro.NewCodec(schema)
+	t.header = make([]byte, len(avroMarker)+8)
+	copy(t.header, avroMarker)
+	binary.LittleEndian.PutUint64(t.header[len(avroMarker):], avroFingerprint([]byte(schema)))
+	return t
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *AvroEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.AvroFormat] = NewAvroEncoder
+}
+
+// Encode encodes telemetry records into binary Avro records.
+func (t *AvroEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		buf, err := t.codec.BinaryFromNative(append([]byte{}, t.header...), t.encode(rec))
+		if err != nil {
+			return nil, err
+		}
+		t.batch = append(t.batch, newBinaryRecord(rec, buf))
+	}
+	return t.batch, nil
+}
+
+// encode converts a record into the native Avro representation of goavro.
+func (t *AvroEncoder) encode(rec *engine.Record) map[string]interface{} {
+	m := map[string]interface{}{versionAttr: t.version}
+	for _, c := range pqSchema.columns {
+		m[c.name] = mapAvro(c, rec)
+	}
+	sftype := engine.Mapper.MapStr(engine.SF_TYPE)(rec)
+	ns := schemaPackage(t.version)
+	for _, g := range pqSchema.groups {
+		name := pqSections[g.section]
+		if !hasSection(g.section, sftype, rec) {
+			m[name] = nil
+			continue
+		}
+		gm := make(map[string]interface{}, len(g.columns))
+		for _, c := range g.columns {
+			gm[c.name] = mapAvro(c, rec)
+		}
+		m[name] = goavro.Union(ns+"."+schemaTypeName(g.section), gm)
+	}
+	policies, tags := getPolicies(rec)
+	ps := make([]interface{}, len(policies))
+	for i, p := range policies {
+		ps[i] = map[string]interface{}{"id": p.ID, "desc": p.Desc, "priority": p.Priority}
+	}
+	m[POLICIES_ATTR] = ps
+	m[TAGS_ATTR] = tags
+	return m
+}
+
+// mapAvro returns the native Avro value of an exported attribute for column c.
+func mapAvro(c pqColumn, rec *engine.Record) interface{} {
+	v := mapParquet(c, rec)
+	if c.kind != pqSvcList {
+		return v
+	}
+	svcs := v.([]pqService)
+	sl := make([]interface{}, len(svcs))
+	for i, s := range svcs {
+		pl := make([]interface{}, len(s.Ports))
+		for j, p := range s.Ports {
+			pl[j] = map[string]interface{}{"port": p.Port, "targetport": p.TargetPort, "nodeport": p.NodePort, "proto": p.Proto}
+		}
+		sl[i] = map[string]interface{}{"id": s.ID, "name": s.Name, "namespace": s.Namespace, "clusterIP": s.ClusterIP, "ports": pl}
+	}
+	return sl
+}
+
+// Cleanup cleans up resources.
+func (t *AvroEncoder) Cleanup() {}
+
+// AvroSchema returns the Avro schema of the records of JSON schema version, as published with the processor.
+func AvroSchema(version string) string {
+	var buf bytes.Buffer
+	json.Indent(&buf, []byte(newAvroSchema(version)), "", "  ")
+	return buf.String()
+}
+
+// avroRecord, avroField and avroArray describe Avro schema types. Their fields are declared in the order
+// of the Parsing Canonical Form of schemas.
+type avroRecord struct {
+	Name   string      `json:"name"`
+	Type   string      `json:"type"`
+	Fields []avroField `json:"fields"`
+}
+
+type avroField struct {
+	Name string      `json:"name"`
+	Type interface{} `json:"type"`
+}
+
+type avroArray struct {
+	Type  string      `json:"type"`
+	Items interface{} `json:"items"`
+}
+
+// newAvroSchema builds the Avro schema of records of JSON schema version, in Parsing Canonical Form.
+func newAvroSchema(version string) string {
+	ns := schemaPackage(version) + "."
+	svc := avroRecord{Name: ns + serviceTypeName, Type: "record", Fields: []avroField{
+		{Name: "id", Type: "string"},
+		{Name: "name", Type: "string"},
+		{Name: "namespace", Type: "string"},
+		{Name: "clusterIP", Type: avroArray{Type: "array", Items: "string"}},
+		{Name: "ports", Type: avroArray{Type: "array", Items: avroRecord{Name: ns + portTypeName, Type: "record", Fields: []avroField{
+			{Name: "port", Type: "int"},
+			{Name: "targetport", Type: "int"},
+			{Name: "nodeport", Type: "int"},
+			{Name: "proto", Type: "string"},
+		}}}},
+	}}
+	types := map[pqKind]interface{}{
+		pqString:  "string",
+		pqInt:     "long",
+		pqBool:    "boolean",
+		pqStrList: avroArray{Type: "array", Items: "string"},
+		pqIntList: avroArray{Type: "array", Items: "long"},
+		pqSvcList: avroArray{Type: "array", Items: svc},
+	}
+	fields := []avroField{{Name: versionAttr, Type: "string"}}
+	for _, c := range pqSchema.columns {
+		fields = append(fields, avroField{Name: c.name, Type: types[c.kind]})
+	}
+	for _, g := range pqSchema.groups {
+		gfields := make([]avroField, 0, len(g.columns))
+		for _, c := range g.columns {
+			gfields = append(gfields, avroField{Name: c.name, Type: types[c.kind]})
+		}
+		group := avroRecord{Name: ns + schemaTypeName(g.section), Type: "record", Fields: gfields}
+		fields = append(fields, avroField{Name: pqSections[g.section], Type: []interface{}{"null", group}})
+	}
+	fields = append(fields,
+		avroField{Name: POLICIES_ATTR, Type: avroArray{Type: "array", Items: avroRecord{Name: ns + policyTypeName, Type: "record", Fields: []avroField{
+			{Name: "id", Type: "string"},
+			{Name: "desc", Type: "string"},
+			{Name: "priority", Type: "long"},
+		}}}},
+		avroField{Name: TAGS_ATTR, Type: avroArray{Type: "array", Items: "string"}})
+	buf, _ := json.Marshal(avroRecord{Name: ns + recordTypeName, Type: "record", Fields: fields})
+	return string(buf)
+}
+
+// avroFingerprintTable is the lookup table of the CRC-64-AVRO fingerprint.
+var avroFingerprintTable = func() (t [256]uint64) {
+	for i := range t {
+		fp := uint64(i)
+		for j := 0; j < 8; j++ {
+			fp = (fp >> 1) ^ (avroFingerprintEmpty & -(fp & 1))
+		}
+		t[i] = fp
+	}
+	return
+}()
+
+const avroFingerprintEmpty uint64 = 0xc15d213aa4d7a795
+
+// avroFingerprint computes the CRC-64-AVRO (Rabin) fingerprint of a schema in Parsing Canonical Form.
+func avroFingerprint(schema []byte) uint64 {
+	fp := avroFingerprintEmpty
+	for _, b := range schema {
+		fp = (fp >> 8) ^ avroFingerprintTable[byte(fp)^b]
+	}
+	return fp
+}
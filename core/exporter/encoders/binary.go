@@ -0,0 +1,74 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// BinaryRecord is a record serialized in a binary format (e.g., Avro, Protobuf), along with the attributes
+// it is routed by.
+type BinaryRecord struct {
+	Ts     int64  // record timestamp
+	NodeID string // node ID
+	Data   []byte // serialized record
+}
+
+// newBinaryRecord creates a binary record for rec, serialized as data.
+func newBinaryRecord(rec *engine.Record, data []byte) *BinaryRecord {
+	return &BinaryRecord{
+		Ts:     engine.Mapper.MapInt(engine.SF_TS)(rec),
+		NodeID: engine.Mapper.MapStr(engine.SF_NODE_ID)(rec),
+		Data:   data,
+	}
+}
+
+// Names of the types of binary records.
+const (
+	recordTypeName  = "Record"
+	policyTypeName  = "Policy"
+	serviceTypeName = "Service"
+	portTypeName    = "Port"
+	versionAttr     = "version"
+)
+
+// schemaPackage returns the namespace of the binary record schemas of JSON schema version, so that
+// records of different schema versions have distinct types.
+func schemaPackage(version string) string {
+	if version == "" {
+		return "sysflow"
+	}
+	return "sysflow.v" + strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return '_'
+	}, version)
+}
+
+// schemaTypeName returns the name of the type of a record section in binary record schemas.
+func schemaTypeName(section engine.SectionType) string {
+	name := pqSections[section]
+	return strings.ToUpper(name[:1]) + name[1:]
+}
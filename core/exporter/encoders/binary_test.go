@@ -0,0 +1,145 @@
+package encoders_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/linkedin/goavro"
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+const schemasPath = "../../../resources/schemas"
+
+// schemaVersion returns the JSON schema version of the build.
+func schemaVersion(t *testing.T) string {
+	buf, err := os.ReadFile("../../../makefile.manifest.inc")
+	assert.NoError(t, err)
+	m := regexp.MustCompile(`(?m)^SYSFLOW_JSON_SCHEMA_VERSION=(\S+)$`).FindSubmatch(buf)
+	assert.NotNil(t, m)
+	return string(m[1])
+}
+
+func encodeBinary(t *testing.T, factory encoders.EncoderFactory, version string) []*encoders.BinaryRecord {
+	data, err := factory(commons.Config{JSONSchemaVersion: version}).Encode(newECSTestRecords())
+	assert.NoError(t, err)
+	recs := make([]*encoders.BinaryRecord, len(data))
+	for i, d := range data {
+		recs[i] = d.(*encoders.BinaryRecord)
+	}
+	return recs
+}
+
+func TestBinarySchemas(t *testing.T) {
+	version := schemaVersion(t)
+	schemas := map[string]string{
+		fmt.Sprintf("sysflow.v%s.avsc", version):  encoders.AvroSchema(version) + "\n",
+		fmt.Sprintf("sysflow.v%s.proto", version): encoders.ProtobufSchema(version),
+	}
+	for name, schema := range schemas {
+		path := filepath.Join(schemasPath, name)
+		if *update {
+			assert.NoError(t, os.WriteFile(path, []byte(schema), 0644))
+		}
+		// the published schemas of the current version are up to date
+		published, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, string(published), schema, "%s is outdated, run the tests with -update", name)
+	}
+}
+
+func TestAvroEncoder(t *testing.T) {
+	version := schemaVersion(t)
+	recs := encodeBinary(t, encoders.NewAvroEncoder, version)
+	assert.Len(t, recs, 5)
+	assert.Equal(t, testTs, recs[0].Ts)
+	assert.Equal(t, "node1", recs[0].NodeID)
+
+	// records are single-object encoded with the fingerprint of their schema
+	header := recs[0].Data[:10]
+	assert.Equal(t, []byte{0xc3, 0x01}, header[:2])
+	assert.Equal(t, header, recs[1].Data[:10])
+	assert.NotEqual(t, header, encodeBinary(t, encoders.NewAvroEncoder, version+"1")[0].Data[:10])
+
+	// records are decoded with the published schema
+	schema, err := os.ReadFile(filepath.Join(schemasPath, fmt.Sprintf("sysflow.v%s.avsc", version)))
+	assert.NoError(t, err)
+	codec, err := goavro.NewCodec(string(schema))
+	if !assert.NoError(t, err) {
+		return
+	}
+	decode := func(r *encoders.BinaryRecord) map[string]interface{} {
+		native, rest, err := codec.NativeFromBinary(r.Data[10:])
+		assert.NoError(t, err)
+		assert.Empty(t, rest)
+		return native.(map[string]interface{})
+	}
+	ns := "sysflow.v" + version + "."
+	pe, nf := decode(recs[0]), decode(recs[1])
+	assert.Equal(t, version, pe["version"])
+	assert.Equal(t, testTs, pe["ts"])
+	assert.Equal(t, []interface{}{"EXEC"}, pe["opflags"])
+	proc := pe["proc"].(map[string]interface{})[ns+"Proc"].(map[string]interface{})
+	assert.Equal(t, "/bin/sh", proc["exe"])
+	assert.Equal(t, int64(42), proc["pid"])
+	assert.Equal(t, "392abdfb220e", pe["container"].(map[string]interface{})[ns+"Container"].(map[string]interface{})["id"])
+	assert.Nil(t, pe["net"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": "shell", "desc": "shell spawned", "priority": int64(1)},
+		map[string]interface{}{"id": "pipe|shell", "desc": "shell in container\nwith args a=b", "priority": int64(2)},
+	}, pe["policies"])
+	assert.Equal(t, []interface{}{"mitre:T1059", "container", "shell"}, pe["tags"])
+
+	net := nf["net"].(map[string]interface{})[ns+"Net"].(map[string]interface{})
+	assert.Equal(t, int64(443), net["dport"])
+	assert.Equal(t, []interface{}{int64(41000), int64(443)}, net["port"])
+	assert.Nil(t, nf["container"])
+	assert.Empty(t, nf["policies"])
+}
+
+func TestProtobufEncoder(t *testing.T) {
+	version := schemaVersion(t)
+	recs := encodeBinary(t, encoders.NewProtobufEncoder, version)
+	assert.Len(t, recs, 5)
+	assert.Equal(t, "node1", recs[1].NodeID)
+
+	md, err := encoders.ProtobufDescriptor(version)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, "sysflow.v"+version+".Record", string(md.FullName()))
+	decode := func(r *encoders.BinaryRecord) map[string]interface{} {
+		m := dynamicpb.NewMessage(md)
+		assert.NoError(t, proto.Unmarshal(r.Data, m))
+		buf, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(m)
+		assert.NoError(t, err)
+		var doc map[string]interface{}
+		assert.NoError(t, json.Unmarshal(buf, &doc))
+		return doc
+	}
+	pe, nf := decode(recs[0]), decode(recs[1])
+	assert.Equal(t, version, pe["version"])
+	assert.Equal(t, fmt.Sprint(testTs), pe["ts"])
+	assert.Equal(t, "/bin/sh", pe["proc"].(map[string]interface{})["exe"])
+	assert.Equal(t, "392abdfb220e", pe["container"].(map[string]interface{})["id"])
+	assert.NotContains(t, pe, "net")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"id": "shell", "desc": "shell spawned", "priority": "1"},
+		map[string]interface{}{"id": "pipe|shell", "desc": "shell in container\nwith args a=b", "priority": "2"},
+	}, pe["policies"])
+	assert.Equal(t, []interface{}{"mitre:T1059", "container", "shell"}, pe["tags"])
+
+	net := nf["net"].(map[string]interface{})
+	assert.Equal(t, "443", net["dport"])
+	assert.Equal(t, []interface{}{"10.0.0.5", "93.184.216.34"}, net["ip"])
+	assert.NotContains(t, nf, "container")
+	assert.NotContains(t, nf, "policies")
+}
@@ -0,0 +1,137 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// CEF_VERSION is the CEF header version.
+const CEF_VERSION = "CEF:0"
+
+// cefKey is a CEF extension key, with the label of custom keys.
+type cefKey struct {
+	name  string
+	label string
+}
+
+// cefKeys maps record attributes to CEF extension keys.
+var cefKeys = map[siemField]cefKey{
+	siemHost:           {"dvchost", ""},
+	siemHostIP:         {"dvc", ""},
+	siemType:           {"cat", ""},
+	siemOpFlags:        {"act", ""},
+	siemPID:            {"spid", ""},
+	siemExe:            {"sproc", ""},
+	siemCmdline:        {"cs1", "cmdline"},
+	siemUser:           {"suser", ""},
+	siemUID:            {"suid", ""},
+	siemPPID:           {"cn1", "ppid"},
+	siemFilePath:       {"filePath", ""},
+	siemOldFilePath:    {"oldFilePath", ""},
+	siemSrcIP:          {"src", ""},
+	siemSrcPort:        {"spt", ""},
+	siemDstIP:          {"dst", ""},
+	siemDstPort:        {"dpt", ""},
+	siemProto:          {"proto", ""},
+	siemSrcBytes:       {"out", ""},
+	siemDstBytes:       {"in", ""},
+	siemContainerID:    {"cs2", "containerId"},
+	siemContainerName:  {"cs3", "containerName"},
+	siemContainerImage: {"cs4", "containerImage"},
+	siemRules:          {"cs5", "policies"},
+	siemRuleDescs:      {"msg", ""},
+	siemTags:           {"cs6", "tags"},
+}
+
+// cefHeaderEscaper escapes CEF header fields.
+var cefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// cefExtEscaper escapes CEF extension values.
+var cefExtEscaper = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+
+// CEFEncoder encodes records into ArcSight Common Event Format (CEF) events. Alerts have
+// a severity derived from the highest priority of the matched policies; other records
+// have severity 0.
+type CEFEncoder struct {
+	config commons.Config
+	batch  []commons.EncodedData
+	sb     strings.Builder
+}
+
+// NewCEFEncoder instantiates a CEF encoder.
+func NewCEFEncoder(config commons.Config) Encoder {
+	return &CEFEncoder{
+		config: config,
+		batch:  make([]commons.EncodedData, 0, config.EventBuffer)}
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *CEFEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.CEFFormat] = NewCEFEncoder
+}
+
+// Encode encodes telemetry records into CEF events.
+func (t *CEFEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		e := encodeSIEMEvent(rec)
+		t.batch = append(t.batch, e.record(t.encode(e)))
+	}
+	return t.batch, nil
+}
+
+// encode formats a CEF event.
+func (t *CEFEncoder) encode(e *siemEvent) []byte {
+	t.sb.Reset()
+	t.sb.WriteString(CEF_VERSION)
+	for _, h := range []string{SIEM_VENDOR, SIEM_PRODUCT, t.config.Version, e.id, e.name, strconv.Itoa(siemSeverity(e.priority, 0))} {
+		t.sb.WriteByte('|')
+		t.sb.WriteString(cefHeaderEscaper.Replace(h))
+	}
+	t.sb.WriteString("|rt=")
+	t.sb.WriteString(strconv.FormatInt(e.ts/1e6, 10))
+	if e.endTs != 0 {
+		t.sb.WriteString(" end=")
+		t.sb.WriteString(strconv.FormatInt(e.endTs/1e6, 10))
+	}
+	for _, a := range e.attrs {
+		k := cefKeys[a.field]
+		t.sb.WriteByte(' ')
+		t.sb.WriteString(k.name)
+		t.sb.WriteByte('=')
+		t.sb.WriteString(cefExtEscaper.Replace(a.value))
+		if k.label != "" {
+			t.sb.WriteByte(' ')
+			t.sb.WriteString(k.name)
+			t.sb.WriteString("Label=")
+			t.sb.WriteString(k.label)
+		}
+	}
+	return []byte(t.sb.String())
+}
+
+// Cleanup cleans up resources.
+func (t *CEFEncoder) Cleanup() {}
@@ -54,6 +54,7 @@ const (
 	GROUP_ID_ATTR     = "groupId"
 	OBSERVATIONS_ATTR = "observations"
 	POLICIES_ATTR     = "policies"
+	PTREE_ATTR        = "ptree"
 	ID_TAG_ATTR       = "id"
 	DESC_ATTR         = "desc"
 	PRIORITY_ATTR     = "priority"
@@ -0,0 +1,143 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"container/list"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/steakknife/bloomfilter"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// dedupe tracks the records seen in each container in a bloom filter, to tell novel records from
+// semantically equivalent records seen before. The filters of the least recently seen containers are
+// evicted when the number of containers exceeds the capacity.
+type dedupe struct {
+	keys     []engine.StrFieldMap
+	size     uint64
+	fprate   float64
+	interval time.Duration
+	capacity int
+	clock    clock.Clock
+	filters  map[string]*list.Element
+	lru      *list.List
+}
+
+// dedupeFilter is the bloom filter of a container.
+type dedupeFilter struct {
+	cid     string
+	bf      *bloomfilter.Filter
+	created time.Time
+}
+
+// newDedupe creates a dedupe filter from the dedupe settings in c, aged according to clock clk.
+// Unset settings take their default values.
+func newDedupe(c commons.DedupeConfig, clk clock.Clock) *dedupe {
+	if len(c.DedupeKeys) == 0 {
+		c.DedupeKeys = commons.DefaultDedupeConfig.DedupeKeys
+	}
+	if c.DedupeSize == 0 {
+		c.DedupeSize = commons.DefaultDedupeConfig.DedupeSize
+	}
+	if c.DedupeFPRate == 0 {
+		c.DedupeFPRate = commons.DefaultDedupeConfig.DedupeFPRate
+	}
+	if c.DedupeContainers == 0 {
+		c.DedupeContainers = commons.DefaultDedupeConfig.DedupeContainers
+	}
+	d := &dedupe{size: c.DedupeSize, fprate: c.DedupeFPRate, interval: c.DedupeInterval, capacity: c.DedupeContainers, clock: clk,
+		filters: make(map[string]*list.Element), lru: list.New()}
+	for _, k := range c.DedupeKeys {
+		d.keys = append(d.keys, engine.Mapper.MapStr(k))
+	}
+	return d
+}
+
+// novel checks whether no semantically equivalent record has been seen in container cid before,
+// and adds the record to the filter of the container.
+func (d *dedupe) novel(cid string, r *engine.Record) bool {
+	d.clock.Advance(engine.Mapper.MapInt(engine.SF_TS)(r))
+	var f *dedupeFilter
+	e, ok := d.filters[cid]
+	if ok {
+		f = e.Value.(*dedupeFilter)
+		d.lru.MoveToFront(e)
+	}
+	if !ok || (d.interval > 0 && d.clock.Since(f.created) >= d.interval) {
+		bf, err := bloomfilter.NewOptimal(d.size, d.fprate)
+		if err != nil {
+			return true
+		}
+		if ok {
+			f.bf, f.created = bf, d.clock.Now()
+		} else {
+			f = &dedupeFilter{cid: cid, bf: bf, created: d.clock.Now()}
+			d.filters[cid] = d.lru.PushFront(f)
+			d.evict()
+		}
+	}
+	h := d.hash(r)
+	if f.bf.Contains(h) {
+		return false
+	}
+	f.bf.Add(h)
+	return true
+}
+
+// reset clears the filter of container cid.
+func (d *dedupe) reset(cid string) {
+	if e, ok := d.filters[cid]; ok {
+		d.lru.Remove(e)
+		delete(d.filters, cid)
+	}
+}
+
+// evict removes the filters of the least recently seen containers in excess of the capacity.
+func (d *dedupe) evict() {
+	for d.lru.Len() > d.capacity {
+		e := d.lru.Back()
+		d.lru.Remove(e)
+		delete(d.filters, e.Value.(*dedupeFilter).cid)
+	}
+}
+
+// hash computes a hash value over the dedupe keys of a record.
+func (d *dedupe) hash(r *engine.Record) *xxhash.Digest {
+	h := xxhash.New()
+	for _, k := range d.keys {
+		h.WriteString(k(r))
+		h.Write([]byte{0})
+	}
+	return h
+}
+
+// duplicateAlert checks whether r is an alert semantically equivalent to an alert seen before in its
+// container. Records without rules are never duplicates.
+func (d *dedupe) duplicateAlert(r *engine.Record) bool {
+	if d == nil || len(r.Ctx.GetRules()) == 0 {
+		return false
+	}
+	return !d.novel(engine.Mapper.MapStr(engine.SF_CONTAINER_ID)(r), r)
+}
@@ -0,0 +1,143 @@
+package encoders_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// newDedupeTestAlert returns a shell alert in a container, run with the given arguments.
+func newDedupeTestAlert(args string, ts int64) *engine.Record {
+	return newDedupeTestContainerAlert("392abdfb220e", args, ts)
+}
+
+// newDedupeTestContainerAlert returns a shell alert in container cid, run with the given arguments.
+func newDedupeTestContainerAlert(cid string, args string, ts int64) *engine.Record {
+	fr := newFlatRecord(sfgo.PROC_EVT, sfgo.OP_EXEC, ts)
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXEARGS_STR] = args
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_ID_STR] = cid
+	r := engine.NewRecord(fr)
+	r.Ctx.AddRule(engine.Rule{Name: "shell", Desc: "shell spawned", Priority: engine.Medium})
+	return r
+}
+
+func newDedupeTestConfig() commons.Config {
+	config := commons.Config{Clock: clock.EventMode, JSONSchemaVersion: "4", DedupeConfig: commons.DefaultDedupeConfig}
+	config.Dedupe = true
+	return config
+}
+
+func TestDedupe(t *testing.T) {
+	for _, factory := range []encoders.EncoderFactory{encoders.NewJSONEncoder, encoders.NewECSEncoder} {
+		enc := factory(newDedupeTestConfig())
+		data, err := enc.Encode(newSIEMTestRecords())
+		assert.NoError(t, err)
+		assert.Len(t, data, 3)
+
+		// alerts seen before are dropped, other records are kept
+		data, err = enc.Encode(newSIEMTestRecords())
+		assert.NoError(t, err)
+		assert.Len(t, data, 2)
+		data, err = enc.Encode([]*engine.Record{newDedupeTestAlert("-c id", testTs)})
+		assert.NoError(t, err)
+		assert.Len(t, data, 1)
+	}
+
+	// records are kept if dedupe is disabled
+	config := newDedupeTestConfig()
+	config.Dedupe = false
+	enc := encoders.NewJSONEncoder(config)
+	enc.Encode(newSIEMTestRecords())
+	data, err := enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	assert.Len(t, data, 3)
+}
+
+func TestDedupeKeys(t *testing.T) {
+	config := newDedupeTestConfig()
+	config.DedupeKeys = []string{engine.SF_PROC_EXE}
+	enc := encoders.NewJSONEncoder(config)
+	data, err := enc.Encode([]*engine.Record{newDedupeTestAlert("-c id", testTs), newDedupeTestAlert("-c ls", testTs)})
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+
+	// the occurrence encoder creates occurrences for novel alerts, or alerts changing the state of a pool
+	dir := t.TempDir()
+	oconfig := newOccurrenceTestConfig(commons.LocalStorage, dir)
+	oenc := encoders.NewOccurrenceEncoder(oconfig)
+	data, err = oenc.Encode([]*engine.Record{newDedupeTestAlert("-c id", testTs), newDedupeTestAlert("-c ls", testTs)})
+	assert.NoError(t, err)
+	assert.Len(t, data, 2)
+	oenc.Cleanup()
+
+	oconfig.DedupeConfig = config.DedupeConfig
+	oenc = encoders.NewOccurrenceEncoder(oconfig)
+	data, err = oenc.Encode([]*engine.Record{newDedupeTestAlert("-c id", testTs), newDedupeTestAlert("-c ls", testTs)})
+	assert.NoError(t, err)
+	assert.Len(t, data, 1)
+	oenc.Cleanup()
+}
+
+func TestDedupeInterval(t *testing.T) {
+	config := newDedupeTestConfig()
+	config.DedupeInterval = time.Minute
+	enc := encoders.NewECSEncoder(config)
+	for i, n := range []int{1, 0, 1} {
+		data, err := enc.Encode([]*engine.Record{newDedupeTestAlert("-c id", testTs+int64(i)*int64(40*time.Second))})
+		assert.NoError(t, err)
+		assert.Len(t, data, n, "batch %d", i)
+	}
+}
+
+func TestDedupeEviction(t *testing.T) {
+	config := newDedupeTestConfig()
+	config.DedupeContainers = 2
+	enc := encoders.NewJSONEncoder(config)
+	encode := func(cid string) int {
+		data, err := enc.Encode([]*engine.Record{newDedupeTestContainerAlert(cid, "-c id", testTs)})
+		assert.NoError(t, err)
+		return len(data)
+	}
+	assert.Equal(t, 1, encode("c1"))
+	assert.Equal(t, 1, encode("c2"))
+	assert.Equal(t, 0, encode("c1"))
+
+	// the filter of the least recently seen container is evicted
+	assert.Equal(t, 1, encode("c3"))
+	assert.Equal(t, 0, encode("c1"))
+	assert.Equal(t, 0, encode("c3"))
+	assert.Equal(t, 1, encode("c2"))
+}
+
+func TestDedupeConfig(t *testing.T) {
+	c, err := commons.CreateConfig(map[string]interface{}{"dedupe": "true", "dedupe.keys": "sf.proc.exe, sf.file.path", "dedupe.interval": "1h"})
+	assert.NoError(t, err)
+	assert.True(t, c.Dedupe)
+	assert.Equal(t, []string{"sf.proc.exe", "sf.file.path"}, c.DedupeKeys)
+	assert.Equal(t, uint64(100000), c.DedupeSize)
+	assert.Equal(t, time.Hour, c.DedupeInterval)
+	assert.Equal(t, 100, c.DedupeContainers)
+
+	c, err = commons.CreateConfig(map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.False(t, c.Dedupe)
+	assert.Equal(t, commons.DefaultDedupeConfig.DedupeKeys, c.DedupeKeys)
+
+	for _, conf := range []map[string]interface{}{
+		{"dedupe.keys": "sf.proc.nope"},
+		{"dedupe.keys": ","},
+		{"dedupe.size": "0"},
+		{"dedupe.fprate": "1.5"},
+		{"dedupe.interval": "soon"},
+		{"dedupe.containers": "0"},
+	} {
+		_, err = commons.CreateConfig(conf)
+		assert.Error(t, err, "%v", conf)
+	}
+}
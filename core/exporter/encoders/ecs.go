@@ -32,6 +32,7 @@ import (
 	"github.com/cespare/xxhash/v2"
 	"github.com/satta/gommunityid"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
 	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
@@ -65,6 +66,7 @@ type ECSRecord struct {
 	Destination  JSONData   `json:"destination,omitempty"`
 	Process      JSONData   `json:"process,omitempty"`
 	User         JSONData   `json:"user,omitempty"`
+	Rule         JSONData   `json:"rule,omitempty"`
 	Tags         []string   `json:"tags,omitempty"`
 }
 
@@ -72,14 +74,37 @@ type ECSRecord struct {
 type ECSEncoder struct {
 	config commons.Config
 	//jsonencoder JSONEncoder
-	batch []commons.EncodedData
+	batch    []commons.EncodedData
+	fieldOps []ecsFieldOp
+	dedupe   *dedupe
+	ptree    *ptreeEncoder
 }
 
 // NewECSEncoder instantiates an ECS encoder.
 func NewECSEncoder(config commons.Config) Encoder {
-	return &ECSEncoder{
+	t := &ECSEncoder{
 		config: config,
 		batch:  make([]commons.EncodedData, 0, config.EventBuffer)}
+
+	// apply the field profile of the exporter
+	if f := newFieldFilter(config.FieldsConfig); f != nil {
+		for _, name := range engine.Fields {
+			fields, ok := ecsFields[name]
+			if !ok {
+				continue
+			}
+			if !f.exported(name) {
+				t.fieldOps = append(t.fieldOps, ecsFieldOp{fields: fields})
+			} else if r := f.redactor(name); r != nil {
+				t.fieldOps = append(t.fieldOps, ecsFieldOp{fields: fields, redact: r})
+			}
+		}
+	}
+	if config.Dedupe {
+		t.dedupe = newDedupe(config.DedupeConfig, clock.New(config.Clock))
+	}
+	t.ptree = newPtreeEncoder(config.FieldsConfig)
+	return t
 }
 
 // Register registers the encoder to the codecs cache.
@@ -91,6 +116,9 @@ func (t *ECSEncoder) Register(codecs map[commons.Format]EncoderFactory) {
 func (t *ECSEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
 	t.batch = t.batch[:0]
 	for _, rec := range recs {
+		if t.dedupe.duplicateAlert(rec) {
+			continue
+		}
 		ecs := t.encode(rec)
 		t.batch = append(t.batch, ecs)
 	}
@@ -135,6 +163,23 @@ func (t *ECSEncoder) encode(rec *engine.Record) *ECSRecord {
 		ecs.encodeK8sEvent(rec)
 	}
 
+	// encode hashes computed by enrichment plugins
+	if hs := rec.Ctx.GetHash(engine.HASH_TYPE_PROC); hs != nil && ecs.Process != nil {
+		if hash := encodeHash(hs); hash != nil {
+			ecs.Process[ECS_HASH] = hash
+		}
+	}
+	if hs := rec.Ctx.GetHash(engine.HASH_TYPE_FILE); hs != nil && ecs.File != nil {
+		if hash := encodeHash(hs); hash != nil {
+			ecs.File[ECS_HASH] = hash
+		}
+	}
+
+	// encode the process ancestors, starting with the parent process
+	if ancestors := t.ptree.ancestors(rec); len(ancestors) > 0 && ecs.Process != nil {
+		ecs.Process[ECS_PROC_ANCESTORS] = t.ptree.encodeECS(ancestors)
+	}
+
 	// encode tags and policy information
 	tags := rec.Ctx.GetTags()
 	rules := rec.Ctx.GetRules()
@@ -148,11 +193,16 @@ func (t *ECSEncoder) encode(rec *engine.Record) *ECSRecord {
 		}
 		ecs.Event[ECS_EVENT_REASON] = strings.Join(reasons, ", ")
 		ecs.Event[ECS_EVENT_SEVERITY] = priority
+		ecs.Rule = encodeRule(rules)
 	}
 	if len(tags) > 0 {
 		ecs.Tags = tags
 	}
 
+	for _, op := range t.fieldOps {
+		op.apply(ecs)
+	}
+
 	return ecs
 }
 
@@ -469,28 +519,81 @@ func encodeUser(rec *engine.Record) JSONData {
 // encodeProcess creates an ECS process field including the nested parent process.
 func encodeProcess(rec *engine.Record) JSONData {
 	exe := engine.Mapper.MapStr(engine.SF_PROC_EXE)(rec)
+	args := engine.Mapper.MapStr(engine.SF_PROC_ARGS)(rec)
 	process := JSONData{
-		ECS_PROC_EXE:     exe,
-		ECS_PROC_ARGS:    engine.Mapper.MapStr(engine.SF_PROC_ARGS)(rec),
-		ECS_PROC_CMDLINE: engine.Mapper.MapStr(engine.SF_PROC_CMDLINE)(rec),
-		ECS_PROC_PID:     engine.Mapper.MapInt(engine.SF_PROC_PID)(rec),
-		ECS_PROC_START:   utils.ToIsoTimeStr(engine.Mapper.MapInt(engine.SF_PROC_CREATETS)(rec)),
-		ECS_PROC_NAME:    path.Base(exe),
-		ECS_PROC_THREAD:  JSONData{ECS_PROC_TID: engine.Mapper.MapInt(engine.SF_PROC_TID)(rec)},
+		ECS_PROC_EXE:        exe,
+		ECS_PROC_ARGS:       args,
+		ECS_PROC_ARGS_COUNT: countArgs(args),
+		ECS_PROC_CMDLINE:    engine.Mapper.MapStr(engine.SF_PROC_CMDLINE)(rec),
+		ECS_PROC_PID:        engine.Mapper.MapInt(engine.SF_PROC_PID)(rec),
+		ECS_PROC_START:      utils.ToIsoTimeStr(engine.Mapper.MapInt(engine.SF_PROC_CREATETS)(rec)),
+		ECS_PROC_NAME:       path.Base(exe),
+		ECS_PROC_THREAD:     JSONData{ECS_PROC_TID: engine.Mapper.MapInt(engine.SF_PROC_TID)(rec)},
+	}
+
+	// the parent process is looked up in the process tree and omitted if unknown
+	ppid := engine.Mapper.MapInt(engine.SF_PPROC_PID)(rec)
+	if ppid == sfgo.Zeros.Int64 {
+		return process
 	}
 	pexe := engine.Mapper.MapStr(engine.SF_PPROC_EXE)(rec)
+	pargs := engine.Mapper.MapStr(engine.SF_PPROC_ARGS)(rec)
 	parent := JSONData{
-		ECS_PROC_EXE:     pexe,
-		ECS_PROC_ARGS:    engine.Mapper.MapStr(engine.SF_PPROC_ARGS)(rec),
-		ECS_PROC_CMDLINE: engine.Mapper.MapStr(engine.SF_PPROC_CMDLINE)(rec),
-		ECS_PROC_PID:     engine.Mapper.MapInt(engine.SF_PPROC_PID)(rec),
-		ECS_PROC_START:   utils.ToIsoTimeStr(engine.Mapper.MapInt(engine.SF_PPROC_CREATETS)(rec)),
-		ECS_PROC_NAME:    path.Base(pexe),
+		ECS_PROC_PID:   ppid,
+		ECS_PROC_START: utils.ToIsoTimeStr(engine.Mapper.MapInt(engine.SF_PPROC_CREATETS)(rec)),
+	}
+	if pexe != sfgo.Zeros.String {
+		parent[ECS_PROC_EXE] = pexe
+		parent[ECS_PROC_NAME] = path.Base(pexe)
+		parent[ECS_PROC_ARGS] = pargs
+		parent[ECS_PROC_ARGS_COUNT] = countArgs(pargs)
+		parent[ECS_PROC_CMDLINE] = engine.Mapper.MapStr(engine.SF_PPROC_CMDLINE)(rec)
 	}
 	process[ECS_PROC_PARENT] = parent
 	return process
 }
 
+// countArgs returns the number of process arguments, including the executable.
+func countArgs(args string) int {
+	return len(strings.Fields(args)) + 1
+}
+
+// encodeHash creates an ECS hash field, or returns nil if the hash set is empty.
+func encodeHash(hs *engine.HashSet) JSONData {
+	hash := JSONData{}
+	if hs.Md5 != "" {
+		hash[ECS_HASH_MD5] = hs.Md5
+	}
+	if hs.Sha1 != "" {
+		hash[ECS_HASH_SHA1] = hs.Sha1
+	}
+	if hs.Sha256 != "" {
+		hash[ECS_HASH_SHA256] = hs.Sha256
+	}
+	if len(hash) == 0 {
+		return nil
+	}
+	return hash
+}
+
+// encodeRule creates an ECS rule field listing the policy rules matched by a record.
+// Rule identifiers are derived from rule names, which are unique within a policy set.
+func encodeRule(rules []engine.Rule) JSONData {
+	ids := make([]string, len(rules))
+	names := make([]string, len(rules))
+	descs := make([]string, len(rules))
+	for i, r := range rules {
+		ids[i] = fmt.Sprintf("%x", xxhash.Sum64String(r.Name))
+		names[i] = r.Name
+		descs[i] = r.Desc
+	}
+	return JSONData{
+		ECS_RULE_ID:   ids,
+		ECS_RULE_NAME: names,
+		ECS_RULE_DESC: descs,
+	}
+}
+
 // encodeEvent creates the central ECS event field and sets the classification attributes
 func encodeEvent(rec *engine.Record, category string, eventType string, action string) JSONData {
 	start := engine.Mapper.MapInt(engine.SF_TS)(rec)
@@ -577,6 +680,124 @@ func encodeFileType(ft string) string {
 	return fileType
 }
 
+// ecsField locates an ECS field derived from a record attribute.
+type ecsField struct {
+	object func(*ECSRecord) JSONData
+	key    string
+}
+
+// ecsFields maps record attributes to the ECS fields derived from them.
+var ecsFields = map[string][]ecsField{
+	engine.SF_TYPE:                 {{ecsEvent, ECS_EVENT_SFTYPE}},
+	engine.SF_RET:                  {{ecsEvent, ECS_EVENT_SFRET}},
+	engine.SF_PROC_PID:             {{ecsProcess, ECS_PROC_PID}},
+	engine.SF_PROC_TID:             {{ecsThread, ECS_PROC_TID}},
+	engine.SF_PROC_NAME:            {{ecsProcess, ECS_PROC_NAME}},
+	engine.SF_PROC_EXE:             {{ecsProcess, ECS_PROC_EXE}},
+	engine.SF_PROC_ARGS:            {{ecsProcess, ECS_PROC_ARGS}},
+	engine.SF_PROC_CMDLINE:         {{ecsProcess, ECS_PROC_CMDLINE}},
+	engine.SF_PROC_CREATETS:        {{ecsProcess, ECS_PROC_START}},
+	engine.SF_PROC_UID:             {{ecsUser, ECS_USER_ID}},
+	engine.SF_PROC_USER:            {{ecsUser, ECS_USER_NAME}},
+	engine.SF_PROC_GID:             {{ecsGroup, ECS_GROUP_ID}},
+	engine.SF_PROC_GROUP:           {{ecsGroup, ECS_GROUP_NAME}},
+	engine.SF_PPROC_PID:            {{ecsParent, ECS_PROC_PID}},
+	engine.SF_PPROC_NAME:           {{ecsParent, ECS_PROC_NAME}},
+	engine.SF_PPROC_EXE:            {{ecsParent, ECS_PROC_EXE}},
+	engine.SF_PPROC_ARGS:           {{ecsParent, ECS_PROC_ARGS}},
+	engine.SF_PPROC_CMDLINE:        {{ecsParent, ECS_PROC_CMDLINE}},
+	engine.SF_PPROC_CREATETS:       {{ecsParent, ECS_PROC_START}},
+	engine.SF_FILE_NAME:            {{ecsFile, ECS_FILE_NAME}},
+	engine.SF_FILE_PATH:            {{ecsFile, ECS_FILE_PATH}},
+	engine.SF_FILE_DIRECTORY:       {{ecsFile, ECS_FILE_DIR}},
+	engine.SF_FILE_NEWPATH:         {{ecsFile, ECS_FILE_TARGET}},
+	engine.SF_FILE_TYPE:            {{ecsFile, ECS_FILE_TYPE}},
+	engine.SF_NET_PROTO:            {{ecsNetwork, ECS_NET_PROTO}, {ecsNetwork, ECS_NET_IANA}},
+	engine.SF_NET_SIP:              {{ecsSource, ECS_ENDPOINT_IP}, {ecsSource, ECS_ENDPOINT_ADDR}},
+	engine.SF_NET_SPORT:            {{ecsSource, ECS_ENDPOINT_PORT}},
+	engine.SF_NET_DIP:              {{ecsDestination, ECS_ENDPOINT_IP}, {ecsDestination, ECS_ENDPOINT_ADDR}},
+	engine.SF_NET_DPORT:            {{ecsDestination, ECS_ENDPOINT_PORT}},
+	engine.SF_FLOW_RBYTES:          {{ecsDestination, ECS_ENDPOINT_BYTES}, {ecsFileAction, ECS_SF_FA_RBYTES}},
+	engine.SF_FLOW_ROPS:            {{ecsDestination, ECS_ENDPOINT_PACKETS}, {ecsFileAction, ECS_SF_FA_ROPS}},
+	engine.SF_FLOW_WBYTES:          {{ecsSource, ECS_ENDPOINT_BYTES}, {ecsFileAction, ECS_SF_FA_WBYTES}},
+	engine.SF_FLOW_WOPS:            {{ecsSource, ECS_ENDPOINT_PACKETS}, {ecsFileAction, ECS_SF_FA_WOPS}},
+	engine.SF_CONTAINER_ID:         {{ecsContainer, ECS_CONTAINER_ID}},
+	engine.SF_CONTAINER_NAME:       {{ecsContainer, ECS_CONTAINER_NAME}},
+	engine.SF_CONTAINER_TYPE:       {{ecsContainer, ECS_CONTAINER_RUNTIME}},
+	engine.SF_CONTAINER_PRIVILEGED: {{ecsContainer, ECS_CONTAINER_PRIV}},
+	engine.SF_CONTAINER_IMAGEID:    {{ecsImage, ECS_IMAGE_ID}},
+	engine.SF_CONTAINER_IMAGE:      {{ecsImage, ECS_IMAGE_NAME}},
+	engine.SF_POD_TS:               {{ecsPod, ECS_POD_TS}},
+	engine.SF_POD_ID:               {{ecsPod, ECS_POD_ID}},
+	engine.SF_POD_NAME:             {{ecsPod, ECS_POD_NAME}, {ecsResource, ECS_RESOURCE_NAME}},
+	engine.SF_POD_NODENAME:         {{ecsPod, ECS_POD_NODENAME}},
+	engine.SF_POD_NAMESPACE:        {{ecsPod, ECS_POD_NAMESPACE}, {ecsOrchestrator, ECS_ORCHESTRATOR_NAMESPACE}},
+	engine.SF_POD_RESTARTCOUNT:     {{ecsPod, ECS_POD_RESTARTCOUNT}},
+	engine.SF_POD_HOSTIP:           {{ecsPod, ECS_POD_HOSTIP}},
+	engine.SF_POD_INTERNALIP:       {{ecsPod, ECS_POD_INTERNALIP}},
+	engine.SF_K8SE_MESSAGE:         {{ecsEvent, ECS_EVENT_ORIGINAL}},
+	engine.SF_NODE_ID:              {{ecsHost, ECS_HOST_ID}},
+	engine.SF_NODE_IP:              {{ecsHost, ECS_HOST_IP}},
+}
+
+func ecsEvent(ecs *ECSRecord) JSONData        { return ecs.Event }
+func ecsHost(ecs *ECSRecord) JSONData         { return ecs.Host }
+func ecsProcess(ecs *ECSRecord) JSONData      { return ecs.Process }
+func ecsThread(ecs *ECSRecord) JSONData       { return ecsChild(ecs.Process, ECS_PROC_THREAD) }
+func ecsParent(ecs *ECSRecord) JSONData       { return ecsChild(ecs.Process, ECS_PROC_PARENT) }
+func ecsUser(ecs *ECSRecord) JSONData         { return ecs.User }
+func ecsGroup(ecs *ECSRecord) JSONData        { return ecsChild(ecs.User, ECS_GROUP) }
+func ecsFile(ecs *ECSRecord) JSONData         { return ecs.File }
+func ecsFileAction(ecs *ECSRecord) JSONData   { return ecs.FileAction }
+func ecsNetwork(ecs *ECSRecord) JSONData      { return ecs.Network }
+func ecsSource(ecs *ECSRecord) JSONData       { return ecs.Source }
+func ecsDestination(ecs *ECSRecord) JSONData  { return ecs.Destination }
+func ecsContainer(ecs *ECSRecord) JSONData    { return ecs.Container }
+func ecsImage(ecs *ECSRecord) JSONData        { return ecsChild(ecs.Container, ECS_IMAGE) }
+func ecsPod(ecs *ECSRecord) JSONData          { return ecs.Pod }
+func ecsOrchestrator(ecs *ECSRecord) JSONData { return ecs.Orchestrator }
+func ecsResource(ecs *ECSRecord) JSONData {
+	return ecsChild(ecs.Orchestrator, ECS_ORCHESTRATOR_RESOURCE)
+}
+
+func ecsChild(o JSONData, key string) JSONData {
+	c, _ := o[key].(JSONData)
+	return c
+}
+
+// ecsFieldOp removes or redacts the ECS fields derived from a record attribute.
+type ecsFieldOp struct {
+	fields []ecsField
+	redact redactor // nil if the fields are removed
+}
+
+// apply applies the operation to an ECS record.
+func (op ecsFieldOp) apply(ecs *ECSRecord) {
+	for _, f := range op.fields {
+		o := f.object(ecs)
+		v, ok := o[f.key]
+		if !ok {
+			continue
+		}
+		if op.redact == nil {
+			delete(o, f.key)
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			o[f.key] = op.redact(v)
+		case []string:
+			r := make([]string, len(v))
+			for i, s := range v {
+				r[i] = op.redact(s)
+			}
+			o[f.key] = r
+		default:
+			o[f.key] = op.redact(fmt.Sprint(v))
+		}
+	}
+}
+
 func extracTags(tags []engine.EnrichmentTag) []string {
 	s := make([]string, 0)
 	for _, v := range tags {
@@ -0,0 +1,149 @@
+package encoders_test
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+const ecsMappingPath = "../../../resources/mappings/ecs_mapping.json"
+
+type ecsMapping struct {
+	Type       string                `json:"type"`
+	Properties map[string]ecsMapping `json:"properties"`
+}
+
+func loadECSMapping(t *testing.T) map[string]ecsMapping {
+	data, err := os.ReadFile(ecsMappingPath)
+	assert.NoError(t, err)
+	var m struct {
+		Mappings ecsMapping `json:"mappings"`
+	}
+	assert.NoError(t, json.Unmarshal(data, &m))
+	return m.Mappings.Properties
+}
+
+// checkECSFields checks that every field of an ECS document is defined in the ECS mapping with a compatible type.
+func checkECSFields(t *testing.T, mapping map[string]ecsMapping, prefix string, doc map[string]interface{}) {
+	for k, v := range doc {
+		name := prefix + k
+		m, ok := mapping[k]
+		if !assert.True(t, ok, "field %s is not defined in the ECS mapping", name) {
+			continue
+		}
+		if arr, ok := v.([]interface{}); ok {
+			for _, e := range arr {
+				checkECSValue(t, m, name, e)
+			}
+			continue
+		}
+		checkECSValue(t, m, name, v)
+	}
+}
+
+func checkECSValue(t *testing.T, m ecsMapping, name string, v interface{}) {
+	if o, ok := v.(map[string]interface{}); ok {
+		assert.NotNil(t, m.Properties, "field %s is not an object in the ECS mapping", name)
+		checkECSFields(t, m.Properties, name+".", o)
+		return
+	}
+	switch m.Type {
+	case "keyword", "text", "ip", "date_nanos":
+		assert.IsType(t, "", v, "field %s", name)
+	case "long", "integer", "short":
+		assert.IsType(t, float64(0), v, "field %s", name)
+	case "boolean":
+		assert.IsType(t, true, v, "field %s", name)
+	default:
+		assert.Fail(t, "unexpected mapping type", "field %s has type %q", name, m.Type)
+	}
+}
+
+func encodeECS(t *testing.T, recs []*engine.Record) []map[string]interface{} {
+	enc := encoders.NewECSEncoder(commons.Config{Version: "0.5.0", EcsVersion: "8.4.0"})
+	data, err := enc.Encode(recs)
+	assert.NoError(t, err)
+	return decodeECS(t, data)
+}
+
+func decodeECS(t *testing.T, data []commons.EncodedData) []map[string]interface{} {
+	docs := make([]map[string]interface{}, len(data))
+	for i, d := range data {
+		b, err := json.Marshal(d)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(b, &docs[i]))
+	}
+	return docs
+}
+
+// newECSTestRecords returns the SIEM test records with hashes, a file flow, and a process without a known parent.
+func newECSTestRecords() []*engine.Record {
+	recs := newSIEMTestRecords()
+	recs[0].Ctx.SetHashes(engine.HASH_TYPE_PROC, &engine.HashSet{Md5: "5d41402abc4b2a76b9719d911017c592", Sha256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"})
+
+	fr := newFlatRecord(sfgo.FILE_FLOW, sfgo.OP_OPEN|sfgo.OP_READ_RECV, testTs+int64(5e9))
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.FILE_PATH_STR] = "/etc/shadow"
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FILE_RESTYPE_INT] = 'f'
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_FILE_NUMRRECVOPS_INT] = 1
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_FILE_NUMRRECVBYTES_INT] = 1024
+	ff := engine.NewRecord(fr)
+	ff.Ctx.SetHashes(engine.HASH_TYPE_FILE, &engine.HashSet{Sha1: "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"})
+	ff.Ctx.AddRule(engine.Rule{Name: "shadow read", Desc: "read of sensitive file", Priority: engine.Low})
+
+	fr = newFlatRecord(sfgo.PROC_EVT, sfgo.OP_CLONE, testTs+int64(6e9))
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.PROC_POID_HPID_INT] = 0
+	orphan := engine.NewRecord(fr)
+
+	return append(recs, ff, orphan)
+}
+
+func TestECSMapping(t *testing.T) {
+	mapping := loadECSMapping(t)
+	for _, doc := range encodeECS(t, newECSTestRecords()) {
+		checkECSFields(t, mapping, "", doc)
+	}
+}
+
+func TestECSEncoder(t *testing.T) {
+	docs := encodeECS(t, newECSTestRecords())
+	pe, nf, ff, orphan := docs[0], docs[1], docs[3], docs[4]
+
+	// rules and severity of alerts
+	rule := pe["rule"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"shell", "pipe|shell"}, rule["name"])
+	assert.Equal(t, []interface{}{"shell spawned", "shell in container\nwith args a=b"}, rule["description"])
+	ids := rule["id"].([]interface{})
+	assert.Len(t, ids, 2)
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.Equal(t, ids[0], encodeECS(t, newSIEMTestRecords())[0]["rule"].(map[string]interface{})["id"].([]interface{})[0])
+	assert.Equal(t, float64(engine.High), pe["event"].(map[string]interface{})["severity"])
+	assert.Equal(t, float64(engine.Low), ff["event"].(map[string]interface{})["severity"])
+	assert.NotContains(t, nf, "rule")
+	assert.NotContains(t, nf["event"], "severity")
+
+	// hashes
+	process := pe["process"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{
+		"md5":    "5d41402abc4b2a76b9719d911017c592",
+		"sha256": "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}, process["hash"])
+	assert.Equal(t, map[string]interface{}{"sha1": "aaf4c61ddcc5e8a2dabede0f3b482cd9aea9434d"}, ff["file"].(map[string]interface{})["hash"])
+	assert.NotContains(t, nf["process"], "hash")
+
+	// process tree
+	assert.Equal(t, float64(len(strings.Fields("-c echo a=b|c\\d\necho\tdone"))+1), process["args_count"])
+	parent := process["parent"].(map[string]interface{})
+	assert.Equal(t, float64(1), parent["pid"])
+	assert.NotContains(t, orphan["process"], "parent")
+
+	// network transport
+	assert.Equal(t, "tcp", nf["network"].(map[string]interface{})["transport"])
+	assert.Equal(t, "6", nf["network"].(map[string]interface{})["iana_number"])
+}
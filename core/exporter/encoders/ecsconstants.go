@@ -69,7 +69,7 @@ const (
 	ECS_NET_BYTES = "bytes"
 	ECS_NET_CID   = "community_id"
 	ECS_NET_IANA  = "iana_number"
-	ECS_NET_PROTO = "protocol"
+	ECS_NET_PROTO = "transport"
 
 	// used in source and destination fields
 	ECS_ENDPOINT_ADDR    = "address"
@@ -93,9 +93,11 @@ const (
 	ECS_POD_INTERNALIP   = "internalip"
 	ECS_POD_RESTARTCOUNT = "restartcnt"
 
+	ECS_PROC_ANCESTORS  = "ancestors"
 	ECS_PROC_ARGS_COUNT = "args_count"
 	ECS_PROC_ARGS       = "args"
 	ECS_PROC_CMDLINE    = "command_line"
+	ECS_PROC_CONTAINER  = "container"
 	ECS_PROC_EXE        = "executable"
 	ECS_PROC_NAME       = "name"
 	ECS_PROC_PARENT     = "parent"
@@ -103,6 +105,11 @@ const (
 	ECS_PROC_THREAD     = "thread"
 	ECS_PROC_TID        = "id"
 	ECS_PROC_START      = "start"
+	ECS_PROC_USER       = "user"
+
+	ECS_RULE_ID   = "id"
+	ECS_RULE_NAME = "name"
+	ECS_RULE_DESC = "description"
 
 	ECS_SF_FA_RBYTES = "bytes_read"
 	ECS_SF_FA_ROPS   = "read_ops"
@@ -0,0 +1,103 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+)
+
+// EventStorage stores the event files written by event pools, which give context to occurrences.
+// Event files are written to a local directory, under paths also used as storage keys.
+type EventStorage interface {
+	// Dir returns the local directory of event files.
+	Dir() string
+	// Store stores the event file at path, relative to the local directory, once events are appended to it.
+	Store(path string) error
+}
+
+// NewEventStorage creates the event storage selected in the configuration, or nil if events are not stored.
+func NewEventStorage(config commons.Config) (EventStorage, error) {
+	switch config.OccStorage {
+	case commons.LocalStorage:
+		return &LocalEventStorage{dir: config.OccPath}, nil
+	case commons.S3Storage:
+		return NewS3EventStorage(config)
+	}
+	return nil, nil
+}
+
+// LocalEventStorage keeps event files in the local directory.
+type LocalEventStorage struct {
+	dir string
+}
+
+// Dir returns the local directory of event files.
+func (s *LocalEventStorage) Dir() string {
+	return s.dir
+}
+
+// Store does nothing, since event files are written in place.
+func (s *LocalEventStorage) Store(path string) error {
+	return nil
+}
+
+// S3EventStorage uploads event files to a bucket of an S3-compatible object store, with their paths as keys.
+// Since objects cannot be appended to, an event file is uploaded in full every time events are appended to it.
+type S3EventStorage struct {
+	dir    string
+	bucket string
+	client *minio.Client
+}
+
+// NewS3EventStorage creates an object store event storage. Credentials are read from the configuration,
+// or otherwise from the environment, the AWS credentials file, or the IAM role of the instance.
+func NewS3EventStorage(config commons.Config) (*S3EventStorage, error) {
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.Static{Value: credentials.Value{AccessKeyID: config.OccS3AccessKey, SecretAccessKey: config.OccS3SecretKey, SignerType: credentials.SignatureV4}},
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	})
+	client, err := minio.New(config.OccS3Endpoint, &minio.Options{Creds: creds, Secure: config.OccS3Secure, Region: config.OccS3Region})
+	if err != nil {
+		return nil, err
+	}
+	return &S3EventStorage{dir: config.OccPath, bucket: config.OccS3Bucket, client: client}, nil
+}
+
+// Dir returns the local directory of event files.
+func (s *S3EventStorage) Dir() string {
+	return s.dir
+}
+
+// Store uploads the event file at path.
+func (s *S3EventStorage) Store(path string) error {
+	_, err := s.client.FPutObject(context.Background(), s.bucket, filepath.ToSlash(path), filepath.Join(s.dir, path),
+		minio.PutObjectOptions{ContentType: "avro/binary"})
+	return err
+}
@@ -0,0 +1,144 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+)
+
+// redactor redacts an attribute value.
+type redactor func(string) string
+
+// redaction is a redactor applied to a set of attributes or sections.
+type redaction struct {
+	fields []string
+	redact redactor
+}
+
+// fieldFilter selects the record attributes exported by an encoder, and redacts their values,
+// according to the field profile of the exporter.
+type fieldFilter struct {
+	include    []string
+	exclude    []string
+	redactions []redaction
+}
+
+// newFieldFilter creates a field filter from an exporter configuration, or returns nil
+// if the configuration exports all attributes as is.
+func newFieldFilter(c commons.FieldsConfig) *fieldFilter {
+	if len(c.FieldsInclude) == 0 && len(c.FieldsExclude) == 0 && len(c.RedactMask) == 0 && len(c.RedactTruncate) == 0 && len(c.RedactHash) == 0 {
+		return nil
+	}
+	// redactions are applied in order: mask, truncate, hash
+	return &fieldFilter{
+		include: c.FieldsInclude,
+		exclude: c.FieldsExclude,
+		redactions: []redaction{
+			{c.RedactMask, func(s string) string {
+				return c.RedactMaskPattern.ReplaceAllLiteralString(s, c.RedactMaskReplacement)
+			}},
+			{c.RedactTruncate, func(s string) string {
+				return truncate(s, c.RedactTruncateLength)
+			}},
+			{c.RedactHash, func(s string) string {
+				h := sha256.Sum256([]byte(c.RedactHashSalt + s))
+				return hex.EncodeToString(h[:])
+			}},
+		},
+	}
+}
+
+// exported returns true if attribute name is exported.
+func (f *fieldFilter) exported(name string) bool {
+	if f == nil {
+		return true
+	}
+	return (len(f.include) == 0 || matchField(name, f.include)) && !matchField(name, f.exclude)
+}
+
+// excluded returns true if any of the given attributes is excluded.
+func (f *fieldFilter) excluded(names ...string) bool {
+	if f == nil {
+		return false
+	}
+	for _, name := range names {
+		if matchField(name, f.exclude) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactor returns the redactor of an attribute, or nil if its value is exported as is. If several attribute
+// names are given, each redaction is applied once if it applies to any of them.
+func (f *fieldFilter) redactor(names ...string) redactor {
+	if f == nil {
+		return nil
+	}
+	var red redactor
+	for _, r := range f.redactions {
+		if !matchAnyField(names, r.fields) {
+			continue
+		}
+		if prev, next := red, r.redact; prev != nil {
+			red = func(s string) string { return next(prev(s)) }
+		} else {
+			red = next
+		}
+	}
+	return red
+}
+
+// matchField returns true if attribute name is one of the given attributes, or belongs to one of the given sections.
+func matchField(name string, fields []string) bool {
+	name = strings.TrimSuffix(name, "+")
+	for _, f := range fields {
+		if name == f || strings.HasPrefix(name, f+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAnyField returns true if any of the given attribute names matches the given attributes or sections.
+func matchAnyField(names []string, fields []string) bool {
+	for _, name := range names {
+		if matchField(name, fields) {
+			return true
+		}
+	}
+	return false
+}
+
+// truncate returns the first n characters of s.
+func truncate(s string, n int) string {
+	for i := range s {
+		if n == 0 {
+			return s[:i]
+		}
+		n--
+	}
+	return s
+}
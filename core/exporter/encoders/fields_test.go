@@ -0,0 +1,126 @@
+package encoders_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+)
+
+func newFieldsTestConfig(t *testing.T, conf map[string]interface{}) commons.Config {
+	conf[commons.JSONSchemaVersionKey] = "4"
+	c, err := commons.CreateConfig(conf)
+	assert.NoError(t, err)
+	return c
+}
+
+func encodeJSON(t *testing.T, c commons.Config) []map[string]interface{} {
+	data, err := encoders.NewJSONEncoder(c).Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	return decodeJSON(t, data)
+}
+
+func decodeJSON(t *testing.T, data []commons.EncodedData) []map[string]interface{} {
+	var recs []map[string]interface{}
+	for _, d := range data {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(d.([]byte), &m), string(d.([]byte)))
+		recs = append(recs, m)
+	}
+	return recs
+}
+
+func sha256Hex(s string) string {
+	h := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(h[:])
+}
+
+func TestJSONFieldProjection(t *testing.T) {
+	recs := encodeJSON(t, newFieldsTestConfig(t, map[string]interface{}{
+		commons.FieldsIncludeConfigKey: "sf.type, sf.proc, sf.net",
+		commons.FieldsExcludeConfigKey: "sf.proc.args,sf.proc.aexe",
+	}))
+	assert.Len(t, recs, 3)
+	pe := recs[0]
+	assert.Equal(t, "PE", pe["type"])
+	assert.NotContains(t, pe, "ts")
+	assert.NotContains(t, pe, "node")
+	assert.NotContains(t, pe, "container")
+	proc := pe["proc"].(map[string]interface{})
+	assert.Equal(t, "/bin/sh", proc["exe"])
+	assert.NotContains(t, proc, "args")
+	assert.NotContains(t, proc, "aexe")
+	assert.Contains(t, pe, "policies")
+	assert.Contains(t, recs[1], "net")
+	assert.NotContains(t, recs[2], "net")
+
+	// records without any of the selected sections
+	recs = encodeJSON(t, newFieldsTestConfig(t, map[string]interface{}{commons.FieldsIncludeConfigKey: "sf.ts,sf.net"}))
+	assert.Equal(t, []string{"policies", "tags", "ts", "version"}, keys(recs[0]))
+	assert.Equal(t, []string{"net", "ts", "version"}, keys(recs[1]))
+}
+
+func TestJSONFieldRedaction(t *testing.T) {
+	recs := encodeJSON(t, newFieldsTestConfig(t, map[string]interface{}{
+		commons.RedactHashConfigKey:            "sf.proc.cmdline",
+		commons.RedactHashSaltConfigKey:        "salt",
+		commons.RedactTruncateConfigKey:        "sf.file,sf.proc.cmdline",
+		commons.RedactTruncateLengthConfigKey:  "6",
+		commons.RedactMaskConfigKey:            "sf.proc.args",
+		commons.RedactMaskPatternConfigKey:     `a=\w+`,
+		commons.RedactMaskReplacementConfigKey: "a=xxx",
+	}))
+	proc := recs[0]["proc"].(map[string]interface{})
+	assert.Equal(t, sha256Hex("salt/bin/s"), proc["cmdline"])
+	assert.Equal(t, "-c echo a=xxx|c\\d\necho\tdone", proc["args"])
+	assert.Equal(t, "/bin/sh", proc["exe"])
+	file := recs[2]["file"].(map[string]interface{})
+	assert.Equal(t, "/tmp/a", file["path"])
+	assert.Equal(t, "/tmp/b", file["newpath"])
+	assert.Equal(t, "0", file["fd"]) // redacted values are exported as strings
+}
+
+func TestECSFieldProfile(t *testing.T) {
+	c := newFieldsTestConfig(t, map[string]interface{}{
+		commons.FieldsExcludeConfigKey:     "sf.proc.args,sf.pproc,sf.net.sport",
+		commons.RedactHashConfigKey:        "sf.proc.cmdline,sf.container.name",
+		commons.RedactMaskConfigKey:        "sf.file.newpath",
+		commons.RedactMaskPatternConfigKey: `\t.*`,
+	})
+	data, err := encoders.NewECSEncoder(c).Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	pe := data[0].(*encoders.ECSRecord)
+	assert.NotContains(t, pe.Process, encoders.ECS_PROC_ARGS)
+	assert.NotContains(t, pe.Process[encoders.ECS_PROC_PARENT], encoders.ECS_PROC_CMDLINE)
+	assert.Equal(t, sha256Hex("/bin/sh -c echo a=b|c\\d\necho\tdone"), pe.Process[encoders.ECS_PROC_CMDLINE])
+	assert.Equal(t, "/bin/sh", pe.Process[encoders.ECS_PROC_EXE])
+	assert.Equal(t, sha256Hex("web"), pe.Container[encoders.ECS_CONTAINER_NAME])
+	nf := data[1].(*encoders.ECSRecord)
+	assert.NotContains(t, nf.Source, encoders.ECS_ENDPOINT_PORT)
+	assert.Equal(t, int64(443), nf.Destination[encoders.ECS_ENDPOINT_PORT])
+	fe := data[2].(*encoders.ECSRecord)
+	assert.Equal(t, "/tmp/b***", fe.File[encoders.ECS_FILE_TARGET])
+}
+
+func TestFieldsConfig(t *testing.T) {
+	_, err := commons.CreateConfig(map[string]interface{}{commons.FieldsExcludeConfigKey: "sf.proc.cmd"})
+	assert.EqualError(t, err, "invalid value 'sf.proc.cmd' for key 'fields.exclude': unknown attribute or section 'sf.proc.cmd'")
+	_, err = commons.CreateConfig(map[string]interface{}{commons.RedactMaskConfigKey: "sf.proc"})
+	assert.EqualError(t, err, "missing value for key 'fields.redact.mask.pattern'")
+	_, err = commons.CreateConfig(map[string]interface{}{commons.FieldsIncludeConfigKey: "sf.pod.hostip,sf.k"})
+	assert.Error(t, err)
+}
+
+func keys(m map[string]interface{}) []string {
+	var l []string
+	for k := range m {
+		l = append(l, k)
+	}
+	sort.Strings(l)
+	return l
+}
@@ -29,6 +29,7 @@ import (
 
 	"github.com/mailru/easyjson/jwriter"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
 	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
@@ -38,19 +39,42 @@ import (
 type JSONEncoder struct {
 	config     commons.Config
 	fieldCache []*engine.FieldValue
+	redactors  map[*engine.FieldValue]redactor
 	writer     *jwriter.Writer
 	buf        []byte
 	batch      []commons.EncodedData
+	dedupe     *dedupe
+	ptree      *ptreeEncoder
 }
 
 // NewJSONEncoder instantiates a JSON encoder.
 func NewJSONEncoder(config commons.Config) Encoder {
-	return &JSONEncoder{
+	t := &JSONEncoder{
 		fieldCache: engine.FieldValues,
 		config:     config,
 		writer:     &jwriter.Writer{},
 		buf:        make([]byte, 0, BUFFER_SIZE),
 		batch:      make([]commons.EncodedData, 0, config.EventBuffer)}
+
+	// apply the field profile of the exporter
+	if f := newFieldFilter(config.FieldsConfig); f != nil {
+		t.fieldCache = make([]*engine.FieldValue, 0, len(engine.FieldValues))
+		t.redactors = make(map[*engine.FieldValue]redactor)
+		for _, fv := range engine.FieldValues {
+			if !f.exported(fv.FieldName) {
+				continue
+			}
+			t.fieldCache = append(t.fieldCache, fv)
+			if r := f.redactor(fv.FieldName); r != nil {
+				t.redactors[fv] = r
+			}
+		}
+	}
+	if config.Dedupe {
+		t.dedupe = newDedupe(config.DedupeConfig, clock.New(config.Clock))
+	}
+	t.ptree = newPtreeEncoder(config.FieldsConfig)
+	return t
 }
 
 // Register registers the encoder to the codecs cache.
@@ -62,6 +86,9 @@ func (t *JSONEncoder) Register(codecs map[commons.Format]EncoderFactory) {
 func (t *JSONEncoder) Encode(recs []*engine.Record) (data []commons.EncodedData, err error) {
 	t.batch = t.batch[:0]
 	for _, rec := range recs {
+		if t.dedupe.duplicateAlert(rec) {
+			continue
+		}
 		var j commons.EncodedData
 		if j, err = t.encode(rec); err != nil {
 			return nil, err
@@ -283,7 +310,17 @@ func (t *JSONEncoder) encode(rec *engine.Record) (commons.EncodedData, error) {
 			}
 		}
 	}
-	t.writer.RawByte(END_CURLY)
+	if state != BEGIN_STATE && existed {
+		t.writer.RawByte(END_CURLY)
+	} else if buf := t.writer.Buffer.Buf; len(buf) > 0 && buf[len(buf)-1] == COMMA {
+		// the field profile excluded the sections that would follow
+		t.writer.Buffer.Buf = buf[:len(buf)-1]
+	}
+
+	// Encode process ancestors
+	if ancestors := t.ptree.ancestors(rec); len(ancestors) > 0 {
+		t.writePtree(ancestors)
+	}
 
 	// Encode policies
 	numRules := len(rec.Ctx.GetRules())
@@ -342,6 +379,38 @@ func (t *JSONEncoder) encode(rec *engine.Record) (commons.EncodedData, error) {
 	return t.writer.BuildBytes()
 }
 
+// writePtree writes the exported attributes of process ancestors, starting with the parent process.
+func (t *JSONEncoder) writePtree(ancestors []*sfgo.Process) {
+	t.writer.RawString(PTREE)
+	for i, proc := range ancestors {
+		if i > 0 {
+			t.writer.RawByte(COMMA)
+		}
+		t.writer.RawByte(BEGIN_CURLY)
+		first := true
+		for attr := ptreeAttr(0); attr < numPtreeAttrs; attr++ {
+			if !t.ptree.exported[attr] {
+				continue
+			}
+			if !first {
+				t.writer.RawByte(COMMA)
+			}
+			first = false
+			t.writer.RawByte(DOUBLE_QUOTE)
+			t.writer.RawString(ptreeNames[attr])
+			t.writer.RawString(QUOTE_COLON)
+			switch v := t.ptree.value(attr, proc).(type) {
+			case int64:
+				t.writer.Int64(v)
+			case string:
+				t.writer.String(v)
+			}
+		}
+		t.writer.RawByte(END_CURLY)
+	}
+	t.writer.RawByte(END_SQUARE)
+}
+
 func (t *JSONEncoder) writeAttribute(fv *engine.FieldValue, fieldID int, rec *engine.Record) {
 	t.writer.RawByte(DOUBLE_QUOTE)
 	name := fv.FieldSects[fieldID]
@@ -351,6 +420,10 @@ func (t *JSONEncoder) writeAttribute(fv *engine.FieldValue, fieldID int, rec *en
 		t.writer.RawString(name)
 	}
 	t.writer.RawString(QUOTE_COLON)
+	if r, ok := t.redactors[fv]; ok {
+		t.writer.String(r(engine.Mapper.MapStr(fv.FieldName)(rec)))
+		return
+	}
 	MapJSON(fv, t.writer, rec)
 }
 
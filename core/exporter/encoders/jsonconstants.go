@@ -40,6 +40,7 @@ const (
 	BEGIN_SQUARE      = '['
 	SPACE             = ' '
 	POLICIES          = ",\"" + POLICIES_ATTR + "\":["
+	PTREE             = ",\"" + PTREE_ATTR + "\":["
 	ID_TAG            = "{\"" + ID_TAG_ATTR + "\":"
 	DESC              = ",\"" + DESC_ATTR + "\":"
 	PRIORITY          = ",\"" + PRIORITY_ATTR + "\":"
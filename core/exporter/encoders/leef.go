@@ -0,0 +1,136 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// LEEF header values. Attributes are tab-delimited.
+const (
+	LEEF_VERSION   = "LEEF:2.0"
+	LEEF_DELIMITER = "x09"
+)
+
+// LEEF timestamp formats, in Java and Go layouts.
+const (
+	LEEF_TIME_FORMAT = "yyyy-MM-dd'T'HH:mm:ss.SSSZ"
+	leefGoTimeFormat = "2006-01-02T15:04:05.000-0700"
+)
+
+// leefKeys maps record attributes to LEEF attribute keys. Keys without a LEEF predefined
+// equivalent are custom keys.
+var leefKeys = map[siemField]string{
+	siemHost:           "hostName",
+	siemHostIP:         "hostIP",
+	siemType:           "cat",
+	siemOpFlags:        "action",
+	siemPID:            "pid",
+	siemExe:            "exe",
+	siemCmdline:        "cmdline",
+	siemUser:           "usrName",
+	siemUID:            "uid",
+	siemPPID:           "ppid",
+	siemFilePath:       "filePath",
+	siemOldFilePath:    "oldFilePath",
+	siemSrcIP:          "src",
+	siemSrcPort:        "srcPort",
+	siemDstIP:          "dst",
+	siemDstPort:        "dstPort",
+	siemProto:          "proto",
+	siemSrcBytes:       "srcBytes",
+	siemDstBytes:       "dstBytes",
+	siemContainerID:    "containerId",
+	siemContainerName:  "containerName",
+	siemContainerImage: "containerImage",
+	siemRules:          "policy",
+	siemRuleDescs:      "reason",
+	siemTags:           "tags",
+}
+
+// leefHeaderEscaper escapes LEEF header fields.
+var leefHeaderEscaper = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+
+// leefValueEscaper escapes LEEF attribute values.
+var leefValueEscaper = strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\r", `\r`, "\n", `\n`)
+
+// LEEFEncoder encodes records into IBM QRadar Log Event Extended Format (LEEF) events. Alerts
+// have a severity derived from the highest priority of the matched policies; other records
+// have severity 1.
+type LEEFEncoder struct {
+	config commons.Config
+	batch  []commons.EncodedData
+	sb     strings.Builder
+}
+
+// NewLEEFEncoder instantiates a LEEF encoder.
+func NewLEEFEncoder(config commons.Config) Encoder {
+	return &LEEFEncoder{
+		config: config,
+		batch:  make([]commons.EncodedData, 0, config.EventBuffer)}
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *LEEFEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.LEEFFormat] = NewLEEFEncoder
+}
+
+// Encode encodes telemetry records into LEEF events.
+func (t *LEEFEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		e := encodeSIEMEvent(rec)
+		t.batch = append(t.batch, e.record(t.encode(e)))
+	}
+	return t.batch, nil
+}
+
+// encode formats a LEEF event.
+func (t *LEEFEncoder) encode(e *siemEvent) []byte {
+	t.sb.Reset()
+	t.sb.WriteString(LEEF_VERSION)
+	for _, h := range []string{SIEM_VENDOR, SIEM_PRODUCT, t.config.Version, e.id, LEEF_DELIMITER} {
+		t.sb.WriteByte('|')
+		t.sb.WriteString(leefHeaderEscaper.Replace(h))
+	}
+	t.sb.WriteByte('|')
+	t.sb.WriteString("devTime=")
+	t.sb.WriteString(time.Unix(0, e.ts).UTC().Format(leefGoTimeFormat))
+	t.sb.WriteString("\tdevTimeFormat=")
+	t.sb.WriteString(LEEF_TIME_FORMAT)
+	t.sb.WriteString("\tsev=")
+	t.sb.WriteString(strconv.Itoa(siemSeverity(e.priority, 1)))
+	for _, a := range e.attrs {
+		t.sb.WriteByte('\t')
+		t.sb.WriteString(leefKeys[a.field])
+		t.sb.WriteByte('=')
+		t.sb.WriteString(leefValueEscaper.Replace(a.value))
+	}
+	return []byte(t.sb.String())
+}
+
+// Cleanup cleans up resources.
+func (t *LEEFEncoder) Cleanup() {}
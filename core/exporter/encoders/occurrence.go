@@ -24,19 +24,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"hash"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 	"time"
 
-	"github.com/cespare/xxhash/v2"
 	"github.com/linkedin/goavro"
 	cmap "github.com/orcaman/concurrent-map"
-	"github.com/steakknife/bloomfilter"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders/avro/occurrence/event"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
@@ -47,21 +45,17 @@ import (
 type EventPool struct {
 	CID           string
 	Events        []*Event
-	Filter        *bloomfilter.Filter
 	RuleTypes     *utils.Set
 	TopSeverity   Severity
 	LastFlushTime time.Time
 	encTs         int64
 	epw           *EventPoolWriter
+	clock         clock.Clock
 }
 
-// NewEventPool creates a new EventPool instace.
-func NewEventPool(cid string, ts int64) (ep *EventPool, err error) {
-	bf, err := bloomfilter.NewOptimal(maxElements, probCollide)
-	if err != nil {
-		return
-	}
-	return &EventPool{CID: cid, Filter: bf, RuleTypes: utils.NewSet(), TopSeverity: SeverityLow, encTs: ts}, nil
+// NewEventPool creates a new EventPool instace, aged according to clock clk.
+func NewEventPool(cid string, ts int64, clk clock.Clock) (ep *EventPool, err error) {
+	return &EventPool{CID: cid, RuleTypes: utils.NewSet(), TopSeverity: SeverityLow, LastFlushTime: clk.Now(), encTs: ts, clock: clk}, nil
 }
 
 // State returns a tuple summarizing the state of the event pool.
@@ -71,7 +65,7 @@ func (ep *EventPool) State() (int, Severity) {
 
 // Aged checks if event pool has aged.
 func (ep *EventPool) Aged(maxAge int) bool {
-	return time.Since(ep.LastFlushTime).Minutes() > float64(maxAge)
+	return ep.clock.Since(ep.LastFlushTime).Minutes() > float64(maxAge)
 }
 
 // ReachedCapacity indicates whether the pool has reached its configured event capacity.
@@ -79,12 +73,16 @@ func (ep *EventPool) ReachedCapacity(capacity int) bool {
 	return len(ep.Events) >= capacity
 }
 
-// Flush writes off event slice.
-func (ep *EventPool) Flush(pathPrefix string, s3Prefix string, clusterID string) (err error) {
+// Flush writes off event slice to the event file of the pool in store. Events are discarded if store is nil.
+func (ep *EventPool) Flush(store EventStorage, prefix string, clusterID string) (err error) {
 	var events []interface{}
+	var exportPath string
 	for _, v := range ep.Events {
-		exportPath := fmt.Sprintf("%s/%s", pathPrefix, v.getExportFilePath(s3Prefix, clusterID, ep.encTs))
-		if err = ep.UpdateEventPoolWriter(exportPath, v.Schema()); err != nil {
+		if store == nil {
+			break
+		}
+		exportPath = v.getExportFilePath(prefix, clusterID, ep.encTs)
+		if err = ep.UpdateEventPoolWriter(filepath.Join(store.Dir(), exportPath), v.Schema()); err != nil {
 			return
 		}
 		var m map[string]interface{}
@@ -97,23 +95,21 @@ func (ep *EventPool) Flush(pathPrefix string, s3Prefix string, clusterID string)
 			return
 		}
 		ep.epw.fw.Sync()
+		if err = store.Store(exportPath); err != nil {
+			return
+		}
 	}
 	ep.Events = nil
-	ep.LastFlushTime = time.Now()
+	ep.LastFlushTime = ep.clock.Now()
 	return
 }
 
-// Reset clears event slice and resets sketch counters and filter.
+// Reset clears event slice and resets sketch counters.
 func (ep *EventPool) Reset() (err error) {
-	bf, err := bloomfilter.NewOptimal(maxElements, probCollide)
-	if err != nil {
-		return
-	}
 	ep.Events = nil
-	ep.Filter = bf
 	ep.RuleTypes = utils.NewSet()
 	ep.TopSeverity = SeverityLow
-	ep.LastFlushTime = time.Now()
+	ep.LastFlushTime = ep.clock.Now()
 	return
 }
 
@@ -187,6 +183,9 @@ func (epw *EventPoolWriter) Append(events []interface{}) error {
 
 // Cleanup closes the event pool writer file writer.
 func (epw *EventPoolWriter) Cleanup() error {
+	if epw == nil || epw.fw == nil {
+		return nil
+	}
 	return epw.fw.Close()
 }
 
@@ -240,16 +239,25 @@ func (e *Event) getTimePartitions() (year int, month int, day int) {
 	return timeStamp.Year(), int(timeStamp.Month()), timeStamp.Day()
 }
 
-// Occurrence object for IBM Findings API.
+// Occurrence is an incident raised for the events of a container or host, when an event matches new
+// policies, raises the top severity of the events, or is semantically new. Occurrences are exported
+// to IBM Findings API, or as JSON objects by other transports.
 type Occurrence struct {
-	ID         string
-	ShortDescr string
-	LongDescr  string
-	Severity   Severity
-	Certainty  Certainty
-	ResType    string
-	ResName    string
-	AlertQuery string
+	ID          string    `json:"id"`
+	Ts          int64     `json:"ts"`
+	ShortDescr  string    `json:"short_description"`
+	LongDescr   string    `json:"long_description"`
+	Details     string    `json:"details,omitempty"`
+	Severity    Severity  `json:"severity"`
+	Certainty   Certainty `json:"certainty"`
+	ResType     string    `json:"resource_type"`
+	ResName     string    `json:"resource_name"`
+	ClusterID   string    `json:"cluster_id,omitempty"`
+	NodeID      string    `json:"node_id,omitempty"`
+	ContainerID string    `json:"container_id,omitempty"`
+	Rules       []string  `json:"rules,omitempty"`
+	Tags        []string  `json:"tags,omitempty"`
+	Events      string    `json:"events,omitempty"`
 }
 
 // NoteID returns the occurence note ID based on the occurrence's severity.
@@ -260,21 +268,35 @@ func (occ *Occurrence) NoteID() string {
 	return OFFENSE
 }
 
-// OccurrenceEncoder is an encoder for IBM Findings' occurrences.
+// OccurrenceEncoder is an encoder for occurrences. The events of each container are kept in an event pool,
+// whose events are written to the event storage, and an occurrence is created for the events that change the
+// state of the pool.
 type OccurrenceEncoder struct {
 	config      commons.Config
 	exportCache cmap.ConcurrentMap
 	batch       []commons.EncodedData
 	ts          int64
+	clock       clock.Clock
+	lastID      int64
+	store       EventStorage
+	dedupe      *dedupe
 }
 
 // NewOccurrenceEncoder creates a new Occurrence encoder.
 func NewOccurrenceEncoder(config commons.Config) Encoder {
+	clk := clock.New(config.Clock)
+	store, err := NewEventStorage(config)
+	if err != nil {
+		logger.Error.Printf("Unable to create occurrence event storage, events are not stored: %v", err)
+	}
 	return &OccurrenceEncoder{
 		config:      config,
 		exportCache: cmap.New(),
 		batch:       make([]commons.EncodedData, 0, config.EventBuffer),
-		ts:          time.Now().Unix()}
+		ts:          clk.Now().Unix(),
+		clock:       clk,
+		store:       store,
+		dedupe:      newDedupe(config.DedupeConfig, clk)}
 }
 
 // Register registers the encoder to the codecs cache.
@@ -303,6 +325,7 @@ func (oe *OccurrenceEncoder) Encode(recs []*engine.Record) ([]commons.EncodedDat
 
 // addEvent adds a record to export queue.
 func (oe *OccurrenceEncoder) addEvent(r *engine.Record) (e *Event, ep *EventPool, alert bool) {
+	oe.clock.Advance(engine.Mapper.MapInt(engine.SF_TS)(r))
 	cid := engine.Mapper.MapStr(engine.SF_CONTAINER_ID)(r)
 	ep = oe.getEventPool(cid)
 
@@ -318,9 +341,7 @@ func (oe *OccurrenceEncoder) addEvent(r *engine.Record) (e *Event, ep *EventPool
 	}
 
 	// check if a semantically equivalent record has been seen before
-	h := oe.semanticHash(r)
-	if !ep.Filter.Contains(h) {
-		ep.Filter.Add(h)
+	if oe.dedupe.novel(cid, r) {
 		alert = true
 	}
 
@@ -334,14 +355,15 @@ func (oe *OccurrenceEncoder) addEvent(r *engine.Record) (e *Event, ep *EventPool
 	// (1) an occurrence is generated for the current event, or
 	// (2) the event pool has reached its configured capacity, or
 	// (3) the event pool has aged.
-	full := ep.ReachedCapacity(oe.config.FindingsPoolCapacity)
-	aged := ep.Aged(oe.config.FindingsPoolMaxAge)
+	full := ep.ReachedCapacity(oe.config.OccPoolCapacity)
+	aged := ep.Aged(oe.config.OccPoolMaxAge)
 	if alert || full || aged {
-		if err := ep.Flush(oe.config.FindingsPath, oe.config.FindingsS3Prefix, oe.config.ClusterID); err != nil {
+		if err := ep.Flush(oe.store, oe.config.OccPrefix, oe.config.ClusterID); err != nil {
 			logger.Error.Println(err)
 		}
 		if aged {
 			ep.Reset()
+			oe.dedupe.reset(cid)
 		}
 	}
 
@@ -355,18 +377,39 @@ func (oe *OccurrenceEncoder) getEventPool(cid string) *EventPool {
 	if v, ok := m.Get(cid); ok {
 		ep = v.(*EventPool)
 	} else {
-		ep, _ = NewEventPool(cid, oe.ts)
+		if oe.ts == 0 {
+			// an event clock starts at the first record
+			oe.ts = oe.clock.Now().Unix()
+		}
+		ep, _ = NewEventPool(cid, oe.ts, oe.clock)
 		m.Set(cid, ep)
 	}
 	return ep
 }
 
+// nextID returns a unique occurrence ID suffix, derived from the current time in microseconds.
+// IDs are kept increasing, since an event clock may not move between occurrences.
+func (oe *OccurrenceEncoder) nextID() int64 {
+	id := oe.clock.Now().UnixNano() / 1000
+	if id <= oe.lastID {
+		id = oe.lastID + 1
+	}
+	oe.lastID = id
+	return id
+}
+
 // createOccurrence creates a new Occurence object.
 func (oe *OccurrenceEncoder) createOccurrence(e *Event, ep *EventPool) *Occurrence {
 	oc := new(Occurrence)
 	oc.Certainty = CertaintyMedium
-	oc.ID = fmt.Sprintf(noteIDStrFmt, ep.CID, time.Now().UTC().UnixNano()/1000)
-	envStr := e.getEnvDescription(oe.config.FindingsS3Prefix, oe.config.ClusterID)
+	oc.ID = fmt.Sprintf(noteIDStrFmt, ep.CID, oe.nextID())
+	oc.Ts = e.Ts
+	oc.ClusterID = e.ClusterID
+	oc.NodeID = e.NodeID
+	if ep.CID != sfgo.Zeros.String {
+		oc.ContainerID = ep.CID
+	}
+	envStr := e.getEnvDescription(oe.config.OccPrefix, oe.config.ClusterID)
 	if ep.CID != sfgo.Zeros.String {
 		oc.ResName = fmt.Sprintf("%s:%s [%s]", ep.CID, engine.Mapper.MapStr(engine.SF_CONTAINER_NAME)(e.Record), envStr)
 		oc.ResType = engine.Mapper.MapStr(engine.SF_CONTAINER_TYPE)(e.Record)
@@ -376,6 +419,7 @@ func (oe *OccurrenceEncoder) createOccurrence(e *Event, ep *EventPool) *Occurren
 	}
 	rnames, tags, severity := oe.summarizePolicy(e.Record)
 	oc.Severity = severity
+	oc.Rules, oc.Tags = rnames, tags
 	polStr := fmt.Sprintf(policiesStrFmt, strings.Join(rnames, listSep))
 	tagsStr := fmt.Sprintf(tagsStrFmt, strings.Join(tags, listSep))
 	var detStr string
@@ -408,8 +452,10 @@ func (oe *OccurrenceEncoder) createOccurrence(e *Event, ep *EventPool) *Occurren
 	}
 	oc.ShortDescr = shortDescr
 	oc.LongDescr = fmt.Sprintf(detailsStrFmt, encDetStr, polStr, tagsStr)
-	oc.AlertQuery = fmt.Sprintf(sqlQueryStrFmt, oe.config.FindingsS3Region, oe.config.FindingsS3Bucket,
-		e.getExportFilePath(oe.config.FindingsS3Prefix, oe.config.ClusterID, ep.encTs), oe.config.FindingsS3Region, oe.config.FindingsS3Bucket)
+	oc.Details = detStr
+	if oe.store != nil {
+		oc.Events = filepath.ToSlash(e.getExportFilePath(oe.config.OccPrefix, oe.config.ClusterID, ep.encTs))
+	}
 	return oc
 }
 
@@ -469,17 +515,6 @@ func (oe *OccurrenceEncoder) formatResource(r *engine.Record) (res string) {
 	return
 }
 
-// semanticHash computes a hash value over record attributes denoting the semantics of the record (used in the bloom filter).
-func (oe *OccurrenceEncoder) semanticHash(r *engine.Record) hash.Hash64 {
-	h := xxhash.New()
-	h.Write([]byte(engine.Mapper.MapStr(engine.SF_PROC_CMDLINE)(r)))
-	h.Write([]byte(engine.Mapper.MapStr(engine.SF_PROC_UID)(r)))
-	h.Write([]byte(engine.Mapper.MapStr(engine.SF_FILE_OID)(r)))
-	h.Write([]byte(engine.Mapper.MapStr(engine.SF_OPFLAGS)(r)))
-	h.Write([]byte(engine.Mapper.MapStr(engine.SF_PROC_TTY)(r)))
-	return h
-}
-
 // Cleanup cleans up resources.
 func (oe *OccurrenceEncoder) Cleanup() {
 	for _, v := range oe.exportCache.Items() {
@@ -3,14 +3,22 @@ package encoders_test
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/actgardner/gogen-avro/v7/container"
 	"github.com/linkedin/goavro"
 	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders/avro/occurrence/event"
 )
@@ -149,3 +157,164 @@ func TestGoavroEventSerialization(t *testing.T) {
 	fr.Close()
 	os.Remove(path)
 }
+
+func TestEventPoolAgedEventTime(t *testing.T) {
+	c := clock.NewEventClock()
+	c.Advance(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC).UnixNano())
+	ep, err := encoders.NewEventPool("cid", c.Now().Unix(), c)
+	assert.NoError(t, err)
+	assert.NoError(t, ep.Reset())
+	assert.False(t, ep.Aged(5))
+	c.Advance(c.Now().Add(5 * time.Minute).UnixNano())
+	assert.False(t, ep.Aged(5))
+	c.Advance(c.Now().Add(time.Second).UnixNano())
+	assert.True(t, ep.Aged(5))
+	assert.NoError(t, ep.Reset())
+	assert.False(t, ep.Aged(5))
+}
+
+func newOccurrenceTestConfig(storage commons.Storage, dir string) commons.Config {
+	return commons.Config{
+		Clock: clock.EventMode,
+		OccurrenceConfig: commons.OccurrenceConfig{
+			OccStorage:      storage,
+			OccPath:         dir,
+			OccPrefix:       "sysflow",
+			OccPoolCapacity: 250,
+			OccPoolMaxAge:   1440,
+			OccS3Region:     "us-east-1",
+			OccS3Bucket:     "events",
+		},
+	}
+}
+
+func countOCFRecords(t *testing.T, path string) (n int) {
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	r, err := goavro.NewOCFReader(f)
+	assert.NoError(t, err)
+	for r.Scan() {
+		_, err := r.Read()
+		assert.NoError(t, err)
+		n++
+	}
+	return
+}
+
+func TestOccurrenceEncoder(t *testing.T) {
+	dir := t.TempDir()
+	enc := encoders.NewOccurrenceEncoder(newOccurrenceTestConfig(commons.LocalStorage, dir))
+	defer enc.Cleanup()
+	data, err := enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	assert.Len(t, data, 3)
+
+	occ := data[0].(*encoders.Occurrence)
+	assert.Equal(t, "392abdfb220e", occ.ContainerID)
+	assert.Equal(t, "node1", occ.NodeID)
+	assert.Equal(t, testTs, occ.Ts)
+	assert.Equal(t, encoders.SeverityHigh, occ.Severity)
+	assert.Equal(t, []string{"shell", "pipe|shell"}, occ.Rules)
+	assert.Equal(t, []string{"mitre:T1059", "container", "shell"}, occ.Tags)
+	assert.Empty(t, data[1].(*encoders.Occurrence).ContainerID)
+
+	// context events are written to the event file of the container
+	assert.True(t, strings.HasPrefix(occ.Events, "sysflow/node1/10.0.0.5/"), occ.Events)
+	assert.True(t, strings.HasSuffix(occ.Events, fmt.Sprintf("/392abdfb220e_%d.avro", testTs/int64(time.Second))), occ.Events)
+	assert.Equal(t, 1, countOCFRecords(t, filepath.Join(dir, occ.Events)))
+
+	// occurrences are serialized as JSON objects for transports other than IBM Findings
+	buf, err := json.Marshal(occ)
+	assert.NoError(t, err)
+	var m map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf, &m))
+	assert.Equal(t, "HIGH", m["severity"])
+	assert.Equal(t, "MEDIUM", m["certainty"])
+	assert.Equal(t, occ.Events, m["events"])
+
+}
+
+// testObjectStore is a fake S3-compatible object store recording uploaded objects.
+type testObjectStore struct {
+	mu      sync.Mutex
+	objects map[string]int
+}
+
+func (s *testObjectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusNotImplemented)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	s.objects[r.URL.Path] = len(body)
+	w.Header().Set("ETag", `"d41d8cd98f00b204e9800998ecf8427e"`)
+}
+
+func TestOccurrenceEncoderStorage(t *testing.T) {
+	// events are discarded without storage
+	dir := t.TempDir()
+	enc := encoders.NewOccurrenceEncoder(newOccurrenceTestConfig(commons.NoStorage, dir))
+	data, err := enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	assert.Len(t, data, 3)
+	assert.Empty(t, data[0].(*encoders.Occurrence).Events)
+	enc.Cleanup()
+	files, _ := os.ReadDir(dir)
+	assert.Empty(t, files)
+
+	// event files are uploaded to the object store when events are appended to them
+	s := &testObjectStore{objects: make(map[string]int)}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	config := newOccurrenceTestConfig(commons.S3Storage, dir)
+	config.OccS3Endpoint = strings.TrimPrefix(srv.URL, "http://")
+	config.OccS3Secure = false
+	enc = encoders.NewOccurrenceEncoder(config)
+	data, err = enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	enc.Cleanup()
+	occ := data[0].(*encoders.Occurrence)
+	fi, err := os.Stat(filepath.Join(dir, occ.Events))
+	assert.NoError(t, err)
+	assert.Len(t, s.objects, 2)
+	assert.Equal(t, int(fi.Size()), s.objects["/events/"+occ.Events])
+}
+
+func TestOccurrenceConfig(t *testing.T) {
+	// deprecated findings keys are read if occurrence keys are not set
+	c, err := commons.CreateConfig(map[string]interface{}{"format": "occurrence", "findings.path": "/tmp/occ", "findings.pool.capacity": "10", "occurrence.pool.capacity": "20"})
+	assert.NoError(t, err)
+	assert.Equal(t, commons.LocalStorage, c.OccStorage)
+	assert.Equal(t, "/tmp/occ", c.OccPath)
+	assert.Equal(t, 20, c.OccPoolCapacity)
+
+	_, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3"})
+	assert.Error(t, err)
+	c, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3", "occurrence.s3.bucket": "events"})
+	assert.NoError(t, err)
+	assert.Equal(t, commons.S3Storage, c.OccStorage)
+	assert.True(t, c.OccS3Secure)
+
+	// the object store is validated on startup
+	_, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3", "occurrence.s3.bucket": "events", "occurrence.s3.endpoint": "http://minio:9000"})
+	assert.Error(t, err)
+	_, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3", "occurrence.s3.bucket": "Events_"})
+	assert.Error(t, err)
+	_, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.path": ""})
+	assert.Error(t, err)
+
+	// a secret key missing from the vault leaves credentials to the environment
+	vault := t.TempDir()
+	c, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3", "occurrence.s3.bucket": "events", "vault.secrets": "true", "vault.path": vault})
+	assert.NoError(t, err)
+	assert.Empty(t, c.OccS3SecretKey)
+	assert.NoError(t, os.WriteFile(filepath.Join(vault, commons.OccS3SecretKeyConfigKey), []byte("secret\n"), 0600))
+	c, err = commons.CreateConfig(map[string]interface{}{"format": "occurrence", "occurrence.storage": "s3", "occurrence.s3.bucket": "events", "vault.secrets": "true", "vault.path": vault})
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", c.OccS3SecretKey)
+}
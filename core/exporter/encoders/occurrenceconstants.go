@@ -41,8 +41,6 @@ const (
 	noteIDStrFmt   = "%s-%d"
 	connStrFmt     = "%s:%d-%s:%d"
 
-	sqlQueryStrFmt = "SELECT * FROM cos://%s/%s/%s STORED AS AVRO LIMIT 500 INTO cos://%s/%s/sql-query"
-
 	listSep = ","
 
 	hostFileName = "host"
@@ -65,12 +63,6 @@ func NoteIDs() *utils.Set {
 	return s
 }
 
-// Bloom filter settings.
-const (
-	maxElements = 100000
-	probCollide = 0.0000001
-)
-
 // Severity type for enumeration.
 type Severity int
 
@@ -86,6 +78,11 @@ func (s Severity) String() string {
 	return [...]string{"LOW", "MEDIUM", "HIGH"}[s]
 }
 
+// MarshalText encodes a severity as its string representation.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
 // Certainty type for enumeration.
 type Certainty int
 
@@ -100,3 +97,8 @@ const (
 func (s Certainty) String() string {
 	return [...]string{"LOW", "MEDIUM", "HIGH"}[s]
 }
+
+// MarshalText encodes a certainty as its string representation.
+func (s Certainty) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
@@ -0,0 +1,218 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// OTLP attribute names. Resource attributes follow the OpenTelemetry semantic conventions.
+const (
+	OTLP_HOST_NAME            = "host.name"
+	OTLP_HOST_IP              = "host.ip"
+	OTLP_CLUSTER_NAME         = "k8s.cluster.name"
+	OTLP_CONTAINER_ID         = "container.id"
+	OTLP_CONTAINER_NAME       = "container.name"
+	OTLP_CONTAINER_RUNTIME    = "container.runtime"
+	OTLP_CONTAINER_IMAGE_NAME = "container.image.name"
+	OTLP_POD_UID              = "k8s.pod.uid"
+	OTLP_POD_NAME             = "k8s.pod.name"
+	OTLP_NAMESPACE_NAME       = "k8s.namespace.name"
+	OTLP_NODE_NAME            = "k8s.node.name"
+	OTLP_PROC_EXE             = "process.executable.path"
+	OTLP_PROC_PID             = "process.pid"
+	OTLP_PROC_CMDLINE         = "process.command_line"
+	OTLP_SF_TYPE              = "sf.type"
+	OTLP_SF_OPFLAGS           = "sf.opflags"
+	OTLP_SF_ALERT             = "sf.alert"
+	OTLP_SF_RULES             = "sf.rules"
+	OTLP_SF_RULE_DESCS        = "sf.rules.desc"
+	OTLP_SF_TAGS              = "sf.tags"
+)
+
+// OTLPRecord is an OpenTelemetry log record along with the resource that produced it.
+// Records of a batch produced by the same resource share the same Resource object.
+type OTLPRecord struct {
+	Resource *resourcepb.Resource
+	Log      *logspb.LogRecord
+}
+
+// OTLPEncoder encodes records into OpenTelemetry log records. The log body holds
+// the JSON representation of a record, and the attributes identify the record type,
+// process, and the policies matched by alerts. The severity of alerts is derived
+// from the highest priority of the matched policies.
+type OTLPEncoder struct {
+	config    commons.Config
+	json      *JSONEncoder
+	resources map[string]*resourcepb.Resource
+	batch     []commons.EncodedData
+}
+
+// NewOTLPEncoder instantiates an OTLP encoder.
+func NewOTLPEncoder(config commons.Config) Encoder {
+	return &OTLPEncoder{
+		config:    config,
+		json:      NewJSONEncoder(config).(*JSONEncoder),
+		resources: make(map[string]*resourcepb.Resource),
+		batch:     make([]commons.EncodedData, 0, config.EventBuffer)}
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *OTLPEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.OTLPFormat] = NewOTLPEncoder
+}
+
+// Encode encodes telemetry records into OpenTelemetry log records.
+func (t *OTLPEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	t.batch = t.batch[:0]
+	for k := range t.resources {
+		delete(t.resources, k)
+	}
+	observed := uint64(time.Now().UnixNano())
+	for _, rec := range recs {
+		body, err := t.json.encode(rec)
+		if err != nil {
+			return nil, err
+		}
+		log := &logspb.LogRecord{
+			TimeUnixNano:         uint64(engine.Mapper.MapInt(engine.SF_TS)(rec)),
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       logspb.SeverityNumber_SEVERITY_NUMBER_INFO,
+			Body:                 stringValue(string(body.([]byte))),
+			Attributes:           encodeOTLPAttributes(rec),
+		}
+
+		// encode tags and policy information
+		tags := rec.Ctx.GetTags()
+		if rules := rec.Ctx.GetRules(); len(rules) > 0 {
+			names := make([]string, len(rules))
+			descs := make([]string, len(rules))
+			priority := int(engine.Low)
+			for i, r := range rules {
+				names[i], descs[i] = r.Name, r.Desc
+				tags = append(tags, extracTags(r.Tags)...)
+				priority = utils.Max(priority, int(r.Priority))
+			}
+			log.SeverityNumber = otlpSeverities[engine.Priority(priority)]
+			log.SeverityText = engine.Priority(priority).String()
+			log.Attributes = append(log.Attributes,
+				&commonpb.KeyValue{Key: OTLP_SF_RULES, Value: stringArrayValue(names)},
+				&commonpb.KeyValue{Key: OTLP_SF_RULE_DESCS, Value: stringArrayValue(descs)})
+		}
+		if len(tags) > 0 {
+			log.Attributes = append(log.Attributes, &commonpb.KeyValue{Key: OTLP_SF_TAGS, Value: stringArrayValue(tags)})
+		}
+		t.batch = append(t.batch, &OTLPRecord{Resource: t.encodeResource(rec), Log: log})
+	}
+	return t.batch, nil
+}
+
+// otlpSeverities maps rule priorities to log severities.
+var otlpSeverities = map[engine.Priority]logspb.SeverityNumber{
+	engine.Low:    logspb.SeverityNumber_SEVERITY_NUMBER_WARN,
+	engine.Medium: logspb.SeverityNumber_SEVERITY_NUMBER_ERROR,
+	engine.High:   logspb.SeverityNumber_SEVERITY_NUMBER_FATAL,
+}
+
+// encodeResource returns the resource of a record, i.e., its node, cluster, container and pod.
+func (t *OTLPEncoder) encodeResource(rec *engine.Record) *resourcepb.Resource {
+	attrs := make([]*commonpb.KeyValue, 0, 12)
+	addStr := func(k string, v string) {
+		if v != sfgo.Zeros.String {
+			attrs = append(attrs, &commonpb.KeyValue{Key: k, Value: stringValue(v)})
+		}
+	}
+	addStr(OTLP_HOST_NAME, engine.Mapper.MapStr(engine.SF_NODE_ID)(rec))
+	addStr(OTLP_HOST_IP, engine.Mapper.MapStr(engine.SF_NODE_IP)(rec))
+	addStr(OTLP_CLUSTER_NAME, t.config.ClusterID)
+	if engine.Mapper.MapStr(engine.SF_TYPE)(rec) != sfgo.TyKEStr {
+		if cid := engine.Mapper.MapStr(engine.SF_CONTAINER_ID)(rec); cid != sfgo.Zeros.String {
+			addStr(OTLP_CONTAINER_ID, cid)
+			addStr(OTLP_CONTAINER_NAME, engine.Mapper.MapStr(engine.SF_CONTAINER_NAME)(rec))
+			addStr(OTLP_CONTAINER_RUNTIME, engine.Mapper.MapStr(engine.SF_CONTAINER_TYPE)(rec))
+			addStr(OTLP_CONTAINER_IMAGE_NAME, engine.Mapper.MapStr(engine.SF_CONTAINER_IMAGE)(rec))
+		}
+		if pid := engine.Mapper.MapStr(engine.SF_POD_ID)(rec); pid != sfgo.Zeros.String {
+			addStr(OTLP_POD_UID, pid)
+			addStr(OTLP_POD_NAME, engine.Mapper.MapStr(engine.SF_POD_NAME)(rec))
+			addStr(OTLP_NAMESPACE_NAME, engine.Mapper.MapStr(engine.SF_POD_NAMESPACE)(rec))
+			addStr(OTLP_NODE_NAME, engine.Mapper.MapStr(engine.SF_POD_NODENAME)(rec))
+		}
+	}
+
+	// records with the same attributes share the same resource
+	var key strings.Builder
+	for _, a := range attrs {
+		key.WriteString(a.Key)
+		key.WriteByte(0)
+		key.WriteString(a.Value.GetStringValue())
+		key.WriteByte(0)
+	}
+	if r, ok := t.resources[key.String()]; ok {
+		return r
+	}
+	r := &resourcepb.Resource{Attributes: attrs}
+	t.resources[key.String()] = r
+	return r
+}
+
+// encodeOTLPAttributes returns the attributes of a log record.
+func encodeOTLPAttributes(rec *engine.Record) []*commonpb.KeyValue {
+	sfType := engine.Mapper.MapStr(engine.SF_TYPE)(rec)
+	attrs := []*commonpb.KeyValue{
+		{Key: OTLP_SF_TYPE, Value: stringValue(sfType)},
+		{Key: OTLP_SF_ALERT, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: rec.Ctx.IsAlert()}}},
+	}
+	if opflags := engine.Mapper.MapStr(engine.SF_OPFLAGS)(rec); opflags != sfgo.Zeros.String {
+		attrs = append(attrs, &commonpb.KeyValue{Key: OTLP_SF_OPFLAGS, Value: stringValue(opflags)})
+	}
+	if sfType != sfgo.TyKEStr {
+		attrs = append(attrs,
+			&commonpb.KeyValue{Key: OTLP_PROC_EXE, Value: stringValue(engine.Mapper.MapStr(engine.SF_PROC_EXE)(rec))},
+			&commonpb.KeyValue{Key: OTLP_PROC_PID, Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: engine.Mapper.MapInt(engine.SF_PROC_PID)(rec)}}},
+			&commonpb.KeyValue{Key: OTLP_PROC_CMDLINE, Value: stringValue(engine.Mapper.MapStr(engine.SF_PROC_CMDLINE)(rec))})
+	}
+	return attrs
+}
+
+func stringValue(s string) *commonpb.AnyValue {
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: s}}
+}
+
+func stringArrayValue(l []string) *commonpb.AnyValue {
+	values := make([]*commonpb.AnyValue, len(l))
+	for i, s := range l {
+		values[i] = stringValue(s)
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}}
+}
+
+// Cleanup cleans up resources.
+func (t *OTLPEncoder) Cleanup() {}
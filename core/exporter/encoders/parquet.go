@@ -0,0 +1,450 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// ParquetRow is a record encoded as a Parquet row, along with the attributes the row is partitioned by.
+type ParquetRow struct {
+	Ts     int64       // record timestamp
+	NodeID string      // node ID
+	Value  interface{} // pointer to a struct described by ParquetSchema
+}
+
+// ParquetEncoder encodes records into Parquet rows. Rows hold the attributes exported by the JSON
+// encoder: top-level attributes are columns, and record sections (e.g., proc, file, container) are
+// optional groups, which are null for records without the section.
+type ParquetEncoder struct {
+	batch []commons.EncodedData
+}
+
+// NewParquetEncoder instantiates a Parquet encoder.
+func NewParquetEncoder(config commons.Config) Encoder {
+	return &ParquetEncoder{batch: make([]commons.EncodedData, 0, config.EventBuffer)}
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *ParquetEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.ParquetFormat] = NewParquetEncoder
+}
+
+// Encode encodes telemetry records into Parquet rows.
+func (t *ParquetEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		t.batch = append(t.batch, &ParquetRow{
+			Ts:     engine.Mapper.MapInt(engine.SF_TS)(rec),
+			NodeID: engine.Mapper.MapStr(engine.SF_NODE_ID)(rec),
+			Value:  pqSchema.encode(rec),
+		})
+	}
+	return t.batch, nil
+}
+
+// Cleanup cleans up resources.
+func (t *ParquetEncoder) Cleanup() {}
+
+// ParquetSchema returns a pointer to an empty row, whose struct tags describe the Parquet schema of encoded rows.
+func ParquetSchema() interface{} {
+	return reflect.New(pqSchema.row).Interface()
+}
+
+// pqKind defines the value type of a Parquet column.
+type pqKind uint8
+
+// Parquet column value types.
+const (
+	pqString pqKind = iota
+	pqInt
+	pqBool
+	pqStrList
+	pqIntList
+	pqSvcList
+)
+
+// pqTags maps column value types to Parquet struct tags.
+var pqTags = map[pqKind]string{
+	pqString:  "name=%s, type=BYTE_ARRAY, convertedtype=UTF8, encoding=PLAIN_DICTIONARY",
+	pqInt:     "name=%s, type=INT64",
+	pqBool:    "name=%s, type=BOOLEAN",
+	pqStrList: "name=%s, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8, valueencoding=PLAIN_DICTIONARY",
+	pqIntList: "name=%s, type=LIST, valuetype=INT64",
+	pqSvcList: "name=%s, type=LIST",
+}
+
+// pqTypes maps column value types to Go types.
+var pqTypes = map[pqKind]reflect.Type{
+	pqString:  reflect.TypeOf(""),
+	pqInt:     reflect.TypeOf(int64(0)),
+	pqBool:    reflect.TypeOf(false),
+	pqStrList: reflect.TypeOf([]string{}),
+	pqIntList: reflect.TypeOf([]int64{}),
+	pqSvcList: reflect.TypeOf([]pqService{}),
+}
+
+// pqSections maps record sections to the names of their groups, which are the ones used by the JSON encoder.
+var pqSections = map[engine.SectionType]string{
+	engine.SectProc:   PROC,
+	engine.SectPProc:  PPROC,
+	engine.SectNet:    NET,
+	engine.SectFile:   FILEF,
+	engine.SectFlow:   FLOW,
+	engine.SectCont:   CONTAINER,
+	engine.SectPod:    POD,
+	engine.SectK8sEvt: KE,
+	engine.SectNode:   NODE,
+	engine.SectMeta:   META,
+}
+
+// pqPort is a Parquet row element for service ports.
+type pqPort struct {
+	Port       int32  `parquet:"name=port, type=INT32"`
+	TargetPort int32  `parquet:"name=targetport, type=INT32"`
+	NodePort   int32  `parquet:"name=nodeport, type=INT32"`
+	Proto      string `parquet:"name=proto, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// pqService is a Parquet row element for pod services.
+type pqService struct {
+	ID        string   `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name      string   `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Namespace string   `parquet:"name=namespace, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ClusterIP []string `parquet:"name=clusterIP, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Ports     []pqPort `parquet:"name=ports, type=LIST"`
+}
+
+// pqPolicy is a Parquet row element for the policies matched by a record.
+type pqPolicy struct {
+	ID       string `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Desc     string `parquet:"name=desc, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Priority int64  `parquet:"name=priority, type=INT64"`
+}
+
+// pqColumn maps an exported attribute to a column of a row or group struct.
+type pqColumn struct {
+	fv    *engine.FieldValue
+	name  string
+	kind  pqKind
+	field int
+}
+
+// pqGroup maps a record section to an optional group of a row struct.
+type pqGroup struct {
+	section engine.SectionType
+	typ     reflect.Type
+	field   int
+	columns []pqColumn
+}
+
+// pqRowSchema describes the struct type of Parquet rows, which is built from the exported attributes.
+type pqRowSchema struct {
+	row      reflect.Type
+	columns  []pqColumn
+	groups   []*pqGroup
+	policies int
+	tags     int
+}
+
+// pqSchema is the schema of the rows produced by the Parquet encoder.
+var pqSchema = newPQRowSchema(engine.FieldValues)
+
+// newPQRowSchema builds a row struct type for the exported attributes fvs.
+func newPQRowSchema(fvs []*engine.FieldValue) *pqRowSchema {
+	s := &pqRowSchema{}
+	var fields []reflect.StructField
+	groupFields := make(map[engine.SectionType][]reflect.StructField)
+	groups := make(map[engine.SectionType]*pqGroup)
+	for _, fv := range fvs {
+		name := strings.TrimSuffix(fv.FieldSects[len(fv.FieldSects)-1], "+")
+		kind := getPQKind(fv)
+		if len(fv.FieldSects) == 2 {
+			s.columns = append(s.columns, pqColumn{fv: fv, name: name, kind: kind, field: len(fields)})
+			fields = append(fields, newPQField(len(fields), fmt.Sprintf(pqTags[kind], name), pqTypes[kind]))
+			continue
+		}
+		if _, ok := pqSections[fv.Entry.Section]; !ok {
+			// not exported by the JSON encoder either (e.g., JSON-encoded pod attributes)
+			continue
+		}
+		g, ok := groups[fv.Entry.Section]
+		if !ok {
+			g = &pqGroup{section: fv.Entry.Section}
+			groups[fv.Entry.Section] = g
+			s.groups = append(s.groups, g)
+		}
+		gf := groupFields[fv.Entry.Section]
+		g.columns = append(g.columns, pqColumn{fv: fv, name: name, kind: kind, field: len(gf)})
+		groupFields[fv.Entry.Section] = append(gf, newPQField(len(gf), fmt.Sprintf(pqTags[kind], name), pqTypes[kind]))
+	}
+	for _, g := range s.groups {
+		g.typ = reflect.StructOf(groupFields[g.section])
+		g.field = len(fields)
+		fields = append(fields, newPQField(len(fields), "name="+pqSections[g.section], reflect.PtrTo(g.typ)))
+	}
+	s.policies = len(fields)
+	fields = append(fields, newPQField(len(fields), "name="+POLICIES_ATTR+", type=LIST", reflect.TypeOf([]pqPolicy{})))
+	s.tags = len(fields)
+	fields = append(fields, newPQField(len(fields), fmt.Sprintf(pqTags[pqStrList], TAGS_ATTR), pqTypes[pqStrList]))
+	s.row = reflect.StructOf(fields)
+	return s
+}
+
+// newPQField creates the i-th field of a row or group struct.
+func newPQField(i int, tag string, typ reflect.Type) reflect.StructField {
+	return reflect.StructField{Name: fmt.Sprintf("F%d", i), Type: typ, Tag: reflect.StructTag(`parquet:"` + tag + `"`)}
+}
+
+// getPQKind returns the column value type of an exported attribute, following the JSON encoder.
+func getPQKind(fv *engine.FieldValue) pqKind {
+	switch fv.FieldName {
+	case engine.SF_PPROC_TTY, engine.SF_PPROC_ENTRY:
+		return pqBool
+	case engine.SF_NET_PORT:
+		return pqIntList
+	case engine.SF_POD_HOSTIP, engine.SF_POD_INTERNALIP:
+		return pqStrList
+	}
+	switch fv.Entry.Type {
+	case engine.MapIntVal, engine.MapSpecialInt:
+		return pqInt
+	case engine.MapBoolVal, engine.MapSpecialBool:
+		return pqBool
+	case engine.MapArrayStr:
+		return pqStrList
+	case engine.MapArrayInt:
+		return pqIntList
+	case engine.MapArraySvc:
+		return pqSvcList
+	}
+	return pqString
+}
+
+// encode converts a record into a row. Sections are omitted for the same records as in the JSON encoder.
+func (s *pqRowSchema) encode(rec *engine.Record) interface{} {
+	row := reflect.New(s.row)
+	v := row.Elem()
+	for _, c := range s.columns {
+		v.Field(c.field).Set(reflect.ValueOf(mapParquet(c, rec)))
+	}
+	sftype := engine.Mapper.MapStr(engine.SF_TYPE)(rec)
+	for _, g := range s.groups {
+		if !hasSection(g.section, sftype, rec) {
+			continue
+		}
+		gv := reflect.New(g.typ)
+		for _, c := range g.columns {
+			gv.Elem().Field(c.field).Set(reflect.ValueOf(mapParquet(c, rec)))
+		}
+		v.Field(g.field).Set(gv)
+	}
+	policies, tags := getPolicies(rec)
+	v.Field(s.policies).Set(reflect.ValueOf(policies))
+	v.Field(s.tags).Set(reflect.ValueOf(tags))
+	return row.Interface()
+}
+
+// getPolicies returns the policies matched by a record, and the tags of the record and its policies.
+func getPolicies(rec *engine.Record) ([]pqPolicy, []string) {
+	policies := make([]pqPolicy, 0, len(rec.Ctx.GetRules()))
+	tags := append([]string{}, rec.Ctx.GetTags()...)
+	for _, r := range rec.Ctx.GetRules() {
+		policies = append(policies, pqPolicy{ID: r.Name, Desc: r.Desc, Priority: int64(r.Priority)})
+		for _, tag := range r.Tags {
+			switch tag := tag.(type) {
+			case []string:
+				tags = append(tags, tag...)
+			default:
+				tags = append(tags, tag.(string))
+			}
+		}
+	}
+	return policies, tags
+}
+
+// hasSection checks whether a record of type sftype has attributes in section.
+func hasSection(section engine.SectionType, sftype string, rec *engine.Record) bool {
+	if sftype == sfgo.TyKEStr {
+		return section == engine.SectK8sEvt || section == engine.SectNode || section == engine.SectMeta
+	}
+	switch section {
+	case engine.SectPProc:
+		return engine.Mapper.MapInt(engine.SF_PPROC_PID)(rec) != 0
+	case engine.SectNet:
+		return sftype == sfgo.TyNFStr
+	case engine.SectFile:
+		return sftype == sfgo.TyFFStr || sftype == sfgo.TyFEStr
+	case engine.SectFlow:
+		return sftype == sfgo.TyFFStr || sftype == sfgo.TyNFStr
+	case engine.SectCont:
+		return engine.Mapper.MapStr(engine.SF_CONTAINER_ID)(rec) != sfgo.Zeros.String
+	case engine.SectPod:
+		return engine.Mapper.MapStr(engine.SF_POD_ID)(rec) != sfgo.Zeros.String
+	case engine.SectK8sEvt:
+		return false
+	}
+	return true
+}
+
+// mapParquet returns the value of an exported attribute for column c.
+func mapParquet(c pqColumn, r *engine.Record) interface{} {
+	fv := c.fv
+	switch fv.Entry.FlatIndex {
+	case engine.A_IDS, engine.PARENT_IDS:
+		return getCachedValue(r, fv.Entry.AuxAttr, c.kind)
+	}
+	switch c.kind {
+	case pqString:
+		if fv.Entry.Type == engine.MapStrVal {
+			return utils.TrimBoundingQuotes(r.GetStr(fv.Entry.FlatIndex, fv.Entry.Source))
+		}
+		v, _ := fv.Entry.Map(r).(string)
+		return utils.TrimBoundingQuotes(v)
+	case pqInt:
+		if fv.Entry.Type == engine.MapIntVal {
+			return r.GetInt(fv.Entry.FlatIndex, fv.Entry.Source)
+		}
+		v, _ := fv.Entry.Map(r).(int64)
+		return v
+	case pqBool:
+		if fv.Entry.Type == engine.MapBoolVal {
+			return r.GetInt(fv.Entry.FlatIndex, fv.Entry.Source) == 1
+		}
+		v, _ := fv.Entry.Map(r).(bool)
+		return v
+	case pqStrList:
+		switch fv.FieldName {
+		case engine.SF_OPFLAGS:
+			rtype, _ := sfgo.ParseRecordType(r.GetInt(sfgo.SF_REC_TYPE, fv.Entry.Source))
+			return sfgo.GetOpFlags(int32(r.GetInt(fv.Entry.FlatIndex, fv.Entry.Source)), rtype)
+		case engine.SF_FILE_OPENFLAGS:
+			return sfgo.GetOpenFlags(r.GetInt(fv.Entry.FlatIndex, fv.Entry.Source))
+		case engine.SF_NET_IP:
+			return []string{
+				sfgo.GetIPStr(int32(r.GetInt(sfgo.FL_NETW_SIP_INT, fv.Entry.Source))),
+				sfgo.GetIPStr(int32(r.GetInt(sfgo.FL_NETW_DIP_INT, fv.Entry.Source))),
+			}
+		case engine.SF_POD_HOSTIP, engine.SF_POD_INTERNALIP:
+			ips := []string{}
+			if v := r.GetIntArray(fv.Entry.FlatIndex, fv.Entry.Source); v != nil {
+				for _, ip := range *v {
+					ips = append(ips, sfgo.GetIPStr(int32(ip)))
+				}
+			}
+			return ips
+		}
+		v, _ := fv.Entry.Map(r).(string)
+		return []string{v}
+	case pqIntList:
+		if fv.FieldName == engine.SF_NET_PORT {
+			return []int64{r.GetInt(sfgo.FL_NETW_SPORT_INT, fv.Entry.Source), r.GetInt(sfgo.FL_NETW_DPORT_INT, fv.Entry.Source)}
+		}
+		if v := r.GetIntArray(fv.Entry.FlatIndex, fv.Entry.Source); v != nil {
+			return append([]int64{}, *v...)
+		}
+		return []int64{}
+	case pqSvcList:
+		svcs := []pqService{}
+		if v := r.GetSvcArray(fv.Entry.FlatIndex, fv.Entry.Source); v != nil {
+			for _, s := range *v {
+				svc := pqService{ID: s.Id, Name: s.Name, Namespace: s.Namespace, ClusterIP: []string{}, Ports: []pqPort{}}
+				for _, ip := range s.ClusterIP {
+					svc.ClusterIP = append(svc.ClusterIP, sfgo.GetIPStr(int32(ip)))
+				}
+				for _, p := range s.PortList {
+					svc.Ports = append(svc.Ports, pqPort{Port: p.Port, TargetPort: p.TargetPort, NodePort: p.NodePort, Proto: p.Proto})
+				}
+				svcs = append(svcs, svc)
+			}
+		}
+		return svcs
+	}
+	return nil
+}
+
+// getCachedValue returns the value of a parent process or ancestry attribute for a column of type kind.
+func getCachedValue(r *engine.Record, attr engine.RecAttribute, kind pqKind) interface{} {
+	ptree := r.Fr.Ptree
+	if attr == engine.ProcAName || attr == engine.ProcAExe || attr == engine.ProcACmdLine || attr == engine.ProcAPID {
+		strs, ints := []string{}, []int64{}
+		for _, p := range ptree {
+			switch attr {
+			case engine.ProcAName:
+				strs = append(strs, utils.TrimBoundingQuotes(filepath.Base(p.Exe)))
+			case engine.ProcAExe:
+				strs = append(strs, utils.TrimBoundingQuotes(p.Exe))
+			case engine.ProcACmdLine:
+				strs = append(strs, getCmdLine(p))
+			case engine.ProcAPID:
+				ints = append(ints, p.Oid.Hpid)
+			}
+		}
+		if kind == pqIntList {
+			return ints
+		}
+		return strs
+	}
+	if len(ptree) < 2 {
+		return reflect.Zero(pqTypes[kind]).Interface()
+	}
+	p := ptree[1]
+	switch attr {
+	case engine.PProcName:
+		return utils.TrimBoundingQuotes(filepath.Base(p.Exe))
+	case engine.PProcExe:
+		return utils.TrimBoundingQuotes(p.Exe)
+	case engine.PProcArgs:
+		return utils.TrimBoundingQuotes(p.ExeArgs)
+	case engine.PProcUID:
+		return int64(p.Uid)
+	case engine.PProcUser:
+		return utils.TrimBoundingQuotes(p.UserName)
+	case engine.PProcGID:
+		return int64(p.Gid)
+	case engine.PProcGroup:
+		return utils.TrimBoundingQuotes(p.GroupName)
+	case engine.PProcTTY:
+		return p.Tty
+	case engine.PProcEntry:
+		return p.Entry
+	case engine.PProcCmdLine:
+		return getCmdLine(p)
+	}
+	return reflect.Zero(pqTypes[kind]).Interface()
+}
+
+// getCmdLine returns the command line of process p.
+func getCmdLine(p *sfgo.Process) string {
+	exe := utils.TrimBoundingQuotes(p.Exe)
+	if exeArgs := utils.TrimBoundingQuotes(p.ExeArgs); len(exeArgs) > 0 {
+		return exe + " " + exeArgs
+	}
+	return exe
+}
@@ -0,0 +1,298 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// ProtobufEncoder encodes records into Protocol Buffers messages, with the fields of the records of the
+// Avro encoder. Messages are built from the descriptor of the record schema, which is published as a
+// .proto file; record sections are message fields, which are unset for records without the section.
+type ProtobufEncoder struct {
+	version string
+	record  protoreflect.MessageDescriptor
+	err     error
+	batch   []commons.EncodedData
+}
+
+// NewProtobufEncoder instantiates a Protobuf encoder.
+func NewProtobufEncoder(config commons.Config) Encoder {
+	t := &ProtobufEncoder{version: config.JSONSchemaVersion, batch: make([]commons.EncodedData, 0, config.EventBuffer)}
+	t.record, t.err = ProtobufDescriptor(config.JSONSchemaVersion)
+	return t
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *ProtobufEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.ProtobufFormat] = NewProtobufEncoder
+}
+
+// Encode encodes telemetry records into Protobuf messages.
+func (t *ProtobufEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	if t.err != nil {
+		return nil, t.err
+	}
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		buf, err := proto.Marshal(t.encode(rec))
+		if err != nil {
+			return nil, err
+		}
+		t.batch = append(t.batch, newBinaryRecord(rec, buf))
+	}
+	return t.batch, nil
+}
+
+// encode converts a record into a dynamic message of the record schema.
+func (t *ProtobufEncoder) encode(rec *engine.Record) proto.Message {
+	m := dynamicpb.NewMessage(t.record)
+	fields := t.record.Fields()
+	m.Set(fields.ByName(versionAttr), protoreflect.ValueOfString(t.version))
+	for _, c := range pqSchema.columns {
+		setProtobuf(m, c, rec)
+	}
+	sftype := engine.Mapper.MapStr(engine.SF_TYPE)(rec)
+	for _, g := range pqSchema.groups {
+		if !hasSection(g.section, sftype, rec) {
+			continue
+		}
+		gm := m.Mutable(fields.ByName(protoreflect.Name(pqSections[g.section]))).Message()
+		for _, c := range g.columns {
+			setProtobuf(gm, c, rec)
+		}
+	}
+	policies, tags := getPolicies(rec)
+	pl := m.Mutable(fields.ByName(POLICIES_ATTR)).List()
+	for _, p := range policies {
+		e := pl.NewElement()
+		pm := e.Message()
+		pfields := pm.Descriptor().Fields()
+		pm.Set(pfields.ByName("id"), protoreflect.ValueOfString(p.ID))
+		pm.Set(pfields.ByName("desc"), protoreflect.ValueOfString(p.Desc))
+		pm.Set(pfields.ByName("priority"), protoreflect.ValueOfInt64(p.Priority))
+		pl.Append(e)
+	}
+	tl := m.Mutable(fields.ByName(TAGS_ATTR)).List()
+	for _, tag := range tags {
+		tl.Append(protoreflect.ValueOfString(tag))
+	}
+	return m
+}
+
+// setProtobuf sets the field of message m for the exported attribute of column c.
+func setProtobuf(m protoreflect.Message, c pqColumn, rec *engine.Record) {
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(c.name))
+	switch v := mapParquet(c, rec).(type) {
+	case string:
+		m.Set(fd, protoreflect.ValueOfString(v))
+	case int64:
+		m.Set(fd, protoreflect.ValueOfInt64(v))
+	case bool:
+		m.Set(fd, protoreflect.ValueOfBool(v))
+	case []string:
+		l := m.Mutable(fd).List()
+		for _, s := range v {
+			l.Append(protoreflect.ValueOfString(s))
+		}
+	case []int64:
+		l := m.Mutable(fd).List()
+		for _, i := range v {
+			l.Append(protoreflect.ValueOfInt64(i))
+		}
+	case []pqService:
+		l := m.Mutable(fd).List()
+		for _, s := range v {
+			e := l.NewElement()
+			sm := e.Message()
+			sfields := sm.Descriptor().Fields()
+			sm.Set(sfields.ByName("id"), protoreflect.ValueOfString(s.ID))
+			sm.Set(sfields.ByName("name"), protoreflect.ValueOfString(s.Name))
+			sm.Set(sfields.ByName("namespace"), protoreflect.ValueOfString(s.Namespace))
+			ips := sm.Mutable(sfields.ByName("clusterIP")).List()
+			for _, ip := range s.ClusterIP {
+				ips.Append(protoreflect.ValueOfString(ip))
+			}
+			ports := sm.Mutable(sfields.ByName("ports")).List()
+			for _, p := range s.Ports {
+				pe := ports.NewElement()
+				pm := pe.Message()
+				pfields := pm.Descriptor().Fields()
+				pm.Set(pfields.ByName("port"), protoreflect.ValueOfInt32(p.Port))
+				pm.Set(pfields.ByName("targetport"), protoreflect.ValueOfInt32(p.TargetPort))
+				pm.Set(pfields.ByName("nodeport"), protoreflect.ValueOfInt32(p.NodePort))
+				pm.Set(pfields.ByName("proto"), protoreflect.ValueOfString(p.Proto))
+				ports.Append(pe)
+			}
+			l.Append(e)
+		}
+	}
+}
+
+// Cleanup cleans up resources.
+func (t *ProtobufEncoder) Cleanup() {}
+
+// ProtobufDescriptor returns the descriptor of the record messages of JSON schema version, e.g., to decode
+// messages with dynamicpb.
+func ProtobufDescriptor(version string) (protoreflect.MessageDescriptor, error) {
+	fd, err := protodesc.NewFile(newProtobufFile(version), new(protoregistry.Files))
+	if err != nil {
+		return nil, fmt.Errorf("invalid protobuf schema for version '%s': %v", version, err)
+	}
+	return fd.Messages().ByName(recordTypeName), nil
+}
+
+// ProtobufSchema returns the .proto file of the record messages of JSON schema version, as published with
+// the processor. Field numbers are stable within a schema version.
+func ProtobufSchema(version string) string {
+	fdp := newProtobufFile(version)
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// SysFlow records of JSON schema version %s, as exported by the protobuf encoder.\n\n", version)
+	fmt.Fprintf(&sb, "syntax = %q;\n\npackage %s;\n", fdp.GetSyntax(), fdp.GetPackage())
+	for _, md := range fdp.MessageType {
+		fmt.Fprintf(&sb, "\nmessage %s {\n", md.GetName())
+		for _, f := range md.Field {
+			typ := pbTypeNames[f.GetType()]
+			if f.GetType() == descriptorpb.FieldDescriptorProto_TYPE_MESSAGE {
+				typ = strings.TrimPrefix(f.GetTypeName(), "."+fdp.GetPackage()+".")
+			}
+			if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+				typ = "repeated " + typ
+			}
+			fmt.Fprintf(&sb, "  %s %s = %d;\n", typ, f.GetName(), f.GetNumber())
+		}
+		sb.WriteString("}\n")
+	}
+	return sb.String()
+}
+
+// pbTypeNames maps the scalar field types of record messages to their names in .proto files.
+var pbTypeNames = map[descriptorpb.FieldDescriptorProto_Type]string{
+	descriptorpb.FieldDescriptorProto_TYPE_STRING: "string",
+	descriptorpb.FieldDescriptorProto_TYPE_INT64:  "int64",
+	descriptorpb.FieldDescriptorProto_TYPE_INT32:  "int32",
+	descriptorpb.FieldDescriptorProto_TYPE_BOOL:   "bool",
+}
+
+// pbMessage builds a message descriptor with fields numbered in declaration order.
+type pbMessage struct {
+	pkg string
+	md  *descriptorpb.DescriptorProto
+}
+
+func newPBMessage(pkg, name string) *pbMessage {
+	return &pbMessage{pkg: pkg, md: &descriptorpb.DescriptorProto{Name: proto.String(name)}}
+}
+
+// add adds a field of type typ, whose message type is msg for message fields.
+func (m *pbMessage) add(name string, typ descriptorpb.FieldDescriptorProto_Type, msg string, repeated bool) *pbMessage {
+	f := &descriptorpb.FieldDescriptorProto{
+		Name:     proto.String(name),
+		JsonName: proto.String(name),
+		Number:   proto.Int32(int32(len(m.md.Field) + 1)),
+		Type:     typ.Enum(),
+		Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+	}
+	if msg != "" {
+		f.TypeName = proto.String("." + m.pkg + "." + msg)
+	}
+	if repeated {
+		f.Label = descriptorpb.FieldDescriptorProto_LABEL_REPEATED.Enum()
+	}
+	m.md.Field = append(m.md.Field, f)
+	return m
+}
+
+// addColumn adds the field of the exported attribute of column c.
+func (m *pbMessage) addColumn(c pqColumn) *pbMessage {
+	switch c.kind {
+	case pqInt:
+		return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", false)
+	case pqBool:
+		return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_BOOL, "", false)
+	case pqStrList:
+		return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", true)
+	case pqIntList:
+		return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_INT64, "", true)
+	case pqSvcList:
+		return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, serviceTypeName, true)
+	}
+	return m.add(c.name, descriptorpb.FieldDescriptorProto_TYPE_STRING, "", false)
+}
+
+// newProtobufFile builds the file descriptor of the record messages of JSON schema version.
+func newProtobufFile(version string) *descriptorpb.FileDescriptorProto {
+	const (
+		str = descriptorpb.FieldDescriptorProto_TYPE_STRING
+		i64 = descriptorpb.FieldDescriptorProto_TYPE_INT64
+		i32 = descriptorpb.FieldDescriptorProto_TYPE_INT32
+		msg = descriptorpb.FieldDescriptorProto_TYPE_MESSAGE
+	)
+	pkg := schemaPackage(version)
+	record := newPBMessage(pkg, recordTypeName).add(versionAttr, str, "", false)
+	for _, c := range pqSchema.columns {
+		record.addColumn(c)
+	}
+	var groups []*descriptorpb.DescriptorProto
+	for _, g := range pqSchema.groups {
+		group := newPBMessage(pkg, schemaTypeName(g.section))
+		for _, c := range g.columns {
+			group.addColumn(c)
+		}
+		groups = append(groups, group.md)
+		record.add(pqSections[g.section], msg, schemaTypeName(g.section), false)
+	}
+	record.add(POLICIES_ATTR, msg, policyTypeName, true).add(TAGS_ATTR, str, "", true)
+
+	service := newPBMessage(pkg, serviceTypeName).
+		add("id", str, "", false).
+		add("name", str, "", false).
+		add("namespace", str, "", false).
+		add("clusterIP", str, "", true).
+		add("ports", msg, portTypeName, true)
+	port := newPBMessage(pkg, portTypeName).
+		add("port", i32, "", false).
+		add("targetport", i32, "", false).
+		add("nodeport", i32, "", false).
+		add("proto", str, "", false)
+	policy := newPBMessage(pkg, policyTypeName).
+		add("id", str, "", false).
+		add("desc", str, "", false).
+		add("priority", i64, "", false)
+
+	messages := append([]*descriptorpb.DescriptorProto{record.md}, groups...)
+	return &descriptorpb.FileDescriptorProto{
+		Name:        proto.String(strings.ReplaceAll(pkg, ".", "/") + "/record.proto"),
+		Package:     proto.String(pkg),
+		Syntax:      proto.String("proto3"),
+		MessageType: append(messages, service.md, port.md, policy.md),
+	}
+}
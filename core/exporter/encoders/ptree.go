@@ -0,0 +1,163 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// ptreeAttr is an exported attribute of the ancestors of a process.
+type ptreeAttr int
+
+// Ancestor attributes.
+const (
+	ptreePID ptreeAttr = iota
+	ptreeExe
+	ptreeArgs
+	ptreeUID
+	ptreeCreateTs
+	ptreeContainer
+	numPtreeAttrs
+)
+
+// ptreeFields maps ancestor attributes to the process attributes whose field profile they follow.
+// An ancestor attribute is exported if the first process attribute is, unless any of the others is excluded,
+// and is redacted by the redactions of all of them, so that, e.g., redacting sf.proc.cmdline or sf.pproc
+// also redacts the executables and arguments of ancestors.
+var ptreeFields = [numPtreeAttrs][]string{
+	{engine.SF_PROC_PID, engine.SF_PPROC_PID},
+	{engine.SF_PROC_EXE, engine.SF_PROC_CMDLINE, engine.SF_PPROC_EXE, engine.SF_PPROC_CMDLINE},
+	{engine.SF_PROC_ARGS, engine.SF_PROC_CMDLINE, engine.SF_PPROC_ARGS, engine.SF_PPROC_CMDLINE},
+	{engine.SF_PROC_UID, engine.SF_PPROC_UID},
+	{engine.SF_PROC_CREATETS, engine.SF_PPROC_CREATETS},
+	{engine.SF_CONTAINER_ID},
+}
+
+// ptreeNames maps ancestor attributes to their names in JSON records.
+var ptreeNames = [numPtreeAttrs]string{"pid", "exe", "args", "uid", "createts", "containerid"}
+
+// ptreeEncoder selects the ancestors of the process of a record, and their exported attributes.
+type ptreeEncoder struct {
+	depth     int
+	exported  [numPtreeAttrs]bool
+	redactors [numPtreeAttrs]redactor
+}
+
+// newPtreeEncoder creates a process tree encoder from the field profile c, or returns nil if ancestors
+// are not exported.
+func newPtreeEncoder(c commons.FieldsConfig) *ptreeEncoder {
+	if !c.Ptree {
+		return nil
+	}
+	f := newFieldFilter(c)
+	p := &ptreeEncoder{depth: c.PtreeDepth}
+	some := false
+	for attr, names := range ptreeFields {
+		p.exported[attr] = f.exported(names[0]) && !f.excluded(names[1:]...)
+		p.redactors[attr] = f.redactor(names...)
+		some = some || p.exported[attr]
+	}
+	if !some {
+		return nil
+	}
+	return p
+}
+
+// ancestors returns the ancestors of the process of rec, starting with its parent, up to the depth limit.
+func (p *ptreeEncoder) ancestors(rec *engine.Record) []*sfgo.Process {
+	if p == nil || len(rec.Fr.Ptree) < 2 {
+		return nil
+	}
+	ptree := rec.Fr.Ptree[1:]
+	if p.depth > 0 && len(ptree) > p.depth {
+		ptree = ptree[:p.depth]
+	}
+	return ptree
+}
+
+// value returns the value of attribute attr of ancestor proc, as an int64 or a string. Redacted values are strings.
+func (p *ptreeEncoder) value(attr ptreeAttr, proc *sfgo.Process) (v interface{}) {
+	switch attr {
+	case ptreePID:
+		v = proc.Oid.Hpid
+	case ptreeExe:
+		v = utils.TrimBoundingQuotes(proc.Exe)
+	case ptreeArgs:
+		v = utils.TrimBoundingQuotes(proc.ExeArgs)
+	case ptreeUID:
+		v = int64(proc.Uid)
+	case ptreeCreateTs:
+		v = proc.Oid.CreateTS
+	case ptreeContainer:
+		v = sfgo.Zeros.String
+		if proc.ContainerId != nil && proc.ContainerId.UnionType == sfgo.ContainerIdUnionTypeEnumString {
+			v = proc.ContainerId.String
+		}
+	}
+	if r := p.redactors[attr]; r != nil {
+		return r(fmt.Sprint(v))
+	}
+	return
+}
+
+// encodeECS creates the ECS process fields of ancestors.
+func (p *ptreeEncoder) encodeECS(ancestors []*sfgo.Process) []JSONData {
+	docs := make([]JSONData, 0, len(ancestors))
+	for _, proc := range ancestors {
+		doc := JSONData{}
+		for attr := ptreeAttr(0); attr < numPtreeAttrs; attr++ {
+			if !p.exported[attr] {
+				continue
+			}
+			v := p.value(attr, proc)
+			switch attr {
+			case ptreePID:
+				doc[ECS_PROC_PID] = v
+			case ptreeExe:
+				doc[ECS_PROC_EXE] = v
+				if p.redactors[attr] == nil {
+					doc[ECS_PROC_NAME] = path.Base(v.(string))
+				}
+			case ptreeArgs:
+				doc[ECS_PROC_ARGS] = v
+			case ptreeUID:
+				doc[ECS_PROC_USER] = JSONData{ECS_USER_ID: v}
+			case ptreeCreateTs:
+				if ts, ok := v.(int64); ok {
+					v = utils.ToIsoTimeStr(ts)
+				}
+				doc[ECS_PROC_START] = v
+			case ptreeContainer:
+				if v != sfgo.Zeros.String {
+					doc[ECS_PROC_CONTAINER] = JSONData{ECS_CONTAINER_ID: v}
+				}
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
@@ -0,0 +1,146 @@
+package encoders_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// newPtreeTestRecords returns the SIEM test records, where the process of the alert has three ancestors.
+func newPtreeTestRecords() []*engine.Record {
+	recs := newSIEMTestRecords()
+	cid := &sfgo.ContainerIdUnion{UnionType: sfgo.ContainerIdUnionTypeEnumString, String: "392abdfb220e"}
+	recs[0].Fr.Ptree = append(recs[0].Fr.Ptree,
+		&sfgo.Process{Oid: &sfgo.OID{CreateTS: testTs - int64(time.Second), Hpid: 7}, Exe: "/usr/bin/bash", ExeArgs: "-l", Uid: 1000, ContainerId: cid},
+		&sfgo.Process{Oid: &sfgo.OID{CreateTS: testTs - int64(time.Hour), Hpid: 5}, Exe: "/usr/sbin/sshd", ExeArgs: "-D"},
+		&sfgo.Process{Oid: &sfgo.OID{CreateTS: 1, Hpid: 1}, Exe: "/sbin/init"})
+	return recs
+}
+
+func encodePtreeJSON(t *testing.T, conf map[string]interface{}) []map[string]interface{} {
+	data, err := encoders.NewJSONEncoder(newFieldsTestConfig(t, conf)).Encode(newPtreeTestRecords())
+	assert.NoError(t, err)
+	return decodeJSON(t, data)
+}
+
+func TestJSONPtree(t *testing.T) {
+	recs := encodePtreeJSON(t, map[string]interface{}{})
+	assert.NotContains(t, recs[0], "ptree")
+
+	recs = encodePtreeJSON(t, map[string]interface{}{commons.PtreeConfigKey: "true"})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"pid": 7.0, "exe": "/usr/bin/bash", "args": "-l", "uid": 1000.0, "createts": float64(testTs - int64(time.Second)), "containerid": "392abdfb220e"},
+		map[string]interface{}{"pid": 5.0, "exe": "/usr/sbin/sshd", "args": "-D", "uid": 0.0, "createts": float64(testTs - int64(time.Hour)), "containerid": ""},
+		map[string]interface{}{"pid": 1.0, "exe": "/sbin/init", "args": "", "uid": 0.0, "createts": 1.0, "containerid": ""},
+	}, recs[0]["ptree"])
+	assert.Contains(t, recs[0], "policies")
+	assert.NotContains(t, recs[1], "ptree") // no known ancestors
+
+	// ancestor attributes follow the field profile of the process attributes
+	recs = encodePtreeJSON(t, map[string]interface{}{
+		commons.PtreeConfigKey:                 "true",
+		commons.PtreeDepthConfigKey:            "2",
+		commons.FieldsExcludeConfigKey:         "sf.proc.uid,sf.proc.createts,sf.container",
+		commons.RedactMaskConfigKey:            "sf.proc.args",
+		commons.RedactMaskPatternConfigKey:     `-\w`,
+		commons.RedactMaskReplacementConfigKey: "-x",
+	})
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"pid": 7.0, "exe": "/usr/bin/bash", "args": "-x"},
+		map[string]interface{}{"pid": 5.0, "exe": "/usr/sbin/sshd", "args": "-x"},
+	}, recs[0]["ptree"])
+}
+
+func TestPtreeRedaction(t *testing.T) {
+	// redacting command lines or parent processes also redacts the executables and arguments of ancestors
+	for _, field := range []string{engine.SF_PROC_CMDLINE, engine.SF_PPROC_CMDLINE} {
+		recs := encodePtreeJSON(t, map[string]interface{}{
+			commons.PtreeConfigKey:         "true",
+			commons.PtreeDepthConfigKey:    "1",
+			commons.FieldsExcludeConfigKey: "sf.proc.uid,sf.proc.createts,sf.container",
+			commons.RedactHashConfigKey:    field,
+		})
+		ptree := recs[0]["ptree"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, 7.0, ptree["pid"], field)
+		assert.Len(t, ptree["exe"], 64, field)
+		assert.Len(t, ptree["args"], 64, field)
+	}
+
+	// redacting parent process attributes redacts the same attributes of ancestors
+	recs := encodePtreeJSON(t, map[string]interface{}{
+		commons.PtreeConfigKey:         "true",
+		commons.PtreeDepthConfigKey:    "1",
+		commons.FieldsExcludeConfigKey: "sf.proc.uid,sf.proc.createts,sf.container",
+		commons.RedactHashConfigKey:    engine.SF_PPROC_PID,
+	})
+	ptree := recs[0]["ptree"].([]interface{})[0].(map[string]interface{})
+	assert.Len(t, ptree["pid"], 64)
+	assert.Equal(t, "/usr/bin/bash", ptree["exe"])
+	assert.Equal(t, "-l", ptree["args"])
+	recs = encodePtreeJSON(t, map[string]interface{}{
+		commons.PtreeConfigKey:         "true",
+		commons.PtreeDepthConfigKey:    "1",
+		commons.FieldsExcludeConfigKey: "sf.proc.uid,sf.proc.createts,sf.container",
+		commons.RedactHashConfigKey:    "sf.pproc",
+	})
+	ptree = recs[0]["ptree"].([]interface{})[0].(map[string]interface{})
+	for _, attr := range []string{"pid", "exe", "args"} {
+		assert.Len(t, ptree[attr], 64, attr)
+	}
+
+	// excluding command lines also excludes ancestor executables and arguments
+	recs = encodePtreeJSON(t, map[string]interface{}{
+		commons.PtreeConfigKey:         "true",
+		commons.PtreeDepthConfigKey:    "1",
+		commons.FieldsExcludeConfigKey: "sf.proc.uid,sf.proc.createts,sf.container,sf.pproc.cmdline",
+	})
+	assert.Equal(t, []interface{}{map[string]interface{}{"pid": 7.0}}, recs[0]["ptree"])
+}
+
+func TestECSPtree(t *testing.T) {
+	c := newFieldsTestConfig(t, map[string]interface{}{commons.PtreeConfigKey: "true", commons.PtreeDepthConfigKey: "2"})
+	data, err := encoders.NewECSEncoder(c).Encode(newPtreeTestRecords())
+	assert.NoError(t, err)
+	pe := data[0].(*encoders.ECSRecord)
+	assert.Equal(t, []encoders.JSONData{
+		{
+			encoders.ECS_PROC_PID:       int64(7),
+			encoders.ECS_PROC_EXE:       "/usr/bin/bash",
+			encoders.ECS_PROC_NAME:      "bash",
+			encoders.ECS_PROC_ARGS:      "-l",
+			encoders.ECS_PROC_USER:      encoders.JSONData{encoders.ECS_USER_ID: int64(1000)},
+			encoders.ECS_PROC_START:     utils.ToIsoTimeStr(testTs - int64(time.Second)),
+			encoders.ECS_PROC_CONTAINER: encoders.JSONData{encoders.ECS_CONTAINER_ID: "392abdfb220e"},
+		},
+		{
+			encoders.ECS_PROC_PID:   int64(5),
+			encoders.ECS_PROC_EXE:   "/usr/sbin/sshd",
+			encoders.ECS_PROC_NAME:  "sshd",
+			encoders.ECS_PROC_ARGS:  "-D",
+			encoders.ECS_PROC_USER:  encoders.JSONData{encoders.ECS_USER_ID: int64(0)},
+			encoders.ECS_PROC_START: utils.ToIsoTimeStr(testTs - int64(time.Hour)),
+		},
+	}, pe.Process[encoders.ECS_PROC_ANCESTORS])
+	assert.NotContains(t, data[1].(*encoders.ECSRecord).Process, encoders.ECS_PROC_ANCESTORS)
+
+	// ancestors are defined in the ECS mapping
+	mapping := loadECSMapping(t)
+	for _, doc := range decodeECS(t, data) {
+		checkECSFields(t, mapping, "", doc)
+	}
+}
+
+func TestPtreeConfig(t *testing.T) {
+	_, err := commons.CreateConfig(map[string]interface{}{commons.PtreeDepthConfigKey: "-1"})
+	assert.EqualError(t, err, "invalid value '-1' for key 'fields.ptree.depth': expected a non-negative integer")
+	c, err := commons.CreateConfig(map[string]interface{}{commons.PtreeConfigKey: "true", commons.PtreeDepthConfigKey: "3"})
+	assert.NoError(t, err)
+	assert.True(t, c.Ptree)
+	assert.Equal(t, 3, c.PtreeDepth)
+}
@@ -0,0 +1,217 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// SIEM event header values.
+const (
+	SIEM_VENDOR  = "SysFlow"
+	SIEM_PRODUCT = "sf-processor"
+)
+
+// SIEMRecord is a record encoded as a SIEM event line (e.g., CEF, LEEF), along with the
+// record attributes that transports expose in message headers.
+type SIEMRecord struct {
+	Event    []byte
+	Ts       int64
+	Host     string
+	Rules    []string
+	Priority int // highest priority of the matched policies, or -1 if the record is not an alert
+	Tags     []string
+}
+
+// siemField identifies a record attribute exported in SIEM event formats.
+type siemField int
+
+// SIEM event attributes.
+const (
+	siemHost siemField = iota
+	siemHostIP
+	siemType
+	siemOpFlags
+	siemPID
+	siemExe
+	siemCmdline
+	siemUser
+	siemUID
+	siemPPID
+	siemFilePath
+	siemOldFilePath
+	siemSrcIP
+	siemSrcPort
+	siemDstIP
+	siemDstPort
+	siemProto
+	siemSrcBytes
+	siemDstBytes
+	siemContainerID
+	siemContainerName
+	siemContainerImage
+	siemRules
+	siemRuleDescs
+	siemTags
+)
+
+// siemAttr is a record attribute exported in SIEM event formats.
+type siemAttr struct {
+	field siemField
+	value string
+}
+
+// siemEvent holds the format-independent representation of a record exported in SIEM event formats.
+type siemEvent struct {
+	ts       int64
+	endTs    int64
+	id       string
+	name     string
+	host     string
+	rules    []string
+	priority int
+	tags     []string
+	attrs    []siemAttr
+}
+
+// siemTypeNames maps record types to event names.
+var siemTypeNames = map[string]string{
+	sfgo.TyPEStr: "Process event",
+	sfgo.TyPFStr: "Process flow",
+	sfgo.TyFEStr: "File event",
+	sfgo.TyFFStr: "File flow",
+	sfgo.TyNFStr: "Network flow",
+	sfgo.TyKEStr: "Kubernetes event",
+}
+
+// siemSeverities maps rule priorities to SIEM event severities, on a 0-10 scale.
+var siemSeverities = map[engine.Priority]int{
+	engine.Low:    3,
+	engine.Medium: 6,
+	engine.High:   8,
+}
+
+// siemSeverity returns the SIEM event severity of a record, given the highest priority of the policies it matched.
+func siemSeverity(priority int, event int) int {
+	if priority < 0 {
+		return event
+	}
+	return siemSeverities[engine.Priority(priority)]
+}
+
+// encodeSIEMEvent extracts the attributes of a record exported in SIEM event formats. Alerts are identified
+// by the name of their highest priority policy, and described by its description. Other records are identified
+// by their record type.
+func encodeSIEMEvent(rec *engine.Record) *siemEvent {
+	sfType := engine.Mapper.MapStr(engine.SF_TYPE)(rec)
+	opflags := engine.Mapper.MapStr(engine.SF_OPFLAGS)(rec)
+	e := &siemEvent{
+		ts:       engine.Mapper.MapInt(engine.SF_TS)(rec),
+		id:       sfType,
+		name:     strings.TrimSpace(siemTypeNames[sfType] + " " + opflags),
+		host:     engine.Mapper.MapStr(engine.SF_NODE_ID)(rec),
+		priority: -1,
+		tags:     rec.Ctx.GetTags(),
+	}
+	if sfType == sfgo.TyNFStr || sfType == sfgo.TyFFStr || sfType == sfgo.TyPFStr {
+		e.endTs = engine.Mapper.MapInt(engine.SF_ENDTS)(rec)
+	}
+	addStr := func(f siemField, v string) {
+		if v != sfgo.Zeros.String {
+			e.attrs = append(e.attrs, siemAttr{f, v})
+		}
+	}
+	addInt := func(f siemField, v int64) {
+		e.attrs = append(e.attrs, siemAttr{f, strconv.FormatInt(v, 10)})
+	}
+	addStr(siemHost, e.host)
+	addStr(siemHostIP, engine.Mapper.MapStr(engine.SF_NODE_IP)(rec))
+	addStr(siemType, sfType)
+	addStr(siemOpFlags, opflags)
+	if sfType != sfgo.TyKEStr {
+		addInt(siemPID, engine.Mapper.MapInt(engine.SF_PROC_PID)(rec))
+		addStr(siemExe, engine.Mapper.MapStr(engine.SF_PROC_EXE)(rec))
+		addStr(siemCmdline, strings.TrimSpace(engine.Mapper.MapStr(engine.SF_PROC_CMDLINE)(rec)))
+		addStr(siemUser, engine.Mapper.MapStr(engine.SF_PROC_USER)(rec))
+		addInt(siemUID, engine.Mapper.MapInt(engine.SF_PROC_UID)(rec))
+		addInt(siemPPID, engine.Mapper.MapInt(engine.SF_PPROC_PID)(rec))
+	}
+	switch sfType {
+	case sfgo.TyFEStr, sfgo.TyFFStr:
+		fpath := engine.Mapper.MapStr(engine.SF_FILE_PATH)(rec)
+		if newPath := engine.Mapper.MapStr(engine.SF_FILE_NEWPATH)(rec); newPath != sfgo.Zeros.String {
+			addStr(siemFilePath, newPath)
+			addStr(siemOldFilePath, fpath)
+		} else {
+			addStr(siemFilePath, fpath)
+		}
+	case sfgo.TyNFStr:
+		addStr(siemSrcIP, engine.Mapper.MapStr(engine.SF_NET_SIP)(rec))
+		addInt(siemSrcPort, engine.Mapper.MapInt(engine.SF_NET_SPORT)(rec))
+		addStr(siemDstIP, engine.Mapper.MapStr(engine.SF_NET_DIP)(rec))
+		addInt(siemDstPort, engine.Mapper.MapInt(engine.SF_NET_DPORT)(rec))
+		addStr(siemProto, strings.ToUpper(sfgo.GetProto(engine.Mapper.MapInt(engine.SF_NET_PROTO)(rec))))
+		addInt(siemSrcBytes, engine.Mapper.MapInt(engine.SF_FLOW_WBYTES)(rec))
+		addInt(siemDstBytes, engine.Mapper.MapInt(engine.SF_FLOW_RBYTES)(rec))
+	}
+	if sfType != sfgo.TyKEStr {
+		if cid := engine.Mapper.MapStr(engine.SF_CONTAINER_ID)(rec); cid != sfgo.Zeros.String {
+			addStr(siemContainerID, cid)
+			addStr(siemContainerName, engine.Mapper.MapStr(engine.SF_CONTAINER_NAME)(rec))
+			addStr(siemContainerImage, engine.Mapper.MapStr(engine.SF_CONTAINER_IMAGE)(rec))
+		}
+	}
+
+	// encode tags and policy information
+	if rules := rec.Ctx.GetRules(); len(rules) > 0 {
+		descs := make([]string, 0, len(rules))
+		top := rules[0]
+		for _, r := range rules {
+			e.rules = append(e.rules, r.Name)
+			if r.Desc != sfgo.Zeros.String {
+				descs = append(descs, r.Desc)
+			}
+			e.tags = append(e.tags, extracTags(r.Tags)...)
+			if r.Priority > top.Priority {
+				top = r
+			}
+		}
+		e.id, e.name, e.priority = top.Name, top.Desc, int(top.Priority)
+		if e.name == sfgo.Zeros.String {
+			e.name = top.Name
+		}
+		addStr(siemRules, strings.Join(e.rules, ", "))
+		addStr(siemRuleDescs, strings.Join(descs, "; "))
+	}
+	if len(e.tags) > 0 {
+		addStr(siemTags, strings.Join(e.tags, ","))
+	}
+	return e
+}
+
+// record wraps an encoded SIEM event line into a SIEM record.
+func (e *siemEvent) record(event []byte) *SIEMRecord {
+	return &SIEMRecord{Event: event, Ts: e.ts, Host: e.host, Rules: e.rules, Priority: e.priority, Tags: e.tags}
+}
@@ -0,0 +1,110 @@
+package encoders_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+var testTs = time.Date(2022, 10, 17, 14, 0, 0, 0, time.UTC).UnixNano()
+
+func newFlatRecord(rtype int64, opflags int64, ts int64) sfgo.FlatRecord {
+	fr := sfgo.FlatRecord{
+		Sources: []sfgo.Source{sfgo.SYSFLOW_SRC},
+		Ints:    [][]int64{make([]int64, sfgo.INT_ARRAY_SIZE)},
+		Strs:    [][]string{make([]string, sfgo.STR_ARRAY_SIZE)},
+		Anys:    [][]interface{}{make([]interface{}, sfgo.ANY_ARRAY_SIZE)},
+		Ptree:   []*sfgo.Process{{Oid: &sfgo.OID{CreateTS: 1, Hpid: 42}, Exe: "/bin/sh"}},
+	}
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.SF_REC_TYPE] = rtype
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.TS_INT] = ts
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.EV_PROC_OPFLAGS_INT] = opflags
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.PROC_OID_HPID_INT] = 42
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.PROC_POID_HPID_INT] = 1
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.PROC_UID_INT] = 1000
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.SFHE_EXPORTER_STR] = "node1"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.SFHE_IP_STR] = "10.0.0.5"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXE_STR] = "/bin/sh"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_USERNAME_STR] = "alice"
+	return fr
+}
+
+// newSIEMTestRecords returns an alert with characters that must be escaped, a network flow, and a file rename.
+func newSIEMTestRecords() []*engine.Record {
+	fr := newFlatRecord(sfgo.PROC_EVT, sfgo.OP_EXEC, testTs)
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXEARGS_STR] = "-c echo a=b|c\\d\necho\tdone"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_ID_STR] = "392abdfb220e"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_NAME_STR] = "web"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_IMAGE_STR] = "nginx:latest"
+	pe := engine.NewRecord(fr)
+	pe.Ctx.AddRule(engine.Rule{Name: "shell", Desc: "shell spawned", Priority: engine.Medium, Tags: []engine.EnrichmentTag{"mitre:T1059"}})
+	pe.Ctx.AddRule(engine.Rule{Name: "pipe|shell", Desc: "shell in container\nwith args a=b", Priority: engine.High, Tags: []engine.EnrichmentTag{[]string{"container", "shell"}}})
+
+	fr = newFlatRecord(sfgo.NET_FLOW, sfgo.OP_CONNECT|sfgo.OP_READ_RECV|sfgo.OP_WRITE_SEND, testTs+int64(time.Second))
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_ENDTS_INT] = testTs + int64(3*time.Second)
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_SIP_INT] = 0x0500000a
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_SPORT_INT] = 41000
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_DIP_INT] = 0x22d8b85d
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_DPORT_INT] = 443
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_PROTO_INT] = 6
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_NUMRRECVBYTES_INT] = 2048
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.FL_NETW_NUMWSENDBYTES_INT] = 512
+	nf := engine.NewRecord(fr)
+
+	fr = newFlatRecord(sfgo.FILE_EVT, sfgo.OP_RENAME, testTs+int64(4*time.Second))
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.FILE_PATH_STR] = "/tmp/a"
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.SEC_FILE_PATH_STR] = "/tmp/b\tc"
+	fe := engine.NewRecord(fr)
+
+	return []*engine.Record{pe, nf, fe}
+}
+
+func checkGolden(t *testing.T, name string, data []commons.EncodedData) {
+	var buf bytes.Buffer
+	for _, d := range data {
+		rec, ok := d.(*encoders.SIEMRecord)
+		assert.True(t, ok)
+		buf.Write(rec.Event)
+		buf.WriteByte('\n')
+	}
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		assert.NoError(t, os.WriteFile(path, buf.Bytes(), 0644))
+	}
+	golden, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(golden), buf.String())
+}
+
+func TestCEFEncoder(t *testing.T) {
+	enc := encoders.NewCEFEncoder(commons.Config{Version: "0.5.0"})
+	data, err := enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	checkGolden(t, "cef", data)
+
+	rec := data[0].(*encoders.SIEMRecord)
+	assert.Equal(t, testTs, rec.Ts)
+	assert.Equal(t, "node1", rec.Host)
+	assert.Equal(t, []string{"shell", "pipe|shell"}, rec.Rules)
+	assert.Equal(t, int(engine.High), rec.Priority)
+	assert.Equal(t, []string{"mitre:T1059", "container", "shell"}, rec.Tags)
+	assert.Equal(t, -1, data[1].(*encoders.SIEMRecord).Priority)
+}
+
+func TestLEEFEncoder(t *testing.T) {
+	enc := encoders.NewLEEFEncoder(commons.Config{Version: "0.5.0"})
+	data, err := enc.Encode(newSIEMTestRecords())
+	assert.NoError(t, err)
+	checkGolden(t, "leef", data)
+}
@@ -0,0 +1,280 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encoders implements codecs for exporting records and events in different data formats.
+package encoders
+
+import (
+	"encoding/hex"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// SysFlowEncoder converts records back into the SysFlow records they were flattened from.
+// Each record is encoded into a slice of SysFlow records holding its header, pod, container,
+// process ancestry and file entities, followed by the event or flow record itself.
+// Attributes that are not kept in flat records (e.g., process event arguments, pod labels)
+// are not restored.
+type SysFlowEncoder struct {
+	batch []commons.EncodedData
+}
+
+// NewSysFlowEncoder instantiates a SysFlow encoder.
+func NewSysFlowEncoder(config commons.Config) Encoder {
+	return &SysFlowEncoder{batch: make([]commons.EncodedData, 0, config.EventBuffer)}
+}
+
+// Register registers the encoder to the codecs cache.
+func (t *SysFlowEncoder) Register(codecs map[commons.Format]EncoderFactory) {
+	codecs[commons.SysFlowFormat] = NewSysFlowEncoder
+}
+
+// Encode encodes telemetry records into SysFlow records.
+func (t *SysFlowEncoder) Encode(recs []*engine.Record) ([]commons.EncodedData, error) {
+	t.batch = t.batch[:0]
+	for _, rec := range recs {
+		if sfs := t.encode(rec); sfs != nil {
+			t.batch = append(t.batch, sfs)
+		}
+	}
+	return t.batch, nil
+}
+
+// encode converts a record into its header and entity records, followed by the record itself.
+// It returns nil for record types that cannot be converted.
+func (t *SysFlowEncoder) encode(rec *engine.Record) []*sfgo.SysFlow {
+	ints := rec.Fr.Ints[sfgo.SYSFLOW_IDX]
+	strs := rec.Fr.Strs[sfgo.SYSFLOW_IDX]
+	sfs := []*sfgo.SysFlow{{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: &sfgo.SFHeader{
+		Version:  ints[sfgo.SFHE_VERSION_INT],
+		Exporter: strs[sfgo.SFHE_EXPORTER_STR],
+		Ip:       strs[sfgo.SFHE_IP_STR],
+		Filename: strs[sfgo.SFHE_FILENAME_STR],
+	}}}}
+
+	rtype := ints[sfgo.SF_REC_TYPE]
+	switch rtype {
+	case sfgo.K8S_EVT:
+		return append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumK8sEvent, K8sEvent: &sfgo.K8sEvent{
+			Kind:    sfgo.K8sComponent(ints[sfgo.K8SE_KIND_INT]),
+			Action:  sfgo.K8sAction(ints[sfgo.K8SE_ACTION_INT]),
+			Ts:      ints[sfgo.TS_INT],
+			Message: strs[sfgo.K8SE_MESSAGE_STR],
+		}}})
+	case sfgo.PROC_EVT, sfgo.NET_FLOW, sfgo.FILE_FLOW, sfgo.FILE_EVT:
+	default:
+		return nil
+	}
+
+	if pod := t.encodePod(&rec.Fr); pod != nil {
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumPod, Pod: pod}})
+	}
+	if strs[sfgo.CONT_ID_STR] != sfgo.Zeros.String {
+		cont := &sfgo.Container{
+			Id:         strs[sfgo.CONT_ID_STR],
+			Name:       strs[sfgo.CONT_NAME_STR],
+			Image:      strs[sfgo.CONT_IMAGE_STR],
+			Imageid:    strs[sfgo.CONT_IMAGEID_STR],
+			Type:       sfgo.ContainerType(ints[sfgo.CONT_TYPE_INT]),
+			Privileged: ints[sfgo.CONT_PRIVILEGED_INT] == 1,
+		}
+		if id := strs[sfgo.POD_ID_STR]; id != sfgo.Zeros.String {
+			cont.PodId = &sfgo.PodIdUnion{UnionType: sfgo.PodIdUnionTypeEnumString, String: id}
+		}
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumContainer, Container: cont}})
+	}
+
+	// ancestors are written first, from the root down; the process itself is taken from the
+	// flat record, which holds its attributes as of the record, unlike the cached process tree
+	proc := t.encodeProcess(ints, strs)
+	for i := len(rec.Fr.Ptree) - 1; i >= 0; i-- {
+		if p := rec.Fr.Ptree[i]; p.Oid != nil && *p.Oid != *proc.Oid {
+			sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcess, Process: copyProcess(p)}})
+		}
+	}
+	sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcess, Process: proc}})
+	procOID := proc.Oid
+
+	var fileOID sfgo.FOID
+	if rtype == sfgo.FILE_FLOW || rtype == sfgo.FILE_EVT {
+		file := &sfgo.File{
+			State:       sfgo.SFObjectState(ints[sfgo.FILE_STATE_INT]),
+			Oid:         getFOID(strs[sfgo.FILE_OID_STR]),
+			Ts:          ints[sfgo.FILE_TS_INT],
+			Restype:     int32(ints[sfgo.FILE_RESTYPE_INT]),
+			Path:        strs[sfgo.FILE_PATH_STR],
+			ContainerId: getContainerIDUnion(strs[sfgo.FILE_CONTAINERID_STRING_STR]),
+		}
+		fileOID = file.Oid
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumFile, File: file}})
+	}
+
+	switch rtype {
+	case sfgo.PROC_EVT:
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcessEvent, ProcessEvent: &sfgo.ProcessEvent{
+			ProcOID: procOID,
+			Ts:      ints[sfgo.EV_PROC_TS_INT],
+			Tid:     ints[sfgo.EV_PROC_TID_INT],
+			OpFlags: int32(ints[sfgo.EV_PROC_OPFLAGS_INT]),
+			Ret:     int32(ints[sfgo.EV_PROC_RET_INT]),
+		}}})
+	case sfgo.NET_FLOW:
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumNetworkFlow, NetworkFlow: &sfgo.NetworkFlow{
+			ProcOID:       procOID,
+			Ts:            ints[sfgo.FL_NETW_TS_INT],
+			Tid:           ints[sfgo.FL_NETW_TID_INT],
+			OpFlags:       int32(ints[sfgo.FL_NETW_OPFLAGS_INT]),
+			EndTs:         ints[sfgo.FL_NETW_ENDTS_INT],
+			Sip:           int32(ints[sfgo.FL_NETW_SIP_INT]),
+			Sport:         int32(ints[sfgo.FL_NETW_SPORT_INT]),
+			Dip:           int32(ints[sfgo.FL_NETW_DIP_INT]),
+			Dport:         int32(ints[sfgo.FL_NETW_DPORT_INT]),
+			Proto:         int32(ints[sfgo.FL_NETW_PROTO_INT]),
+			Fd:            int32(ints[sfgo.FL_NETW_FD_INT]),
+			NumRRecvOps:   ints[sfgo.FL_NETW_NUMRRECVOPS_INT],
+			NumWSendOps:   ints[sfgo.FL_NETW_NUMWSENDOPS_INT],
+			NumRRecvBytes: ints[sfgo.FL_NETW_NUMRRECVBYTES_INT],
+			NumWSendBytes: ints[sfgo.FL_NETW_NUMWSENDBYTES_INT],
+		}}})
+	case sfgo.FILE_FLOW:
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumFileFlow, FileFlow: &sfgo.FileFlow{
+			ProcOID:       procOID,
+			Ts:            ints[sfgo.FL_FILE_TS_INT],
+			Tid:           ints[sfgo.FL_FILE_TID_INT],
+			OpFlags:       int32(ints[sfgo.FL_FILE_OPFLAGS_INT]),
+			OpenFlags:     int32(ints[sfgo.FL_FILE_OPENFLAGS_INT]),
+			EndTs:         ints[sfgo.FL_FILE_ENDTS_INT],
+			FileOID:       fileOID,
+			Fd:            int32(ints[sfgo.FL_FILE_FD_INT]),
+			NumRRecvOps:   ints[sfgo.FL_FILE_NUMRRECVOPS_INT],
+			NumWSendOps:   ints[sfgo.FL_FILE_NUMWSENDOPS_INT],
+			NumRRecvBytes: ints[sfgo.FL_FILE_NUMRRECVBYTES_INT],
+			NumWSendBytes: ints[sfgo.FL_FILE_NUMWSENDBYTES_INT],
+		}}})
+	case sfgo.FILE_EVT:
+		fe := &sfgo.FileEvent{
+			ProcOID: procOID,
+			Ts:      ints[sfgo.EV_FILE_TS_INT],
+			Tid:     ints[sfgo.EV_FILE_TID_INT],
+			OpFlags: int32(ints[sfgo.EV_FILE_OPFLAGS_INT]),
+			FileOID: fileOID,
+			Ret:     int32(ints[sfgo.EV_FILE_RET_INT]),
+		}
+		if oid := strs[sfgo.SEC_FILE_OID_STR]; oid != sfgo.Zeros.String {
+			nf := &sfgo.File{
+				State:       sfgo.SFObjectState(ints[sfgo.SEC_FILE_STATE_INT]),
+				Oid:         getFOID(oid),
+				Ts:          ints[sfgo.SEC_FILE_TS_INT],
+				Restype:     int32(ints[sfgo.SEC_FILE_RESTYPE_INT]),
+				Path:        strs[sfgo.SEC_FILE_PATH_STR],
+				ContainerId: getContainerIDUnion(strs[sfgo.SEC_FILE_CONTAINERID_STRING_STR]),
+			}
+			fe.NewFileOID = &sfgo.NewFileOIDUnion{UnionType: sfgo.NewFileOIDUnionTypeEnumFOID, FOID: nf.Oid}
+			sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumFile, File: nf}})
+		}
+		sfs = append(sfs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumFileEvent, FileEvent: fe}})
+	}
+	return sfs
+}
+
+// encodePod converts the pod attributes of a flat record into a pod entity, or returns nil if the record has no pod.
+func (t *SysFlowEncoder) encodePod(fr *sfgo.FlatRecord) *sfgo.Pod {
+	ints := fr.Ints[sfgo.SYSFLOW_IDX]
+	strs := fr.Strs[sfgo.SYSFLOW_IDX]
+	anys := fr.Anys[sfgo.SYSFLOW_IDX]
+	if strs[sfgo.POD_ID_STR] == sfgo.Zeros.String {
+		return nil
+	}
+	pod := &sfgo.Pod{
+		Ts:           ints[sfgo.POD_TS_INT],
+		Id:           strs[sfgo.POD_ID_STR],
+		Name:         strs[sfgo.POD_NAME_STR],
+		NodeName:     strs[sfgo.POD_NODENAME_STR],
+		Namespace:    strs[sfgo.POD_NAMESPACE_STR],
+		RestartCount: ints[sfgo.POD_RESTARTCOUNT_INT],
+		Labels:       make(map[string]string),
+		Selectors:    make(map[string]string),
+	}
+	if ips, ok := anys[sfgo.POD_HOSTIP_ANY].(*[]int64); ok {
+		pod.HostIP = *ips
+	}
+	if ips, ok := anys[sfgo.POD_INTERNALIP_ANY].(*[]int64); ok {
+		pod.InternalIP = *ips
+	}
+	if svcs, ok := anys[sfgo.POD_SERVICES_ANY].(*[]*sfgo.Service); ok {
+		pod.Services = *svcs
+	}
+	return pod
+}
+
+// encodeProcess converts the process attributes of a flat record into a process entity.
+func (t *SysFlowEncoder) encodeProcess(ints []int64, strs []string) *sfgo.Process {
+	proc := &sfgo.Process{
+		State:       sfgo.SFObjectState(ints[sfgo.PROC_STATE_INT]),
+		Oid:         &sfgo.OID{CreateTS: ints[sfgo.PROC_OID_CREATETS_INT], Hpid: ints[sfgo.PROC_OID_HPID_INT]},
+		Ts:          ints[sfgo.PROC_TS_INT],
+		Exe:         strs[sfgo.PROC_EXE_STR],
+		ExeArgs:     strs[sfgo.PROC_EXEARGS_STR],
+		Uid:         int32(ints[sfgo.PROC_UID_INT]),
+		UserName:    strs[sfgo.PROC_USERNAME_STR],
+		Gid:         int32(ints[sfgo.PROC_GID_INT]),
+		GroupName:   strs[sfgo.PROC_GROUPNAME_STR],
+		Tty:         ints[sfgo.PROC_TTY_INT] == 1,
+		ContainerId: getContainerIDUnion(strs[sfgo.PROC_CONTAINERID_STRING_STR]),
+		Entry:       ints[sfgo.PROC_ENTRY_INT] == 1,
+	}
+	if ints[sfgo.PROC_POID_HPID_INT] != sfgo.Zeros.Int64 {
+		proc.Poid = &sfgo.PoidUnion{UnionType: sfgo.PoidUnionTypeEnumOID, OID: &sfgo.OID{CreateTS: ints[sfgo.PROC_POID_CREATETS_INT], Hpid: ints[sfgo.PROC_POID_HPID_INT]}}
+	}
+	return proc
+}
+
+// Cleanup cleans up resources.
+func (t *SysFlowEncoder) Cleanup() {}
+
+// getFOID decodes a hex-encoded file object ID.
+func getFOID(s string) (oid sfgo.FOID) {
+	if b, err := hex.DecodeString(s); err == nil {
+		copy(oid[:], b)
+	}
+	return
+}
+
+// copyProcess copies a process entity from the reader's tables, setting its null unions to nil,
+// since null unions are only serialized from nil values.
+func copyProcess(p *sfgo.Process) *sfgo.Process {
+	c := *p
+	if c.Poid != nil && c.Poid.UnionType != sfgo.PoidUnionTypeEnumOID {
+		c.Poid = nil
+	}
+	if c.ContainerId != nil && c.ContainerId.UnionType != sfgo.ContainerIdUnionTypeEnumString {
+		c.ContainerId = nil
+	}
+	return &c
+}
+
+// getContainerIDUnion returns a container ID union for container id, or nil (null) if id is empty.
+func getContainerIDUnion(id string) *sfgo.ContainerIdUnion {
+	if id == sfgo.Zeros.String {
+		return nil
+	}
+	return &sfgo.ContainerIdUnion{UnionType: sfgo.ContainerIdUnionTypeEnumString, String: id}
+}
@@ -27,6 +27,7 @@ import (
 
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
@@ -45,6 +46,7 @@ type Exporter struct {
 	config    commons.Config
 	encoder   encoders.Encoder
 	transport transports.TransportProtocol
+	clock     clock.Clock
 	recs      []*engine.Record
 	counter   int
 }
@@ -69,6 +71,13 @@ func (s *Exporter) registerCodecs() {
 	(&encoders.JSONEncoder{}).Register(codecs)
 	(&encoders.ECSEncoder{}).Register(codecs)
 	(&encoders.OccurrenceEncoder{}).Register(codecs)
+	(&encoders.SysFlowEncoder{}).Register(codecs)
+	(&encoders.ParquetEncoder{}).Register(codecs)
+	(&encoders.OTLPEncoder{}).Register(codecs)
+	(&encoders.CEFEncoder{}).Register(codecs)
+	(&encoders.LEEFEncoder{}).Register(codecs)
+	(&encoders.AvroEncoder{}).Register(codecs)
+	(&encoders.ProtobufEncoder{}).Register(codecs)
 }
 
 // registerExportProtocols register transport protocols for exporting processor data.
@@ -79,6 +88,13 @@ func (s *Exporter) registerExportProtocols() {
 	(&transports.NullProto{}).Register(protocols)
 	(&transports.FindingsAPIProto{}).Register(protocols)
 	(&transports.ElasticProto{}).Register(protocols)
+	(&transports.SysFlowProto{}).Register(protocols)
+	(&transports.ParquetProto{}).Register(protocols)
+	(&transports.OTLPProto{}).Register(protocols)
+	(&transports.WebhookProto{}).Register(protocols)
+	(&transports.OpenSearchProto{}).Register(protocols)
+	(&transports.SplunkProto{}).Register(protocols)
+	(&transports.StreamProto{}).Register(protocols)
 }
 
 // Init initializes the plugin with a configuration map and cache.
@@ -96,6 +112,7 @@ func (s *Exporter) Init(conf map[string]interface{}) error {
 	if err != nil {
 		return err
 	}
+	s.clock = clock.New(s.config.Clock)
 
 	// initialize encoder
 	if createCodec, ok := codecs[s.config.Format]; ok {
@@ -135,7 +152,8 @@ func (s *Exporter) Process(ch interface{}, wg *sync.WaitGroup) {
 	maxIdle := 1 * time.Second
 	ticker := time.NewTicker(maxIdle)
 	defer ticker.Stop()
-	lastFlush := time.Now()
+	eventTime := s.config.Clock == clock.EventMode
+	lastFlush, lastWallFlush := s.clock.Now(), time.Now()
 
 	logger.Trace.Printf("Starting exporter in mode %s with channel capacity %d", s.config.Transport.String(), cap(record))
 
@@ -144,13 +162,16 @@ RecLoop:
 		select {
 		case fc, ok := <-record:
 			if ok {
+				s.clock.Advance(engine.Mapper.MapInt(engine.SF_TS)(fc))
 				s.counter++
 				s.recs = append(s.recs, fc)
-				if s.counter >= s.config.EventBuffer {
+				// with event time, records are also flushed on arrival once idle, so that
+				// batches do not depend on the replay speed
+				if s.counter >= s.config.EventBuffer || eventTime && s.clock.Since(lastFlush) > maxIdle {
 					s.process()
 					s.recs = s.recs[:0]
 					s.counter = 0
-					lastFlush = time.Now()
+					lastFlush, lastWallFlush = s.clock.Now(), time.Now()
 				}
 			} else {
 				ticker.Stop()
@@ -161,18 +182,22 @@ RecLoop:
 				break RecLoop
 			}
 		case <-ticker.C:
-			// force flush records after 1sec idle
-			if time.Since(lastFlush) > maxIdle && s.counter > 0 {
+			// force flush records after 1sec idle; event time does not advance without records,
+			// so the idle period is measured with the system clock
+			if time.Since(lastWallFlush) > maxIdle && s.counter > 0 {
 				s.process()
 				s.recs = s.recs[:0]
 				s.counter = 0
-				lastFlush = time.Now()
+				lastFlush, lastWallFlush = s.clock.Now(), time.Now()
 			}
 		}
 	}
 }
 
 func (s *Exporter) process() error {
+	if t, ok := s.transport.(transports.RecordTransportProtocol); ok {
+		return s.processRecords(t)
+	}
 	data, err := s.encoder.Encode(s.recs)
 	if err != nil {
 		logger.Error.Println(err)
@@ -188,6 +213,26 @@ func (s *Exporter) process() error {
 	return nil
 }
 
+// processRecords encodes records one at a time, so that transport t receives each record along with its encoding.
+func (s *Exporter) processRecords(t transports.RecordTransportProtocol) error {
+	data := make([]commons.EncodedData, len(s.recs))
+	for i := range s.recs {
+		d, err := s.encoder.Encode(s.recs[i : i+1])
+		if err != nil {
+			logger.Error.Println(err)
+			return err
+		}
+		if len(d) > 0 {
+			data[i] = d[0]
+		}
+	}
+	if err := t.ExportRecords(s.recs, data); err != nil {
+		logger.Error.Println(err)
+		return err
+	}
+	return nil
+}
+
 // SetOutChan sets the output channel of the plugin.
 func (s *Exporter) SetOutChan(ch []interface{}) {}
 
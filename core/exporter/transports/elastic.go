@@ -26,12 +26,16 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	netmod "net"
 	"net/http"
 	"os"
+	"strings"
+	"sync"
 	"time"
 
 	elasticsearch "github.com/elastic/go-elasticsearch/v8"
+	"github.com/elastic/go-elasticsearch/v8/esapi"
 	estransport "github.com/elastic/go-elasticsearch/v8/estransport"
 	"github.com/elastic/go-elasticsearch/v8/esutil"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
@@ -41,11 +45,28 @@ import (
 
 // ElasticProto implements the TransportProtocol interface for Elastic.
 type ElasticProto struct {
-	es     *elasticsearch.Client
-	config commons.Config
-	bi     esutil.BulkIndexer
-	ctx    context.Context
-	start  time.Time
+	es      *elasticsearch.Client
+	config  commons.Config
+	bi      esutil.BulkIndexer
+	ctx     context.Context
+	start   time.Time
+	dated   bool
+	hour    int64
+	index   string
+	mu      sync.Mutex
+	retries []*esDoc
+	policy  []byte
+	tmpl    []byte
+	stop    chan struct{}
+	wg      sync.WaitGroup
+}
+
+// esDoc is a document added to the bulk indexer, kept for retries.
+type esDoc struct {
+	index    string
+	id       string
+	body     []byte
+	attempts int
 }
 
 // NewElasticProto creates a new Elastic protocol object.
@@ -53,7 +74,8 @@ func NewElasticProto(conf commons.Config) TransportProtocol {
 	return &ElasticProto{config: conf}
 }
 
-// Init initializes the Elastic client.
+// Init initializes the Elastic client, installs the index template, and starts the bulk indexer.
+// If the cluster cannot be reached, the template is installed in the background once it becomes available.
 func (s *ElasticProto) Init() (err error) {
 	cfg := elasticsearch.Config{
 		Addresses: s.config.ESAddresses,
@@ -70,14 +92,28 @@ func (s *ElasticProto) Init() (err error) {
 		//CACert:    ioutil.ReadFile("path/to/ca.crt"),
 		Logger: &estransport.JSONLogger{Output: os.Stdout},
 	}
-	s.es, err = elasticsearch.NewClient(cfg)
+	if s.es, err = elasticsearch.NewClient(cfg); err != nil {
+		return err
+	}
+	s.ctx = context.Background()
+	s.start = time.Now().UTC()
+	s.dated = strings.Contains(s.config.ESIndex, "%")
+	if err = s.loadTemplate(); err != nil {
+		return err
+	}
+	if err = s.installTemplate(); err != nil {
+		logger.Warn.Printf("Index template not installed, retrying in the background: %v", err)
+		s.stop = make(chan struct{})
+		s.wg.Add(1)
+		go s.retryTemplate()
+	}
+	s.bi, err = s.newBulkIndexer()
 	return err
 }
 
-// Export creates the batch, adds the ecs data and executes it
-func (s *ElasticProto) Export(data []commons.EncodedData) (err error) {
-	s.bi, err = esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
-		Index:         s.config.ESIndex,
+// newBulkIndexer creates a bulk indexer.
+func (s *ElasticProto) newBulkIndexer() (esutil.BulkIndexer, error) {
+	bi, err := esutil.NewBulkIndexer(esutil.BulkIndexerConfig{
 		Client:        s.es,
 		NumWorkers:    s.config.ESNumWorkers,   // default: 0 (= number of CPUs)
 		FlushBytes:    s.config.ESFlushBuffer,  // default: 5M
@@ -85,12 +121,130 @@ func (s *ElasticProto) Export(data []commons.EncodedData) (err error) {
 	})
 	if err != nil {
 		logger.Error.Println("Failed to create bulk indexer")
+	}
+	return bi, err
+}
+
+// loadTemplate reads the ILM policy and builds the index template, if configured.
+func (s *ElasticProto) loadTemplate() (err error) {
+	if s.config.ESILMFile != "" {
+		if s.policy, err = os.ReadFile(s.config.ESILMFile); err != nil {
+			return err
+		}
+	}
+	if s.config.ESTemplateMappings == "" && s.config.ESILMPolicy == "" {
+		return nil
+	}
+	template := map[string]interface{}{}
+	if s.config.ESTemplateMappings != "" {
+		data, err := os.ReadFile(s.config.ESTemplateMappings)
+		if err != nil {
+			return err
+		}
+		var m struct {
+			Mappings json.RawMessage `json:"mappings"`
+		}
+		if err = json.Unmarshal(data, &m); err != nil || m.Mappings == nil {
+			return fmt.Errorf("expected mappings object in %s", s.config.ESTemplateMappings)
+		}
+		template["mappings"] = m.Mappings
+	}
+	if s.config.ESILMPolicy != "" {
+		template["settings"] = map[string]interface{}{"index.lifecycle.name": s.config.ESILMPolicy}
+	}
+	it := map[string]interface{}{
+		"index_patterns": []string{indexPattern(s.config.ESIndex)},
+		"priority":       200,
+		"template":       template,
+	}
+	if s.config.ESDataStream {
+		it["data_stream"] = map[string]interface{}{}
+	}
+	s.tmpl, err = json.Marshal(it)
+	return err
+}
+
+// installTemplate installs the ILM policy and the index template, if configured.
+func (s *ElasticProto) installTemplate() error {
+	if s.policy != nil {
+		res, err := s.es.ILM.PutLifecycle(s.config.ESILMPolicy, s.es.ILM.PutLifecycle.WithBody(bytes.NewReader(s.policy)), s.es.ILM.PutLifecycle.WithContext(s.ctx))
+		if err = checkESResponse(res, err); err != nil {
+			return fmt.Errorf("failed to install ILM policy %s: %v", s.config.ESILMPolicy, err)
+		}
+	}
+	if s.tmpl != nil {
+		res, err := s.es.Indices.PutIndexTemplate(s.config.ESTemplateName, bytes.NewReader(s.tmpl), s.es.Indices.PutIndexTemplate.WithContext(s.ctx))
+		if err = checkESResponse(res, err); err != nil {
+			return fmt.Errorf("failed to install index template %s: %v", s.config.ESTemplateName, err)
+		}
+	}
+	return nil
+}
+
+// retryTemplate installs the ILM policy and the index template with exponential backoff until it succeeds
+// or the transport is cleaned up.
+func (s *ElasticProto) retryTemplate() {
+	defer s.wg.Done()
+	backoff := time.Second
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-time.After(backoff):
+		}
+		err := s.installTemplate()
+		if err == nil {
+			logger.Info.Printf("Installed index template %s", s.config.ESTemplateName)
+			return
+		}
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+		logger.Warn.Printf("Index template not installed, retrying in %s: %v", backoff, err)
+	}
+}
+
+// checkESResponse returns an error if an Elastic API request failed.
+func checkESResponse(res *esapi.Response, err error) error {
+	if err != nil {
 		return err
 	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return errors.New(res.String())
+	}
+	return nil
+}
 
-	s.ctx = context.Background()
-	s.start = time.Now().UTC()
+// indexPattern returns the index pattern matching the indices named after an index name.
+func indexPattern(name string) string {
+	if i := strings.Index(name, "%"); i >= 0 {
+		return name[:i] + "*"
+	}
+	return name
+}
 
+// indexName returns the index of a record, derived from its timestamp if the index name has date patterns.
+func (s *ElasticProto) indexName(r *encoders.ECSRecord) string {
+	if !s.dated {
+		return s.config.ESIndex
+	}
+	t, err := time.Parse(time.RFC3339Nano, r.Ts)
+	if err != nil {
+		t = time.Now()
+	}
+	t = t.UTC()
+	if hour := t.Unix() / 3600; hour != s.hour || s.index == "" {
+		s.hour, s.index = hour, expandPattern(s.config.ESIndex, t, "")
+	}
+	return s.index
+}
+
+// Export adds the ecs data to the bulk indexer, along with documents whose ingestion failed in previous batches.
+func (s *ElasticProto) Export(data []commons.EncodedData) (err error) {
+	if err = s.requeue(s.bi); err != nil {
+		return err
+	}
 	for _, d := range data {
 		if r, ok := d.(*encoders.ECSRecord); ok {
 			body, err := json.Marshal(r)
@@ -98,41 +252,72 @@ func (s *ElasticProto) Export(data []commons.EncodedData) (err error) {
 				logger.Error.Println("Failed to create json")
 				return err
 			}
-
-			err = s.bi.Add(s.ctx, esutil.BulkIndexerItem{
-				Action:     "create",
-				DocumentID: r.ID,
-				Body:       bytes.NewReader(body),
-				OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
-					if err != nil {
-						logger.Error.Print(err)
-					} else {
-						logger.Error.Printf("%s: %s", res.Error.Type, res.Error.Reason)
-					}
-				},
-			})
-			if err != nil {
-				logger.Error.Println("Failed to add document")
+			if err = s.add(s.bi, &esDoc{index: s.indexName(r), id: r.ID, body: body}); err != nil {
 				return err
 			}
 		} else {
 			return errors.New("expected ECSRecord as exported data")
 		}
 	}
+	return
+}
 
-	if err = s.bi.Close(s.ctx); err != nil {
-		logger.Error.Println("Failed to close bulk indexer")
-		return err
+// add adds a document to bulk indexer bi.
+func (s *ElasticProto) add(bi esutil.BulkIndexer, doc *esDoc) error {
+	err := bi.Add(s.ctx, esutil.BulkIndexerItem{
+		Index:      doc.index,
+		Action:     "create",
+		DocumentID: doc.id,
+		Body:       bytes.NewReader(doc.body),
+		OnFailure: func(ctx context.Context, item esutil.BulkIndexerItem, res esutil.BulkIndexerResponseItem, err error) {
+			s.onFailure(doc, res, err)
+		},
+	})
+	if err != nil {
+		logger.Error.Println("Failed to add document")
 	}
+	return err
+}
 
-	duration := time.Since(s.start)
-	biStats := s.bi.Stats()
-	v := 1000.0 * float64(biStats.NumAdded) / float64(duration/time.Millisecond)
-	logger.Info.Printf("add=%d\tflush=%d\tfail=%d\treqs=%d\tdur=%-6s\t%6d recs/s",
-		biStats.NumAdded, biStats.NumFlushed, biStats.NumFailed, biStats.NumRequests,
-		duration.Truncate(time.Millisecond), int64(v))
+// onFailure queues a document for retry if its ingestion failed due to a transient error.
+func (s *ElasticProto) onFailure(doc *esDoc, res esutil.BulkIndexerResponseItem, err error) {
+	if err == nil && res.Status == http.StatusConflict {
+		// the document was ingested by a previous attempt
+		return
+	}
+	if (err != nil || res.Status == http.StatusTooManyRequests || res.Status >= http.StatusInternalServerError) && doc.attempts < s.config.ESRetryMax {
+		doc.attempts++
+		s.mu.Lock()
+		s.retries = append(s.retries, doc)
+		s.mu.Unlock()
+		return
+	}
+	if err != nil {
+		logger.Error.Print(err)
+	} else {
+		logger.Error.Printf("%s: %s", res.Error.Type, res.Error.Reason)
+	}
+}
 
-	return
+// requeue adds the documents queued for retry to bulk indexer bi.
+func (s *ElasticProto) requeue(bi esutil.BulkIndexer) error {
+	s.mu.Lock()
+	retries := s.retries
+	s.retries = nil
+	s.mu.Unlock()
+	for _, doc := range retries {
+		if err := s.add(bi, doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pending returns true if documents are queued for retry.
+func (s *ElasticProto) pending() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.retries) > 0
 }
 
 // Register registers the Elastic proto object with the exporter.
@@ -140,5 +325,38 @@ func (s *ElasticProto) Register(eps map[commons.Transport]TransportProtocolFacto
 	eps[commons.ESTransport] = NewElasticProto
 }
 
-// Cleanup  closes the Elastic connection.
-func (s *ElasticProto) Cleanup() {}
+// Cleanup stops template installation retries and flushes the bulk indexer, retrying failed documents until they are ingested or run out of attempts.
+func (s *ElasticProto) Cleanup() {
+	if s.stop != nil {
+		close(s.stop)
+		s.wg.Wait()
+		s.stop = nil
+	}
+	if s.bi == nil {
+		return
+	}
+	if err := s.bi.Close(s.ctx); err != nil {
+		logger.Error.Println("Failed to close bulk indexer")
+	}
+	stats := s.bi.Stats()
+	for s.pending() {
+		bi, err := s.newBulkIndexer()
+		if err != nil {
+			break
+		}
+		if err = s.requeue(bi); err == nil {
+			err = bi.Close(s.ctx)
+		}
+		if err != nil {
+			logger.Error.Println("Failed to retry documents")
+			break
+		}
+	}
+	s.bi = nil
+
+	duration := time.Since(s.start)
+	v := 1000.0 * float64(stats.NumAdded) / float64(duration/time.Millisecond+1)
+	logger.Info.Printf("add=%d\tflush=%d\tfail=%d\treqs=%d\tdur=%-6s\t%6d recs/s",
+		stats.NumAdded, stats.NumFlushed, stats.NumFailed, stats.NumRequests,
+		duration.Truncate(time.Millisecond), int64(v))
+}
@@ -0,0 +1,225 @@
+package transports_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+)
+
+// testCluster is a fake Elasticsearch cluster recording installed templates and policies, and ingested documents.
+// Documents listed in failures are rejected with the given status codes first, and the first unavailable
+// template and policy requests are rejected as if the cluster was down.
+type testCluster struct {
+	mu          sync.Mutex
+	unavailable int
+	templates   map[string]map[string]interface{}
+	policies    map[string]map[string]interface{}
+	docs        map[string][]string
+	failures    map[string][]int
+	bulks       int
+}
+
+func newTestCluster() *testCluster {
+	return &testCluster{
+		templates: make(map[string]map[string]interface{}),
+		policies:  make(map[string]map[string]interface{}),
+		docs:      make(map[string][]string),
+		failures:  make(map[string][]int),
+	}
+}
+
+func (c *testCluster) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	body, _ := io.ReadAll(r.Body)
+	switch {
+	case r.Method == http.MethodPut && c.unavailable > 0 && !strings.HasPrefix(r.URL.Path, "/_bulk"):
+		c.unavailable--
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_index_template/"):
+		var t map[string]interface{}
+		json.Unmarshal(body, &t)
+		c.templates[strings.TrimPrefix(r.URL.Path, "/_index_template/")] = t
+		io.WriteString(w, `{"acknowledged":true}`)
+	case r.Method == http.MethodPut && strings.HasPrefix(r.URL.Path, "/_ilm/policy/"):
+		var p map[string]interface{}
+		json.Unmarshal(body, &p)
+		c.policies[strings.TrimPrefix(r.URL.Path, "/_ilm/policy/")] = p
+		io.WriteString(w, `{"acknowledged":true}`)
+	case r.URL.Path == "/_bulk":
+		c.bulks++
+		var items []string
+		s := bufio.NewScanner(bytes.NewReader(body))
+		s.Buffer(make([]byte, 1<<20), 1<<20)
+		for s.Scan() {
+			var action map[string]struct {
+				Index string `json:"_index"`
+				ID    string `json:"_id"`
+			}
+			json.Unmarshal(s.Bytes(), &action)
+			s.Scan()
+			meta := action["create"]
+			status := http.StatusCreated
+			if codes := c.failures[meta.ID]; len(codes) > 0 {
+				status, c.failures[meta.ID] = codes[0], codes[1:]
+			}
+			if status == http.StatusCreated {
+				c.docs[meta.Index] = append(c.docs[meta.Index], meta.ID)
+			}
+			items = append(items, fmt.Sprintf(`{"create":{"_index":%q,"_id":%q,"status":%d,"error":{"type":"test_exception","reason":"status %d"}}}`, meta.Index, meta.ID, status, status))
+		}
+		fmt.Fprintf(w, `{"took":1,"errors":true,"items":[%s]}`, strings.Join(items, ","))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newElasticTestConfig(url string) commons.Config {
+	return commons.Config{
+		Format:    commons.ECSFormat,
+		Transport: commons.ESTransport,
+		ESConfig: commons.ESConfig{
+			ESAddresses:    []string{url},
+			ESIndex:        "sysflow-%Y.%m.%d",
+			ESNumWorkers:   1,
+			ESFlushBuffer:  5e+6,
+			ESFlushTimeout: time.Minute,
+			ESTemplateName: "sysflow",
+			ESRetryMax:     2,
+		},
+	}
+}
+
+func encodeECS(t *testing.T, config commons.Config) []commons.EncodedData {
+	data, err := encoders.NewECSEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	return data
+}
+
+func TestElasticProtoTemplate(t *testing.T) {
+	c := newTestCluster()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	dir := t.TempDir()
+	policy := filepath.Join(dir, "policy.json")
+	assert.NoError(t, os.WriteFile(policy, []byte(`{"policy":{"phases":{"delete":{"min_age":"30d","actions":{"delete":{}}}}}}`), 0644))
+	config := newElasticTestConfig(srv.URL)
+	config.ESTemplateMappings = "../../../resources/mappings/ecs_mapping.json"
+	config.ESILMPolicy = "sysflow-policy"
+	config.ESILMFile = policy
+	proto := transports.NewElasticProto(config)
+	assert.NoError(t, proto.Init())
+	proto.Cleanup()
+
+	assert.Contains(t, c.policies, "sysflow-policy")
+	tmpl := c.templates["sysflow"]
+	assert.Equal(t, []interface{}{"sysflow-*"}, tmpl["index_patterns"])
+	assert.NotContains(t, tmpl, "data_stream")
+	template := tmpl["template"].(map[string]interface{})
+	assert.Equal(t, "sysflow-policy", template["settings"].(map[string]interface{})["index.lifecycle.name"])
+	assert.Contains(t, template["mappings"].(map[string]interface{})["properties"], "process")
+
+	// data streams are matched by name
+	config = newElasticTestConfig(srv.URL)
+	config.ESIndex = "logs-sysflow-default"
+	config.ESDataStream = true
+	config.ESTemplateMappings = "../../../resources/mappings/ecs_mapping.json"
+	proto = transports.NewElasticProto(config)
+	assert.NoError(t, proto.Init())
+	proto.Cleanup()
+	tmpl = c.templates["sysflow"]
+	assert.Equal(t, []interface{}{"logs-sysflow-default"}, tmpl["index_patterns"])
+	assert.Contains(t, tmpl, "data_stream")
+
+	config.ESTemplateMappings = policy
+	assert.Error(t, transports.NewElasticProto(config).Init())
+}
+
+func TestElasticProtoTemplateRetry(t *testing.T) {
+	c := newTestCluster()
+	c.unavailable = 5 // more than the client retries
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	// the transport starts while the cluster is unavailable, and installs the template once it is back
+	config := newElasticTestConfig(srv.URL)
+	config.ESTemplateMappings = "../../../resources/mappings/ecs_mapping.json"
+	proto := transports.NewElasticProto(config)
+	assert.NoError(t, proto.Init())
+	assert.Eventually(t, func() bool {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		return c.templates["sysflow"] != nil
+	}, 10*time.Second, 10*time.Millisecond)
+	proto.Cleanup()
+
+	// retries stop on cleanup
+	config.ESAddresses = []string{"http://127.0.0.1:1"}
+	proto = transports.NewElasticProto(config)
+	assert.NoError(t, proto.Init())
+	proto.Cleanup()
+}
+
+func TestElasticProtoExport(t *testing.T) {
+	c := newTestCluster()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	config := newElasticTestConfig(srv.URL)
+	proto := transports.NewElasticProto(config)
+	assert.NoError(t, proto.Init())
+	assert.Empty(t, c.templates)
+
+	data := encodeECS(t, config)
+	ids := make([]string, len(data))
+	for i, d := range data {
+		ids[i] = d.(*encoders.ECSRecord).ID
+	}
+	// transient failures are retried, up to es.retry.max times
+	c.failures[ids[0]] = []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}
+	c.failures[ids[1]] = []int{http.StatusBadRequest}
+	c.failures[ids[2]] = []int{http.StatusTooManyRequests, http.StatusTooManyRequests, http.StatusTooManyRequests}
+	assert.NoError(t, proto.Export(data))
+	proto.Cleanup()
+
+	assert.Equal(t, map[string][]string{"sysflow-2022.10.17": {ids[0]}}, c.docs)
+	assert.Equal(t, 3, c.bulks)
+	assert.Equal(t, []int{}, c.failures[ids[0]])
+}
+
+func TestElasticProtoConfig(t *testing.T) {
+	conf := map[string]interface{}{"export": "es", "format": "ecs", "es.index": "sysflow-%Y.%m.%d"}
+	c, err := commons.CreateConfig(conf)
+	assert.NoError(t, err)
+	assert.Equal(t, "sysflow", c.ESTemplateName)
+	assert.Equal(t, 3, c.ESRetryMax)
+
+	conf["es.index"] = "sysflow-%y"
+	_, err = commons.CreateConfig(conf)
+	assert.Error(t, err)
+
+	conf["es.index"], conf["es.datastream"] = "sysflow-%Y", "true"
+	_, err = commons.CreateConfig(conf)
+	assert.Error(t, err)
+
+	conf["es.index"], conf["es.ilm.file"] = "logs-sysflow-default", "policy.json"
+	_, err = commons.CreateConfig(conf)
+	assert.Error(t, err)
+}
@@ -21,52 +21,355 @@
 package transports
 
 import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/klauspost/compress/zstd"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/tidwall/gjson"
 )
 
-// TextFileProto implements the TransportProtocol interface for a text file.
+// fileExtensions maps compression options to the extensions of compressed files.
+var fileExtensions = map[commons.Compression]string{
+	commons.GzipCompression: ".gz",
+	commons.ZstdCompression: ".zst",
+}
+
+// TextFileProto implements the TransportProtocol interface for text files, holding one record per line.
+// Binary records (e.g., Avro, Protobuf) are written as a stream of records, each prefixed with its length as a varint.
+// By default, records are written to a single file, truncated at startup. If the file path contains
+// patterns, or files are rotated or pruned, records are appended to the file named after the exporter
+// clock and record node ID. A file is rotated to <path>.<timestamp> once it reaches its maximum size or
+// age, and closed once the clock moves to a new path. Closed files are compressed and pruned in the background.
 type TextFileProto struct {
-	config  commons.Config
-	fhandle *os.File
+	config   commons.Config
+	clock    clock.Clock
+	mu       sync.Mutex // guards files, which is read by the background worker
+	files    map[string]*textFile
+	rotating bool
+	perNode  bool
+	lastTs   int64
+	jobs     chan string
+	done     chan struct{}
+}
+
+// textFile is a text file being written.
+type textFile struct {
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+	size   int64
+	opened time.Time
 }
 
 // NewTextFileProto creates a new text file protcol object.
 func NewTextFileProto(conf commons.Config) TransportProtocol {
-	return &TextFileProto{config: conf}
+	return &TextFileProto{
+		config:   conf,
+		clock:    clock.New(conf.Clock),
+		files:    make(map[string]*textFile),
+		rotating: conf.FileMaxSize > 0 || conf.FileInterval > 0 || conf.FileMaxFiles > 0 || conf.FileMaxAge > 0 || strings.Contains(conf.Path, "%"),
+		perNode:  expandPattern(conf.Path, time.Time{}, "a") != expandPattern(conf.Path, time.Time{}, "b"),
+	}
 }
 
-// Init initializes the text file.
+// Init initializes the text file. If files are rotated, they are opened when the first record is
+// exported, and previously rotated files are pruned.
 func (s *TextFileProto) Init() error {
-	os.Remove(s.config.Path)
-	f, err := os.OpenFile(s.config.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-	s.fhandle = f
-	return err
+	if !s.rotating {
+		os.Remove(s.config.Path)
+		_, err := s.open("", s.config.Path)
+		return err
+	}
+	s.jobs, s.done = make(chan string, 16), make(chan struct{})
+	go s.work()
+	s.jobs <- ""
+	return nil
 }
 
-// Export writes the buffer to the open file.
-func (s *TextFileProto) Export(data []commons.EncodedData) (err error) {
+// Export writes the buffer to the open files, and flushes them.
+func (s *TextFileProto) Export(data []commons.EncodedData) error {
 	for _, d := range data {
-		if buf, ok := d.([]byte); ok {
-			if _, err = s.fhandle.Write(buf); err != nil {
+		f, err := s.fileOf(d)
+		if err != nil {
+			return err
+		}
+		if rec, ok := d.(*encoders.BinaryRecord); ok {
+			var prefix [binary.MaxVarintLen64]byte
+			n, _ := f.writer.Write(prefix[:binary.PutUvarint(prefix[:], uint64(len(rec.Data)))])
+			f.size += int64(n)
+			n, err = f.writer.Write(rec.Data)
+			f.size += int64(n)
+			if err != nil {
 				return err
 			}
-			s.fhandle.WriteString("\n")
-		} else if buf, err := json.Marshal(d); err == nil {
-			if _, err = s.fhandle.Write(buf); err != nil {
+			continue
+		}
+		n, err := f.writer.Write(getTextLine(d))
+		f.size += int64(n)
+		if err != nil {
+			return err
+		}
+		if err = f.writer.WriteByte('\n'); err != nil {
+			return err
+		}
+		f.size++
+	}
+	for _, f := range s.files {
+		if err := f.writer.Flush(); err != nil {
+			return err
+		}
+		if s.config.FileFsync {
+			if err := f.file.Sync(); err != nil {
 				return err
 			}
-			s.fhandle.WriteString("\n")
-		} else {
-			if _, err = s.fhandle.WriteString(fmt.Sprintf("%v\n", d)); err != nil {
-				return err
+		}
+	}
+	return nil
+}
+
+// getTextLine returns the text line of an encoded record.
+func getTextLine(d commons.EncodedData) []byte {
+	if buf, ok := d.([]byte); ok {
+		return buf
+	} else if rec, ok := d.(*encoders.SIEMRecord); ok {
+		return rec.Event
+	} else if buf, err := json.Marshal(d); err == nil {
+		return buf
+	}
+	return []byte(fmt.Sprintf("%v", d))
+}
+
+// getTextRecordMeta returns the timestamp and node ID of an encoded record.
+func getTextRecordMeta(d commons.EncodedData) (ts int64, node string) {
+	switch d := d.(type) {
+	case []byte:
+		res := gjson.GetManyBytes(d, "ts", "node.id")
+		return res[0].Int(), res[1].String()
+	case *encoders.ECSRecord:
+		meta := getECSSyslogMeta(d)
+		return meta.ts.UnixNano(), meta.host
+	case *encoders.SIEMRecord:
+		return d.Ts, d.Host
+	case *encoders.BinaryRecord:
+		return d.Ts, d.NodeID
+	}
+	return 0, ""
+}
+
+// fileOf returns the file to which a record is written, closing or rotating the files of its node
+// if needed, and opening a new file if none is open.
+func (s *TextFileProto) fileOf(d commons.EncodedData) (*textFile, error) {
+	if !s.rotating {
+		return s.files[""], nil
+	}
+	var node string
+	if s.perNode || s.config.Clock == clock.EventMode {
+		var ts int64
+		ts, node = getTextRecordMeta(d)
+		s.clock.Advance(ts)
+	}
+	if !s.perNode {
+		node = ""
+	}
+	path := expandPattern(s.config.Path, s.clock.Now().UTC(), getPartitionName(node))
+	f, ok := s.files[node]
+	if ok && f.path != path {
+		if err := s.close(node, false); err != nil {
+			return nil, err
+		}
+		ok = false
+	} else if ok && ((s.config.FileMaxSize > 0 && f.size >= s.config.FileMaxSize) ||
+		(s.config.FileInterval > 0 && s.clock.Since(f.opened) >= s.config.FileInterval)) {
+		if err := s.close(node, true); err != nil {
+			return nil, err
+		}
+		ok = false
+	}
+	if ok {
+		return f, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	return s.open(node, path)
+}
+
+// open opens the file at path in append mode, as the file of node.
+func (s *TextFileProto) open(node string, path string) (*textFile, error) {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	f := &textFile{path: path, file: file, writer: bufio.NewWriter(file), opened: s.clock.Now()}
+	if fi, err := file.Stat(); err == nil {
+		f.size = fi.Size()
+	}
+	s.mu.Lock()
+	s.files[node] = f
+	s.mu.Unlock()
+	if s.rotating {
+		logger.Info.Printf("Writing text file %s", path)
+	}
+	return f, nil
+}
+
+// close flushes and closes the file of node. If rotate is set, the file is renamed to <path>.<timestamp>.
+// Closed files are then handed to the background worker.
+func (s *TextFileProto) close(node string, rotate bool) error {
+	f, ok := s.files[node]
+	if !ok {
+		return nil
+	}
+	s.mu.Lock()
+	delete(s.files, node)
+	s.mu.Unlock()
+	err := f.writer.Flush()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	path := f.path
+	if rotate {
+		// rotated files are ordered by the timestamp in their names, which must be unique
+		ts := s.clock.Now().Unix()
+		if ts <= s.lastTs {
+			ts = s.lastTs + 1
+		}
+		for ; ; ts++ {
+			path = fmt.Sprintf("%s.%d", f.path, ts)
+			if _, err := os.Stat(path); os.IsNotExist(err) {
+				break
+			}
+		}
+		s.lastTs = ts
+		if err := os.Rename(f.path, path); err != nil {
+			return err
+		}
+	}
+	s.jobs <- path
+	return nil
+}
+
+// work compresses the closed files sent to the jobs channel, and prunes the closed files
+// exceeding the retention policy, until the jobs channel is closed.
+func (s *TextFileProto) work() {
+	defer close(s.done)
+	for path := range s.jobs {
+		if path != "" && s.config.FileCompression != commons.NoCompression {
+			if err := compressFile(path, s.config.FileCompression); err != nil {
+				logger.Error.Printf("Unable to compress text file %s: %v", path, err)
+			}
+		}
+		if s.config.FileMaxFiles > 0 || s.config.FileMaxAge > 0 {
+			s.prune()
+		}
+	}
+}
+
+// compressFile appends the compressed content of the file at path to <path>.<extension>, and removes it.
+// Appending keeps the content of files closed more than once, as gzip members and zstd frames can be concatenated.
+func compressFile(path string, c commons.Compression) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(path+fileExtensions[c], os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	var w io.WriteCloser
+	if c == commons.ZstdCompression {
+		if w, err = zstd.NewWriter(out); err != nil {
+			out.Close()
+			return err
+		}
+	} else {
+		w = gzip.NewWriter(out)
+	}
+	_, err = io.Copy(w, in)
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// prune removes the closed files older than the maximum age, and the oldest closed files in excess
+// of the maximum number of files. Closed files are the files matching the file path pattern, followed
+// by any suffix, which are not open.
+func (s *TextFileProto) prune() {
+	matches, err := filepath.Glob(getPatternGlob(s.config.Path) + "*")
+	if err != nil {
+		logger.Error.Println("Unable to list text files: ", err)
+		return
+	}
+	open := make(map[string]bool)
+	s.mu.Lock()
+	for _, f := range s.files {
+		open[f.path] = true
+	}
+	s.mu.Unlock()
+	var files []os.FileInfo
+	paths := make(map[os.FileInfo]string)
+	for _, m := range matches {
+		if fi, err := os.Stat(m); err == nil && fi.Mode().IsRegular() && !open[m] {
+			files = append(files, fi)
+			paths[fi] = m
+		}
+	}
+	// newest files first
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().After(files[j].ModTime()) })
+	for i, fi := range files {
+		if (s.config.FileMaxFiles > 0 && i >= s.config.FileMaxFiles) ||
+			(s.config.FileMaxAge > 0 && time.Since(fi.ModTime()) > s.config.FileMaxAge) {
+			if err := os.Remove(paths[fi]); err != nil {
+				logger.Error.Println("Unable to remove text file: ", err)
 			}
 		}
 	}
-	return
+}
+
+// getPatternGlob returns a glob matching the expansions of path pattern s.
+func getPatternGlob(s string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '%' && i+1 < len(s) && s[i+1] == '%':
+			sb.WriteByte('%')
+			i++
+		case s[i] == '%' && i+1 < len(s):
+			sb.WriteByte('*')
+			i++
+		case strings.IndexByte(`*?[\`, s[i]) >= 0:
+			sb.WriteByte('\\')
+			sb.WriteByte(s[i])
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
 }
 
 // Register registers the text file proto object with the exporter.
@@ -74,9 +377,21 @@ func (s *TextFileProto) Register(eps map[commons.Transport]TransportProtocolFact
 	eps[commons.FileTransport] = NewTextFileProto
 }
 
-// Cleanup closes the text file.
+// Cleanup closes the text files, which are neither rotated nor compressed so that they are appended
+// to when the exporter restarts, and waits for the background worker.
 func (s *TextFileProto) Cleanup() {
-	if s.fhandle != nil {
-		s.fhandle.Close()
+	for _, f := range s.files {
+		if err := f.writer.Flush(); err != nil {
+			logger.Error.Println("Unable to flush text file: ", err)
+		}
+		f.file.Close()
+	}
+	s.mu.Lock()
+	s.files = make(map[string]*textFile)
+	s.mu.Unlock()
+	if s.jobs != nil {
+		close(s.jobs)
+		<-s.done
+		s.jobs = nil
 	}
 }
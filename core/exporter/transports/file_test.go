@@ -0,0 +1,183 @@
+package transports_test
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+func encodeJSON(t *testing.T, recs ...*engine.Record) []commons.EncodedData {
+	data, err := encoders.NewJSONEncoder(commons.Config{JSONSchemaVersion: "4"}).Encode(recs)
+	assert.NoError(t, err)
+	return data
+}
+
+// countLines returns the number of lines of a text file, decompressing it if needed.
+func countLines(t *testing.T, path string) (n int) {
+	f, err := os.Open(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer f.Close()
+	var s *bufio.Scanner
+	switch filepath.Ext(path) {
+	case ".gz":
+		r, err := gzip.NewReader(f)
+		assert.NoError(t, err)
+		s = bufio.NewScanner(r)
+	case ".zst":
+		r, err := zstd.NewReader(f)
+		assert.NoError(t, err)
+		defer r.Close()
+		s = bufio.NewScanner(r)
+	default:
+		s = bufio.NewScanner(f)
+	}
+	for s.Scan() {
+		n++
+	}
+	return
+}
+
+func TestTextFileProto(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "export.out")
+	assert.NoError(t, os.WriteFile(path, []byte("stale\n"), 0644))
+	proto := transports.NewTextFileProto(commons.Config{FileConfig: commons.FileConfig{Path: path}})
+	assert.NoError(t, proto.Init())
+	assert.NoError(t, proto.Export(encodeJSON(t, newOTLPTestRecords()...)))
+	proto.Cleanup()
+
+	// the file is truncated at startup
+	assert.Equal(t, 3, countLines(t, path))
+}
+
+func TestTextFileProtoBinary(t *testing.T) {
+	dir := t.TempDir()
+	config := commons.Config{Clock: clock.EventMode, JSONSchemaVersion: "5", FileConfig: commons.FileConfig{Path: filepath.Join(dir, "%n.pb")}}
+	data, err := encoders.NewProtobufEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	proto := transports.NewTextFileProto(config)
+	assert.NoError(t, proto.Init())
+	assert.NoError(t, proto.Export(data))
+	proto.Cleanup()
+
+	// binary records are length-delimited, and routed by node
+	buf, err := os.ReadFile(filepath.Join(dir, "node1.pb"))
+	assert.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		n, k := binary.Uvarint(buf)
+		if !assert.Greater(t, k, 0) {
+			return
+		}
+		assert.Equal(t, data[i].(*encoders.BinaryRecord).Data, buf[k:k+int(n)])
+		buf = buf[k+int(n):]
+	}
+	assert.Empty(t, buf)
+	assert.FileExists(t, filepath.Join(dir, "node2.pb"))
+}
+
+func TestTextFileProtoRotation(t *testing.T) {
+	dir := t.TempDir()
+	config := commons.Config{
+		Clock: clock.EventMode,
+		FileConfig: commons.FileConfig{
+			Path:            filepath.Join(dir, "%n", "alerts-%Y%m%d.json"),
+			FileInterval:    10 * time.Second,
+			FileCompression: commons.GzipCompression,
+		},
+	}
+	proto := transports.NewTextFileProto(config)
+	assert.NoError(t, proto.Init())
+
+	sec, day := int64(time.Second), int64(24*time.Hour)
+	assert.NoError(t, proto.Export(encodeJSON(t,
+		newTestRecord("node1", "/bin/sh", testTs),
+		newTestRecord("node2", "/bin/sh", testTs),
+		newTestRecord("node1", "/bin/ls", testTs+sec),
+	)))
+	assert.NoError(t, proto.Export(encodeJSON(t,
+		newTestRecord("node1", "/bin/sh", testTs+12*sec), // interval elapsed, node1 file rotated
+		newTestRecord("node1", "/bin/sh", testTs+day),    // new date, node1 file closed
+	)))
+	proto.Cleanup()
+
+	// files are appended to when the exporter restarts
+	proto = transports.NewTextFileProto(config)
+	assert.NoError(t, proto.Init())
+	assert.NoError(t, proto.Export(encodeJSON(t, newTestRecord("node1", "/bin/ps", testTs+day+sec))))
+	proto.Cleanup()
+
+	rotated := filepath.Join(dir, "node1", "alerts-20221017.json."+strconv.FormatInt((testTs+12*sec)/sec, 10)+".gz")
+	files, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(dir, "node1", "alerts-20221017.json.gz"),
+		filepath.Join(dir, "node1", "alerts-20221018.json"),
+		filepath.Join(dir, "node2", "alerts-20221017.json"),
+		rotated,
+	}, files)
+	assert.Equal(t, 2, countLines(t, rotated))
+	assert.Equal(t, 1, countLines(t, filepath.Join(dir, "node1", "alerts-20221017.json.gz")))
+	assert.Equal(t, 2, countLines(t, filepath.Join(dir, "node1", "alerts-20221018.json")))
+	assert.Equal(t, 1, countLines(t, filepath.Join(dir, "node2", "alerts-20221017.json")))
+}
+
+func TestTextFileProtoRetention(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "alerts.json")
+	old := path + ".1000.zst"
+	assert.NoError(t, os.WriteFile(old, nil, 0644))
+	assert.NoError(t, os.Chtimes(old, time.Now().Add(-48*time.Hour), time.Now().Add(-48*time.Hour)))
+	config := commons.Config{
+		FileConfig: commons.FileConfig{
+			Path:            path,
+			FileMaxSize:     1,
+			FileCompression: commons.ZstdCompression,
+			FileMaxFiles:    1,
+			FileMaxAge:      24 * time.Hour,
+			FileFsync:       true,
+		},
+	}
+	proto := transports.NewTextFileProto(config)
+	assert.NoError(t, proto.Init())
+	for _, r := range newOTLPTestRecords() {
+		assert.NoError(t, proto.Export(encodeJSON(t, r)))
+		time.Sleep(10 * time.Millisecond)
+	}
+	proto.Cleanup()
+
+	// files older than the maximum age, and the oldest rotated files, are removed
+	files, err := filepath.Glob(path + "*")
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+	assert.Contains(t, files, path)
+	assert.NotContains(t, files, old)
+	for _, f := range files {
+		assert.Equal(t, 1, countLines(t, f))
+	}
+}
+
+func TestTextFileProtoConfig(t *testing.T) {
+	c, err := commons.CreateConfig(map[string]interface{}{"export": "file", "file.path": "/var/log/sysflow/%n/alerts-%Y-%m-%d.json", "file.maxsize": "100", "file.compression": "zstd"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(100<<20), c.FileMaxSize)
+	assert.Equal(t, commons.ZstdCompression, c.FileCompression)
+
+	_, err = commons.CreateConfig(map[string]interface{}{"export": "file", "file.path": "alerts-%y.json"})
+	assert.Error(t, err)
+	_, err = commons.CreateConfig(map[string]interface{}{"export": "file", "file.compression": "snappy"})
+	assert.Error(t, err)
+}
@@ -36,8 +36,9 @@ import (
 )
 
 const (
-	details     = "Finding Context"
-	queryURLFmt = "%s/?instance_crn=%s&statement=%s"
+	details        = "Finding Context"
+	queryURLFmt    = "%s/?instance_crn=%s&statement=%s"
+	sqlQueryStrFmt = "SELECT * FROM cos://%s/%s/%s STORED AS AVRO LIMIT 500 INTO cos://%s/%s/sql-query"
 )
 
 // FindingsAPIProto implements a custom client for IBM Cloud Security and Compliance Insights.
@@ -49,6 +50,8 @@ type FindingsAPIProto struct {
 	SQLQueryURL string
 	SQLQueryCrn string
 	Region      string
+	S3Region    string
+	S3Bucket    string
 }
 
 // NewFindingsAPIProto is a constructor for FindingsAPIProto.
@@ -59,7 +62,9 @@ func NewFindingsAPIProto(conf commons.Config) TransportProtocol {
 		FindingsURL: conf.FindingsURL,
 		SQLQueryURL: conf.FindingsSQLQueryURL,
 		SQLQueryCrn: conf.FindingsSQLQueryCrn,
-		Region:      conf.FindingsRegion}
+		Region:      conf.FindingsRegion,
+		S3Region:    conf.FindingsS3Region,
+		S3Bucket:    conf.FindingsS3Bucket}
 }
 
 // Init intializes a new protocol object.
@@ -107,10 +112,12 @@ func (s *FindingsAPIProto) CreateOccurrence(occ *encoders.Occurrence) error {
 
 	noteName := fmt.Sprintf("%s/providers/%s/notes/%s", s.AccountID, s.ProviderID, occ.NoteID())
 	var nextStep []findingsv1.RemediationStep
-	if occ.AlertQuery != "" {
+	if occ.Events != "" {
+		// the context events are queried from the bucket holding the event files
+		query := fmt.Sprintf(sqlQueryStrFmt, s.S3Region, s.S3Bucket, occ.Events, s.S3Region, s.S3Bucket)
 		nextStep = []findingsv1.RemediationStep{{
 			Title: core.StringPtr(details),
-			URL:   core.StringPtr(fmt.Sprintf(queryURLFmt, s.SQLQueryURL, s.SQLQueryCrn, occ.AlertQuery))},
+			URL:   core.StringPtr(fmt.Sprintf(queryURLFmt, s.SQLQueryURL, s.SQLQueryCrn, query))},
 		}
 	}
 	finding := findingsv1.Finding{Severity: core.StringPtr(occ.Severity.String()), Certainty: core.StringPtr(occ.Certainty.String()), NextSteps: nextStep}
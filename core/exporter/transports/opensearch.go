@@ -0,0 +1,53 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"fmt"
+
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+)
+
+// OpenSearchProto implements the TransportProtocol interface for OpenSearch.
+// OpenSearch provides the bulk and index template APIs of Elasticsearch, so ECS records are
+// ingested as by the Elastic transport, using the same configuration keys.
+type OpenSearchProto struct {
+	*ElasticProto
+}
+
+// NewOpenSearchProto creates a new OpenSearch protocol object.
+func NewOpenSearchProto(conf commons.Config) TransportProtocol {
+	return &OpenSearchProto{&ElasticProto{config: conf}}
+}
+
+// Init initializes the OpenSearch client, installs the index template, and starts the bulk indexer.
+func (s *OpenSearchProto) Init() error {
+	// index lifecycles are managed by ISM policies in OpenSearch, which select indices on their own
+	if s.config.ESILMPolicy != "" || s.config.ESILMFile != "" {
+		return fmt.Errorf("export '%s' does not support ILM policies, use ISM policies with an ISM template instead", commons.OpenSearchTransport)
+	}
+	return s.ElasticProto.Init()
+}
+
+// Register registers the OpenSearch proto object with the exporter.
+func (s *OpenSearchProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.OpenSearchTransport] = NewOpenSearchProto
+}
@@ -0,0 +1,238 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	otlpScopeName   = "sf-processor"
+	otlpLogsPath    = "/v1/logs"
+	otlpContentType = "application/x-protobuf"
+)
+
+// OTLPProto implements the TransportProtocol interface for OpenTelemetry collectors.
+// Each batch of records is sent in a single export request, which is retried with
+// exponential backoff if the collector is unavailable or asks to retry.
+type OTLPProto struct {
+	config commons.Config
+	scope  *commonpb.InstrumentationScope
+	conn   *grpc.ClientConn
+	client collogspb.LogsServiceClient
+	http   *http.Client
+	url    string
+}
+
+// NewOTLPProto creates a new OTLP protocol object.
+func NewOTLPProto(conf commons.Config) TransportProtocol {
+	return &OTLPProto{config: conf}
+}
+
+// Init initializes the connection to the collector.
+func (s *OTLPProto) Init() error {
+	if s.config.Format != commons.OTLPFormat {
+		return fmt.Errorf("export '%s' requires format '%s'", commons.OTLPTransport, commons.OTLPFormat)
+	}
+	s.scope = &commonpb.InstrumentationScope{Name: otlpScopeName, Version: s.config.Version}
+	var tlsConfig *tls.Config
+	var err error
+	if s.config.OTLPTLS {
+		tlsConfig, err = newTLSConfig(s.config.OTLPTLSCA, s.config.OTLPTLSCert, s.config.OTLPTLSKey, s.config.OTLPTLSSkipVerify)
+		if err != nil {
+			return err
+		}
+	}
+	if s.config.OTLPProtocol == commons.HTTPProto {
+		scheme := "http"
+		if tlsConfig != nil {
+			scheme = "https"
+		}
+		s.url = fmt.Sprintf("%s://%s%s", scheme, s.config.OTLPEndpoint, otlpLogsPath)
+		s.http = &http.Client{Transport: &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}}
+		return nil
+	}
+	creds := insecure.NewCredentials()
+	if tlsConfig != nil {
+		creds = credentials.NewTLS(tlsConfig)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if s.config.OTLPCompression == commons.GzipCompression {
+		opts = append(opts, grpc.WithDefaultCallOptions(grpc.UseCompressor(grpcgzip.Name)))
+	}
+	// the connection is established lazily, so that the processor starts while the collector is down
+	if s.conn, err = grpc.Dial(s.config.OTLPEndpoint, opts...); err != nil {
+		return err
+	}
+	s.client = collogspb.NewLogsServiceClient(s.conn)
+	return nil
+}
+
+// Export sends a batch of log records to the collector.
+func (s *OTLPProto) Export(data []commons.EncodedData) error {
+	req := &collogspb.ExportLogsServiceRequest{}
+	scopes := make(map[*resourcepb.Resource]*logspb.ScopeLogs)
+	for _, d := range data {
+		r, ok := d.(*encoders.OTLPRecord)
+		if !ok {
+			return fmt.Errorf("unsupported data type %T for export '%s'", d, commons.OTLPTransport)
+		}
+		sl, ok := scopes[r.Resource]
+		if !ok {
+			sl = &logspb.ScopeLogs{Scope: s.scope}
+			scopes[r.Resource] = sl
+			req.ResourceLogs = append(req.ResourceLogs, &logspb.ResourceLogs{Resource: r.Resource, ScopeLogs: []*logspb.ScopeLogs{sl}})
+		}
+		sl.LogRecords = append(sl.LogRecords, r.Log)
+	}
+
+	r := retrier{max: s.config.OTLPRetryMax, backoff: s.config.OTLPRetryBackoff}
+	err := r.do(fmt.Sprintf("export %d log records", len(data)), func() (time.Duration, error) {
+		var resp *collogspb.ExportLogsServiceResponse
+		var delay time.Duration
+		var err error
+		if s.client != nil {
+			resp, delay, err = s.exportGRPC(req)
+		} else {
+			resp, delay, err = s.exportHTTP(req)
+		}
+		if ps := resp.GetPartialSuccess(); err == nil && ps.GetRejectedLogRecords() > 0 {
+			logger.Warn.Printf("Collector rejected %d of %d log records: %s", ps.GetRejectedLogRecords(), len(data), ps.GetErrorMessage())
+		}
+		return delay, err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export %d log records: %v", len(data), err)
+	}
+	return nil
+}
+
+// exportGRPC sends an export request over gRPC. On failure, it returns a negative delay if
+// the request must not be retried.
+func (s *OTLPProto) exportGRPC(req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.OTLPTimeout)
+	defer cancel()
+	if len(s.config.OTLPHeaders) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, metadata.New(s.config.OTLPHeaders))
+	}
+	resp, err := s.client.Export(ctx, req)
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Canceled, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted,
+			codes.OutOfRange, codes.Unavailable, codes.DataLoss:
+			return nil, 0, err
+		}
+		return nil, -1, err
+	}
+	return resp, 0, nil
+}
+
+// exportHTTP sends an export request over HTTP. On failure, it returns a negative delay if the
+// request must not be retried, or the delay requested by the collector before retrying.
+func (s *OTLPProto) exportHTTP(req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, time.Duration, error) {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return nil, -1, err
+	}
+	if s.config.OTLPCompression == commons.GzipCompression {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err = zw.Write(body); err == nil {
+			err = zw.Close()
+		}
+		if err != nil {
+			return nil, -1, err
+		}
+		body = buf.Bytes()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.OTLPTimeout)
+	defer cancel()
+	hreq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, -1, err
+	}
+	for k, v := range s.config.OTLPHeaders {
+		hreq.Header.Set(k, v)
+	}
+	hreq.Header.Set("Content-Type", otlpContentType)
+	if s.config.OTLPCompression == commons.GzipCompression {
+		hreq.Header.Set("Content-Encoding", "gzip")
+	}
+	hresp, err := s.http.Do(hreq)
+	if err != nil {
+		// connection errors and timeouts are transient
+		return nil, 0, err
+	}
+	defer hresp.Body.Close()
+	rbody, err := io.ReadAll(hresp.Body)
+	if err != nil {
+		return nil, 0, err
+	}
+	switch hresp.StatusCode {
+	case http.StatusOK:
+		resp := &collogspb.ExportLogsServiceResponse{}
+		if err = proto.Unmarshal(rbody, resp); err != nil {
+			logger.Warn.Printf("Unable to parse collector response: %v", err)
+		}
+		return resp, 0, nil
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return nil, retryAfter(hresp), fmt.Errorf("collector responded with status %s", hresp.Status)
+	}
+	return nil, -1, fmt.Errorf("collector responded with status %s", hresp.Status)
+}
+
+// Register registers the OTLP proto object with the exporter.
+func (s *OTLPProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.OTLPTransport] = NewOTLPProto
+}
+
+// Cleanup closes the collector connection.
+func (s *OTLPProto) Cleanup() {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.http != nil {
+		s.http.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,212 @@
+package transports_test
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// testCollector is an OTLP logs service failing the first requests with the given errors.
+type testCollector struct {
+	collogspb.UnimplementedLogsServiceServer
+	mu       sync.Mutex
+	errs     []error
+	calls    int
+	reqs     []*collogspb.ExportLogsServiceRequest
+	metadata metadata.MD
+}
+
+func (c *testCollector) Export(ctx context.Context, req *collogspb.ExportLogsServiceRequest) (*collogspb.ExportLogsServiceResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	c.metadata, _ = metadata.FromIncomingContext(ctx)
+	if len(c.errs) > 0 {
+		err := c.errs[0]
+		c.errs = c.errs[1:]
+		return nil, err
+	}
+	c.reqs = append(c.reqs, req)
+	return &collogspb.ExportLogsServiceResponse{}, nil
+}
+
+func newOTLPTestRecords() []*engine.Record {
+	recs := []*engine.Record{
+		newTestRecord("node1", "/bin/sh", testTs),
+		newTestRecord("node1", "/bin/ls", testTs+1),
+		newTestRecord("node2", "/bin/ps", testTs+2),
+	}
+	for _, r := range recs[:2] {
+		r.Fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_ID_STR] = "392abdfb220e"
+		r.Fr.Strs[sfgo.SYSFLOW_IDX][sfgo.CONT_NAME_STR] = "web"
+	}
+	recs[1].Ctx.AddRule(engine.Rule{Name: "shell", Desc: "shell spawned", Priority: engine.High})
+	return recs
+}
+
+func getAttr(attrs []*commonpb.KeyValue, key string) *commonpb.AnyValue {
+	for _, a := range attrs {
+		if a.Key == key {
+			return a.Value
+		}
+	}
+	return nil
+}
+
+func checkOTLPRequest(t *testing.T, req *collogspb.ExportLogsServiceRequest) {
+	// records are grouped by resource
+	assert.Len(t, req.ResourceLogs, 2)
+	res := req.ResourceLogs[0]
+	assert.Equal(t, "node1", getAttr(res.Resource.Attributes, encoders.OTLP_HOST_NAME).GetStringValue())
+	assert.Equal(t, "cluster1", getAttr(res.Resource.Attributes, encoders.OTLP_CLUSTER_NAME).GetStringValue())
+	assert.Equal(t, "392abdfb220e", getAttr(res.Resource.Attributes, encoders.OTLP_CONTAINER_ID).GetStringValue())
+	assert.Equal(t, "web", getAttr(res.Resource.Attributes, encoders.OTLP_CONTAINER_NAME).GetStringValue())
+	assert.Equal(t, "sf-processor", res.ScopeLogs[0].Scope.Name)
+	logs := res.ScopeLogs[0].LogRecords
+	assert.Len(t, logs, 2)
+	assert.Equal(t, uint64(testTs), logs[0].TimeUnixNano)
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_WARN, logs[0].SeverityNumber)
+	assert.Equal(t, logspb.SeverityNumber_SEVERITY_NUMBER_FATAL, logs[1].SeverityNumber)
+	assert.Equal(t, "high", logs[1].SeverityText)
+	assert.Equal(t, "PE", getAttr(logs[1].Attributes, encoders.OTLP_SF_TYPE).GetStringValue())
+	assert.Equal(t, "/bin/ls", getAttr(logs[1].Attributes, encoders.OTLP_PROC_EXE).GetStringValue())
+	rules := getAttr(logs[1].Attributes, encoders.OTLP_SF_RULES).GetArrayValue().GetValues()
+	assert.Len(t, rules, 2)
+	assert.Equal(t, "shell", rules[1].GetStringValue())
+	assert.Equal(t, "mitre:T1059", getAttr(logs[1].Attributes, encoders.OTLP_SF_TAGS).GetArrayValue().GetValues()[0].GetStringValue())
+	assert.True(t, strings.HasPrefix(logs[1].Body.GetStringValue(), "{"))
+	assert.Contains(t, logs[1].Body.GetStringValue(), `"/bin/ls"`)
+
+	res = req.ResourceLogs[1]
+	assert.Equal(t, "node2", getAttr(res.Resource.Attributes, encoders.OTLP_HOST_NAME).GetStringValue())
+	assert.Nil(t, getAttr(res.Resource.Attributes, encoders.OTLP_CONTAINER_ID))
+	assert.Len(t, res.ScopeLogs[0].LogRecords, 1)
+}
+
+func newOTLPTestConfig(proto commons.OTLPProto, endpoint string) commons.Config {
+	return commons.Config{
+		Format:    commons.OTLPFormat,
+		ClusterID: "cluster1",
+		OTLPConfig: commons.OTLPConfig{
+			OTLPProtocol:     proto,
+			OTLPEndpoint:     endpoint,
+			OTLPHeaders:      map[string]string{"x-tenant": "sec"},
+			OTLPTimeout:      time.Second,
+			OTLPRetryMax:     2,
+			OTLPRetryBackoff: time.Millisecond,
+		},
+	}
+}
+
+func startTestCollector(t *testing.T, c *testCollector) string {
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	srv := grpc.NewServer()
+	collogspb.RegisterLogsServiceServer(srv, c)
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+	return lis.Addr().String()
+}
+
+func TestOTLPProtoGRPC(t *testing.T) {
+	c := &testCollector{errs: []error{status.Error(codes.Unavailable, "collector down")}}
+	config := newOTLPTestConfig(commons.GRPCProto, startTestCollector(t, c))
+	proto := transports.NewOTLPProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewOTLPEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+	assert.Equal(t, 2, c.calls)
+	assert.Len(t, c.reqs, 1)
+	assert.Equal(t, []string{"sec"}, c.metadata.Get("x-tenant"))
+	checkOTLPRequest(t, c.reqs[0])
+}
+
+func TestOTLPProtoGRPCRetries(t *testing.T) {
+	unavailable := status.Error(codes.Unavailable, "collector down")
+	c := &testCollector{errs: []error{unavailable, unavailable, unavailable, unavailable}}
+	config := newOTLPTestConfig(commons.GRPCProto, startTestCollector(t, c))
+	proto := transports.NewOTLPProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewOTLPEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.Error(t, proto.Export(data))
+	assert.Equal(t, 3, c.calls)
+
+	// permanent errors are not retried
+	c.errs, c.calls = []error{status.Error(codes.InvalidArgument, "bad request")}, 0
+	assert.Error(t, proto.Export(data))
+	assert.Equal(t, 1, c.calls)
+}
+
+func TestOTLPProtoHTTP(t *testing.T) {
+	var reqs []*collogspb.ExportLogsServiceRequest
+	calls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, "/v1/logs", r.URL.Path)
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "sec", r.Header.Get("X-Tenant"))
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		assert.Equal(t, "gzip", r.Header.Get("Content-Encoding"))
+		zr, err := gzip.NewReader(r.Body)
+		assert.NoError(t, err)
+		body, err := io.ReadAll(zr)
+		assert.NoError(t, err)
+		req := &collogspb.ExportLogsServiceRequest{}
+		assert.NoError(t, proto.Unmarshal(body, req))
+		reqs = append(reqs, req)
+		resp, _ := proto.Marshal(&collogspb.ExportLogsServiceResponse{})
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.Write(resp)
+	}))
+	defer srv.Close()
+
+	config := newOTLPTestConfig(commons.HTTPProto, strings.TrimPrefix(srv.URL, "http://"))
+	config.OTLPCompression = commons.GzipCompression
+	p := transports.NewOTLPProto(config)
+	assert.NoError(t, p.Init())
+	defer p.Cleanup()
+
+	data, err := encoders.NewOTLPEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, p.Export(data))
+	assert.Equal(t, 2, calls)
+	assert.Len(t, reqs, 1)
+	checkOTLPRequest(t, reqs[0])
+}
+
+func TestOTLPProtoFormat(t *testing.T) {
+	proto := transports.NewOTLPProto(commons.Config{Format: commons.JSONFormat})
+	assert.Error(t, proto.Init())
+}
@@ -0,0 +1,208 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+const (
+	pqDateFormat  = "2006-01-02"
+	pqParallelism = 1
+)
+
+// pqCodecs maps compression options to Parquet compression codecs.
+var pqCodecs = map[commons.Compression]parquet.CompressionCodec{
+	commons.NoCompression:     parquet.CompressionCodec_UNCOMPRESSED,
+	commons.SnappyCompression: parquet.CompressionCodec_SNAPPY,
+	commons.GzipCompression:   parquet.CompressionCodec_GZIP,
+	commons.ZstdCompression:   parquet.CompressionCodec_ZSTD,
+}
+
+// ParquetProto implements the TransportProtocol interface for Parquet files.
+// Rows are partitioned by record date and node ID into directories named
+// date=<yyyy-mm-dd>/node=<node ID>, holding files named <prefix>.<timestamp>.parquet.
+// Files are written under a hidden name, and renamed once they are complete.
+type ParquetProto struct {
+	config commons.Config
+	clock  clock.Clock
+	files  map[pqPartition]*pqFile
+	date   string
+	lastTs int64
+}
+
+// pqPartition identifies a partition of the output directory.
+type pqPartition struct {
+	date string
+	node string
+}
+
+// pqFile is a Parquet file being written.
+type pqFile struct {
+	path   string
+	tmp    string
+	file   *os.File
+	writer *writer.ParquetWriter
+	opened time.Time
+}
+
+// NewParquetProto creates a new Parquet file protocol object.
+func NewParquetProto(conf commons.Config) TransportProtocol {
+	return &ParquetProto{config: conf, clock: clock.New(conf.Clock), files: make(map[pqPartition]*pqFile)}
+}
+
+// Init checks the configuration and creates the output directory. Parquet files are
+// created when the first row of their partition is exported.
+func (s *ParquetProto) Init() error {
+	if s.config.Format != commons.ParquetFormat {
+		return fmt.Errorf("export '%s' requires format '%s'", commons.ParquetTransport, commons.ParquetFormat)
+	}
+	return os.MkdirAll(s.config.PQDir, 0755)
+}
+
+// Export writes rows to the files of their partitions.
+func (s *ParquetProto) Export(data []commons.EncodedData) error {
+	for _, d := range data {
+		row, ok := d.(*encoders.ParquetRow)
+		if !ok {
+			return fmt.Errorf("unsupported data type %T for export '%s'", d, commons.ParquetTransport)
+		}
+		if err := s.write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write writes a row to the file of its partition. Files of previous dates are closed when
+// the first row of a new date is written.
+func (s *ParquetProto) write(row *encoders.ParquetRow) error {
+	s.clock.Advance(row.Ts)
+	part := pqPartition{date: time.Unix(0, row.Ts).UTC().Format(pqDateFormat), node: row.NodeID}
+	if part.date > s.date {
+		s.date = part.date
+		for p := range s.files {
+			if p.date < s.date {
+				if err := s.close(p); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	f, err := s.rotate(part)
+	if err != nil {
+		return err
+	}
+	return f.writer.Write(row.Value)
+}
+
+// rotate closes the file of partition part if it has reached its maximum size or age, and creates
+// a new file if none is open.
+func (s *ParquetProto) rotate(part pqPartition) (*pqFile, error) {
+	if f, ok := s.files[part]; ok {
+		// rows not yet encoded into pages are not counted, as their compressed size is unknown
+		size := f.writer.Offset + f.writer.Size
+		if (s.config.PQMaxSize <= 0 || size < s.config.PQMaxSize) &&
+			(s.config.PQInterval <= 0 || s.clock.Since(f.opened) < s.config.PQInterval) {
+			return f, nil
+		}
+		if err := s.close(part); err != nil {
+			return nil, err
+		}
+	}
+	dir := filepath.Join(s.config.PQDir, "date="+part.date, "node="+getPartitionName(part.node))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	// file names are ordered by the timestamp in their names, which must be unique
+	opened := s.clock.Now()
+	ts := opened.Unix()
+	if ts <= s.lastTs {
+		ts = s.lastTs + 1
+	}
+	s.lastTs = ts
+	name := fmt.Sprintf("%s.%d.parquet", s.config.PQPrefix, ts)
+	f := &pqFile{path: filepath.Join(dir, name), tmp: filepath.Join(dir, "."+name), opened: opened}
+	var err error
+	if f.file, err = os.OpenFile(f.tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644); err != nil {
+		return nil, err
+	}
+	if f.writer, err = writer.NewParquetWriterFromWriter(f.file, encoders.ParquetSchema(), pqParallelism); err != nil {
+		f.file.Close()
+		os.Remove(f.tmp)
+		return nil, err
+	}
+	f.writer.RowGroupSize = s.config.PQRowGroupSize
+	f.writer.CompressionType = pqCodecs[s.config.PQCompression]
+	s.files[part] = f
+	logger.Info.Printf("Writing Parquet file %s", f.path)
+	return f, nil
+}
+
+// close completes the file of partition part, and moves it to its final name.
+func (s *ParquetProto) close(part pqPartition) error {
+	f, ok := s.files[part]
+	if !ok {
+		return nil
+	}
+	delete(s.files, part)
+	err := f.writer.WriteStop()
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.tmp, f.path)
+}
+
+// getPartitionName returns a partition directory name for value v.
+func getPartitionName(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return strings.NewReplacer("/", "_", string(filepath.Separator), "_").Replace(v)
+}
+
+// Register registers the Parquet file protocol object with the exporter.
+func (s *ParquetProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.ParquetTransport] = NewParquetProto
+}
+
+// Cleanup completes all open Parquet files.
+func (s *ParquetProto) Cleanup() {
+	for part := range s.files {
+		if err := s.close(part); err != nil {
+			logger.Error.Println("Unable to close Parquet file: ", err)
+		}
+	}
+}
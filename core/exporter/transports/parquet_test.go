@@ -0,0 +1,102 @@
+package transports_test
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"github.com/xitongsys/parquet-go-source/local"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func newTestRecord(node string, exe string, ts int64) *engine.Record {
+	fr := sfgo.FlatRecord{
+		Sources: []sfgo.Source{sfgo.SYSFLOW_SRC},
+		Ints:    [][]int64{make([]int64, sfgo.INT_ARRAY_SIZE)},
+		Strs:    [][]string{make([]string, sfgo.STR_ARRAY_SIZE)},
+		Anys:    [][]interface{}{make([]interface{}, sfgo.ANY_ARRAY_SIZE)},
+	}
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.SF_REC_TYPE] = sfgo.PROC_EVT
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.TS_INT] = ts
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.EV_PROC_OPFLAGS_INT] = sfgo.OP_EXEC
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.SFHE_EXPORTER_STR] = node
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXE_STR] = exe
+	r := engine.NewRecord(fr)
+	r.Ctx.AddRule(engine.Rule{Name: "exec", Desc: "process executed", Priority: engine.Low, Tags: []engine.EnrichmentTag{"mitre:T1059"}})
+	return r
+}
+
+func readParquet(t *testing.T, path string) []interface{} {
+	f, err := local.NewLocalFileReader(path)
+	assert.NoError(t, err)
+	defer f.Close()
+	pr, err := reader.NewParquetReader(f, encoders.ParquetSchema(), 1)
+	assert.NoError(t, err)
+	defer pr.ReadStop()
+	rows := reflect.New(reflect.SliceOf(reflect.TypeOf(encoders.ParquetSchema()).Elem()))
+	rows.Elem().Set(reflect.MakeSlice(rows.Elem().Type(), int(pr.GetNumRows()), int(pr.GetNumRows())))
+	assert.NoError(t, pr.Read(rows.Interface()))
+	var values []interface{}
+	for i := 0; i < rows.Elem().Len(); i++ {
+		values = append(values, rows.Elem().Index(i).Addr().Interface())
+	}
+	return values
+}
+
+func TestParquetProto(t *testing.T) {
+	dir := t.TempDir()
+	config := commons.Config{
+		Format: commons.ParquetFormat,
+		Clock:  clock.EventMode,
+		ParquetConfig: commons.ParquetConfig{
+			PQDir:          dir,
+			PQPrefix:       "sysflow",
+			PQCompression:  commons.SnappyCompression,
+			PQRowGroupSize: 1 << 20,
+			PQInterval:     10 * time.Second,
+		},
+	}
+	proto := transports.NewParquetProto(config)
+	assert.NoError(t, proto.Init())
+
+	sec, day := int64(time.Second), int64(24*time.Hour)
+	recs := []*engine.Record{
+		newTestRecord("node1", "/bin/sh", testTs),
+		newTestRecord("node2", "/bin/sh", testTs+sec),
+		newTestRecord("node1", "/bin/ls", testTs+2*sec),
+		newTestRecord("node1", "/bin/ls", testTs+12*sec), // interval elapsed
+		newTestRecord("node1", "/bin/ps", testTs+day),    // next date
+	}
+	data, err := encoders.NewParquetEncoder(config).Encode(recs)
+	assert.NoError(t, err)
+	var rows []interface{}
+	for _, d := range data {
+		rows = append(rows, d.(*encoders.ParquetRow).Value)
+	}
+	assert.NoError(t, proto.Export(data))
+	proto.Cleanup()
+
+	node1, node2 := filepath.Join(dir, "date=2022-10-17", "node=node1"), filepath.Join(dir, "date=2022-10-17", "node=node2")
+	assert.Equal(t, []interface{}{rows[0], rows[2]}, readParquet(t, filepath.Join(node1, "sysflow.1666015200.parquet")))
+	assert.Equal(t, []interface{}{rows[1]}, readParquet(t, filepath.Join(node2, "sysflow.1666015201.parquet")))
+	assert.Equal(t, []interface{}{rows[3]}, readParquet(t, filepath.Join(node1, "sysflow.1666015212.parquet")))
+	assert.Equal(t, []interface{}{rows[4]}, readParquet(t, filepath.Join(dir, "date=2022-10-18", "node=node1", "sysflow.1666101600.parquet")))
+
+	// files are renamed once complete
+	hidden, err := filepath.Glob(filepath.Join(dir, "*", "*", ".*"))
+	assert.NoError(t, err)
+	assert.Empty(t, hidden)
+}
+
+func TestParquetProtoFormat(t *testing.T) {
+	proto := transports.NewParquetProto(commons.Config{Format: commons.JSONFormat})
+	assert.Error(t, proto.Init())
+}
@@ -0,0 +1,55 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// expandPattern replaces the patterns of s with the values of t and node: %Y, %m, %d, %H
+// (year, month, day, hour), %n (node ID), and %% (a literal percent sign).
+func expandPattern(s string, t time.Time, node string) string {
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '%' || i+1 == len(s) {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'Y':
+			fmt.Fprintf(&sb, "%04d", t.Year())
+		case 'm':
+			fmt.Fprintf(&sb, "%02d", t.Month())
+		case 'd':
+			fmt.Fprintf(&sb, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&sb, "%02d", t.Hour())
+		case 'n':
+			sb.WriteString(node)
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
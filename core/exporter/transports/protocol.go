@@ -20,7 +20,10 @@
 // Package transports implements transports for telemetry data.
 package transports
 
-import "github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+import (
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
 
 // TransportProtocol is an interface to support a transport protocol.
 type TransportProtocol interface {
@@ -36,5 +39,13 @@ type TestableTransportProtocol interface {
 	Test() (bool, error)
 }
 
+// RecordTransportProtocol is a transport protocol that also receives the records from which data is encoded,
+// e.g., to select records with SFPL conditions. data[i] is the encoding of recs[i], or nil if the encoder
+// dropped the record.
+type RecordTransportProtocol interface {
+	TransportProtocol
+	ExportRecords(recs []*engine.Record, data []commons.EncodedData) error
+}
+
 // TransportProtocolFactory defines a factory type for transport protocols.
 type TransportProtocolFactory func(commons.Config) TransportProtocol
@@ -0,0 +1,67 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+)
+
+// maxBackoff is the maximum delay between two attempts of a request.
+const maxBackoff = 30 * time.Second
+
+// retrier retries failed requests with exponential backoff.
+type retrier struct {
+	max     int
+	backoff time.Duration
+}
+
+// do calls send until it succeeds, it fails permanently, or the maximum number of retries is reached,
+// and returns the last error. On failure, send returns a negative delay if the request must not be
+// retried, or the delay requested by the receiver before retrying, if any.
+func (r retrier) do(desc string, send func() (time.Duration, error)) error {
+	backoff := r.backoff
+	for retries := 0; ; retries++ {
+		delay, err := send()
+		if err == nil || delay < 0 || retries >= r.max {
+			return err
+		}
+		if delay < backoff {
+			delay = backoff
+		}
+		logger.Warn.Printf("Failed to %s, retrying in %s: %v", desc, delay, err)
+		time.Sleep(delay)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// retryAfter returns the delay requested in the Retry-After header of an HTTP response, if any.
+func retryAfter(resp *http.Response) time.Duration {
+	if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+		return time.Duration(secs) * time.Second
+	}
+	return 0
+}
@@ -0,0 +1,281 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+)
+
+// Splunk HTTP Event Collector endpoints.
+const (
+	splunkEventPath = "/services/collector/event"
+	splunkAckPath   = "/services/collector/ack"
+)
+
+// splunkAckInterval is the delay between two acknowledgement polls when the exporter stops.
+const splunkAckInterval = time.Second
+
+// splunkEvent is an event in the JSON format of the HTTP Event Collector.
+type splunkEvent struct {
+	Time       json.Number     `json:"time"`
+	Host       string          `json:"host,omitempty"`
+	Source     string          `json:"source,omitempty"`
+	SourceType string          `json:"sourcetype,omitempty"`
+	Index      string          `json:"index,omitempty"`
+	Event      json.RawMessage `json:"event"`
+}
+
+// splunkResponse is the response of the HTTP Event Collector to an event request.
+type splunkResponse struct {
+	Text  string `json:"text"`
+	Code  int    `json:"code"`
+	AckID *int64 `json:"ackId"`
+}
+
+// splunkBatch is a batch of events waiting for indexer acknowledgement.
+type splunkBatch struct {
+	body []byte
+	n    int
+	sent time.Time
+}
+
+// SplunkProto implements the TransportProtocol interface for the Splunk HTTP Event Collector (HEC).
+// Each batch of records is posted in one request. If indexer acknowledgement is enabled, batches
+// are kept until acknowledged, and sent again if not acknowledged within a timeout.
+type SplunkProto struct {
+	config  commons.Config
+	client  *http.Client
+	retrier retrier
+	url     string
+	ackURL  string
+	channel string
+	pending map[int64]*splunkBatch
+}
+
+// NewSplunkProto creates a new Splunk HEC protocol object.
+func NewSplunkProto(conf commons.Config) TransportProtocol {
+	return &SplunkProto{config: conf, pending: make(map[int64]*splunkBatch)}
+}
+
+// Init initializes the HTTP client and the acknowledgement channel.
+func (s *SplunkProto) Init() (err error) {
+	if s.config.Format != commons.JSONFormat && s.config.Format != commons.ECSFormat {
+		return fmt.Errorf("export '%s' requires format '%s' or '%s'", commons.SplunkTransport, commons.JSONFormat, commons.ECSFormat)
+	}
+	u, err := url.Parse(s.config.SplunkURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme '%s' in %s", u.Scheme, s.config.SplunkURL)
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if u.Scheme == "https" {
+		transport.TLSClientConfig, err = newTLSConfig(s.config.SplunkTLSCA, "", "", s.config.SplunkTLSSkipVerify)
+		if err != nil {
+			return err
+		}
+	}
+	s.client = &http.Client{Transport: transport, Timeout: s.config.SplunkTimeout}
+	base := strings.TrimSuffix(s.config.SplunkURL, "/")
+	s.url, s.ackURL = base+splunkEventPath, base+splunkAckPath
+	s.channel = s.config.SplunkAckChannel
+	if s.channel == "" && s.config.SplunkAck {
+		if s.channel, err = newChannelID(); err != nil {
+			return err
+		}
+	}
+	s.retrier = retrier{max: s.config.SplunkRetryMax, backoff: s.config.SplunkRetryBackoff}
+	return nil
+}
+
+// newChannelID creates a random channel identifier in UUID format.
+func newChannelID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6], b[8] = b[6]&0x0f|0x40, b[8]&0x3f|0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+// Export posts a batch of records to the HTTP Event Collector.
+func (s *SplunkProto) Export(data []commons.EncodedData) error {
+	if s.config.SplunkAck {
+		if err := s.poll(true); err != nil {
+			logger.Warn.Println(err)
+		}
+	}
+	if len(data) == 0 {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, d := range data {
+		ev := splunkEvent{Source: s.config.SplunkSource, SourceType: s.config.SplunkSourceType, Index: s.config.SplunkIndex}
+		var meta syslogMeta
+		switch d := d.(type) {
+		case []byte:
+			ev.Event, meta = d, getJSONSyslogMeta(d)
+		case *encoders.ECSRecord:
+			event, err := json.Marshal(d)
+			if err != nil {
+				return err
+			}
+			ev.Event, meta = event, getECSSyslogMeta(d)
+		default:
+			return fmt.Errorf("unexpected record type %T for export '%s'", d, commons.SplunkTransport)
+		}
+		ev.Time, ev.Host = json.Number(fmt.Sprintf("%d.%03d", meta.ts.Unix(), meta.ts.Nanosecond()/1e6)), meta.host
+		if err := enc.Encode(&ev); err != nil {
+			return err
+		}
+	}
+	return s.send(&splunkBatch{body: buf.Bytes(), n: len(data)})
+}
+
+// send posts a batch of events, and keeps it for acknowledgement if needed.
+func (s *SplunkProto) send(b *splunkBatch) error {
+	var res splunkResponse
+	err := s.post(s.url, b.body, &res, fmt.Sprintf("send %d records to %s", b.n, s.url))
+	if err != nil || !s.config.SplunkAck {
+		return err
+	}
+	if res.AckID == nil {
+		return fmt.Errorf("%s did not acknowledge %d records; is indexer acknowledgement enabled for the token?", s.url, b.n)
+	}
+	b.sent = time.Now()
+	s.pending[*res.AckID] = b
+	return nil
+}
+
+// poll queries the acknowledgement status of pending batches. If resend is true, batches that
+// were not acknowledged within the acknowledgement timeout are sent again.
+func (s *SplunkProto) poll(resend bool) error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(s.pending))
+	for id := range s.pending {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	body, err := json.Marshal(map[string][]int64{"acks": ids})
+	if err != nil {
+		return err
+	}
+	var res struct {
+		Acks map[string]bool `json:"acks"`
+	}
+	if err = s.post(s.ackURL, body, &res, fmt.Sprintf("query %d acknowledgements from %s", len(ids), s.ackURL)); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		b := s.pending[id]
+		if res.Acks[fmt.Sprint(id)] {
+			delete(s.pending, id)
+		} else if resend && time.Since(b.sent) > s.config.SplunkAckTimeout {
+			logger.Warn.Printf("Records not acknowledged by %s within %s, sending %d records again", s.url, s.config.SplunkAckTimeout, b.n)
+			delete(s.pending, id)
+			if err := s.send(b); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// post sends a request to the HTTP Event Collector, retrying on server errors, and decodes the response into res.
+func (s *SplunkProto) post(url string, body []byte, res interface{}, desc string) error {
+	return s.retrier.do(desc, func() (time.Duration, error) {
+		req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return -1, err
+		}
+		req.Header.Set("Authorization", "Splunk "+s.config.SplunkToken)
+		req.Header.Set("Content-Type", "application/json")
+		if s.channel != "" {
+			req.Header.Set("X-Splunk-Request-Channel", s.channel)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			// connection errors and timeouts are transient
+			return 0, err
+		}
+		defer resp.Body.Close()
+		buf, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return 0, json.Unmarshal(buf, res)
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			return retryAfter(resp), fmt.Errorf("%s responded with status %s: %s", url, resp.Status, bytes.TrimSpace(buf))
+		}
+		return -1, fmt.Errorf("%s responded with status %s: %s", url, resp.Status, bytes.TrimSpace(buf))
+	})
+}
+
+// Register registers the Splunk HEC proto object with the exporter.
+func (s *SplunkProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.SplunkTransport] = NewSplunkProto
+}
+
+// Cleanup waits for the acknowledgement of pending batches, and closes idle connections.
+func (s *SplunkProto) Cleanup() {
+	if s.client == nil {
+		return
+	}
+	deadline := time.Now().Add(s.config.SplunkAckTimeout)
+	for len(s.pending) > 0 {
+		if err := s.poll(false); err != nil {
+			logger.Error.Println(err)
+			break
+		}
+		if len(s.pending) == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			n := 0
+			for _, b := range s.pending {
+				n += b.n
+			}
+			logger.Error.Printf("%d records not acknowledged by %s within %s", n, s.url, s.config.SplunkAckTimeout)
+			break
+		}
+		time.Sleep(splunkAckInterval)
+	}
+	s.client.CloseIdleConnections()
+}
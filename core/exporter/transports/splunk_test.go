@@ -0,0 +1,206 @@
+package transports_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+)
+
+// testHEC is a fake Splunk HTTP Event Collector. Event requests get increasing acknowledgement IDs,
+// and the IDs listed in lost are never acknowledged.
+type testHEC struct {
+	mu       sync.Mutex
+	codes    []int
+	events   [][]map[string]interface{}
+	channels []string
+	ackReqs  int
+	lost     map[int64]bool
+	nextAck  int64
+}
+
+func (h *testHEC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if r.Header.Get("Authorization") != "Splunk token" {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"text":"Invalid token","code":4}`)
+		return
+	}
+	if len(h.codes) > 0 {
+		code := h.codes[0]
+		h.codes = h.codes[1:]
+		w.WriteHeader(code)
+		io.WriteString(w, `{"text":"Server is busy","code":9}`)
+		return
+	}
+	body, _ := io.ReadAll(r.Body)
+	switch r.URL.Path {
+	case "/services/collector/event":
+		var batch []map[string]interface{}
+		s := bufio.NewScanner(bytes.NewReader(body))
+		for s.Scan() {
+			var ev map[string]interface{}
+			json.Unmarshal(s.Bytes(), &ev)
+			batch = append(batch, ev)
+		}
+		h.events = append(h.events, batch)
+		h.channels = append(h.channels, r.Header.Get("X-Splunk-Request-Channel"))
+		fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%d}`, h.nextAck)
+		h.nextAck++
+	case "/services/collector/ack":
+		h.ackReqs++
+		var req struct {
+			Acks []int64 `json:"acks"`
+		}
+		json.Unmarshal(body, &req)
+		acks := make(map[string]bool)
+		for _, id := range req.Acks {
+			acks[fmt.Sprint(id)] = !h.lost[id]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"acks": acks})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newSplunkTestConfig(url string) commons.Config {
+	return commons.Config{
+		Format:            commons.JSONFormat,
+		JSONSchemaVersion: "4",
+		SplunkConfig: commons.SplunkConfig{
+			SplunkURL:          url,
+			SplunkToken:        "token",
+			SplunkIndex:        "security",
+			SplunkSource:       "sysflow",
+			SplunkSourceType:   "sysflow:json",
+			SplunkAckTimeout:   time.Minute,
+			SplunkTimeout:      time.Second,
+			SplunkRetryMax:     2,
+			SplunkRetryBackoff: time.Millisecond,
+		},
+	}
+}
+
+func TestSplunkProtoExport(t *testing.T) {
+	h := &testHEC{codes: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	config := newSplunkTestConfig(srv.URL + "/")
+	proto := transports.NewSplunkProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+
+	// a batch of records is sent in one request
+	assert.Len(t, h.events, 1)
+	batch := h.events[0]
+	assert.Len(t, batch, 3)
+	assert.Equal(t, "node2", batch[2]["host"])
+	assert.Equal(t, 1666015200.0, batch[2]["time"])
+	assert.Equal(t, "security", batch[0]["index"])
+	assert.Equal(t, "sysflow", batch[0]["source"])
+	assert.Equal(t, "sysflow:json", batch[0]["sourcetype"])
+	assert.Equal(t, "/bin/ls", batch[1]["event"].(map[string]interface{})["proc"].(map[string]interface{})["exe"])
+	assert.Equal(t, "", h.channels[0])
+	assert.Zero(t, h.ackReqs)
+
+	// ECS records are sent as JSON objects
+	config.Format = commons.ECSFormat
+	data, err = encoders.NewECSEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+	assert.Len(t, h.events, 2)
+	assert.Equal(t, "node1", h.events[1][0]["host"])
+	assert.Contains(t, h.events[1][0]["event"], "@timestamp")
+}
+
+func TestSplunkProtoAck(t *testing.T) {
+	h := &testHEC{lost: map[int64]bool{0: true}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	config := newSplunkTestConfig(srv.URL)
+	config.SplunkAck = true
+	config.SplunkAckTimeout = 10 * time.Millisecond
+	proto := transports.NewSplunkProto(config)
+	assert.NoError(t, proto.Init())
+
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data[:2]))
+	time.Sleep(20 * time.Millisecond)
+
+	// the first batch is not acknowledged in time and is sent again
+	assert.NoError(t, proto.Export(data[2:]))
+	assert.Len(t, h.events, 3)
+	assert.Len(t, h.events[1], 2)
+	assert.Equal(t, 1, h.ackReqs)
+	assert.NotEmpty(t, h.channels[0])
+	assert.Equal(t, h.channels[0], h.channels[2])
+
+	// pending batches are acknowledged when the exporter stops
+	proto.Cleanup()
+	assert.Equal(t, 2, h.ackReqs)
+}
+
+func TestSplunkProtoConfig(t *testing.T) {
+	h := &testHEC{}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	config := newSplunkTestConfig(srv.URL)
+	config.SplunkToken = "invalid"
+	proto := transports.NewSplunkProto(config)
+	assert.NoError(t, proto.Init())
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.Error(t, proto.Export(data))
+	proto.Cleanup()
+
+	config = newSplunkTestConfig(srv.URL)
+	config.Format = commons.CEFFormat
+	assert.Error(t, transports.NewSplunkProto(config).Init())
+
+	_, err = commons.CreateConfig(map[string]interface{}{"export": "splunk", "splunk.url": srv.URL})
+	assert.Error(t, err)
+	c, err := commons.CreateConfig(map[string]interface{}{"export": "splunk", "splunk.url": srv.URL, "splunk.token": "token", "splunk.ack": "true"})
+	assert.NoError(t, err)
+	assert.True(t, c.SplunkAck)
+	assert.Equal(t, "sysflow", c.SplunkSourceType)
+}
+
+func TestOpenSearchProto(t *testing.T) {
+	c := newTestCluster()
+	srv := httptest.NewServer(c)
+	defer srv.Close()
+
+	config := newElasticTestConfig(srv.URL)
+	config.Transport = commons.OpenSearchTransport
+	config.ESTemplateMappings = "../../../resources/mappings/ecs_mapping.json"
+	proto := transports.NewOpenSearchProto(config)
+	assert.NoError(t, proto.Init())
+	data := encodeECS(t, config)
+	assert.NoError(t, proto.Export(data))
+	proto.Cleanup()
+	assert.Contains(t, c.templates, "sysflow")
+	assert.Len(t, c.docs["sysflow-2022.10.17"], 3)
+
+	config.ESILMPolicy = "sysflow-policy"
+	assert.Error(t, transports.NewOpenSearchProto(config).Init())
+}
@@ -0,0 +1,318 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"golang.org/x/net/websocket"
+)
+
+// streamFilterParam is the query parameter holding the SFPL condition that selects the records sent to a client.
+const streamFilterParam = "filter"
+
+// connKey is the request context key of the client connection.
+type connKey struct{}
+
+// streamClient is a client subscribed to the live stream.
+type streamClient struct {
+	addr   string
+	filter *engine.Criterion // nil if all records are sent
+	queue  chan []byte
+	conn   net.Conn
+	done   chan struct{}
+	once   sync.Once
+}
+
+// close disconnects the client, aborting pending writes.
+func (c *streamClient) close() {
+	c.once.Do(func() {
+		close(c.done)
+		if c.conn != nil {
+			c.conn.Close()
+		}
+	})
+}
+
+// StreamProto implements the TransportProtocol interface of an HTTP endpoint streaming records live to
+// subscribed clients, as newline-delimited JSON or WebSocket messages. Clients can select records with an
+// SFPL condition, and clients that fall behind the stream are disconnected, so that the pipeline never
+// waits for them.
+type StreamProto struct {
+	config   commons.Config
+	server   *http.Server
+	listener net.Listener
+	mu       sync.RWMutex
+	clients  map[*streamClient]struct{}
+}
+
+// NewStreamProto creates a new live stream protocol object.
+func NewStreamProto(conf commons.Config) TransportProtocol {
+	return &StreamProto{config: conf, clients: make(map[*streamClient]struct{})}
+}
+
+// Init starts serving the stream.
+func (s *StreamProto) Init() (err error) {
+	if s.config.Format != commons.JSONFormat && s.config.Format != commons.ECSFormat {
+		return fmt.Errorf("export '%s' requires format '%s' or '%s'", commons.StreamTransport, commons.JSONFormat, commons.ECSFormat)
+	}
+	var tlsConfig *tls.Config
+	if s.config.StreamTLSCert != "" {
+		cert, err := tls.LoadX509KeyPair(s.config.StreamTLSCert, s.config.StreamTLSKey)
+		if err != nil {
+			return err
+		}
+		tlsConfig = &tls.Config{MinVersion: tls.VersionTLS12, Certificates: []tls.Certificate{cert}}
+	}
+	if s.listener, err = net.Listen("tcp", s.config.StreamAddr); err != nil {
+		return err
+	}
+	if tlsConfig != nil {
+		s.listener = tls.NewListener(s.listener, tlsConfig)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(s.config.StreamPath, s.serve)
+	s.server = &http.Server{
+		Handler: mux,
+		// the connection of a client is kept in the request context to disconnect slow clients
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			return context.WithValue(ctx, connKey{}, c)
+		},
+	}
+	go func() {
+		if err := s.server.Serve(s.listener); err != nil && err != http.ErrServerClosed {
+			logger.Error.Println(err)
+		}
+	}()
+	logger.Info.Printf("Streaming records on %s%s", s.listener.Addr(), s.config.StreamPath)
+	return nil
+}
+
+// Addr returns the address on which the stream is served.
+func (s *StreamProto) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// serve subscribes a client to the stream until it disconnects, or falls behind the stream.
+func (s *StreamProto) serve(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	if s.config.StreamToken != "" && !s.authorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	c := &streamClient{addr: r.RemoteAddr, queue: make(chan []byte, s.config.StreamBuffer), done: make(chan struct{})}
+	c.conn, _ = r.Context().Value(connKey{}).(net.Conn)
+	if cond := r.URL.Query().Get(streamFilterParam); cond != "" {
+		filter, err := engine.CompileCondition(cond)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.filter = &filter
+	}
+	if !s.subscribe(c) {
+		http.Error(w, "maximum number of stream clients reached", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.unsubscribe(c)
+	if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		websocket.Server{Handler: func(ws *websocket.Conn) { s.serveWebSocket(c, ws) }}.ServeHTTP(w, r)
+	} else {
+		s.serveNDJSON(c, w, r)
+	}
+}
+
+// authorized checks the bearer token of a request.
+func (s *StreamProto) authorized(r *http.Request) bool {
+	const prefix = "Bearer "
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, prefix) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(auth[len(prefix):]), []byte(s.config.StreamToken)) == 1
+}
+
+// serveNDJSON writes the records queued for client c as newline-delimited JSON.
+func (s *StreamProto) serveNDJSON(c *streamClient, w http.ResponseWriter, r *http.Request) {
+	flusher, _ := w.(http.Flusher)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	write := func(line []byte) bool {
+		_, err := w.Write(line)
+		if err == nil {
+			_, err = io.WriteString(w, "\n")
+		}
+		return err == nil
+	}
+	for {
+		select {
+		case line := <-c.queue:
+			if !write(line) {
+				return
+			}
+			// records queued meanwhile are written before flushing
+			for n := len(c.queue); n > 0; n-- {
+				if !write(<-c.queue) {
+					return
+				}
+			}
+			flusher.Flush()
+		case <-c.done:
+			return
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// serveWebSocket sends the records queued for client c as WebSocket text messages.
+func (s *StreamProto) serveWebSocket(c *streamClient, ws *websocket.Conn) {
+	ws.PayloadType = websocket.TextFrame
+	// messages from the client are discarded, until it closes the connection
+	go func() {
+		io.Copy(io.Discard, ws)
+		c.close()
+	}()
+	for {
+		select {
+		case line := <-c.queue:
+			if _, err := ws.Write(line); err != nil {
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// subscribe adds client c to the stream, unless the maximum number of clients is reached.
+func (s *StreamProto) subscribe(c *streamClient) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.config.StreamMaxClients > 0 && len(s.clients) >= s.config.StreamMaxClients {
+		return false
+	}
+	s.clients[c] = struct{}{}
+	logger.Info.Printf("Stream client %s subscribed", c.addr)
+	return true
+}
+
+// unsubscribe removes client c from the stream and disconnects it.
+func (s *StreamProto) unsubscribe(c *streamClient) {
+	s.mu.Lock()
+	if _, ok := s.clients[c]; ok {
+		delete(s.clients, c)
+		logger.Info.Printf("Stream client %s unsubscribed", c.addr)
+	}
+	s.mu.Unlock()
+	c.close()
+}
+
+// Export sends records to the clients without a filter, since filters are evaluated on records.
+func (s *StreamProto) Export(data []commons.EncodedData) error {
+	return s.broadcast(nil, data)
+}
+
+// ExportRecords sends records to the clients whose filter they match.
+func (s *StreamProto) ExportRecords(recs []*engine.Record, data []commons.EncodedData) error {
+	return s.broadcast(recs, data)
+}
+
+// broadcast queues records for the clients, and disconnects the clients whose queue is full.
+func (s *StreamProto) broadcast(recs []*engine.Record, data []commons.EncodedData) error {
+	s.mu.RLock()
+	if len(s.clients) == 0 {
+		s.mu.RUnlock()
+		return nil
+	}
+	lines := make([][]byte, len(data))
+	for i, d := range data {
+		switch d := d.(type) {
+		case nil:
+		case []byte:
+			lines[i] = d
+		default:
+			buf, err := json.Marshal(d)
+			if err != nil {
+				s.mu.RUnlock()
+				return err
+			}
+			lines[i] = buf
+		}
+	}
+	var slow []*streamClient
+	for c := range s.clients {
+	Lines:
+		for i, line := range lines {
+			if line == nil || (c.filter != nil && (recs == nil || !c.filter.Eval(recs[i]))) {
+				continue
+			}
+			select {
+			case c.queue <- line:
+			default:
+				slow = append(slow, c)
+				break Lines
+			}
+		}
+	}
+	s.mu.RUnlock()
+	for _, c := range slow {
+		logger.Warn.Printf("Disconnecting stream client %s, which fell %d records behind", c.addr, s.config.StreamBuffer)
+		s.unsubscribe(c)
+	}
+	return nil
+}
+
+// Register registers the live stream protocol object with the exporter.
+func (s *StreamProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.StreamTransport] = NewStreamProto
+}
+
+// Cleanup stops serving the stream and disconnects clients.
+func (s *StreamProto) Cleanup() {
+	if s.server != nil {
+		s.server.Close()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		c.close()
+	}
+	s.clients = make(map[*streamClient]struct{})
+}
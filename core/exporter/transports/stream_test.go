@@ -0,0 +1,164 @@
+package transports_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"golang.org/x/net/websocket"
+)
+
+func newStreamTestProto(t *testing.T, conf map[string]interface{}) *transports.StreamProto {
+	conf[commons.TransportConfigKey] = commons.StreamTransport.String()
+	conf[commons.StreamAddrConfigKey] = "localhost:0"
+	c, err := commons.CreateConfig(conf)
+	assert.NoError(t, err)
+	s := transports.NewStreamProto(c).(*transports.StreamProto)
+	assert.NoError(t, s.Init())
+	return s
+}
+
+// encodeStreamRecords encodes records one at a time, as the exporter does for record transports.
+func encodeStreamRecords(t *testing.T, enc encoders.Encoder, recs []*engine.Record) []commons.EncodedData {
+	data := make([]commons.EncodedData, len(recs))
+	for i := range recs {
+		d, err := enc.Encode(recs[i : i+1])
+		assert.NoError(t, err)
+		data[i] = d[0]
+	}
+	return data
+}
+
+func subscribe(t *testing.T, s *transports.StreamProto, token string, filter string) *http.Response {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/stream", s.Addr()), nil)
+	assert.NoError(t, err)
+	if filter != "" {
+		req.URL.RawQuery = "filter=" + strings.ReplaceAll(filter, " ", "+")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return resp
+}
+
+func readLines(t *testing.T, r io.Reader, n int) (lines []map[string]interface{}) {
+	s := bufio.NewScanner(r)
+	for i := 0; i < n && s.Scan(); i++ {
+		var m map[string]interface{}
+		assert.NoError(t, json.Unmarshal(s.Bytes(), &m))
+		lines = append(lines, m)
+	}
+	assert.NoError(t, s.Err())
+	return
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	s := newStreamTestProto(t, map[string]interface{}{commons.StreamTokenConfigKey: "secret"})
+	defer s.Cleanup()
+
+	resp := subscribe(t, s, "", "")
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp.Body.Close()
+	resp = subscribe(t, s, "secret", "sf.proc.exe =")
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp.Body.Close()
+
+	all := subscribe(t, s, "secret", "")
+	defer all.Body.Close()
+	assert.Equal(t, http.StatusOK, all.StatusCode)
+	assert.Equal(t, "application/x-ndjson", all.Header.Get("Content-Type"))
+	node2 := subscribe(t, s, "secret", "sf.node.id = node2")
+	defer node2.Body.Close()
+
+	recs := withPtree(newOTLPTestRecords())
+	enc := encoders.NewJSONEncoder(commons.Config{JSONSchemaVersion: "4"})
+	assert.NoError(t, s.ExportRecords(recs, encodeStreamRecords(t, enc, recs)))
+	lines := readLines(t, all.Body, 3)
+	if assert.Len(t, lines, 3) {
+		assert.Equal(t, "/bin/sh", lines[0]["proc"].(map[string]interface{})["exe"])
+		assert.Equal(t, "/bin/ps", lines[2]["proc"].(map[string]interface{})["exe"])
+	}
+	lines = readLines(t, node2.Body, 1)
+	if assert.Len(t, lines, 1) {
+		assert.Equal(t, "node2", lines[0]["node"].(map[string]interface{})["id"])
+	}
+
+	// records exported without records are only sent to clients without a filter
+	assert.NoError(t, s.Export(encodeStreamRecords(t, enc, recs[2:])))
+	assert.Len(t, readLines(t, all.Body, 1), 1)
+}
+
+func TestStreamWebSocket(t *testing.T) {
+	s := newStreamTestProto(t, map[string]interface{}{commons.FormatConfigKey: commons.ECSFormat.String()})
+	defer s.Cleanup()
+
+	config, err := websocket.NewConfig(fmt.Sprintf("ws://%s/stream?filter=sf.proc.exe+%%3D+/bin/ls", s.Addr()), "http://localhost")
+	assert.NoError(t, err)
+	ws, err := websocket.DialConfig(config)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer ws.Close()
+
+	recs := newOTLPTestRecords()
+	enc := encoders.NewECSEncoder(commons.Config{Version: "0.5.0", EcsVersion: "8.4.0"})
+	assert.NoError(t, s.ExportRecords(recs, encodeStreamRecords(t, enc, recs)))
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var msg string
+	assert.NoError(t, websocket.Message.Receive(ws, &msg))
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(msg), &doc))
+	assert.Equal(t, "/bin/ls", doc["process"].(map[string]interface{})["executable"])
+}
+
+func TestStreamSlowClient(t *testing.T) {
+	s := newStreamTestProto(t, map[string]interface{}{commons.StreamBufferConfigKey: "2", commons.StreamMaxClientsConfigKey: "1"})
+	defer s.Cleanup()
+
+	slow := subscribe(t, s, "", "")
+	defer slow.Body.Close()
+	resp := subscribe(t, s, "", "")
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+	resp.Body.Close()
+
+	// the client does not read, so that its queue fills once the connection buffers are full
+	rec := newTestRecord("node1", "/bin/sh", testTs)
+	rec.Fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXEARGS_STR] = strings.Repeat("a", 64*1024)
+	recs := []*engine.Record{rec}
+	data := encodeStreamRecords(t, encoders.NewJSONEncoder(commons.Config{JSONSchemaVersion: "4"}), recs)
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, s.ExportRecords(recs, data))
+	}
+	_, err := io.Copy(io.Discard, slow.Body)
+	assert.Error(t, err)
+
+	resp = subscribe(t, s, "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	resp.Body.Close()
+}
+
+func TestStreamConfig(t *testing.T) {
+	_, err := commons.CreateConfig(map[string]interface{}{commons.StreamPathConfigKey: "stream"})
+	assert.EqualError(t, err, "invalid value 'stream' for key 'stream.path': path must start with '/'")
+	_, err = commons.CreateConfig(map[string]interface{}{commons.StreamBufferConfigKey: "0"})
+	assert.EqualError(t, err, "invalid value '0' for key 'stream.buffer': expected a positive integer")
+	_, err = commons.CreateConfig(map[string]interface{}{commons.StreamTLSCertConfigKey: "cert.pem"})
+	assert.EqualError(t, err, "keys 'stream.tls.cert' and 'stream.tls.key' must be set together")
+	s := transports.NewStreamProto(commons.Config{Format: commons.OccurrenceFormat})
+	assert.EqualError(t, s.Init(), "export 'stream' requires format 'json' or 'ecs'")
+}
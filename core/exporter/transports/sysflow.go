@@ -0,0 +1,233 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+)
+
+const (
+	sfRecordsPerBlock = 1000
+)
+
+// SysFlowProto implements the TransportProtocol interface for SysFlow trace files.
+// Trace files are Avro object container files named <prefix>.<timestamp>.sf, which
+// can be read back with the file driver. Header and entity records are written once
+// per file, before the first record that refers to them.
+type SysFlowProto struct {
+	config  commons.Config
+	clock   clock.Clock
+	file    *os.File
+	out     *countingWriter
+	writer  *container.Writer
+	opened  time.Time
+	lastTs  int64
+	hdr     *sfgo.SFHeader
+	written map[entityKey]interface{}
+}
+
+// entityKey identifies an entity record written to a trace file.
+type entityKey struct {
+	rtype sfgo.RecUnionTypeEnum
+	id    interface{}
+}
+
+// countingWriter counts the bytes written to a trace file.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+	return
+}
+
+// NewSysFlowProto creates a new SysFlow trace file protocol object.
+func NewSysFlowProto(conf commons.Config) TransportProtocol {
+	return &SysFlowProto{config: conf, clock: clock.New(conf.Clock)}
+}
+
+// Init checks the configuration and creates the output directory. Trace files are
+// created when the first record is exported.
+func (s *SysFlowProto) Init() error {
+	if s.config.Format != commons.SysFlowFormat {
+		return fmt.Errorf("export '%s' requires format '%s'", commons.SysFlowTransport, commons.SysFlowFormat)
+	}
+	return os.MkdirAll(s.config.SFDir, 0755)
+}
+
+// Export writes SysFlow records to the current trace file.
+func (s *SysFlowProto) Export(data []commons.EncodedData) (err error) {
+	for _, d := range data {
+		sfs, ok := d.([]*sfgo.SysFlow)
+		if !ok || len(sfs) == 0 {
+			return fmt.Errorf("unsupported data type %T for export '%s'", d, commons.SysFlowTransport)
+		}
+		if err = s.write(sfs); err != nil {
+			return
+		}
+	}
+	if s.writer != nil {
+		err = s.writer.Flush()
+	}
+	return
+}
+
+// write writes a record along with the header and entity records it refers to.
+// The header is the first record in sfs, and the record itself is the last one.
+func (s *SysFlowProto) write(sfs []*sfgo.SysFlow) error {
+	s.clock.Advance(getRecordTime(sfs[len(sfs)-1]))
+	if err := s.rotate(); err != nil {
+		return err
+	}
+	for i, sf := range sfs {
+		if i < len(sfs)-1 && !s.isNew(sf) {
+			continue
+		}
+		if err := s.writer.WriteRecord(sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isNew checks whether a header or entity record differs from the one last written to the trace
+// file, and records it as written. A new header starts a new stream, for which entities are
+// written again.
+func (s *SysFlowProto) isNew(sf *sfgo.SysFlow) bool {
+	var key entityKey
+	var v interface{}
+	switch sf.Rec.UnionType {
+	case sfgo.RecUnionTypeEnumSFHeader:
+		if s.hdr != nil && *s.hdr == *sf.Rec.SFHeader {
+			return false
+		}
+		s.hdr = sf.Rec.SFHeader
+		s.written = make(map[entityKey]interface{})
+		return true
+	case sfgo.RecUnionTypeEnumContainer:
+		key, v = entityKey{sf.Rec.UnionType, sf.Rec.Container.Id}, sf.Rec.Container
+	case sfgo.RecUnionTypeEnumPod:
+		key, v = entityKey{sf.Rec.UnionType, sf.Rec.Pod.Id}, sf.Rec.Pod
+	case sfgo.RecUnionTypeEnumProcess:
+		key, v = entityKey{sf.Rec.UnionType, *sf.Rec.Process.Oid}, sf.Rec.Process
+	case sfgo.RecUnionTypeEnumFile:
+		key, v = entityKey{sf.Rec.UnionType, sf.Rec.File.Oid}, sf.Rec.File
+	default:
+		return true
+	}
+	if w, ok := s.written[key]; ok && (w == v || reflect.DeepEqual(w, v)) {
+		return false
+	}
+	s.written[key] = v
+	return true
+}
+
+// rotate closes the current trace file if it has reached its maximum size or age, and creates
+// a new trace file if none is open.
+func (s *SysFlowProto) rotate() error {
+	if s.writer != nil {
+		size := s.out.n + int64(s.writer.BlockBufferSize())
+		if (s.config.SFMaxSize <= 0 || size < s.config.SFMaxSize) &&
+			(s.config.SFInterval <= 0 || s.clock.Since(s.opened) < s.config.SFInterval) {
+			return nil
+		}
+		if err := s.close(); err != nil {
+			return err
+		}
+	}
+	// trace files are ordered by the timestamp in their names, which must be unique
+	s.opened = s.clock.Now()
+	ts := s.opened.Unix()
+	if ts <= s.lastTs {
+		ts = s.lastTs + 1
+	}
+	s.lastTs = ts
+	path := filepath.Join(s.config.SFDir, fmt.Sprintf("%s.%d.sf", s.config.SFPrefix, ts))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	s.file, s.out = f, &countingWriter{w: f}
+	if s.writer, err = sfgo.NewSysFlowWriter(s.out, container.Snappy, sfRecordsPerBlock); err != nil {
+		f.Close()
+		s.file, s.writer = nil, nil
+		return err
+	}
+	s.hdr = nil
+	logger.Info.Printf("Writing SysFlow trace file %s", path)
+	return nil
+}
+
+// close flushes and closes the current trace file.
+func (s *SysFlowProto) close() error {
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Flush()
+	if cerr := s.file.Close(); err == nil {
+		err = cerr
+	}
+	s.file, s.out, s.writer = nil, nil, nil
+	return err
+}
+
+// getRecordTime returns the timestamp of an event or flow record.
+func getRecordTime(sf *sfgo.SysFlow) int64 {
+	switch sf.Rec.UnionType {
+	case sfgo.RecUnionTypeEnumProcessEvent:
+		return sf.Rec.ProcessEvent.Ts
+	case sfgo.RecUnionTypeEnumNetworkFlow:
+		return sf.Rec.NetworkFlow.Ts
+	case sfgo.RecUnionTypeEnumFileFlow:
+		return sf.Rec.FileFlow.Ts
+	case sfgo.RecUnionTypeEnumFileEvent:
+		return sf.Rec.FileEvent.Ts
+	case sfgo.RecUnionTypeEnumK8sEvent:
+		return sf.Rec.K8sEvent.Ts
+	}
+	return 0
+}
+
+// Register registers the SysFlow trace file protocol object with the exporter.
+func (s *SysFlowProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.SysFlowTransport] = NewSysFlowProto
+}
+
+// Cleanup flushes and closes the current trace file.
+func (s *SysFlowProto) Cleanup() {
+	if err := s.close(); err != nil {
+		logger.Error.Println("Unable to close SysFlow trace file: ", err)
+	}
+}
@@ -0,0 +1,90 @@
+package transports_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLoggers(logger.TRACE)
+	os.Exit(m.Run())
+}
+
+var testTs = time.Date(2022, 10, 17, 14, 0, 0, 0, time.UTC).UnixNano()
+
+func newTestRecords(exe string, ts int64) []*sfgo.SysFlow {
+	oid := &sfgo.OID{CreateTS: 1, Hpid: 42}
+	return []*sfgo.SysFlow{
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: &sfgo.SFHeader{Version: 4, Exporter: "node1", Ip: "NA"}}},
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcess, Process: &sfgo.Process{Oid: oid, Exe: exe}}},
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcessEvent, ProcessEvent: &sfgo.ProcessEvent{ProcOID: oid, Ts: ts}}},
+	}
+}
+
+func readTraces(t *testing.T, dir string) (types [][]sfgo.RecUnionTypeEnum) {
+	paths, err := filepath.Glob(filepath.Join(dir, "trace.*.sf"))
+	assert.NoError(t, err)
+	for _, p := range paths {
+		f, err := os.Open(p)
+		assert.NoError(t, err)
+		r, err := sfgo.NewSysFlowReader(f)
+		assert.NoError(t, err)
+		var ts []sfgo.RecUnionTypeEnum
+		for {
+			sf, err := r.Read()
+			if err != nil {
+				break
+			}
+			ts = append(ts, sf.Rec.UnionType)
+		}
+		f.Close()
+		types = append(types, ts)
+	}
+	return
+}
+
+func TestSysFlowProto(t *testing.T) {
+	dir := t.TempDir()
+	config := commons.Config{
+		Format: commons.SysFlowFormat,
+		Clock:  clock.EventMode,
+		SysFlowConfig: commons.SysFlowConfig{
+			SFDir:      dir,
+			SFPrefix:   "trace",
+			SFInterval: 10 * time.Second,
+		},
+	}
+	proto := transports.NewSysFlowProto(config)
+	assert.NoError(t, proto.Init())
+
+	sec := int64(time.Second)
+	assert.NoError(t, proto.Export([]commons.EncodedData{
+		newTestRecords("/bin/sh", testTs),
+		newTestRecords("/bin/sh", testTs+sec),    // entities already written
+		newTestRecords("/bin/ls", testTs+2*sec),  // process changed
+		newTestRecords("/bin/ls", testTs+12*sec), // interval elapsed, entities written again
+	}))
+	proto.Cleanup()
+
+	hdr, proc, pe := sfgo.RecUnionTypeEnumSFHeader, sfgo.RecUnionTypeEnumProcess, sfgo.RecUnionTypeEnumProcessEvent
+	assert.Equal(t, [][]sfgo.RecUnionTypeEnum{
+		{hdr, proc, pe, pe, proc, pe},
+		{hdr, proc, pe},
+	}, readTraces(t, dir))
+	_, err := os.Stat(filepath.Join(dir, "trace.1666015212.sf"))
+	assert.NoError(t, err)
+}
+
+func TestSysFlowProtoFormat(t *testing.T) {
+	proto := transports.NewSysFlowProto(commons.Config{Format: commons.JSONFormat})
+	assert.Error(t, proto.Init())
+}
@@ -21,21 +21,55 @@
 package transports
 
 import (
-	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	syslog "github.com/RackSec/srslog"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+	"github.com/tidwall/gjson"
 )
 
-// SyslogProto implements the TransportProtocol interface for syslog.
+const (
+	syslogFacility = syslog.LOG_DAEMON
+	syslogNilValue = "-"
+	syslogTsFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// syslogSeverities maps rule priorities to syslog severities.
+var syslogSeverities = map[engine.Priority]syslog.Priority{
+	engine.Low:    syslog.LOG_WARNING,
+	engine.Medium: syslog.LOG_ERR,
+	engine.High:   syslog.LOG_CRIT,
+}
+
+// sdEscaper escapes structured data parameter values (RFC 5424, section 6.3.3).
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+// SyslogProto implements the TransportProtocol interface for syslog. Records are sent
+// as RFC 5424 messages, with the policies matched by alerts and the record tags in a
+// structured data element, and a severity derived from the policy priorities.
 type SyslogProto struct {
 	sysl   *syslog.Writer
 	config commons.Config
+	procID string
+}
+
+// syslogMeta holds the record attributes mapped to syslog message headers.
+type syslogMeta struct {
+	ts       time.Time
+	host     string
+	rules    []string
+	priority int
+	tags     []string
 }
 
 // NewSyslogProto creates a new syslog protocol object.
@@ -48,39 +82,144 @@ func (s *SyslogProto) Init() error {
 	var err error
 	raddr := fmt.Sprintf("%s:%d", s.config.Host, s.config.Port)
 	if s.config.Proto == commons.TCPTLSProto {
-		// TODO: verify connection with given trust certifications
-		nopTLSConfig := &tls.Config{InsecureSkipVerify: true}
-		s.sysl, err = syslog.DialWithTLSConfig("tcp+tls", raddr, syslog.LOG_ALERT|syslog.LOG_DAEMON, s.config.Tag, nopTLSConfig)
-	} else {
-		s.sysl, err = syslog.Dial(s.config.Proto.String(), raddr, syslog.LOG_ALERT|syslog.LOG_DAEMON, s.config.Tag)
+		tlsConfig, err := newTLSConfig(s.config.SyslogTLSCA, s.config.SyslogTLSCert, s.config.SyslogTLSKey, s.config.SyslogTLSSkipVerify)
+		if err != nil {
+			return err
+		}
+		s.sysl, err = syslog.DialWithTLSConfig("tcp+tls", raddr, syslogFacility, s.config.Tag, tlsConfig)
+		if err != nil {
+			return err
+		}
+	} else if s.sysl, err = syslog.Dial(s.config.Proto.String(), raddr, syslogFacility, s.config.Tag); err != nil {
+		return err
 	}
-	if err == nil {
-		s.sysl.SetFormatter(syslog.RFC5424Formatter)
-		if s.config.LogSource != sfgo.Zeros.String {
-			s.sysl.SetHostname(s.config.LogSource)
+	// messages are formatted by the exporter, since they carry record attributes
+	octetCounting := s.config.Proto != commons.UDPProto && s.config.SyslogFraming == commons.OctetCountingFraming
+	s.sysl.SetFormatter(func(p syslog.Priority, hostname, tag, content string) string {
+		if octetCounting {
+			return strings.TrimSuffix(content, "\n")
 		}
+		return content
+	})
+	if octetCounting {
+		s.sysl.SetFramer(syslog.RFC5425MessageLengthFramer)
 	}
-	return err
+	s.procID = strconv.Itoa(os.Getpid())
+	return nil
 }
 
-// Export sends buffer to syslog daemon as an alert.
+// Export sends records to the syslog daemon.
 func (s *SyslogProto) Export(data []commons.EncodedData) (err error) {
 	for _, d := range data {
-		if buf, ok := d.([]byte); ok {
-			if err = s.sysl.Alert(utils.UnsafeBytesToString(buf)); err != nil {
+		var buf []byte
+		var meta syslogMeta
+		switch d := d.(type) {
+		case []byte:
+			buf, meta = d, getJSONSyslogMeta(d)
+		case *encoders.ECSRecord:
+			if buf, err = json.Marshal(d); err != nil {
 				return err
 			}
-		} else if buf, err := json.Marshal(d); err == nil {
-			if err = s.sysl.Alert(utils.UnsafeBytesToString(buf)); err != nil {
-				return err
+			meta = getECSSyslogMeta(d)
+		case *encoders.SIEMRecord:
+			buf = d.Event
+			meta = syslogMeta{ts: time.Unix(0, d.Ts), host: d.Host, rules: d.Rules, priority: d.Priority, tags: d.Tags}
+		default:
+			if buf, err = json.Marshal(d); err != nil {
+				return errors.New("expected byte array or serializable object as export data")
 			}
-		} else {
-			return errors.New("expected byte array or serializable object as export data")
+			meta = syslogMeta{ts: time.Now(), priority: -1}
+		}
+		if _, err = s.sysl.Write([]byte(s.format(meta, utils.UnsafeBytesToString(buf)))); err != nil {
+			return err
 		}
 	}
 	return
 }
 
+// format creates an RFC 5424 syslog message.
+func (s *SyslogProto) format(meta syslogMeta, msg string) string {
+	severity, msgID := syslog.LOG_INFO, "event"
+	if meta.priority >= 0 {
+		severity, msgID = syslogSeverities[engine.Priority(meta.priority)], "alert"
+	}
+	host := s.config.LogSource
+	if host == sfgo.Zeros.String {
+		host = meta.host
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<%d>1 %s %s %s %s %s ", syslogFacility|severity, meta.ts.UTC().Format(syslogTsFormat),
+		syslogHeader(host, 255), syslogHeader(s.config.Tag, 48), s.procID, msgID)
+	if len(meta.rules) == 0 && len(meta.tags) == 0 {
+		sb.WriteString(syslogNilValue)
+	} else {
+		sb.WriteByte('[')
+		sb.WriteString(s.config.SyslogSDID)
+		for _, r := range meta.rules {
+			fmt.Fprintf(&sb, ` rule="%s"`, sdEscaper.Replace(r))
+		}
+		if meta.priority >= 0 {
+			fmt.Fprintf(&sb, ` priority="%s"`, engine.Priority(meta.priority))
+		}
+		for _, t := range meta.tags {
+			fmt.Fprintf(&sb, ` tag="%s"`, sdEscaper.Replace(t))
+		}
+		sb.WriteByte(']')
+	}
+	sb.WriteByte(' ')
+	sb.WriteString(msg)
+	return sb.String()
+}
+
+// syslogHeader sanitizes a syslog header field, which must hold at most max printable ASCII characters.
+func syslogHeader(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "" {
+		return syslogNilValue
+	}
+	if len(s) > max {
+		return s[:max]
+	}
+	return s
+}
+
+// getJSONSyslogMeta reads the syslog message attributes of a JSON record.
+func getJSONSyslogMeta(buf []byte) syslogMeta {
+	res := gjson.GetManyBytes(buf, "ts", "node.id", "policies.#.id", "policies.#.priority", "tags")
+	meta := syslogMeta{ts: time.Unix(0, res[0].Int()), host: res[1].String(), priority: -1}
+	for _, r := range res[2].Array() {
+		meta.rules = append(meta.rules, r.String())
+	}
+	for _, p := range res[3].Array() {
+		meta.priority = utils.Max(meta.priority, int(p.Int()))
+	}
+	for _, t := range res[4].Array() {
+		meta.tags = append(meta.tags, t.String())
+	}
+	return meta
+}
+
+// getECSSyslogMeta reads the syslog message attributes of an ECS record.
+func getECSSyslogMeta(ecs *encoders.ECSRecord) syslogMeta {
+	meta := syslogMeta{priority: -1, tags: ecs.Tags}
+	meta.ts, _ = time.Parse(time.RFC3339Nano, ecs.Ts)
+	if host, ok := ecs.Host[encoders.ECS_HOST_ID].(string); ok {
+		meta.host = host
+	}
+	if reason, ok := ecs.Event[encoders.ECS_EVENT_REASON].(string); ok {
+		meta.rules = strings.Split(reason, ", ")
+	}
+	if priority, ok := ecs.Event[encoders.ECS_EVENT_SEVERITY].(int); ok {
+		meta.priority = priority
+	}
+	return meta
+}
+
 // Register registers the syslog proto object with the exporter.
 func (s *SyslogProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
 	eps[commons.SyslogTransport] = NewSyslogProto
@@ -0,0 +1,198 @@
+package transports_test
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+)
+
+// writeTestCert writes a self-signed certificate for 127.0.0.1 and its key to dir.
+func writeTestCert(t *testing.T, dir string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "syslog"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	kder, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	cert, keyf := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(cert, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyf, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600))
+	return cert, keyf
+}
+
+// readSyslog reads n octet-counted messages from the first connection accepted by l.
+func readSyslog(t *testing.T, l net.Listener, n int) <-chan []string {
+	ch := make(chan []string, 1)
+	go func() {
+		var msgs []string
+		defer func() { ch <- msgs }()
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for i := 0; i < n; i++ {
+			size, err := r.ReadString(' ')
+			if err != nil {
+				return
+			}
+			len, _ := strconv.Atoi(strings.TrimSpace(size))
+			buf := make([]byte, len)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			msgs = append(msgs, string(buf))
+		}
+	}()
+	return ch
+}
+
+func newSyslogTestConfig(l net.Listener, format commons.Format) commons.Config {
+	addr := l.Addr().(*net.TCPAddr)
+	return commons.Config{
+		Format:            format,
+		JSONSchemaVersion: "4",
+		SyslogConfig: commons.SyslogConfig{
+			Host:       addr.IP.String(),
+			Port:       addr.Port,
+			Tag:        "sysflow",
+			SyslogSDID: "sysflow@32473",
+		},
+	}
+}
+
+var syslogHeaderRe = regexp.MustCompile(`^<(\d+)>1 (\S+) (\S+) sysflow \d+ (\S+) (-|\[.*?[^\\]\]) \{`)
+
+func TestSyslogProto(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	ch := readSyslog(t, l, 3)
+
+	config := newSyslogTestConfig(l, commons.JSONFormat)
+	proto := transports.NewSyslogProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	recs := newOTLPTestRecords()
+	recs[2].Ctx.SetTags([]string{`quote"d`})
+	data, err := encoders.NewJSONEncoder(config).Encode(recs)
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+
+	msgs := <-ch
+	assert.Len(t, msgs, 3)
+	m := syslogHeaderRe.FindStringSubmatch(msgs[0])
+	assert.NotNil(t, m)
+	assert.Equal(t, []string{"28", "2022-10-17T14:00:00.000000Z", "node1", "alert", `[sysflow@32473 rule="exec" priority="low" tag="mitre:T1059"]`}, m[1:])
+	m = syslogHeaderRe.FindStringSubmatch(msgs[1])
+	assert.NotNil(t, m)
+	assert.Equal(t, "26", m[1])
+	assert.Equal(t, `[sysflow@32473 rule="exec" rule="shell" priority="high" tag="mitre:T1059"]`, m[5])
+	m = syslogHeaderRe.FindStringSubmatch(msgs[2])
+	assert.NotNil(t, m)
+	assert.Equal(t, "node2", m[3])
+	assert.Equal(t, `[sysflow@32473 rule="exec" priority="low" tag="quote\"d" tag="mitre:T1059"]`, m[5])
+	assert.True(t, strings.HasSuffix(msgs[2], "}"))
+}
+
+func TestSyslogProtoCEF(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer l.Close()
+	ch := readSyslog(t, l, 3)
+
+	config := newSyslogTestConfig(l, commons.CEFFormat)
+	proto := transports.NewSyslogProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewCEFEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+
+	msgs := <-ch
+	assert.Len(t, msgs, 3)
+	assert.True(t, strings.HasPrefix(msgs[1], "<26>1 2022-10-17T14:00:00.000000Z node1 sysflow "))
+	assert.Contains(t, msgs[1], ` alert [sysflow@32473 rule="exec" rule="shell" priority="high" tag="mitre:T1059"] CEF:0|SysFlow|sf-processor||shell|`)
+}
+
+func TestSyslogProtoTLS(t *testing.T) {
+	cert, key := writeTestCert(t, t.TempDir())
+	c, err := tls.LoadX509KeyPair(cert, key)
+	assert.NoError(t, err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{c}})
+	assert.NoError(t, err)
+	defer l.Close()
+	ch := readSyslog(t, l, 3)
+
+	config := newSyslogTestConfig(l, commons.ECSFormat)
+	config.Proto = commons.TCPTLSProto
+	config.SyslogTLSCA = cert
+	config.LogSource = "collector 1"
+	proto := transports.NewSyslogProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewECSEncoder(config).Encode(newOTLPTestRecords())
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+
+	msgs := <-ch
+	assert.Len(t, msgs, 3)
+	m := syslogHeaderRe.FindStringSubmatch(msgs[1])
+	assert.NotNil(t, m)
+	assert.Equal(t, []string{"26", "2022-10-17T14:00:00.000000Z", "collector_1", "alert", `[sysflow@32473 rule="exec" rule="shell" priority="high" tag="mitre:T1059"]`}, m[1:])
+}
+
+func TestSyslogProtoTLSVerify(t *testing.T) {
+	cert, key := writeTestCert(t, t.TempDir())
+	c, err := tls.LoadX509KeyPair(cert, key)
+	assert.NoError(t, err)
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{c}})
+	assert.NoError(t, err)
+	defer l.Close()
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	// the server certificate is not signed by a trusted CA
+	config := newSyslogTestConfig(l, commons.JSONFormat)
+	config.Proto = commons.TCPTLSProto
+	assert.Error(t, transports.NewSyslogProto(config).Init())
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/utils"
 )
 
@@ -46,6 +47,8 @@ func (s *TerminalProto) Export(data []commons.EncodedData) error {
 	for _, d := range data {
 		if buf, ok := d.([]byte); ok {
 			fmt.Println(utils.UnsafeBytesToString(buf))
+		} else if rec, ok := d.(*encoders.SIEMRecord); ok {
+			fmt.Println(utils.UnsafeBytesToString(rec.Event))
 		} else if buf, err := json.Marshal(d); err == nil {
 			fmt.Println(utils.UnsafeBytesToString(buf))
 		} else {
@@ -0,0 +1,218 @@
+//
+// Copyright (C) 2020 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transports implements transports for telemetry data.
+package transports
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"text/template"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+)
+
+// webhookFuncs are the functions available to body templates.
+var webhookFuncs = template.FuncMap{
+	// json encodes a value into JSON, e.g., to embed record attributes in JSON strings
+	"json": func(v interface{}) (string, error) {
+		buf, err := json.Marshal(v)
+		return string(buf), err
+	},
+}
+
+// WebhookProto implements the TransportProtocol interface for HTTP endpoints (webhooks).
+// Records are posted in JSON format, either as an array holding a batch of records or
+// one record per request, or formatted with a Go template.
+type WebhookProto struct {
+	config  commons.Config
+	client  *http.Client
+	tmpl    *template.Template
+	retrier retrier
+}
+
+// NewWebhookProto creates a new HTTP webhook protocol object.
+func NewWebhookProto(conf commons.Config) TransportProtocol {
+	return &WebhookProto{config: conf}
+}
+
+// Init initializes the HTTP client and parses the body template.
+func (s *WebhookProto) Init() (err error) {
+	if s.config.Format != commons.JSONFormat && s.config.Format != commons.ECSFormat {
+		return fmt.Errorf("export '%s' requires format '%s' or '%s'", commons.HTTPTransport, commons.JSONFormat, commons.ECSFormat)
+	}
+	u, err := url.Parse(s.config.HTTPURL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme '%s' in %s", u.Scheme, s.config.HTTPURL)
+	}
+	transport := &http.Transport{Proxy: http.ProxyFromEnvironment}
+	if u.Scheme == "https" {
+		transport.TLSClientConfig, err = newTLSConfig(s.config.HTTPTLSCA, s.config.HTTPTLSCert, s.config.HTTPTLSKey, s.config.HTTPTLSSkipVerify)
+		if err != nil {
+			return err
+		}
+	}
+	s.client = &http.Client{Transport: transport, Timeout: s.config.HTTPTimeout}
+	if s.config.HTTPTemplate != "" {
+		s.tmpl, err = template.New(filepath.Base(s.config.HTTPTemplate)).Funcs(webhookFuncs).ParseFiles(s.config.HTTPTemplate)
+		if err != nil {
+			return err
+		}
+	}
+	s.retrier = retrier{max: s.config.HTTPRetryMax, backoff: s.config.HTTPRetryBackoff}
+	return nil
+}
+
+// Export posts records to the HTTP endpoint.
+func (s *WebhookProto) Export(data []commons.EncodedData) (err error) {
+	recs := make([][]byte, len(data))
+	for i, d := range data {
+		if buf, ok := d.([]byte); ok {
+			recs[i] = buf
+		} else if recs[i], err = json.Marshal(d); err != nil {
+			return err
+		}
+	}
+	if s.config.HTTPMode == commons.HTTPBatchMode {
+		body, err := s.encodeBatch(recs)
+		if err != nil {
+			return err
+		}
+		return s.post(body, len(recs))
+	}
+	failed := 0
+	for _, rec := range recs {
+		body, err := s.encodeRecord(rec)
+		if err == nil {
+			err = s.post(body, 1)
+		}
+		if err != nil {
+			logger.Error.Println(err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to send %d of %d records to %s", failed, len(recs), s.config.HTTPURL)
+	}
+	return nil
+}
+
+// encodeBatch creates the request body for a batch of JSON records.
+func (s *WebhookProto) encodeBatch(recs [][]byte) ([]byte, error) {
+	if s.tmpl != nil {
+		values := make([]interface{}, len(recs))
+		for i, rec := range recs {
+			v, err := decodeJSON(rec)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = v
+		}
+		return s.execute(values)
+	}
+	var buf bytes.Buffer
+	buf.WriteByte('[')
+	buf.Write(bytes.Join(recs, []byte{','}))
+	buf.WriteByte(']')
+	return buf.Bytes(), nil
+}
+
+// encodeRecord creates the request body for a single JSON record.
+func (s *WebhookProto) encodeRecord(rec []byte) ([]byte, error) {
+	if s.tmpl != nil {
+		v, err := decodeJSON(rec)
+		if err != nil {
+			return nil, err
+		}
+		return s.execute(v)
+	}
+	return rec, nil
+}
+
+// execute applies the body template to value v.
+func (s *WebhookProto) execute(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := s.tmpl.Execute(&buf, v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decodeJSON decodes a JSON record, keeping numbers (e.g., timestamps in nanoseconds) exact.
+func decodeJSON(rec []byte) (v interface{}, err error) {
+	d := json.NewDecoder(bytes.NewReader(rec))
+	d.UseNumber()
+	err = d.Decode(&v)
+	return
+}
+
+// post sends a request body holding n records to the HTTP endpoint, retrying on server errors.
+func (s *WebhookProto) post(body []byte, n int) error {
+	return s.retrier.do(fmt.Sprintf("send %d records to %s", n, s.config.HTTPURL), func() (time.Duration, error) {
+		req, err := http.NewRequest(http.MethodPost, s.config.HTTPURL, bytes.NewReader(body))
+		if err != nil {
+			return -1, err
+		}
+		for k, v := range s.config.HTTPHeaders {
+			req.Header.Set(k, v)
+		}
+		req.Header.Set("Content-Type", s.config.HTTPContentType)
+		if s.config.HTTPToken != "" {
+			req.Header.Set("Authorization", "Bearer "+s.config.HTTPToken)
+		} else if s.config.HTTPUsername != "" {
+			req.SetBasicAuth(s.config.HTTPUsername, s.config.HTTPPassword)
+		}
+		resp, err := s.client.Do(req)
+		if err != nil {
+			// connection errors and timeouts are transient
+			return 0, err
+		}
+		defer resp.Body.Close()
+		io.Copy(io.Discard, resp.Body)
+		switch {
+		case resp.StatusCode >= 200 && resp.StatusCode < 300:
+			return 0, nil
+		case resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests:
+			return retryAfter(resp), fmt.Errorf("%s responded with status %s", s.config.HTTPURL, resp.Status)
+		}
+		return -1, fmt.Errorf("%s responded with status %s", s.config.HTTPURL, resp.Status)
+	})
+}
+
+// Register registers the HTTP webhook proto object with the exporter.
+func (s *WebhookProto) Register(eps map[commons.Transport]TransportProtocolFactory) {
+	eps[commons.HTTPTransport] = NewWebhookProto
+}
+
+// Cleanup closes idle connections.
+func (s *WebhookProto) Cleanup() {
+	if s.client != nil {
+		s.client.CloseIdleConnections()
+	}
+}
@@ -0,0 +1,143 @@
+package transports_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/encoders"
+	"github.com/sysflow-telemetry/sf-processor/core/exporter/transports"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// testWebhook records the requests received by an HTTP endpoint, responding with the given status codes first.
+type testWebhook struct {
+	codes  []int
+	bodies []string
+	reqs   []*http.Request
+}
+
+func (h *testWebhook) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	h.reqs = append(h.reqs, r)
+	if len(h.codes) > 0 {
+		code := h.codes[0]
+		h.codes = h.codes[1:]
+		w.WriteHeader(code)
+		return
+	}
+	h.bodies = append(h.bodies, string(body))
+}
+
+// withPtree adds the process tree to test records, from which the JSON encoder reads parent process attributes.
+func withPtree(recs []*engine.Record) []*engine.Record {
+	for _, r := range recs {
+		exe := r.Fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXE_STR]
+		r.Fr.Ptree = []*sfgo.Process{{Oid: &sfgo.OID{CreateTS: 1, Hpid: 42}, Exe: exe}}
+	}
+	return recs
+}
+
+func newWebhookTestConfig(url string) commons.Config {
+	return commons.Config{
+		Format:            commons.JSONFormat,
+		JSONSchemaVersion: "4",
+		HTTPConfig: commons.HTTPConfig{
+			HTTPURL:          url,
+			HTTPHeaders:      map[string]string{"X-Source": "sysflow"},
+			HTTPContentType:  "application/json",
+			HTTPTimeout:      time.Second,
+			HTTPRetryMax:     2,
+			HTTPRetryBackoff: time.Millisecond,
+		},
+	}
+}
+
+func TestWebhookProtoBatch(t *testing.T) {
+	h := &testWebhook{codes: []int{http.StatusServiceUnavailable}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	config := newWebhookTestConfig(srv.URL)
+	config.HTTPToken = "secret"
+	proto := transports.NewWebhookProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+	assert.Len(t, h.reqs, 2)
+	assert.Equal(t, "Bearer secret", h.reqs[1].Header.Get("Authorization"))
+	assert.Equal(t, "sysflow", h.reqs[1].Header.Get("X-Source"))
+	assert.Equal(t, "application/json", h.reqs[1].Header.Get("Content-Type"))
+
+	var recs []map[string]interface{}
+	assert.Len(t, h.bodies, 1)
+	assert.NoError(t, json.Unmarshal([]byte(h.bodies[0]), &recs))
+	assert.Len(t, recs, 3)
+	assert.Equal(t, "/bin/ls", recs[1]["proc"].(map[string]interface{})["exe"])
+}
+
+func TestWebhookProtoTemplate(t *testing.T) {
+	h := &testWebhook{}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	tmpl := filepath.Join(t.TempDir(), "slack.tmpl")
+	assert.NoError(t, os.WriteFile(tmpl, []byte(`{"text": {{ printf "%s ran %s" .node.id .proc.exe | json }}, "ts": {{ .ts }}}`), 0644))
+	config := newWebhookTestConfig(srv.URL)
+	config.HTTPMode = commons.HTTPRecordMode
+	config.HTTPTemplate = tmpl
+	config.HTTPUsername, config.HTTPPassword = "user", "pass"
+	proto := transports.NewWebhookProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.NoError(t, proto.Export(data))
+	assert.Equal(t, []string{
+		`{"text": "node1 ran /bin/sh", "ts": 1666015200000000000}`,
+		`{"text": "node1 ran /bin/ls", "ts": 1666015200000000001}`,
+		`{"text": "node2 ran /bin/ps", "ts": 1666015200000000002}`,
+	}, h.bodies)
+	user, pass, ok := h.reqs[0].BasicAuth()
+	assert.True(t, ok)
+	assert.Equal(t, "user", user)
+	assert.Equal(t, "pass", pass)
+}
+
+func TestWebhookProtoRetries(t *testing.T) {
+	h := &testWebhook{codes: []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}}
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+
+	config := newWebhookTestConfig(srv.URL)
+	proto := transports.NewWebhookProto(config)
+	assert.NoError(t, proto.Init())
+	defer proto.Cleanup()
+
+	data, err := encoders.NewJSONEncoder(config).Encode(withPtree(newOTLPTestRecords()))
+	assert.NoError(t, err)
+	assert.Error(t, proto.Export(data))
+	assert.Len(t, h.reqs, 3)
+
+	// client errors are not retried
+	h.codes, h.reqs = []int{http.StatusBadRequest}, nil
+	assert.Error(t, proto.Export(data))
+	assert.Len(t, h.reqs, 1)
+}
+
+func TestWebhookProtoConfig(t *testing.T) {
+	assert.Error(t, transports.NewWebhookProto(commons.Config{Format: commons.OTLPFormat}).Init())
+	assert.Error(t, transports.NewWebhookProto(newWebhookTestConfig("ftp://localhost")).Init())
+}
@@ -21,8 +21,12 @@
 package flattener
 
 import (
+	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
@@ -31,27 +35,41 @@ const (
 	FilterMaxAgeKey string = "filter.maxage"
 )
 
+// ConfigSchema declares the configuration keys accepted by the flattener.
+var ConfigSchema = schema.New(handlerName, []schema.Key{
+	{Name: FilterOnOffKey, Type: schema.Enum, Default: Off.String(), Values: []string{Off.String(), On.String()}},
+	{Name: FilterMaxAgeKey, Type: schema.Seconds, Default: "86400"},
+	clock.SchemaKey,
+})
+
+func init() {
+	schema.Register(ConfigSchema)
+}
+
 // Config defines a configuration object for the engine.
 type Config struct {
 	FilterOnOff  OnOff
 	FilterMaxAge time.Duration
+	Clock        clock.Mode
 }
 
 // CreateConfig creates a new config object from config dictionary.
 func CreateConfig(conf map[string]interface{}) (Config, error) {
 	var c Config = Config{FilterOnOff: Off, FilterMaxAge: 24 * time.Hour} // default values
-	var err error
 	if v, ok := conf[FilterOnOffKey].(string); ok {
 		c.FilterOnOff = parseOnOffType(v)
 	}
 	if v, ok := conf[FilterMaxAgeKey].(string); ok {
-		var duration int
-		duration, err = strconv.Atoi(v)
-		if err == nil {
-			c.FilterMaxAge = time.Duration(duration) * time.Second
+		duration, err := strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FilterMaxAgeKey, err)
 		}
+		c.FilterMaxAge = time.Duration(duration) * time.Second
+	}
+	if v, ok := conf[clock.ConfigKey].(string); ok {
+		c.Clock = clock.ParseMode(v)
 	}
-	return c, err
+	return c, nil
 }
 
 // OnOff defines an On-Off state type.
@@ -27,6 +27,7 @@ import (
 
 	"github.com/cespare/xxhash/v2"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 )
 
 var byteInt64 []byte = make([]byte, 8)
@@ -36,6 +37,7 @@ type Filter struct {
 	m   map[uint64]int64
 	q   *list.List
 	ttl time.Duration
+	clk clock.Clock
 }
 
 // Entry encodes a hash value with the time it was first added to the filter.
@@ -44,9 +46,10 @@ type Entry struct {
 	firstSeen time.Time
 }
 
-// NewFilter creates a new time decaying filter that evicts entries that have been seen longer than t duration.
-func NewFilter(t time.Duration) *Filter {
-	return &Filter{m: make(map[uint64]int64), q: list.New(), ttl: t}
+// NewFilter creates a new time decaying filter that evicts entries that have been seen longer than t duration,
+// as measured by clock clk.
+func NewFilter(t time.Duration, clk clock.Clock) *Filter {
+	return &Filter{m: make(map[uint64]int64), q: list.New(), ttl: t, clk: clk}
 }
 
 // Test tests if hash h has been seen since maximum ttl.
@@ -77,7 +80,7 @@ func (f *Filter) Count(h uint64) int64 {
 func (f *Filter) Add(h uint64) {
 	if v, ok := f.m[h]; !ok {
 		f.m[h] = 1
-		f.q.PushBack(Entry{h: h, firstSeen: time.Now()})
+		f.q.PushBack(Entry{h: h, firstSeen: f.clk.Now()})
 	} else {
 		f.m[h] = v + 1
 	}
@@ -87,7 +90,7 @@ func (f *Filter) evictAgedEntries() {
 	for f.q.Len() > 0 {
 		e := f.q.Front()
 		entry := e.Value.(Entry)
-		if time.Since(entry.firstSeen) < f.ttl {
+		if f.clk.Since(entry.firstSeen) < f.ttl {
 			break
 		}
 		f.q.Remove(e)
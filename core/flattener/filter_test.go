@@ -0,0 +1,48 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+package flattener_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
+	"github.com/sysflow-telemetry/sf-processor/core/flattener"
+)
+
+func TestFilterEventTime(t *testing.T) {
+	c := clock.NewEventClock()
+	f := flattener.NewFilter(10*time.Second, c)
+
+	c.Advance(int64(100 * time.Second))
+	assert.False(t, f.TestAndAdd(1))
+	assert.True(t, f.TestAndAdd(1))
+	c.Advance(int64(105 * time.Second))
+	assert.False(t, f.TestAndAdd(2))
+	assert.Equal(t, int64(2), f.Count(1))
+
+	// entries age with record time, regardless of how long replay takes
+	c.Advance(int64(110 * time.Second))
+	assert.False(t, f.Test(1))
+	assert.True(t, f.Test(2))
+	c.Advance(int64(115 * time.Second))
+	assert.False(t, f.Test(2))
+}
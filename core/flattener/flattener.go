@@ -29,6 +29,7 @@ import (
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/plugins"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 )
 
 const (
@@ -50,6 +51,7 @@ func NewFlattenerChan(size int) interface{} {
 type Flattener struct {
 	config Config
 	filter *Filter
+	clock  clock.Clock
 	outCh  []chan *sfgo.FlatRecord
 }
 
@@ -69,10 +71,14 @@ func (s *Flattener) RegisterHandler(hc plugins.SFHandlerCache) {
 }
 
 // Init initializes the handler with a configuration map.
-func (s *Flattener) Init(conf map[string]interface{}) error {
-	s.config, _ = CreateConfig(conf) // no err check, assuming defaults
+func (s *Flattener) Init(conf map[string]interface{}) (err error) {
+	s.config, err = CreateConfig(conf)
+	if err != nil {
+		return err
+	}
+	s.clock = clock.New(s.config.Clock)
 	if s.config.FilterOnOff.Enabled() {
-		s.filter = NewFilter(s.config.FilterMaxAge)
+		s.filter = NewFilter(s.config.FilterMaxAge, s.clock)
 		logger.Info.Printf("Initialized rate limiter with %s time decay", s.config.FilterMaxAge)
 	}
 	return nil
@@ -92,6 +98,7 @@ func (s *Flattener) SetOutChan(chObj []interface{}) {
 
 // out sends a record to every output channel in the plugin.
 func (s *Flattener) out(fr *sfgo.FlatRecord) {
+	s.clock.Advance(fr.Ints[sfgo.SYSFLOW_IDX][sfgo.TS_INT])
 	if s.config.FilterOnOff.Enabled() && s.filter != nil && s.filter.TestAndAdd(semanticHash(fr)) {
 		return
 	}
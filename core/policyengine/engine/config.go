@@ -22,8 +22,11 @@
 package engine
 
 import (
+	"fmt"
 	"strconv"
 	"time"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Configuration keys.
@@ -39,6 +42,23 @@ const (
 	ActionDirKey         string = "actiondir"
 )
 
+// ConfigSchema declares the configuration keys accepted by the policy engine.
+var ConfigSchema = schema.New("policyengine", []schema.Key{
+	{Name: PoliciesConfigKey, Type: schema.String},
+	{Name: ModeConfigKey, Type: schema.Enum, Default: AlertMode.String(), Values: []string{EnrichMode.String(), AlertMode.String()}},
+	{Name: VersionKey, Type: schema.String},
+	{Name: JSONSchemaVersionKey, Type: schema.String},
+	{Name: BuildNumberKey, Type: schema.String},
+	{Name: MonitorKey, Type: schema.Enum, Default: NoneType.String(), Values: []string{NoneType.String(), LocalType.String()}},
+	{Name: MonitorIntervalKey, Type: schema.Seconds, Default: "30"},
+	{Name: ConcurrencyKey, Type: schema.Int, Default: "5"},
+	{Name: ActionDirKey, Type: schema.String, Default: "../resources/actions"},
+})
+
+func init() {
+	schema.Register(ConfigSchema)
+}
+
 // Config defines a configuration object for the engine.
 type Config struct {
 	PoliciesPath      string
@@ -78,12 +98,16 @@ func CreateConfig(conf map[string]interface{}) (Config, error) {
 	if v, ok := conf[MonitorIntervalKey].(string); ok {
 		var duration int
 		duration, err = strconv.Atoi(v)
-		if err == nil {
-			c.MonitorInterval = time.Duration(duration) * time.Second
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, MonitorIntervalKey, err)
 		}
+		c.MonitorInterval = time.Duration(duration) * time.Second
 	}
 	if v, ok := conf[ConcurrencyKey].(string); ok {
 		c.Concurrency, err = strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, ConcurrencyKey, err)
+		}
 	}
 	if v, ok := conf[ActionDirKey].(string); ok {
 		c.ActionDir = v
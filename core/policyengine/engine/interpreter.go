@@ -23,6 +23,7 @@ package engine
 
 import (
 	"errors"
+	"fmt"
 	"regexp"
 	"strconv"
 	"strings"
@@ -107,18 +108,45 @@ func (pi *PolicyInterpreter) compile(path string) error {
 		return err
 	}
 
+	lexerErrors, parserErrors := pi.parse(is)
+
+	errFound := false
+	if len(lexerErrors) > 0 {
+		logger.Error.Printf("Lexer %d errors found\n", len(lexerErrors))
+		for _, e := range lexerErrors {
+			logger.Error.Println("\t", e.Error())
+		}
+		errFound = true
+	}
+	if len(parserErrors) > 0 {
+		logger.Error.Printf("Parser %d errors found\n", len(parserErrors))
+		for _, e := range parserErrors {
+			logger.Error.Println("\t", e.Error())
+		}
+		errFound = true
+	}
+
+	if errFound {
+		return errors.New("errors found during compilation of policies. check logs for detail")
+	}
+
+	return nil
+}
+
+// parse interprets the policy read from input stream is, and returns the lexer and parser errors.
+func (pi *PolicyInterpreter) parse(is antlr.CharStream) (lexerErrors []error, parserErrors []error) {
 	// Create the Lexer
-	lexerErrors := &errorhandler.SfplErrorListener{}
+	lexerListener := &errorhandler.SfplErrorListener{}
 	lexer := parser.NewSfplLexer(is)
 	lexer.RemoveErrorListeners()
-	lexer.AddErrorListener(lexerErrors)
+	lexer.AddErrorListener(lexerListener)
 	stream := antlr.NewCommonTokenStream(lexer, antlr.TokenDefaultChannel)
 
 	// Create the Parser
-	parserErrors := &errorhandler.SfplErrorListener{}
+	parserListener := &errorhandler.SfplErrorListener{}
 	p := parser.NewSfplParser(stream)
 	p.RemoveErrorListeners()
-	p.AddErrorListener(parserErrors)
+	p.AddErrorListener(parserListener)
 
 	// Pre-processing (to deal with usage before definitions of macros and lists)
 	antlr.ParseTreeWalkerDefault.Walk(pi, p.Defs())
@@ -127,27 +155,30 @@ func (pi *PolicyInterpreter) compile(path string) error {
 	// Parse the policy
 	antlr.ParseTreeWalkerDefault.Walk(pi, p.Policy())
 
-	errFound := false
-	if len(lexerErrors.Errors) > 0 {
-		logger.Error.Printf("Lexer %d errors found\n", len(lexerErrors.Errors))
-		for _, e := range lexerErrors.Errors {
-			logger.Error.Println("\t", e.Error())
-		}
-		errFound = true
-	}
-	if len(parserErrors.Errors) > 0 {
-		logger.Error.Printf("Parser %d errors found\n", len(parserErrors.Errors))
-		for _, e := range parserErrors.Errors {
-			logger.Error.Println("\t", e.Error())
+	return lexerListener.Errors, parserListener.Errors
+}
+
+// CompileCondition compiles an SFPL condition expression, e.g., to select records outside of policies.
+// The condition cannot reference macros or lists.
+func CompileCondition(cond string) (c Criterion, err error) {
+	pi := new(PolicyInterpreter)
+	pi.lists = make(map[string][]string)
+	pi.macroCtxs = make(map[string]parser.IExpressionContext)
+
+	// the condition is compiled as the only filter of a policy
+	defer func() {
+		if r := recover(); r != nil {
+			c, err = False, fmt.Errorf("invalid condition '%s'", cond)
 		}
-		errFound = true
+	}()
+	lexerErrors, parserErrors := pi.parse(antlr.NewInputStream(fmt.Sprintf("- filter: selection\n  condition: %s\n", cond)))
+	if errs := append(lexerErrors, parserErrors...); len(errs) > 0 {
+		return False, fmt.Errorf("invalid condition '%s': %v", cond, errs[0])
 	}
-
-	if errFound {
-		return errors.New("errors found during compilation of policies. check logs for detail")
+	if len(pi.filters) != 1 || len(pi.rules) > 0 || len(pi.lists) > 0 || len(pi.macroCtxs) > 0 {
+		return False, fmt.Errorf("invalid condition '%s'", cond)
 	}
-
-	return nil
+	return pi.filters[0].condition, nil
 }
 
 // Compile parses and interprets a set of input policies defined in paths.
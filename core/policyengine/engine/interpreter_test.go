@@ -26,6 +26,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/sysflow-telemetry/sf-apis/go/ioutils"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
 )
 
 var pi *PolicyInterpreter
@@ -48,3 +49,27 @@ func TestCompileDist(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NoError(t, pi.Compile(paths...))
 }
+
+func TestCompileCondition(t *testing.T) {
+	fr := sfgo.FlatRecord{
+		Sources: []sfgo.Source{sfgo.SYSFLOW_SRC},
+		Ints:    [][]int64{make([]int64, sfgo.INT_ARRAY_SIZE)},
+		Strs:    [][]string{make([]string, sfgo.STR_ARRAY_SIZE)},
+	}
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.SF_REC_TYPE] = sfgo.PROC_EVT
+	fr.Ints[sfgo.SYSFLOW_IDX][sfgo.PROC_UID_INT] = 1000
+	fr.Strs[sfgo.SYSFLOW_IDX][sfgo.PROC_EXE_STR] = "/bin/sh"
+	r := NewRecord(fr)
+
+	c, err := CompileCondition("sf.type = PE and sf.proc.exe in (/bin/sh, /bin/bash)")
+	assert.NoError(t, err)
+	assert.True(t, c.Eval(r))
+	c, err = CompileCondition("sf.proc.uid = 0 or sf.proc.name startswith bash")
+	assert.NoError(t, err)
+	assert.False(t, c.Eval(r))
+
+	_, err = CompileCondition("sf.proc.exe =")
+	assert.Error(t, err)
+	_, err = CompileCondition("sf.proc.exe = /bin/sh\n- macro: m\n  condition: sf.proc.uid = 0")
+	assert.EqualError(t, err, "invalid condition 'sf.proc.exe = /bin/sh\n- macro: m\n  condition: sf.proc.uid = 0'")
+}
@@ -71,7 +71,10 @@ func (s *PolicyEngine) Register(pc plugins.SFPluginCache) {
 
 // Init initializes the plugin.
 func (s *PolicyEngine) Init(conf map[string]interface{}) (err error) {
-	s.config, _ = engine.CreateConfig(conf) // no err check, assuming defaults
+	s.config, err = engine.CreateConfig(conf)
+	if err != nil {
+		return
+	}
 
 	if s.config.Mode == engine.EnrichMode {
 		logger.Trace.Println("Setting policy engine in 'enrich' mode")
@@ -22,6 +22,7 @@
 package processor
 
 import (
+	"strconv"
 	"strings"
 	"sync"
 
@@ -40,6 +41,16 @@ const (
 // This plugin should typically be first in the pipeline.
 type SysFlowReader struct {
 	SysFlowProcessor
+	hdr      *sfgo.SFHeader
+	tables   *cache.SFTables
+	contexts map[string]*readerContext
+}
+
+// readerContext holds the entity tables of an input stream.
+// Drivers multiplexing several input streams into the pipeline resend the
+// header record of a stream before forwarding its records; the reader then
+// switches to the entity tables of that stream instead of resetting them.
+type readerContext struct {
 	hdr    *sfgo.SFHeader
 	tables *cache.SFTables
 }
@@ -73,9 +84,19 @@ func (s *SysFlowReader) Register(pc plugins.SFPluginCache) {
 // Init initializes the processor with a configuration map.
 func (s *SysFlowReader) Init(conf map[string]interface{}) (err error) {
 	s.tables = cache.NewSFTables()
+	s.contexts = make(map[string]*readerContext)
 	return s.SysFlowProcessor.Init(conf)
 }
 
+// TransferState hands over the reader's entity cache to a replacement reader when the pipeline is reloaded.
+func (s *SysFlowReader) TransferState(to plugins.SFProcessor) {
+	if r, ok := to.(*SysFlowReader); ok {
+		r.hdr = s.hdr
+		r.tables = s.tables
+		r.contexts = s.contexts
+	}
+}
+
 // Process implements the main processor method of the plugin.
 func (s *SysFlowReader) Process(ch interface{}, wg *sync.WaitGroup) {
 	entEnabled := s.hdl.IsEntityEnabled()
@@ -94,8 +115,9 @@ func (s *SysFlowReader) Process(ch interface{}, wg *sync.WaitGroup) {
 		sf.Header = s.hdr
 		switch sf.Rec.UnionType {
 		case sfgo.SF_HEADER:
-			s.hdr = sf.Rec.SFHeader
-			s.tables.Reset()
+			if s.switchContext(sf.Rec.SFHeader) {
+				break
+			}
 			if entEnabled {
 				s.hdl.HandleHeader(sf, s.hdr)
 			}
@@ -161,6 +183,42 @@ func (s *SysFlowReader) Process(ch interface{}, wg *sync.WaitGroup) {
 	}
 }
 
+// switchContext sets the entity tables for the stream of header hdr. It returns true if
+// hdr is a resent header of a known stream or marks the end of a stream, and false if hdr
+// starts a new stream, in which case the stream is assigned new entity tables.
+func (s *SysFlowReader) switchContext(hdr *sfgo.SFHeader) bool {
+	ref, registered := getStream(hdr)
+	key := getStreamKey(hdr, ref, registered)
+	ctx, ok := s.contexts[key]
+	if ref.end {
+		if ok {
+			streams.Delete(ctx.hdr)
+			delete(s.contexts, key)
+		}
+		streams.Delete(hdr)
+		return true
+	}
+	if ok && ctx.hdr == hdr {
+		s.hdr, s.tables = ctx.hdr, ctx.tables
+		return true
+	}
+	if ok && registered {
+		streams.Delete(ctx.hdr)
+	}
+	s.hdr, s.tables = hdr, cache.NewSFTables()
+	s.contexts[key] = &readerContext{hdr: s.hdr, tables: s.tables}
+	return false
+}
+
+// getStreamKey returns the key identifying the input stream of a header. Headers of streams
+// registered by a driver are keyed by stream, and other headers by exporter and IP.
+func getStreamKey(hdr *sfgo.SFHeader, ref streamRef, registered bool) string {
+	if registered {
+		return "#" + strconv.FormatUint(ref.id, 10)
+	}
+	return hdr.Exporter + "|" + hdr.Ip
+}
+
 // Cleanup tears down the plugin resources.
 func (s *SysFlowReader) Cleanup() {
 	logger.Trace.Println("Exiting ", readerPluginName)
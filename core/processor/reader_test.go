@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/cache"
+)
+
+func newTestHeader(exporter string, ip string) *sfgo.SFHeader {
+	hdr := sfgo.NewSFHeader()
+	hdr.Exporter = exporter
+	hdr.Ip = ip
+	return hdr
+}
+
+func newTestReader() *SysFlowReader {
+	return &SysFlowReader{tables: cache.NewSFTables(), contexts: make(map[string]*readerContext)}
+}
+
+func TestSwitchContextStreams(t *testing.T) {
+	s := newTestReader()
+	h1, h2 := newTestHeader("collector", "10.0.0.1"), newTestHeader("collector", "10.0.0.1")
+	SetStream(h1, 1)
+	SetStream(h2, 2)
+
+	assert.False(t, s.switchContext(h1))
+	t1 := s.tables
+	assert.False(t, s.switchContext(h2))
+	t2 := s.tables
+	assert.NotSame(t, t1, t2)
+
+	// resent headers switch back to the tables of their stream
+	assert.True(t, s.switchContext(h1))
+	assert.Same(t, t1, s.tables)
+	assert.Same(t, h1, s.hdr)
+	assert.True(t, s.switchContext(h2))
+	assert.Same(t, t2, s.tables)
+
+	// a new header on a stream resets its tables only
+	h3 := newTestHeader("collector", "10.0.0.1")
+	SetStream(h3, 1)
+	assert.False(t, s.switchContext(h3))
+	assert.NotSame(t, t1, s.tables)
+	_, ok := getStream(h1)
+	assert.False(t, ok)
+	assert.True(t, s.switchContext(h2))
+	assert.Same(t, t2, s.tables)
+
+	// end of stream markers release the stream
+	for _, id := range []uint64{1, 2} {
+		eos := EndOfStream(id)
+		assert.True(t, s.switchContext(eos.Rec.SFHeader))
+		_, ok = getStream(eos.Rec.SFHeader)
+		assert.False(t, ok)
+	}
+	assert.Empty(t, s.contexts)
+	for _, h := range []*sfgo.SFHeader{h2, h3} {
+		_, ok = getStream(h)
+		assert.False(t, ok)
+	}
+}
+
+func TestSwitchContextUnregistered(t *testing.T) {
+	s := newTestReader()
+	h1, h2 := newTestHeader("collector", "10.0.0.1"), newTestHeader("collector", "10.0.0.1")
+	assert.False(t, s.switchContext(h1))
+	t1 := s.tables
+	assert.True(t, s.switchContext(h1))
+	assert.Same(t, t1, s.tables)
+	assert.False(t, s.switchContext(h2))
+	assert.NotSame(t, t1, s.tables)
+	assert.Len(t, s.contexts, 1)
+}
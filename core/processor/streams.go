@@ -0,0 +1,61 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package processor implements a processor plugin.
+package processor
+
+import (
+	"sync"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// streams maps the header records of input streams multiplexed into the pipeline by
+// drivers (e.g., collector connections) to the stream they belong to.
+var streams sync.Map
+
+// streamRef identifies the input stream of a header record.
+type streamRef struct {
+	id  uint64
+	end bool
+}
+
+// SetStream associates header record hdr with input stream id. Drivers multiplexing several
+// input streams into the pipeline register the headers of each stream, so that the reader
+// keeps separate entity tables per stream regardless of the exporter and IP in the headers.
+func SetStream(hdr *sfgo.SFHeader, id uint64) {
+	streams.Store(hdr, streamRef{id: id})
+}
+
+// EndOfStream returns a header record marking the end of input stream id. Drivers send it
+// once the stream closed, so that the reader releases the entity tables of the stream.
+func EndOfStream(id uint64) *sfgo.SysFlow {
+	sf := sfgo.NewSysFlow()
+	sf.Rec = &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: sfgo.NewSFHeader()}
+	streams.Store(sf.Rec.SFHeader, streamRef{id: id, end: true})
+	return sf
+}
+
+// getStream returns the input stream registered for header record hdr.
+func getStream(hdr *sfgo.SFHeader) (streamRef, bool) {
+	if v, ok := streams.Load(hdr); ok {
+		return v.(streamRef), true
+	}
+	return streamRef{}, false
+}
@@ -0,0 +1,262 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package schema implements declarative schemas for plugin configuration keys.
+package schema
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Type denotes the type of a configuration value.
+type Type int
+
+// Configuration value types.
+const (
+	String   Type = iota // free-form string
+	Int                  // integer
+	Bool                 // true or false
+	Seconds              // integer number of seconds
+	Duration             // Go duration string (e.g., 30s)
+	Enum                 // one of an enumerated set of values
+	List                 // comma-separated list of strings
+	Float                // floating-point number
+)
+
+func (t Type) String() string {
+	return [...]string{"string", "int", "bool", "seconds", "duration", "enum", "list", "float"}[t]
+}
+
+// Key describes an accepted configuration key.
+type Key struct {
+	Name    string
+	Type    Type
+	Default string
+	Values  []string // accepted values for Enum keys
+	Secret  bool     // value is masked when printed
+}
+
+// Check validates a configuration value against the key type.
+func (k Key) Check(v interface{}) error {
+	s, ok := v.(string)
+	if !ok {
+		return fmt.Errorf("invalid value %v for key '%s': expected a quoted %s value, got %T", v, k.Name, k.Type, v)
+	}
+	switch k.Type {
+	case Int:
+		if _, err := strconv.Atoi(s); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s': expected an integer", s, k.Name)
+		}
+	case Seconds:
+		if _, err := strconv.Atoi(s); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s': expected an integer number of seconds", s, k.Name)
+		}
+	case Float:
+		if _, err := strconv.ParseFloat(s, 64); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s': expected a number", s, k.Name)
+		}
+	case Bool:
+		if _, err := strconv.ParseBool(s); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s': expected true or false", s, k.Name)
+		}
+	case Duration:
+		if _, err := time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid value '%s' for key '%s': expected a duration (e.g., 30s, 5m)", s, k.Name)
+		}
+	case Enum:
+		for _, e := range k.Values {
+			if s == e {
+				return nil
+			}
+		}
+		return fmt.Errorf("invalid value '%s' for key '%s': expected one of {%s}", s, k.Name, strings.Join(k.Values, "|"))
+	}
+	return nil
+}
+
+// Schema defines the set of configuration keys accepted by a plugin.
+type Schema struct {
+	Name string
+	Keys []Key
+}
+
+// New creates a new schema for plugin name.
+func New(name string, keys ...[]Key) *Schema {
+	s := &Schema{Name: name}
+	for _, k := range keys {
+		s.Keys = append(s.Keys, k...)
+	}
+	return s
+}
+
+// Key looks up a key declaration by name.
+func (s *Schema) Key(name string) (Key, bool) {
+	for _, k := range s.Keys {
+		if k.Name == name {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// Validate checks a configuration map against the schema. Keys in ignore are
+// accepted without checks. All violations found are reported in the error.
+func (s *Schema) Validate(conf map[string]interface{}, ignore ...string) error {
+	var problems []string
+	names := make([]string, 0, len(conf))
+	for n := range conf {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		if contains(ignore, n) {
+			continue
+		}
+		if k, ok := s.Key(n); ok {
+			if err := k.Check(conf[n]); err != nil {
+				problems = append(problems, err.Error())
+			}
+		} else if sg := s.suggest(n); sg != "" {
+			problems = append(problems, fmt.Sprintf("unknown key '%s' (did you mean '%s'?)", n, sg))
+		} else {
+			problems = append(problems, fmt.Sprintf("unknown key '%s'", n))
+		}
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Plugin: s.Name, Problems: problems}
+	}
+	return nil
+}
+
+// Setting represents the effective value of a configuration key.
+type Setting struct {
+	Key       Key
+	Value     string
+	IsDefault bool
+}
+
+func (s Setting) String() string {
+	v := s.Value
+	if s.Key.Secret && v != "" {
+		v = "********"
+	}
+	if s.IsDefault {
+		return fmt.Sprintf("%s = %s (default)", s.Key.Name, v)
+	}
+	return fmt.Sprintf("%s = %s", s.Key.Name, v)
+}
+
+// Effective returns the effective settings obtained by applying conf over the schema defaults.
+func (s *Schema) Effective(conf map[string]interface{}) []Setting {
+	settings := make([]Setting, 0, len(s.Keys))
+	for _, k := range s.Keys {
+		if v, ok := conf[k.Name]; ok {
+			settings = append(settings, Setting{Key: k, Value: fmt.Sprint(v)})
+		} else {
+			settings = append(settings, Setting{Key: k, Value: k.Default, IsDefault: true})
+		}
+	}
+	return settings
+}
+
+// suggest returns the closest declared key to name, if any is close enough to be a likely typo.
+func (s *Schema) suggest(name string) string {
+	best, dist := "", len(name)/3+1
+	for _, k := range s.Keys {
+		if d := levenshtein(name, k.Name); d <= dist {
+			best, dist = k.Name, d
+		}
+	}
+	return best
+}
+
+// ValidationError lists the configuration problems found for a plugin.
+type ValidationError struct {
+	Plugin   string
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Plugin, strings.Join(e.Problems, "; "))
+}
+
+var (
+	registry = make(map[string]*Schema)
+	mu       sync.RWMutex
+)
+
+// Register adds a plugin schema to the schema registry.
+func Register(s *Schema) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry[s.Name] = s
+}
+
+// Lookup retrieves a registered plugin schema by name.
+func Lookup(name string) (*Schema, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	s, ok := registry[name]
+	return s, ok
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
+
+// levenshtein computes the edit distance between two strings.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func min(v ...int) int {
+	m := v[0]
+	for _, e := range v[1:] {
+		if e < m {
+			m = e
+		}
+	}
+	return m
+}
@@ -0,0 +1,100 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+package schema_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+var testSchema = schema.New("test", []schema.Key{
+	{Name: "syslog.proto", Type: schema.Enum, Default: "tcp", Values: []string{"tcp", "tls", "udp"}},
+	{Name: "syslog.port", Type: schema.Int, Default: "514"},
+	{Name: "filter.maxage", Type: schema.Seconds, Default: "86400"},
+	{Name: "timeout", Type: schema.Duration, Default: "30s"},
+	{Name: "enabled", Type: schema.Bool, Default: "false"},
+	{Name: "password", Type: schema.String, Secret: true},
+	{Name: "speed", Type: schema.Float, Default: "1"},
+})
+
+func TestValidate(t *testing.T) {
+	err := testSchema.Validate(map[string]interface{}{
+		"processor":    "test",
+		"syslog.proto": "udp",
+		"syslog.port":  "6514",
+		"timeout":      "1m",
+		"enabled":      "true",
+		"speed":        "2.5",
+	}, "processor")
+	assert.NoError(t, err)
+}
+
+func TestValidateFloat(t *testing.T) {
+	err := testSchema.Validate(map[string]interface{}{"speed": "fast"})
+	assert.Error(t, err)
+	verr := err.(*schema.ValidationError)
+	assert.Equal(t, []string{"invalid value 'fast' for key 'speed': expected a number"}, verr.Problems)
+}
+
+func TestValidateUnknownKey(t *testing.T) {
+	err := testSchema.Validate(map[string]interface{}{"syslog.prot": "udp", "foo": "bar"})
+	assert.Error(t, err)
+	verr, ok := err.(*schema.ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, "test", verr.Plugin)
+	assert.Equal(t, []string{"unknown key 'foo'", "unknown key 'syslog.prot' (did you mean 'syslog.proto'?)"}, verr.Problems)
+}
+
+func TestValidateMalformedValues(t *testing.T) {
+	err := testSchema.Validate(map[string]interface{}{
+		"syslog.proto":  "http",
+		"syslog.port":   514,
+		"filter.maxage": "1d",
+		"timeout":       "30",
+		"enabled":       "yes",
+	})
+	assert.Error(t, err)
+	verr := err.(*schema.ValidationError)
+	assert.Len(t, verr.Problems, 5)
+	assert.Equal(t, "invalid value 'yes' for key 'enabled': expected true or false", verr.Problems[0])
+	assert.Equal(t, "invalid value '1d' for key 'filter.maxage': expected an integer number of seconds", verr.Problems[1])
+	assert.Equal(t, "invalid value 514 for key 'syslog.port': expected a quoted int value, got int", verr.Problems[2])
+	assert.Equal(t, "invalid value 'http' for key 'syslog.proto': expected one of {tcp|tls|udp}", verr.Problems[3])
+	assert.Equal(t, "invalid value '30' for key 'timeout': expected a duration (e.g., 30s, 5m)", verr.Problems[4])
+}
+
+func TestEffective(t *testing.T) {
+	settings := testSchema.Effective(map[string]interface{}{"syslog.port": "6514", "password": "secret"})
+	assert.Len(t, settings, len(testSchema.Keys))
+	assert.Equal(t, "syslog.proto = tcp (default)", settings[0].String())
+	assert.Equal(t, "syslog.port = 6514", settings[1].String())
+	assert.Equal(t, "password = ********", settings[5].String())
+}
+
+func TestRegistry(t *testing.T) {
+	schema.Register(testSchema)
+	s, ok := schema.Lookup("test")
+	assert.True(t, ok)
+	assert.Equal(t, testSchema, s)
+	_, ok = schema.Lookup("nonexistent")
+	assert.False(t, ok)
+}
@@ -30,16 +30,17 @@ import (
 	"syscall"
 
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
-	"github.com/sysflow-telemetry/sf-apis/go/plugins"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/clock"
 	"github.com/sysflow-telemetry/sf-processor/driver/manifest"
 	"github.com/sysflow-telemetry/sf-processor/driver/pipeline"
+	"github.com/sysflow-telemetry/sf-processor/driver/sysflow"
 )
 
-var pl plugins.SFPipeline
+var pl *pipeline.Pipeline
 
 func initSigTerm() {
-	c := make(chan os.Signal)
+	c := make(chan os.Signal, 1)
 	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		<-c
@@ -50,6 +51,22 @@ func initSigTerm() {
 	}()
 }
 
+func initSigHup() {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	go func() {
+		for range c {
+			logger.Info.Println("Received SIGHUP, reloading pipeline configuration")
+			if pl == nil {
+				continue
+			}
+			if err := pl.Reload(); err != nil {
+				logger.Error.Println("Unable to reload pipeline configuration: ", err)
+			}
+		}
+	}()
+}
+
 func main() { os.Exit(run()) }
 
 func run() int {
@@ -58,7 +75,7 @@ func run() int {
 	initSigTerm()
 
 	// setup arg parsing
-	inputType := flag.String("driver", "file", fmt.Sprintf("Driver name {file|socket|<custom>}"))
+	inputType := flag.String("driver", "file", fmt.Sprintf("Driver name {file|socket|tcp|<custom>}"))
 	cpuprofile := flag.String("cpuprofile", "", "Write cpu profile to `file`")
 	memprofile := flag.String("memprofile", "", "Write memory profile to `file`")
 	traceprofile := flag.String("traceprofile", "", "Write trace profile to `file`")
@@ -67,12 +84,15 @@ func run() int {
 	driverDir := flag.String("driverdir", pipeline.DriverDir, "Dynamic driver directory")
 	pluginDir := flag.String("plugdir", pipeline.PluginDir, "Dynamic plugins directory")
 	test := flag.Bool("test", false, "Test pipeline configuration")
+	watch := flag.Bool("watch", false, "Reload pipeline configuration when the configuration file changes")
+	follow := flag.Bool("follow", false, "Follow directory path, processing new trace files as they are finalized (file driver)")
+	clockMode := flag.String("clock", "", "Clock of time-dependent stages {wall|event}, where event time advances with record timestamps")
 	version := flag.Bool("version", false, "Output version information")
 
 	flag.Usage = func() {
 		fmt.Println(`Usage: sfprocessor [-version
 		   |-test [-log <value>] [-config <value>] [-driverdir <value>] [-plugdir <value>]]
-		   |[-driver <value>] [-log <value>] [-config <value>] [-driverdir <value>] [-plugdir <value>] [-cpuprofile <value>] [-memprofile <value>] [-traceprofile <value>] path]`)
+		   |[-driver <value>] [-watch] [-follow] [-clock <value>] [-log <value>] [-config <value>] [-driverdir <value>] [-plugdir <value>] [-cpuprofile <value>] [-memprofile <value>] [-traceprofile <value>] path]`)
 		fmt.Println()
 		fmt.Println("Positional arguments:")
 		fmt.Println("  path string\n\tInput path")
@@ -134,6 +154,25 @@ func run() int {
 
 	// load pipeline
 	pl = pipeline.New(*driverDir, *pluginDir, *configFile)
+	if *follow {
+		pl.SetDriverConfig(sysflow.FollowConfigKey, "true")
+	}
+	if *clockMode != "" {
+		if err := clock.SchemaKey.Check(*clockMode); err != nil {
+			logger.Error.Println(err)
+			return 1
+		}
+		pl.SetStageConfig(clock.ConfigKey, *clockMode)
+	}
+
+	// validate plugin configuration keys before loading when testing configuration
+	if *test {
+		if err := pl.Validate(); err != nil {
+			logger.Error.Println(err)
+			return 1
+		}
+	}
+
 	err := pl.Load(*inputType)
 	if err != nil {
 		logger.Error.Println("Unable to load pipeline error: ", err.Error())
@@ -146,11 +185,21 @@ func run() int {
 	// log success status for pipeline configuration
 	logger.Info.Println("Successfully loaded pipeline configuration")
 
-	// exit if testing configuration
+	// print effective configuration and exit if testing configuration
 	if *test {
+		pl.PrintConfig()
 		return 0
 	}
 
+	// setup configuration reload handlers
+	initSigHup()
+	if *watch {
+		if err := pl.Watch(); err != nil {
+			logger.Error.Println("Unable to watch pipeline configuration: ", err)
+			return 1
+		}
+	}
+
 	// retrieve positional args
 	path := flag.Arg(0)
 
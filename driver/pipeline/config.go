@@ -21,13 +21,19 @@
 package pipeline
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
 	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
 	"github.com/sysflow-telemetry/sf-processor/core/exporter/commons"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 	"github.com/sysflow-telemetry/sf-processor/driver/manifest"
 )
 
@@ -43,8 +49,12 @@ const (
 	HdlConfig     string = "handler"
 	InChanConfig  string = "in"
 	OutChanConfig string = "out"
+	HdlLibConfig  string = "handlerlibpath"
 )
 
+// stageKeys lists the pipeline attributes accepted in every plugin configuration.
+var stageKeys = []string{ModConfig, ProcConfig, HdlConfig, HdlLibConfig, InChanConfig, OutChanConfig}
+
 // Driver constants/defaults
 const (
 	SockFile            = "/var/run/sysflow.sock"
@@ -59,9 +69,23 @@ const (
 // PluginConfig defines a map for plugin configuration
 type PluginConfig map[string]interface{}
 
+// Global config variables
+const (
+	DriverEnvPrefix string = "DRIVER_"
+)
+
 // Config defines a pipeline configuration object
 type Config struct {
-	Pipeline []PluginConfig `json,mapstructures:"pipeline"`
+	Pipeline []PluginConfig          `json,mapstructures:"pipeline"`
+	Drivers  map[string]PluginConfig `json,mapstructures:"drivers"`
+}
+
+// ConfigurableDriver is implemented by drivers accepting configuration attributes.
+// Driver attributes are read from the drivers section of the pipeline configuration,
+// keyed by driver name, and from environment variables prefixed with DRIVER_.
+type ConfigurableDriver interface {
+	plugins.SFDriver
+	Configure(conf map[string]interface{}) error
 }
 
 // setManifestInfo sets manifest attributes to plugins configuration items.
@@ -96,3 +120,156 @@ func getEnv(k string) string {
 	}
 	return sfgo.Zeros.String
 }
+
+// getSchema retrieves the configuration schema declared by the plugin of a pipeline stage.
+// Handler schemas take precedence over the schema of their enclosing processor.
+func getSchema(c PluginConfig) (*schema.Schema, bool) {
+	if hdl, ok := c[HdlConfig].(string); ok {
+		return schema.Lookup(hdl)
+	}
+	if proc, ok := c[ProcConfig].(string); ok {
+		return schema.Lookup(proc)
+	}
+	return nil, false
+}
+
+// getStageName returns a printable name for a pipeline stage.
+func getStageName(c PluginConfig) string {
+	name, _ := c[ProcConfig].(string)
+	if hdl, ok := c[HdlConfig].(string); ok {
+		name = fmt.Sprintf("%s (%s)", name, hdl)
+	}
+	return name
+}
+
+// getDriverConfig returns the configuration of driver name, with environment overrides applied.
+// Only environment variables corresponding to keys declared in the driver's schema are applied.
+func getDriverConfig(conf *Config, name string) PluginConfig {
+	c := make(PluginConfig)
+	for k, v := range conf.Drivers[name] {
+		c[k] = v
+	}
+	s, ok := schema.Lookup(name)
+	if !ok {
+		return c
+	}
+	for _, k := range s.Keys {
+		env := DriverEnvPrefix + strings.ToUpper(strings.ReplaceAll(k.Name, ".", "_"))
+		if v, ok := os.LookupEnv(env); ok {
+			c[k.Name] = v
+		}
+	}
+	return c
+}
+
+// validateConfig checks plugin configuration items against the schemas declared by plugins.
+func validateConfig(conf *Config) error {
+	var problems []string
+	names := make([]string, 0, len(conf.Drivers))
+	for name := range conf.Drivers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s, ok := schema.Lookup(name)
+		if !ok {
+			continue
+		}
+		if err := s.Validate(conf.Drivers[name]); err != nil {
+			var verr *schema.ValidationError
+			if !errors.As(err, &verr) {
+				return err
+			}
+			for _, p := range verr.Problems {
+				problems = append(problems, fmt.Sprintf("driver %s: %s", name, p))
+			}
+		}
+	}
+	for i, c := range conf.Pipeline {
+		s, ok := getSchema(c)
+		if !ok {
+			continue
+		}
+		if err := s.Validate(c, stageKeys...); err != nil {
+			var verr *schema.ValidationError
+			if !errors.As(err, &verr) {
+				return err
+			}
+			for _, p := range verr.Problems {
+				problems = append(problems, fmt.Sprintf("stage %d, %s: %s", i+1, getStageName(c), p))
+			}
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid pipeline configuration:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// printConfig writes the effective configuration of the driver and of each pipeline stage to w.
+func printConfig(w io.Writer, conf *Config, driver string, dconf PluginConfig) {
+	fmt.Fprintln(w, "Effective pipeline configuration:")
+	fmt.Fprintf(w, "[driver] %s\n", driver)
+	s, _ := schema.Lookup(driver)
+	printSettings(w, s, dconf)
+	for i, c := range conf.Pipeline {
+		fmt.Fprintf(w, "[%d] %s\n", i+1, getStageName(c))
+		for _, k := range stageKeys {
+			if v, ok := c[k]; ok && k != ProcConfig && k != HdlConfig {
+				fmt.Fprintf(w, "\t%s = %v\n", k, v)
+			}
+		}
+		s, _ := getSchema(c)
+		printSettings(w, s, c)
+	}
+}
+
+// printSettings writes the effective settings of a plugin configuration to w.
+// Configuration attributes are printed as is when the plugin declares no schema.
+func printSettings(w io.Writer, s *schema.Schema, c PluginConfig) {
+	if s != nil {
+		for _, st := range s.Effective(c) {
+			if st.IsDefault && st.Value == sfgo.Zeros.String {
+				continue
+			}
+			fmt.Fprintf(w, "\t%s\n", st)
+		}
+		return
+	}
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !isStageKey(k) {
+			fmt.Fprintf(w, "\t%s = %v\n", k, c[k])
+		}
+	}
+}
+
+// getOutChans returns the output channel descriptors of a plugin configuration.
+func getOutChans(c PluginConfig) []string {
+	var outs []string
+	switch t := c[OutChanConfig].(type) {
+	case []interface{}:
+		for _, ch := range t {
+			if s, ok := ch.(string); ok {
+				outs = append(outs, s)
+			}
+		}
+	case string:
+		outs = append(outs, t)
+	}
+	return outs
+}
+
+// isStageKey checks whether k is a pipeline attribute.
+func isStageKey(k string) bool {
+	for _, s := range stageKeys {
+		if s == k {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,22 @@
+package pipeline
+
+import (
+	"testing"
+
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+func TestGetDriverConfig(t *testing.T) {
+	t.Setenv("DRIVER_PEER_TAG", "none")
+	t.Setenv("DRIVER_MAXMSGSIZE", "1024")
+	t.Setenv("DRIVER_FOO", "bar")
+	conf := &Config{Drivers: map[string]PluginConfig{"tcp": {"peer.tag": "ip", "tls.ca": "/etc/ca.pem"}}}
+	c := getDriverConfig(conf, "tcp")
+	if len(c) != 3 || c["peer.tag"] != "none" || c["maxmsgsize"] != "1024" || c["tls.ca"] != "/etc/ca.pem" {
+		t.Fatalf("unexpected driver configuration %v", c)
+	}
+	s, _ := schema.Lookup("tcp")
+	if err := s.Validate(c); err != nil {
+		t.Fatal(err)
+	}
+}
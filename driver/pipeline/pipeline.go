@@ -24,10 +24,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sync"
 
+	"github.com/fsnotify/fsnotify"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 // Pipeline represents a loaded plugin pipeline
@@ -35,6 +38,8 @@ type Pipeline struct {
 	wg          *sync.WaitGroup
 	driver      plugins.SFDriver
 	processors  []plugins.SFProcessor
+	stages      []*stage
+	consumers   map[string][]*relay
 	channels    []interface{}
 	handlers    []plugins.SFHandler
 	pluginCache *PluginCache
@@ -42,6 +47,13 @@ type Pipeline struct {
 	pluginDir   string
 	driverDir   string
 	running     bool
+	conf        *Config
+	driverName  string
+	driverConf  PluginConfig
+	driverAttrs PluginConfig
+	stageAttrs  PluginConfig
+	mu          sync.Mutex
+	watcher     *fsnotify.Watcher
 }
 
 // New creates a new pipeline object
@@ -86,7 +98,11 @@ func (pl *Pipeline) Load(driverName string) error {
 		logger.Error.Println("Unable to load pipeline config: ", err)
 		return err
 	}
+	if err := validateConfig(conf); err != nil {
+		logger.Warn.Println(err)
+	}
 	setManifestInfo(conf)
+	pl.setStageAttrs(conf)
 	if err := pl.pluginCache.LoadDrivers(pl.driverDir); err != nil {
 		logger.Error.Println("Unable to load dynamic driver: ", err)
 		return err
@@ -95,76 +111,167 @@ func (pl *Pipeline) Load(driverName string) error {
 		logger.Error.Println("Unable to load driver: ", err)
 		return err
 	}
-	var in interface{}
-	var out interface{}
-	for _, p := range conf.Pipeline {
-		var prc plugins.SFProcessor
-		if val, ok := p[ProcConfig].(string); ok {
-			prc, err = pl.pluginCache.GetProcessor(pl.pluginDir, val)
-			if err != nil {
-				logger.Error.Println(err)
-				return err
-			}
-			tp := fmt.Sprintf("%T", prc)
-			logger.Trace.Println(tp)
-			err = prc.Init(p)
-			if err != nil {
-				logger.Error.Println(err)
+	pl.driverName = driverName
+	pl.driverConf = getDriverConfig(conf, driverName)
+	for k, v := range pl.driverAttrs {
+		pl.driverConf[k] = v
+	}
+	if d, ok := pl.driver.(ConfigurableDriver); ok {
+		if s, ok := schema.Lookup(driverName); ok {
+			if err = s.Validate(pl.driverConf); err != nil {
+				logger.Error.Println("Invalid driver configuration: ", err)
 				return err
 			}
-		} else {
-			logger.Error.Println("processor tag must exist in plugin config")
-			return err
 		}
-		if v, o := p[InChanConfig].(string); o {
-			in, err = pl.pluginCache.GetChan(v, ChanSize)
-			if err != nil {
-				logger.Error.Println(err)
-				return err
-			}
-			pl.channels = append(pl.channels, in)
-			chp := fmt.Sprintf("%T", in)
-			logger.Trace.Println(chp)
-		} else {
-			logger.Error.Println("in tag must exist in plugin config")
-			return errors.New("in tag must exist in plugin config")
+		if err = d.Configure(pl.driverConf); err != nil {
+			logger.Error.Println("Unable to configure driver: ", err)
+			return err
 		}
-		if v, o := p[OutChanConfig]; o {
-			var channels []interface{}
-			switch t := v.(type) {
-			case []interface{}:
-				for _, channel := range t {
-					out, err = pl.pluginCache.GetChan(channel.(string), ChanSize)
-					if err != nil {
-						logger.Error.Println(err)
-						return err
-					}
-					channels = append(channels, out)
-					chp := fmt.Sprintf("%T", out)
-					pl.channels = append(pl.channels, out)
-					logger.Trace.Println(chp)
-				}
-			case string:
-				out, err = pl.pluginCache.GetChan(t, ChanSize)
-				if err != nil {
-					logger.Error.Println(err)
-					return err
-				}
-				channels = append(channels, out)
-				chp := fmt.Sprintf("%T", out)
-				pl.channels = append(pl.channels, out)
-				logger.Trace.Println(chp)
-			}
-			prc.SetOutChan(channels)
+	}
+	for _, p := range conf.Pipeline {
+		st, err := pl.newStage(p)
+		if err != nil {
+			logger.Error.Println(err)
+			return err
 		}
-		pl.processors = append(pl.processors, prc)
+		pl.stages = append(pl.stages, st)
+		pl.processors = append(pl.processors, st.gen.prc)
+	}
+	pl.consumers = make(map[string][]*relay)
+	for i, st := range pl.stages {
+		id := chanID(conf.Pipeline[i][InChanConfig].(string))
+		pl.consumers[id] = append(pl.consumers[id], st.in)
 		pl.wg.Add(1)
-		go pl.process(prc, in)
+		go st.in.run()
+		st.start(pl, st.gen)
 	}
+	pl.conf = conf
 	pl.test()
 	return nil
 }
 
+// SetDriverConfig sets a driver configuration attribute, overriding the pipeline configuration
+// file and environment. It must be called before the pipeline is loaded.
+func (pl *Pipeline) SetDriverConfig(key string, value string) {
+	if pl.driverAttrs == nil {
+		pl.driverAttrs = make(PluginConfig)
+	}
+	pl.driverAttrs[key] = value
+}
+
+// SetStageConfig sets a configuration attribute of every processor stage, overriding the pipeline
+// configuration file. It must be called before the pipeline is loaded, and persists across reloads.
+func (pl *Pipeline) SetStageConfig(key string, value string) {
+	if pl.stageAttrs == nil {
+		pl.stageAttrs = make(PluginConfig)
+	}
+	pl.stageAttrs[key] = value
+}
+
+// setStageAttrs applies the stage attributes set with SetStageConfig to conf.
+func (pl *Pipeline) setStageAttrs(conf *Config) {
+	for k, v := range pl.stageAttrs {
+		addGlobalConfigItem(conf, k, v)
+	}
+}
+
+// Validate checks the pipeline configuration against the configuration schemas declared by plugins.
+func (pl *Pipeline) Validate() error {
+	conf, err := pl.pluginCache.GetConfig()
+	if err != nil {
+		return err
+	}
+	return validateConfig(conf)
+}
+
+// PrintConfig outputs the effective configuration of each pipeline stage.
+func (pl *Pipeline) PrintConfig() {
+	if pl.conf != nil {
+		printConfig(os.Stdout, pl.conf, pl.driverName, pl.driverConf)
+	}
+}
+
+// newStage creates a pipeline stage from a plugin configuration. The processor is initialized before
+// the stage channels are resolved, since processors may register the channel types of the pipeline.
+func (pl *Pipeline) newStage(p PluginConfig) (*stage, error) {
+	prc, err := pl.newProcessor(p)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := p[InChanConfig].(string)
+	if !ok {
+		return nil, errors.New("in tag must exist in plugin config")
+	}
+	in, err := pl.pluginCache.GetChan(v, ChanSize)
+	if err != nil {
+		return nil, err
+	}
+	pl.channels = append(pl.channels, in)
+	logger.Trace.Println(fmt.Sprintf("%T", in))
+	st := &stage{conf: p}
+	var outs []interface{}
+	for _, v := range getOutChans(p) {
+		out, err := pl.pluginCache.GetChan(v, ChanSize)
+		if err != nil {
+			return nil, err
+		}
+		pl.channels = append(pl.channels, out)
+		logger.Trace.Println(fmt.Sprintf("%T", out))
+		outs = append(outs, out)
+		st.outs = append(st.outs, chanID(v))
+	}
+	if st.gen, err = pl.newGeneration(prc, p, outs); err != nil {
+		return nil, err
+	}
+	st.in = newRelay(in, st.gen.in)
+	return st, nil
+}
+
+// newProcessor creates and initializes a processor instance from a plugin configuration.
+func (pl *Pipeline) newProcessor(p PluginConfig) (plugins.SFProcessor, error) {
+	name, ok := p[ProcConfig].(string)
+	if !ok {
+		return nil, errors.New("processor tag must exist in plugin config")
+	}
+	prc, err := pl.pluginCache.GetProcessor(pl.pluginDir, name)
+	if err != nil {
+		return nil, err
+	}
+	logger.Trace.Println(fmt.Sprintf("%T", prc))
+	if err = prc.Init(p); err != nil {
+		return nil, err
+	}
+	return prc, nil
+}
+
+// newGeneration creates a generation of initialized processor prc from its plugin configuration.
+// The processor reads from a private input channel, and writes into channel objects outs,
+// or into private output channels if outs is nil.
+func (pl *Pipeline) newGeneration(prc plugins.SFProcessor, p PluginConfig, outs []interface{}) (g *generation, err error) {
+	g = &generation{prc: prc, done: make(chan struct{})}
+	if v, o := p[InChanConfig].(string); o {
+		if g.in, err = pl.pluginCache.newChan(v, ChanSize); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("in tag must exist in plugin config")
+	}
+	g.outs = outs
+	if g.outs == nil {
+		for _, v := range getOutChans(p) {
+			out, err := pl.pluginCache.newChan(v, ChanSize)
+			if err != nil {
+				return nil, err
+			}
+			g.outs = append(g.outs, out)
+		}
+	}
+	if len(g.outs) > 0 {
+		prc.SetOutChan(g.outs)
+	}
+	return g, nil
+}
+
 // Init initializes the pipeline
 func (pl *Pipeline) Init(path string) error {
 	logger.Info.Println("Starting the processing pipeline")
@@ -189,6 +296,9 @@ func (pl *Pipeline) Init(path string) error {
 func (pl *Pipeline) Shutdown() error {
 	logger.Info.Println("Stopping the processing pipeline")
 	pl.running = false
+	if pl.watcher != nil {
+		pl.watcher.Close()
+	}
 	pl.driver.Cleanup()
 	return nil
 }
@@ -213,9 +323,13 @@ func (pl *Pipeline) Wait() {
 	pl.wg.Wait()
 }
 
-// Proxy function for handling transparent cleanup of resources
+// Proxy function for handling transparent cleanup of resources. The pipeline is done
+// with the processor once it has been cleaned up, so that sinks can complete their output.
 func (pl *Pipeline) process(prc plugins.SFProcessor, in interface{}) {
-	prc.Process(in, pl.wg)
+	defer pl.wg.Done()
+	wg := new(sync.WaitGroup)
+	wg.Add(1)
+	prc.Process(in, wg)
 	prc.Cleanup()
 }
 
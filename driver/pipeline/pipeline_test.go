@@ -0,0 +1,66 @@
+package pipeline
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// slowCleanupProc is a processor whose cleanup takes a while, e.g., a sink closing its files.
+type slowCleanupProc struct {
+	testProc
+	cleaned chan struct{}
+}
+
+func (p *slowCleanupProc) Cleanup() {
+	time.Sleep(50 * time.Millisecond)
+	close(p.cleaned)
+}
+
+func TestWaitIncludesCleanup(t *testing.T) {
+	pl := New(t.TempDir(), t.TempDir(), "")
+	prc := &slowCleanupProc{testProc: testProc{log: &testLog{tags: make(map[*sfgo.SysFlow]string)}}, cleaned: make(chan struct{})}
+	in := &plugins.SFChannel{In: make(chan *sfgo.SysFlow)}
+	pl.wg.Add(1)
+	go pl.process(prc, in)
+	close(in.In)
+	pl.Wait()
+	select {
+	case <-prc.cleaned:
+	default:
+		t.Fatal("expected Wait to return after the processor was cleaned up")
+	}
+}
+
+// earlyDoneProc is a processor signaling completion before returning from Process.
+type earlyDoneProc struct {
+	testProc
+	returned bool
+	mu       sync.Mutex
+}
+
+func (p *earlyDoneProc) Process(ch interface{}, wg *sync.WaitGroup) {
+	wg.Done()
+	time.Sleep(50 * time.Millisecond)
+	p.mu.Lock()
+	p.returned = true
+	p.mu.Unlock()
+}
+
+func (p *earlyDoneProc) Cleanup() {}
+
+func TestWaitIncludesProcess(t *testing.T) {
+	pl := New(t.TempDir(), t.TempDir(), "")
+	prc := &earlyDoneProc{}
+	pl.wg.Add(1)
+	go pl.process(prc, nil)
+	pl.Wait()
+	prc.mu.Lock()
+	defer prc.mu.Unlock()
+	if !prc.returned {
+		t.Fatal("expected Wait to return after the processor returned")
+	}
+}
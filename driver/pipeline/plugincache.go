@@ -70,6 +70,7 @@ func (p *PluginCache) init() {
 	(&exporter.Exporter{}).Register(p)
 	(&sysflow.FileDriver{}).Register(p)
 	(&sysflow.StreamingDriver{}).Register(p)
+	(&sysflow.TCPDriver{}).Register(p)
 }
 
 // TryToLoadPlugin loads dynamic plugins to plugin cache from dir path.
@@ -140,8 +141,10 @@ func (p *PluginCache) GetConfig() (*Config, error) {
 		return nil, errors.New("Pipeline config file is not a file")
 	}
 	dir := filepath.Dir(p.configFile)
+	p.config = new(Config)
 
-	configReader := viper.New()
+	// use a key delimiter other than '.' so that dotted attribute names are not split into nested maps
+	configReader := viper.NewWithOptions(viper.KeyDelimiter("::"))
 	configReader.SetConfigName(strings.TrimSuffix(filepath.Base(p.configFile), filepath.Ext(p.configFile)))
 	configReader.SetConfigType("json")
 	configReader.AddConfigPath(dir)
@@ -207,6 +210,19 @@ func (p *PluginCache) GetChan(ch string, size int) (interface{}, error) {
 	return nil, fmt.Errorf("channel '%s':'%s' not found in plugin cache", fields[0], fields[1])
 }
 
+// newChan creates an unnamed channel instance of the type specified in channel descriptor ch.
+func (p *PluginCache) newChan(ch string, size int) (interface{}, error) {
+	fields := strings.Fields(ch)
+	if len(fields) != 2 {
+		return nil, errors.New("channel must be of the form <identifier> <type>")
+	}
+	if val, ok := p.chanFuncMap[fields[1]]; ok {
+		funct := val.(func(int) interface{})
+		return funct(size), nil
+	}
+	return nil, fmt.Errorf("channel type '%s' not found in plugin cache", fields[1])
+}
+
 // GetProcessor retrieves a cached plugin processor by name.
 func (p *PluginCache) GetProcessor(dir string, name string) (plugins.SFProcessor, error) {
 	var con interface{} = nil
@@ -0,0 +1,168 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline implements a pluggable data processing pipeline infrastructure.
+package pipeline
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+)
+
+// ReloadDelay defines the quiet period after a configuration file change before the pipeline is reloaded.
+const ReloadDelay = 1 * time.Second
+
+// Reload re-reads the pipeline configuration and restarts the stages whose configuration changed.
+// The new processors of all changed stages are initialized and health checked before any stage is
+// replaced, so that a failed reload leaves the running pipeline untouched. The driver and the channels connecting the stages are kept alive. Changes to the pipeline
+// topology (i.e., processors, handlers, and channels of each stage) require a process restart.
+func (pl *Pipeline) Reload() error {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	if pl.conf == nil {
+		return errors.New("pipeline not loaded")
+	}
+	conf, err := pl.pluginCache.GetConfig()
+	if err != nil {
+		return err
+	}
+	if err := validateConfig(conf); err != nil {
+		return err
+	}
+	setManifestInfo(conf)
+	pl.setStageAttrs(conf)
+	if err := checkTopology(pl.conf, conf); err != nil {
+		return fmt.Errorf("%v; restart the processor to apply the new configuration", err)
+	}
+	// build and check the new generations of all changed stages before replacing any of them
+	type change struct {
+		st *stage
+		g  *generation
+		c  PluginConfig
+	}
+	var changes []change
+	abort := func(err error) error {
+		for _, ch := range changes {
+			ch.g.prc.Cleanup()
+		}
+		return err
+	}
+	for i, st := range pl.stages {
+		c := conf.Pipeline[i]
+		if reflect.DeepEqual(st.conf, c) {
+			continue
+		}
+		logger.Info.Printf("Reloading stage %d, %s", i+1, getStageName(c))
+		prc, err := pl.newProcessor(c)
+		if err != nil {
+			return abort(fmt.Errorf("unable to reload stage %d, %s: %v", i+1, getStageName(c), err))
+		}
+		g, err := pl.newGeneration(prc, c, nil)
+		if err != nil {
+			prc.Cleanup()
+			return abort(fmt.Errorf("unable to reload stage %d, %s: %v", i+1, getStageName(c), err))
+		}
+		changes = append(changes, change{st, g, c})
+		if tprc, ok := g.prc.(plugins.SFTestableProcessor); ok {
+			if _, err := tprc.Test(); err != nil {
+				return abort(fmt.Errorf("health checks for stage %d, %s failed: %v", i+1, getStageName(c), err))
+			}
+		}
+	}
+	for _, ch := range changes {
+		ch.st.replace(pl, ch.g)
+		ch.st.conf = ch.c
+	}
+	pl.conf = &Config{}
+	for i, st := range pl.stages {
+		pl.conf.Pipeline = append(pl.conf.Pipeline, st.conf)
+		pl.processors[i] = st.gen.prc
+	}
+	logger.Info.Printf("Reloaded pipeline configuration, %d stage(s) restarted", len(changes))
+	return nil
+}
+
+// Watch reloads the pipeline whenever the pipeline configuration file changes.
+func (pl *Pipeline) Watch() error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// watch the parent directory, since editors and config maps replace files rather than writing them in place
+	if err = watcher.Add(filepath.Dir(pl.config)); err != nil {
+		watcher.Close()
+		return err
+	}
+	pl.watcher = watcher
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case ev, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != filepath.Clean(pl.config) || ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+					continue
+				}
+				logger.Trace.Printf("Pipeline configuration event %s", ev.String())
+				if timer != nil {
+					timer.Stop()
+				}
+				timer = time.AfterFunc(ReloadDelay, func() {
+					if err := pl.Reload(); err != nil {
+						logger.Error.Println("Unable to reload pipeline configuration: ", err)
+					}
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Error.Println("Pipeline configuration watcher error: ", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// checkTopology verifies that the stages of two pipeline configurations are connected in the same way.
+func checkTopology(prev *Config, next *Config) error {
+	if len(prev.Pipeline) != len(next.Pipeline) {
+		return fmt.Errorf("number of pipeline stages changed from %d to %d", len(prev.Pipeline), len(next.Pipeline))
+	}
+	for i := range prev.Pipeline {
+		p, n := prev.Pipeline[i], next.Pipeline[i]
+		for _, k := range []string{ProcConfig, HdlConfig, HdlLibConfig, InChanConfig} {
+			if !reflect.DeepEqual(p[k], n[k]) {
+				return fmt.Errorf("attribute '%s' of stage %d changed", k, i+1)
+			}
+		}
+		if !reflect.DeepEqual(getOutChans(p), getOutChans(n)) {
+			return fmt.Errorf("attribute '%s' of stage %d changed", OutChanConfig, i+1)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,361 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+func TestMain(m *testing.M) {
+	logger.InitLoggers(logger.TRACE)
+	os.Exit(m.Run())
+}
+
+// testLog records the records received by the sink stages and the processors cleaned up.
+type testLog struct {
+	mu      sync.Mutex
+	tags    map[*sfgo.SysFlow]string
+	order   []*sfgo.SysFlow
+	cleaned []string
+}
+
+func (l *testLog) received(tag string, r *sfgo.SysFlow) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tags[r] = tag
+	l.order = append(l.order, r)
+}
+
+func (l *testLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.order)
+}
+
+// testProc forwards records to its output channel, or logs them if it has none.
+type testProc struct {
+	log *testLog
+	tag string
+	out *plugins.SFChannel
+}
+
+func (p *testProc) Register(pc plugins.SFPluginCache) {}
+
+func (p *testProc) Init(conf map[string]interface{}) error {
+	p.tag, _ = conf["tag"].(string)
+	return nil
+}
+
+func (p *testProc) Process(ch interface{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+	for r := range ch.(*plugins.SFChannel).In {
+		if p.out != nil {
+			p.out.In <- r
+		} else {
+			p.log.received(p.tag, r)
+		}
+	}
+	if p.out != nil {
+		close(p.out.In)
+	}
+}
+
+func (p *testProc) GetName() string { return "testproc" }
+
+func (p *testProc) SetOutChan(ch []interface{}) { p.out = ch[0].(*plugins.SFChannel) }
+
+func (p *testProc) Test() (bool, error) {
+	if p.tag == "fail" {
+		return false, errors.New("health check failed")
+	}
+	return true, nil
+}
+
+func (p *testProc) Cleanup() {
+	p.log.mu.Lock()
+	defer p.log.mu.Unlock()
+	p.log.cleaned = append(p.log.cleaned, p.tag)
+}
+
+type testDriver struct{}
+
+func (d *testDriver) Init(pipeline plugins.SFPipeline) error { return nil }
+func (d *testDriver) Run(path string, running *bool) error   { return nil }
+func (d *testDriver) GetName() string                        { return "testdriver" }
+func (d *testDriver) Register(pc plugins.SFPluginCache)      {}
+func (d *testDriver) Cleanup()                               {}
+
+func writeTestConfig(t *testing.T, path string, fwdTag string, sinkTag string) {
+	conf := map[string]interface{}{
+		"pipeline": []map[string]string{
+			{"processor": "testproc", "in": "in sysflowchan", "out": "mid sysflowchan", "tag": fwdTag},
+			{"processor": "testproc", "in": "mid sysflowchan", "tag": sinkTag},
+		},
+	}
+	data, err := json.Marshal(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestPipeline(t *testing.T, log *testLog) *Pipeline {
+	path := filepath.Join(t.TempDir(), "pipeline.json")
+	writeTestConfig(t, path, "f1", "s1")
+	pl := New(t.TempDir(), t.TempDir(), path)
+	pl.pluginCache.AddDriver("testdriver", func() plugins.SFDriver { return &testDriver{} })
+	pl.pluginCache.AddProcessor("testproc", func() plugins.SFProcessor { return &testProc{log: log} })
+	if err := pl.Load("testdriver"); err != nil {
+		t.Fatal(err)
+	}
+	return pl
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for start := time.Now(); !cond(); time.Sleep(time.Millisecond) {
+		if time.Since(start) > 5*time.Second {
+			t.Fatal("timed out")
+		}
+	}
+}
+
+// waitStages waits for the pipeline to complete, and for the processors of all stages to be cleaned up.
+func waitStages(t *testing.T, pl *Pipeline) {
+	pl.Wait()
+	for _, st := range pl.stages {
+		select {
+		case <-st.gen.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for stage cleanup")
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	log := &testLog{tags: make(map[*sfgo.SysFlow]string)}
+	pl := newTestPipeline(t, log)
+	root := pl.GetRootChannel().(*plugins.SFChannel)
+	recs := newTestRecs(200)
+	for _, r := range recs[:100] {
+		root.In <- r
+	}
+	waitFor(t, func() bool { return log.count() == 100 })
+
+	writeTestConfig(t, pl.config, "f2", "s2")
+	if err := pl.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs[100:] {
+		root.In <- r
+	}
+	close(root.In)
+	waitStages(t, pl)
+
+	if len(log.order) != len(recs) {
+		t.Fatalf("expected %d records, got %d", len(recs), len(log.order))
+	}
+	switched := false
+	for i, r := range log.order {
+		if r != recs[i] {
+			t.Fatalf("record %d out of order", i)
+		}
+		switch tag := log.tags[r]; {
+		case i < 100 && tag != "s1":
+			t.Fatalf("record %d processed by %s before reload", i, tag)
+		case tag == "s2":
+			switched = true
+		case switched:
+			t.Fatalf("record %d processed by %s after replacement", i, tag)
+		}
+	}
+	if !switched {
+		t.Fatal("no records processed by the reloaded stage")
+	}
+	if len(log.cleaned) != 4 {
+		t.Fatalf("expected all processors to be cleaned up, got %v", log.cleaned)
+	}
+	if pl.conf.Pipeline[0]["tag"] != "f2" || pl.conf.Pipeline[1]["tag"] != "s2" {
+		t.Fatal("running configuration not updated")
+	}
+}
+
+func TestReloadAtomic(t *testing.T) {
+	log := &testLog{tags: make(map[*sfgo.SysFlow]string)}
+	pl := newTestPipeline(t, log)
+	fwd, sink := pl.stages[0].gen, pl.stages[1].gen
+
+	writeTestConfig(t, pl.config, "f2", "fail")
+	if err := pl.Reload(); err == nil {
+		t.Fatal("expected reload to fail")
+	}
+	if pl.stages[0].gen != fwd || pl.stages[1].gen != sink {
+		t.Fatal("expected running stages to be kept")
+	}
+	if pl.conf.Pipeline[0]["tag"] != "f1" || pl.conf.Pipeline[1]["tag"] != "s1" {
+		t.Fatal("expected running configuration to be kept")
+	}
+	if len(log.cleaned) != 2 || log.cleaned[0] != "f2" || log.cleaned[1] != "fail" {
+		t.Fatalf("expected new processors to be cleaned up, got %v", log.cleaned)
+	}
+
+	root := pl.GetRootChannel().(*plugins.SFChannel)
+	root.In <- sfgo.NewSysFlow()
+	close(root.In)
+	waitStages(t, pl)
+	if log.count() != 1 || log.tags[log.order[0]] != "s1" {
+		t.Fatal("expected record to be processed by the running stages")
+	}
+}
+
+// writeSysflowTestConfig writes the local pipeline configuration shipped with the processor, with a test
+// policy matching process events and a file exporter writing JSON records to out.
+func writeSysflowTestConfig(t *testing.T, path string, out string) {
+	data, err := os.ReadFile("../../resources/pipelines/pipeline.local.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var conf Config
+	if err = json.Unmarshal(data, &conf); err != nil {
+		t.Fatal(err)
+	}
+	policy := filepath.Join(filepath.Dir(path), "policy.yaml")
+	rule := "- rule: Process event\n  desc: test rule\n  condition: sf.type = PE\n  priority: low\n"
+	if err = os.WriteFile(policy, []byte(rule), 0644); err != nil {
+		t.Fatal(err)
+	}
+	conf.Pipeline[1]["policies"] = policy
+	conf.Pipeline[2] = PluginConfig{"processor": "exporter", "in": conf.Pipeline[2]["in"], "export": "file", "format": "json", "file.path": out}
+	if data, err = json.Marshal(conf); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// countLines returns the number of lines of file path.
+func countLines(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+// isolate runs test t in a new test process, and returns true in the calling process. Built-in processors
+// register handler channels with the first plugin cache of a process, as in the processor, which must thus
+// belong to the pipeline under test.
+func isolate(t *testing.T) bool {
+	if os.Getenv("SF_ISOLATED_TEST") == t.Name() {
+		return false
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^"+t.Name()+"$", "-test.count=1")
+	cmd.Env = append(os.Environ(), "SF_ISOLATED_TEST="+t.Name())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	return true
+}
+
+// newSysflowTestPipeline loads the local pipeline configuration, exporting alerts to out, with the file driver.
+func newSysflowTestPipeline(t *testing.T, path string, out string) *Pipeline {
+	writeSysflowTestConfig(t, path, out)
+	pl := New(t.TempDir(), t.TempDir(), path)
+	if err := pl.Load("file"); err != nil {
+		t.Fatal(err)
+	}
+	return pl
+}
+
+func TestLoadSysflowPipeline(t *testing.T) {
+	if isolate(t) {
+		return
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "alerts.json")
+	pl := newSysflowTestPipeline(t, filepath.Join(dir, "pipeline.json"), out)
+	if err := pl.Init("../../resources/traces/tcp.sf"); err != nil {
+		t.Fatal(err)
+	}
+	waitStages(t, pl)
+	if countLines(t, out) == 0 {
+		t.Fatal("expected alerts to be exported")
+	}
+}
+
+func TestReloadSysflowPipeline(t *testing.T) {
+	if isolate(t) {
+		return
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pipeline.json")
+	out1, out2 := filepath.Join(dir, "alerts1.json"), filepath.Join(dir, "alerts2.json")
+	pl := newSysflowTestPipeline(t, path, out1)
+
+	// the exporter stage is replaced before any record is read
+	prev := pl.stages[2].gen
+	writeSysflowTestConfig(t, path, out2)
+	if err := pl.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-prev.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the exporter stage to be replaced")
+	}
+	if err := pl.Init("../../resources/traces/tcp.sf"); err != nil {
+		t.Fatal(err)
+	}
+	waitStages(t, pl)
+	if countLines(t, out2) == 0 {
+		t.Fatal("expected alerts to be exported by the reloaded stage")
+	}
+	if _, err := os.Stat(out1); err == nil && countLines(t, out1) > 0 {
+		t.Fatal("expected no alerts to be exported by the replaced stage")
+	}
+}
+
+func TestCheckTopology(t *testing.T) {
+	base := func() *Config {
+		return &Config{Pipeline: []PluginConfig{
+			{"processor": "sysflowreader", "handler": "flattener", "in": "sysflow sysflowchan", "out": "flat flattenerchan"},
+			{"processor": "policyengine", "in": "flat flattenerchan", "out": []interface{}{"evt eventchan"}, "mode": "alert"},
+			{"processor": "exporter", "in": "evt eventchan", "export": "terminal"},
+		}}
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		ok     bool
+	}{
+		{"unchanged", func(c *Config) {}, true},
+		{"attribute", func(c *Config) { c.Pipeline[1]["mode"] = "enrich"; c.Pipeline[2]["export"] = "file" }, true},
+		{"out string", func(c *Config) { c.Pipeline[1]["out"] = "evt eventchan" }, true},
+		{"stages", func(c *Config) { c.Pipeline = c.Pipeline[:2] }, false},
+		{"processor", func(c *Config) { c.Pipeline[2]["processor"] = "other" }, false},
+		{"handler", func(c *Config) { delete(c.Pipeline[0], "handler") }, false},
+		{"in", func(c *Config) { c.Pipeline[2]["in"] = "alerts eventchan" }, false},
+		{"out", func(c *Config) { c.Pipeline[0]["out"] = "flat2 flattenerchan" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := base()
+			tt.change(next)
+			if err := checkTopology(base(), next); (err == nil) != tt.ok {
+				t.Fatalf("unexpected result: %v", err)
+			}
+		})
+	}
+}
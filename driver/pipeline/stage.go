@@ -0,0 +1,335 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipeline implements a pluggable data processing pipeline infrastructure.
+package pipeline
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/flattener"
+	"github.com/sysflow-telemetry/sf-processor/core/policyengine/engine"
+)
+
+// StatefulProcessor is implemented by processors that can hand over their
+// internal state (e.g., entity caches) to a replacement instance on reload.
+type StatefulProcessor interface {
+	TransferState(to plugins.SFProcessor)
+}
+
+// stage represents a running pipeline stage.
+//
+// Each processor reads from a private input channel, fed by the stage's input relay from the
+// output channel of its upstream neighbour, so that a stage can be stopped by closing the private
+// channel. Processors write their outputs directly: the first generation of a stage writes into the
+// shared pipeline channels, and replacement generations write into private channels that the relays
+// of downstream stages switch to once the previous generation has closed its outputs.
+type stage struct {
+	conf PluginConfig
+	in   *relay
+	outs []string
+	gen  *generation
+}
+
+// generation represents a processor instance running in a stage.
+type generation struct {
+	prc  plugins.SFProcessor
+	in   interface{}
+	outs []interface{}
+	done chan struct{}
+}
+
+// start runs a processor generation in the stage.
+func (st *stage) start(pl *Pipeline, g *generation) {
+	go func() {
+		pl.process(g.prc, g.in)
+		close(g.done)
+	}()
+}
+
+// replace swaps the running generation of the stage with g. The new processor
+// starts once the previous one drained its input and shut down.
+func (st *stage) replace(pl *Pipeline, g *generation) {
+	prev := st.gen
+	st.gen = g
+	pl.wg.Add(1)
+	for i, name := range st.outs {
+		for _, r := range pl.consumers[name] {
+			r.addSource(g.outs[i])
+		}
+	}
+	st.in.swap(g.in)
+	go func() {
+		<-prev.done
+		if sp, ok := prev.prc.(StatefulProcessor); ok {
+			sp.TransferState(g.prc)
+		}
+		logger.Trace.Printf("Replaced processor %s", prev.prc.GetName())
+		st.start(pl, g)
+	}()
+}
+
+// relay forwards records from the output channel of an upstream stage into the private input
+// channel of the current processor instance of a stage. Sources and destinations are queued by
+// stage replacements and only touched by the relay goroutine, so forwarding doesn't hold locks.
+type relay struct {
+	ops    chanOps
+	src    interface{}
+	dst    interface{}
+	signal chan struct{}
+	mu     sync.Mutex
+	srcs   []interface{}
+	dsts   []interface{}
+	closed bool
+}
+
+// newRelay creates a new relay between channel objects src and dst.
+func newRelay(src interface{}, dst interface{}) *relay {
+	return &relay{ops: opsOf(src), src: src, dst: dst, signal: make(chan struct{}, 1)}
+}
+
+// run forwards records until the source channel is closed and no replacement source is queued.
+func (r *relay) run() {
+	var v interface{}
+	var pending bool
+	for {
+		if !pending {
+			var s recvStatus
+			if v, s = r.ops.recv(r.src, r.signal); s == recvSignaled {
+				r.nextDst()
+				continue
+			} else if s == recvClosed {
+				if !r.nextSrc() {
+					return
+				}
+				continue
+			}
+			pending = true
+		}
+		if r.ops.send(r.dst, v, r.signal) {
+			pending = false
+		} else {
+			r.nextDst()
+		}
+	}
+}
+
+// swap queues channel object dst as the new destination of the relay. The current destination
+// is closed once the relay switches over, so that the processor reading it drains and exits.
+func (r *relay) swap(dst interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		r.ops.close(dst)
+		return
+	}
+	r.dsts = append(r.dsts, dst)
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+// addSource queues channel object src to be read once the current source is closed.
+func (r *relay) addSource(src interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.srcs = append(r.srcs, src)
+}
+
+// nextDst closes the current destination and switches to the last queued destination.
+func (r *relay) nextDst() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range r.dsts {
+		r.ops.close(r.dst)
+		r.dst = d
+	}
+	r.dsts = nil
+}
+
+// nextSrc switches to the next queued source. If there is none, it closes the destinations
+// and returns false.
+func (r *relay) nextSrc() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.srcs) > 0 {
+		r.src = r.srcs[0]
+		r.srcs = r.srcs[1:]
+		return true
+	}
+	r.ops.close(r.dst)
+	for _, d := range r.dsts {
+		r.ops.close(d)
+	}
+	r.dsts = nil
+	r.closed = true
+	return false
+}
+
+// recvStatus defines the outcome of a relay receive.
+type recvStatus int
+
+// recvStatus values.
+const (
+	recvOK recvStatus = iota
+	recvClosed
+	recvSignaled
+)
+
+// chanOps implements the channel operations of a relay for a type of channel object.
+// Receives and sends are interrupted when the signal channel fires.
+type chanOps struct {
+	recv  func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus)
+	send  func(ch interface{}, v interface{}, signal <-chan struct{}) bool
+	close func(ch interface{})
+}
+
+// opsOf returns the channel operations for channel object ch. Channel types of the
+// built-in plugins are handled natively; other types are handled through reflection.
+func opsOf(ch interface{}) chanOps {
+	switch ch.(type) {
+	case *plugins.SFChannel:
+		return chanOps{
+			recv: func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus) {
+				select {
+				case v, ok := <-ch.(*plugins.SFChannel).In:
+					return v, status(ok)
+				case <-signal:
+					return nil, recvSignaled
+				}
+			},
+			send: func(ch interface{}, v interface{}, signal <-chan struct{}) bool {
+				select {
+				case ch.(*plugins.SFChannel).In <- v.(*sfgo.SysFlow):
+					return true
+				case <-signal:
+					return false
+				}
+			},
+			close: func(ch interface{}) { close(ch.(*plugins.SFChannel).In) },
+		}
+	case *plugins.CtxSFChannel:
+		return chanOps{
+			recv: func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus) {
+				select {
+				case v, ok := <-ch.(*plugins.CtxSFChannel).In:
+					return v, status(ok)
+				case <-signal:
+					return nil, recvSignaled
+				}
+			},
+			send: func(ch interface{}, v interface{}, signal <-chan struct{}) bool {
+				select {
+				case ch.(*plugins.CtxSFChannel).In <- v.(*plugins.CtxSysFlow):
+					return true
+				case <-signal:
+					return false
+				}
+			},
+			close: func(ch interface{}) { close(ch.(*plugins.CtxSFChannel).In) },
+		}
+	case *flattener.FlatChannel:
+		return chanOps{
+			recv: func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus) {
+				select {
+				case v, ok := <-ch.(*flattener.FlatChannel).In:
+					return v, status(ok)
+				case <-signal:
+					return nil, recvSignaled
+				}
+			},
+			send: func(ch interface{}, v interface{}, signal <-chan struct{}) bool {
+				select {
+				case ch.(*flattener.FlatChannel).In <- v.(*sfgo.FlatRecord):
+					return true
+				case <-signal:
+					return false
+				}
+			},
+			close: func(ch interface{}) { close(ch.(*flattener.FlatChannel).In) },
+		}
+	case *engine.RecordChannel:
+		return chanOps{
+			recv: func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus) {
+				select {
+				case v, ok := <-ch.(*engine.RecordChannel).In:
+					return v, status(ok)
+				case <-signal:
+					return nil, recvSignaled
+				}
+			},
+			send: func(ch interface{}, v interface{}, signal <-chan struct{}) bool {
+				select {
+				case ch.(*engine.RecordChannel).In <- v.(*engine.Record):
+					return true
+				case <-signal:
+					return false
+				}
+			},
+			close: func(ch interface{}) { close(ch.(*engine.RecordChannel).In) },
+		}
+	}
+	return chanOps{
+		recv: func(ch interface{}, signal <-chan struct{}) (interface{}, recvStatus) {
+			i, v, ok := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectRecv, Chan: chanOf(ch)},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(signal)},
+			})
+			if i == 1 {
+				return nil, recvSignaled
+			}
+			return v, status(ok)
+		},
+		send: func(ch interface{}, v interface{}, signal <-chan struct{}) bool {
+			i, _, _ := reflect.Select([]reflect.SelectCase{
+				{Dir: reflect.SelectSend, Chan: chanOf(ch), Send: v.(reflect.Value)},
+				{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(signal)},
+			})
+			return i == 0
+		},
+		close: func(ch interface{}) { chanOf(ch).Close() },
+	}
+}
+
+// status returns the receive status for the ok flag of a channel receive.
+func status(ok bool) recvStatus {
+	if ok {
+		return recvOK
+	}
+	return recvClosed
+}
+
+// chanOf returns the channel held by the In attribute of a channel object.
+func chanOf(ch interface{}) reflect.Value {
+	return reflect.ValueOf(ch).Elem().FieldByName("In")
+}
+
+// chanID returns the identifier of channel descriptor ch.
+func chanID(ch string) string {
+	if fields := strings.Fields(ch); len(fields) > 0 {
+		return fields[0]
+	}
+	return ch
+}
@@ -0,0 +1,158 @@
+package pipeline
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+func newTestChan(size int) *plugins.SFChannel {
+	return &plugins.SFChannel{In: make(chan *sfgo.SysFlow, size)}
+}
+
+func newTestRecs(n int) []*sfgo.SysFlow {
+	recs := make([]*sfgo.SysFlow, n)
+	for i := range recs {
+		recs[i] = sfgo.NewSysFlow()
+	}
+	return recs
+}
+
+func drain(t *testing.T, ch *plugins.SFChannel) []*sfgo.SysFlow {
+	var recs []*sfgo.SysFlow
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case r, ok := <-ch.In:
+			if !ok {
+				return recs
+			}
+			recs = append(recs, r)
+		case <-timeout:
+			t.Fatalf("channel not closed, %d records received", len(recs))
+		}
+	}
+}
+
+func TestRelaySwapDrainsDestination(t *testing.T) {
+	src, dst1, dst2 := newTestChan(10), newTestChan(10), newTestChan(10)
+	recs := newTestRecs(6)
+	r := newRelay(src, dst1)
+	done := make(chan struct{})
+	go func() {
+		r.run()
+		close(done)
+	}()
+	for _, rec := range recs[:3] {
+		src.In <- rec
+	}
+	for i := 0; i < 3; i++ {
+		if got := <-dst1.In; got != recs[i] {
+			t.Fatalf("record %d not forwarded in order", i)
+		}
+	}
+	r.swap(dst2)
+	if got := drain(t, dst1); len(got) != 0 {
+		t.Fatalf("expected replaced destination to be closed, got %d records", len(got))
+	}
+	for _, rec := range recs[3:] {
+		src.In <- rec
+	}
+	close(src.In)
+	got := drain(t, dst2)
+	if len(got) != 3 {
+		t.Fatalf("expected 3 records in new destination, got %d", len(got))
+	}
+	for i, rec := range got {
+		if rec != recs[i+3] {
+			t.Fatalf("record %d not forwarded in order", i+3)
+		}
+	}
+	<-done
+}
+
+func TestRelaySwapBlockedSend(t *testing.T) {
+	src, dst1, dst2 := newTestChan(10), newTestChan(1), newTestChan(10)
+	recs := newTestRecs(3)
+	r := newRelay(src, dst1)
+	go r.run()
+	for _, rec := range recs {
+		src.In <- rec
+	}
+	// wait until the relay blocks on the full destination
+	for len(src.In) > 1 || len(dst1.In) < 1 {
+		time.Sleep(time.Millisecond)
+	}
+	r.swap(dst2)
+	got := drain(t, dst1)
+	if len(got) != 1 || got[0] != recs[0] {
+		t.Fatalf("expected buffered record to stay in replaced destination, got %d records", len(got))
+	}
+	close(src.In)
+	got = drain(t, dst2)
+	if len(got) != 2 || got[0] != recs[1] || got[1] != recs[2] {
+		t.Fatalf("expected pending records in new destination, got %d records", len(got))
+	}
+}
+
+func TestRelaySourceSwitch(t *testing.T) {
+	src1, src2, dst := newTestChan(10), newTestChan(10), newTestChan(10)
+	recs := newTestRecs(4)
+	r := newRelay(src1, dst)
+	go r.run()
+	r.addSource(src2)
+	src2.In <- recs[2]
+	src2.In <- recs[3]
+	src1.In <- recs[0]
+	src1.In <- recs[1]
+	close(src1.In)
+	close(src2.In)
+	got := drain(t, dst)
+	if len(got) != 4 {
+		t.Fatalf("expected 4 records, got %d", len(got))
+	}
+	for i, rec := range got {
+		if rec != recs[i] {
+			t.Fatalf("record %d not forwarded in order", i)
+		}
+	}
+}
+
+func TestRelaySwapAfterClose(t *testing.T) {
+	src, dst1, dst2 := newTestChan(1), newTestChan(1), newTestChan(1)
+	r := newRelay(src, dst1)
+	close(src.In)
+	r.run()
+	r.swap(dst2)
+	if got := drain(t, dst2); len(got) != 0 {
+		t.Fatalf("expected new destination to be closed, got %d records", len(got))
+	}
+}
+
+type testChan struct {
+	In chan int
+}
+
+func TestRelayReflect(t *testing.T) {
+	src, dst1, dst2 := &testChan{In: make(chan int, 10)}, &testChan{In: make(chan int, 10)}, &testChan{In: make(chan int, 10)}
+	r := newRelay(src, dst1)
+	go r.run()
+	src.In <- 1
+	if v := <-dst1.In; v != 1 {
+		t.Fatalf("expected 1, got %d", v)
+	}
+	r.swap(dst2)
+	if _, ok := <-dst1.In; ok {
+		t.Fatal("expected replaced destination to be closed")
+	}
+	src.In <- 2
+	close(src.In)
+	if v := <-dst2.In; v != 2 {
+		t.Fatalf("expected 2, got %d", v)
+	}
+	if _, ok := <-dst2.In; ok {
+		t.Fatal("expected destination to be closed")
+	}
+}
@@ -0,0 +1,88 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"net"
+	"sync"
+)
+
+// connSet tracks the open connections of a streaming driver.
+type connSet struct {
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	wg       sync.WaitGroup
+}
+
+// newConnSet creates a new connection set.
+func newConnSet() *connSet {
+	return &connSet{conns: make(map[net.Conn]struct{})}
+}
+
+// listen sets the listener accepting connections into the set.
+func (c *connSet) listen(l net.Listener) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.listener = l
+}
+
+// add adds an accepted connection to the set.
+func (c *connSet) add(conn net.Conn) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conns[conn] = struct{}{}
+	c.wg.Add(1)
+}
+
+// remove closes a connection and removes it from the set.
+func (c *connSet) remove(conn net.Conn) {
+	conn.Close()
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+	c.wg.Done()
+}
+
+// len returns the number of open connections.
+func (c *connSet) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.conns)
+}
+
+// close closes the listener and all open connections.
+func (c *connSet) close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.listener != nil {
+		c.listener.Close()
+	}
+	for conn := range c.conns {
+		conn.Close()
+	}
+}
+
+// wait closes the listener and all open connections, and waits for their handlers to return.
+func (c *connSet) wait() {
+	c.close()
+	c.wg.Wait()
+}
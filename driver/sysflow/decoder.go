@@ -0,0 +1,117 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"bytes"
+	"sync"
+
+	"github.com/actgardner/gogen-avro/v7/compiler"
+	"github.com/actgardner/gogen-avro/v7/vm"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/processor"
+)
+
+// BuffSize represents the buffer size of the stream
+const BuffSize = 16384
+
+// compileSchema compiles the SysFlow schema into a deserialization program.
+// The program is stateless and can be shared among concurrent decoders.
+func compileSchema() (*vm.Program, error) {
+	sFlow := sfgo.NewSysFlow()
+	return compiler.CompileSchemaBytes([]byte(sFlow.Schema()), []byte(sFlow.Schema()))
+}
+
+// decoder deserializes Avro binary-encoded SysFlow records.
+type decoder struct {
+	deser  *vm.Program
+	reader *bytes.Reader
+}
+
+// newDecoder creates a new decoder from a compiled schema program.
+func newDecoder(deser *vm.Program) *decoder {
+	return &decoder{deser: deser, reader: bytes.NewReader(nil)}
+}
+
+// decode deserializes a SysFlow record from buf.
+func (d *decoder) decode(buf []byte) (*sfgo.SysFlow, error) {
+	sFlow := sfgo.NewSysFlow()
+	d.reader.Reset(buf)
+	err := vm.Eval(d.reader, d.deser, sFlow)
+	return sFlow, err
+}
+
+// stream represents the state of an input stream multiplexed into the pipeline.
+type stream struct {
+	id  uint64
+	hdr *sfgo.SysFlow
+}
+
+// mux serializes records from concurrent input streams into the pipeline's root channel.
+// Whenever it forwards records of a different stream than the previous record, it resends
+// the last header record of that stream, so that readers can switch entity contexts.
+// Headers are registered with the ID of their stream, so that streams from collectors
+// sharing an exporter ID and IP keep separate contexts.
+type mux struct {
+	mu      sync.Mutex
+	records chan *sfgo.SysFlow
+	last    *stream
+	nextID  uint64
+}
+
+// newMux creates a new stream multiplexer writing into records.
+func newMux(records chan *sfgo.SysFlow) *mux {
+	return &mux{records: records}
+}
+
+// open creates a new input stream.
+func (m *mux) open() *stream {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nextID++
+	return &stream{id: m.nextID}
+}
+
+// send forwards a record of stream s.
+func (m *mux) send(s *stream, sf *sfgo.SysFlow) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if sf.Rec != nil && sf.Rec.UnionType == sfgo.SF_HEADER {
+		s.hdr = sf
+		processor.SetStream(sf.Rec.SFHeader, s.id)
+	} else if m.last != s && s.hdr != nil {
+		m.records <- s.hdr
+	}
+	m.last = s
+	m.records <- sf
+}
+
+// close marks the end of stream s, so that readers release its entity context.
+func (m *mux) close(s *stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s.hdr != nil {
+		m.records <- processor.EndOfStream(s.id)
+	}
+	if m.last == s {
+		m.last = nil
+	}
+}
@@ -21,21 +21,150 @@
 package sysflow
 
 import (
-	"bufio"
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/linkedin/goavro"
 	"github.com/sysflow-telemetry/sf-apis/go/converter"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
 )
 
 const (
 	fileDriverName = "file"
 )
 
+// Configuration keys.
+const (
+	FollowConfigKey      string = "follow"
+	FollowIdleConfigKey  string = "follow.idle"
+	CheckpointConfigKey  string = "checkpoint"
+	S3EndpointConfigKey  string = "s3.endpoint"
+	S3RegionConfigKey    string = "s3.region"
+	S3AccessKeyConfigKey string = "s3.accesskey"
+	S3SecretKeyConfigKey string = "s3.secretkey"
+	S3SecureConfigKey    string = "s3.secure"
+	SelectStartConfigKey string = "select.start"
+	SelectEndConfigKey   string = "select.end"
+	SelectTypesConfigKey string = "select.types"
+	SelectContConfigKey  string = "select.containers"
+	ReplaySpeedConfigKey string = "replay.speed"
+)
+
+// FileConfigSchema declares the configuration keys accepted by the file driver.
+var FileConfigSchema = schema.New(fileDriverName, []schema.Key{
+	{Name: FollowConfigKey, Type: schema.Bool, Default: "false"},
+	{Name: FollowIdleConfigKey, Type: schema.Duration, Default: "0s"},
+	{Name: CheckpointConfigKey, Type: schema.String},
+	{Name: S3EndpointConfigKey, Type: schema.String, Default: "s3.amazonaws.com"},
+	{Name: S3RegionConfigKey, Type: schema.String},
+	{Name: S3AccessKeyConfigKey, Type: schema.String},
+	{Name: S3SecretKeyConfigKey, Type: schema.String, Secret: true},
+	{Name: S3SecureConfigKey, Type: schema.Bool, Default: "true"},
+	{Name: SelectStartConfigKey, Type: schema.String},
+	{Name: SelectEndConfigKey, Type: schema.String},
+	{Name: SelectTypesConfigKey, Type: schema.List},
+	{Name: SelectContConfigKey, Type: schema.List},
+	{Name: ReplaySpeedConfigKey, Type: schema.Float, Default: "0"},
+})
+
+func init() {
+	schema.Register(FileConfigSchema)
+}
+
+// FileConfig defines a configuration object for the file driver.
+type FileConfig struct {
+	Follow      bool
+	FollowIdle  time.Duration
+	Checkpoint  string
+	S3Endpoint  string
+	S3Region    string
+	S3AccessKey string
+	S3SecretKey string
+	S3Secure    bool
+	// record selection
+	SelectStart      int64
+	SelectEnd        int64
+	SelectTypes      []sfgo.SFObjectType
+	SelectContainers []string
+	// paced replay
+	ReplaySpeed float64
+}
+
+// CreateFileConfig creates a new config object from config dictionary.
+func CreateFileConfig(conf map[string]interface{}) (c FileConfig, err error) {
+	// default values
+	c = FileConfig{S3Endpoint: "s3.amazonaws.com", S3Secure: true}
+
+	// parse config map
+	if v, ok := conf[FollowConfigKey].(string); ok {
+		if c.Follow, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FollowConfigKey, err)
+		}
+	}
+	if v, ok := conf[FollowIdleConfigKey].(string); ok {
+		if c.FollowIdle, err = time.ParseDuration(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, FollowIdleConfigKey, err)
+		}
+	}
+	if v, ok := conf[CheckpointConfigKey].(string); ok {
+		c.Checkpoint = v
+	}
+	if v, ok := conf[S3EndpointConfigKey].(string); ok {
+		c.S3Endpoint = v
+	}
+	if v, ok := conf[S3RegionConfigKey].(string); ok {
+		c.S3Region = v
+	}
+	if v, ok := conf[S3AccessKeyConfigKey].(string); ok {
+		c.S3AccessKey = v
+	}
+	if v, ok := conf[S3SecretKeyConfigKey].(string); ok {
+		c.S3SecretKey = v
+	}
+	if v, ok := conf[S3SecureConfigKey].(string); ok {
+		if c.S3Secure, err = strconv.ParseBool(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, S3SecureConfigKey, err)
+		}
+	}
+	if v, ok := conf[SelectStartConfigKey].(string); ok {
+		if c.SelectStart, err = parseTimestamp(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SelectStartConfigKey, err)
+		}
+	}
+	if v, ok := conf[SelectEndConfigKey].(string); ok {
+		if c.SelectEnd, err = parseTimestamp(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SelectEndConfigKey, err)
+		}
+	}
+	if v, ok := conf[SelectTypesConfigKey].(string); ok {
+		if c.SelectTypes, err = parseRecordTypes(v); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, SelectTypesConfigKey, err)
+		}
+	}
+	if v, ok := conf[SelectContConfigKey].(string); ok {
+		c.SelectContainers = parseList(v)
+	}
+	if v, ok := conf[ReplaySpeedConfigKey].(string); ok {
+		if c.ReplaySpeed, err = strconv.ParseFloat(v, 64); err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, ReplaySpeedConfigKey, err)
+		}
+	}
+	if c.SelectEnd != 0 && c.SelectEnd <= c.SelectStart {
+		return c, fmt.Errorf("'%s' must be later than '%s'", SelectEndConfigKey, SelectStartConfigKey)
+	}
+	return
+}
+
 func getFiles(filename string) ([]string, error) {
 	var fls []string
 	if fi, err := os.Stat(filename); os.IsNotExist(err) {
@@ -66,12 +195,18 @@ func getFiles(filename string) ([]string, error) {
 // FileDriver represents reading a sysflow file from source
 type FileDriver struct {
 	pipeline plugins.SFPipeline
-	file     *os.File
+	config   FileConfig
+	file     io.ReadCloser
+	s3       *s3Source
+	sel      *selector
+	pace     *pacer
+	stop     chan struct{}
+	once     sync.Once
 }
 
 // NewFileDriver creates a new file driver object
 func NewFileDriver() plugins.SFDriver {
-	return &FileDriver{}
+	return &FileDriver{stop: make(chan struct{})}
 }
 
 // GetName returns the driver name.
@@ -84,6 +219,12 @@ func (s *FileDriver) Register(pc plugins.SFPluginCache) {
 	pc.AddDriver(fileDriverName, NewFileDriver)
 }
 
+// Configure sets the driver configuration.
+func (s *FileDriver) Configure(conf map[string]interface{}) (err error) {
+	s.config, err = CreateFileConfig(conf)
+	return
+}
+
 // Init initializes the file driver with the pipeline
 func (s *FileDriver) Init(pipeline plugins.SFPipeline) error {
 	s.pipeline = pipeline
@@ -98,51 +239,128 @@ func (s *FileDriver) Run(path string, running *bool) error {
 
 	logger.Trace.Println("Loading file: ", path)
 
-	sfobjcvter := converter.NewSFObjectConverter()
+	var err error
+	s.sel = newSelector(s.config)
+	s.pace = newPacer(s.config.ReplaySpeed, s.stop)
+	if isS3Path(path) {
+		if s.config.Follow {
+			err = errors.New("follow mode is not supported for object store paths")
+			logger.Error.Println("Files error: ", err)
+			return err
+		}
+		if s.s3, err = newS3Source(path, s.config); err != nil {
+			logger.Error.Println("Object store error: ", err)
+			return err
+		}
+	}
+	if s.config.Follow {
+		err = s.follow(path, records, running)
+	} else {
+		err = s.readFiles(path, records, running)
+	}
+	if err != nil {
+		return err
+	}
+	logger.Trace.Println("Closing main channel")
+	close(records)
+	s.pipeline.Wait()
+	return nil
+}
 
-	files, err := getFiles(path)
+// readFiles reads the trace files in path once.
+func (s *FileDriver) readFiles(path string, records chan *sfgo.SysFlow, running *bool) error {
+	var files []string
+	var err error
+	if s.s3 != nil {
+		files, err = s.s3.list(context.Background())
+	} else {
+		files, err = getFiles(path)
+	}
 	if err != nil {
 		logger.Error.Println("Files error: ", err)
 		return err
 	}
 	for _, fn := range files {
-		logger.Trace.Println("Loading file: " + fn)
-		s.file, err = os.Open(fn)
-		if err != nil {
-			logger.Error.Println("File open error: ", err)
+		if _, err := s.readFile(fn, 0, records, running, nil); errors.Is(err, errReadRecord) {
+			logger.Error.Printf("Skipping rest of trace file %s: %v", fn, err)
+		} else if err != nil {
 			return err
 		}
-		reader := bufio.NewReader(s.file)
-		sreader, err := goavro.NewOCFReader(reader)
+		if !*running {
+			break
+		}
+	}
+	return nil
+}
+
+// errReadRecord is returned when a record of a trace file cannot be read, e.g., if the file is truncated.
+var errReadRecord = errors.New("unable to read record")
+
+// readFile reads the records of trace file fn into records, skipping the first skip records.
+// Function progress, if set, is called with the number of records read from the file so far,
+// once the last of them has been handed to the pipeline.
+func (s *FileDriver) readFile(fn string, skip int64, records chan *sfgo.SysFlow, running *bool, progress func(n int64)) (n int64, err error) {
+	logger.Trace.Println("Loading file: " + fn)
+	s.file, err = s.openTrace(fn)
+	if err != nil {
+		logger.Error.Println("File open error: ", err)
+		return
+	}
+	defer s.file.Close()
+	sreader, err := goavro.NewOCFReader(s.file)
+	if err != nil {
+		logger.Error.Println("Reader error: ", err)
+		return
+	}
+	sfobjcvter := converter.NewSFObjectConverter()
+	for sreader.Scan() {
+		if !*running {
+			break
+		}
+		datum, err := sreader.Read()
 		if err != nil {
-			logger.Error.Println("Reader error: ", err)
-			return err
+			logger.Error.Println("Datum reading error: ", err)
+			return n, fmt.Errorf("%w %d: %v", errReadRecord, n+1, err)
 		}
-		for sreader.Scan() {
-			if !*running {
-				break
+		n++
+		sf := sfobjcvter.ConvertToSysFlow(datum)
+		if n <= skip {
+			// replay entities of processed records, so that the reader rebuilds its entity tables
+			if isEntity(sf) && s.sel.accept(sf) {
+				records <- sf
 			}
-			datum, err := sreader.Read()
-			if err != nil {
-				logger.Error.Println("Datum reading error: ", err)
+			continue
+		}
+		if s.sel.accept(sf) {
+			if !s.pace.wait(sf) {
 				break
 			}
-			records <- sfobjcvter.ConvertToSysFlow(datum)
+			records <- sf
 		}
-		s.file.Close()
-		if !*running {
-			break
+		if progress != nil {
+			progress(n)
 		}
 	}
-	logger.Trace.Println("Closing main channel")
-	close(records)
-	s.pipeline.Wait()
-	return nil
+	if err = sreader.Err(); err != nil {
+		logger.Error.Println("Reader error: ", err)
+		return n, fmt.Errorf("%w %d: %v", errReadRecord, n+1, err)
+	}
+	return n, nil
+}
+
+// isEntity returns true if sf is a header or entity record.
+func isEntity(sf *sfgo.SysFlow) bool {
+	switch sf.Rec.UnionType {
+	case sfgo.SF_HEADER, sfgo.SF_CONT, sfgo.SF_POD, sfgo.SF_PROCESS, sfgo.SF_FILE:
+		return true
+	}
+	return false
 }
 
 // Cleanup tears down the driver resources.
 func (s *FileDriver) Cleanup() {
 	logger.Trace.Println("Exiting ", fileDriverName)
+	s.once.Do(func() { close(s.stop) })
 	if s.file != nil {
 		s.file.Close()
 	}
@@ -0,0 +1,286 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+const (
+	// CheckpointInterval is the minimum interval between checkpoint writes while a trace file is being read.
+	CheckpointInterval = 1 * time.Second
+	// ReadRetryInterval is the initial interval between attempts to read a trace file that failed to be read.
+	ReadRetryInterval = 1 * time.Second
+	// MaxReadAttempts is the number of attempts to read a trace file before it is skipped.
+	MaxReadAttempts = 5
+)
+
+// traceFile describes a trace file in a followed directory.
+type traceFile struct {
+	name    string
+	ts      int64
+	modTime time.Time
+}
+
+// before returns true if trace file t precedes the trace file named name with timestamp ts.
+func (t traceFile) before(name string, ts int64) bool {
+	if t.ts != ts {
+		return t.ts < ts
+	}
+	return t.name < name
+}
+
+// listTraces returns the trace files in dir, sorted in timestamp order.
+func listTraces(dir string) ([]traceFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []traceFile
+	for _, e := range entries {
+		if e.IsDir() || !isTraceFile(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// file rotated away since the directory was read
+			continue
+		}
+		files = append(files, traceFile{name: e.Name(), ts: getTraceTime(e.Name(), info.ModTime()), modTime: info.ModTime()})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].before(files[j].name, files[j].ts) })
+	return files, nil
+}
+
+// getTraceTime returns the timestamp of a trace file, parsed from its name (e.g., mon.1531776712.sf.gz),
+// or its modification time if the name holds no timestamp.
+func getTraceTime(name string, modTime time.Time) int64 {
+	parts := strings.Split(trimTraceExt(name), ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if ts, err := strconv.ParseInt(parts[i], 10, 64); err == nil {
+			return ts
+		}
+	}
+	return modTime.Unix()
+}
+
+// checkpoint records the progress of the file driver in a followed directory. The offset counts
+// the records handed to the pipeline; records still buffered in the pipeline when the processor
+// crashes are not replayed on restart.
+type checkpoint struct {
+	File     string `json:"file"`
+	Time     int64  `json:"time"`
+	Offset   int64  `json:"offset"`
+	Done     bool   `json:"done"`
+	path     string
+	saved    time.Time
+	failures int
+}
+
+// loadCheckpoint reads a checkpoint from state file path. An empty checkpoint is returned if
+// the state file does not exist. If path is empty, the checkpoint is kept in memory only.
+func loadCheckpoint(path string) (*checkpoint, error) {
+	cp := &checkpoint{path: path}
+	if path == "" {
+		return cp, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, cp); err != nil {
+		return nil, err
+	}
+	logger.Info.Printf("Resuming from checkpoint %s, record %d", cp.File, cp.Offset)
+	return cp, nil
+}
+
+// skip returns the number of records of trace file t that have already been processed,
+// or -1 if the file has been fully processed.
+func (cp *checkpoint) skip(t traceFile) int64 {
+	if cp.File == "" || !t.before(cp.File, cp.Time) && t.name != cp.File {
+		return 0
+	}
+	if t.name != cp.File || cp.Done {
+		return -1
+	}
+	return cp.Offset
+}
+
+// update sets the progress of the driver to record offset of trace file t. The checkpoint is
+// saved when the file is done, or if the last save is older than CheckpointInterval.
+func (cp *checkpoint) update(t traceFile, offset int64, done bool) {
+	cp.File, cp.Time, cp.Offset, cp.Done = t.name, t.ts, offset, done
+	if cp.path == "" || (!done && time.Since(cp.saved) < CheckpointInterval) {
+		return
+	}
+	if err := cp.save(); err != nil {
+		logger.Error.Println("Unable to save checkpoint: ", err)
+	}
+}
+
+// save writes the checkpoint to its state file.
+func (cp *checkpoint) save() error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	// write to a temporary file first, so that the state file is replaced atomically
+	tmp := cp.path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err = os.Rename(tmp, cp.path); err != nil {
+		return err
+	}
+	cp.saved = time.Now()
+	return nil
+}
+
+// follow watches directory dir, and reads trace files in timestamp order as they are finalized.
+// A trace file is finalized once a newer trace file appears in the directory, or, if an idle
+// period is configured, once it has not been modified for that period.
+func (s *FileDriver) follow(dir string, records chan *sfgo.SysFlow, running *bool) error {
+	if fi, err := os.Stat(dir); err != nil {
+		logger.Error.Println("Files error: ", err)
+		return err
+	} else if !fi.IsDir() {
+		err = errors.New("follow mode requires a directory: " + dir)
+		logger.Error.Println("Files error: ", err)
+		return err
+	}
+	cp, err := loadCheckpoint(s.config.Checkpoint)
+	if err != nil {
+		logger.Error.Println("Unable to load checkpoint: ", err)
+		return err
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		logger.Error.Println("Watcher error: ", err)
+		return err
+	}
+	defer watcher.Close()
+	if err = watcher.Add(dir); err != nil {
+		logger.Error.Println("Watcher error: ", err)
+		return err
+	}
+	logger.Info.Printf("Following trace files in %s", dir)
+
+	for *running {
+		wait, err := s.readTraces(dir, cp, records, running)
+		if err != nil {
+			logger.Error.Println("Files error: ", err)
+			return err
+		}
+		if !*running {
+			break
+		}
+		s.waitForTraces(watcher, wait)
+	}
+	return nil
+}
+
+// readTraces reads the finalized trace files of dir that have not been processed according to
+// checkpoint cp. It returns the time to wait before the trace files should be read again, if a
+// trace file is about to be finalized or failed to be read; zero means waiting for new files.
+// A trace file is only marked as done once it has been read to its end.
+func (s *FileDriver) readTraces(dir string, cp *checkpoint, records chan *sfgo.SysFlow, running *bool) (time.Duration, error) {
+	files, err := listTraces(dir)
+	if err != nil {
+		return 0, err
+	}
+	for i, t := range files {
+		skip := cp.skip(t)
+		if skip < 0 {
+			continue
+		}
+		if i == len(files)-1 {
+			if s.config.FollowIdle <= 0 {
+				return 0, nil
+			}
+			if idle := time.Since(t.modTime); idle < s.config.FollowIdle {
+				return s.config.FollowIdle - idle, nil
+			}
+		}
+		n, err := s.readFile(filepath.Join(dir, t.name), skip, records, running, func(n int64) { cp.update(t, n, false) })
+		if !*running {
+			cp.update(t, n, false)
+			if cp.path != "" {
+				if err := cp.save(); err != nil {
+					logger.Error.Println("Unable to save checkpoint: ", err)
+				}
+			}
+			return 0, nil
+		}
+		if err != nil {
+			if n > skip {
+				cp.update(t, n, false)
+			}
+			if cp.failures++; cp.failures < MaxReadAttempts {
+				wait := ReadRetryInterval << (cp.failures - 1)
+				logger.Error.Printf("Unable to read trace file %s, retrying in %v: %v", t.name, wait, err)
+				return wait, nil
+			}
+			logger.Error.Printf("Skipping trace file %s after %d attempts: %v", t.name, cp.failures, err)
+		}
+		cp.failures = 0
+		cp.update(t, n, true)
+	}
+	return 0, nil
+}
+
+// waitForTraces blocks until the followed directory changes, the driver stops, or the wait period expires.
+func (s *FileDriver) waitForTraces(watcher *fsnotify.Watcher, wait time.Duration) {
+	var timeout <-chan time.Time
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	for {
+		select {
+		case ev := <-watcher.Events:
+			if ev.Op&(fsnotify.Create|fsnotify.Rename) != 0 && isTraceFile(ev.Name) {
+				logger.Trace.Printf("Trace directory event %s", ev.String())
+				return
+			}
+		case err := <-watcher.Errors:
+			logger.Error.Println("Watcher error: ", err)
+		case <-timeout:
+			return
+		case <-s.stop:
+			return
+		}
+	}
+}
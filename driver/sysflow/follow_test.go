@@ -0,0 +1,257 @@
+package sysflow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/actgardner/gogen-avro/v7/container"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// newTestTraceRecs returns a header record followed by n process events with thread IDs 1 to n.
+func newTestTraceRecs(n int) []*sfgo.SysFlow {
+	hdr := sfgo.NewSFHeader()
+	hdr.Exporter = "node"
+	recs := []*sfgo.SysFlow{{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: hdr}}}
+	for i := 1; i <= n; i++ {
+		pe := sfgo.NewProcessEvent()
+		pe.ProcOID = &sfgo.OID{Hpid: 1, CreateTS: 1}
+		pe.Ts = int64(i)
+		pe.Tid = int64(i)
+		recs = append(recs, &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcessEvent, ProcessEvent: pe}})
+	}
+	return recs
+}
+
+// writeTestTrace writes records recs into trace file path.
+func writeTestTrace(t *testing.T, path string, recs []*sfgo.SysFlow) {
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	w, err := sfgo.NewSysFlowWriter(f, container.Null, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range recs {
+		if err := w.WriteRecord(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestFileDriver(c FileConfig) *FileDriver {
+	s := NewFileDriver().(*FileDriver)
+	s.config = c
+	s.sel = newSelector(c)
+	s.pace = newPacer(0, s.stop)
+	return s
+}
+
+// readTestRecords returns the records in ch as thread IDs, with -1 for header records.
+func readTestRecords(ch chan *sfgo.SysFlow) []int64 {
+	var tids []int64
+	for len(ch) > 0 {
+		sf := <-ch
+		if sf.Rec.UnionType == sfgo.SF_HEADER {
+			tids = append(tids, -1)
+		} else {
+			tids = append(tids, sf.Rec.ProcessEvent.Tid)
+		}
+	}
+	return tids
+}
+
+func assertRecords(t *testing.T, got []int64, want ...int64) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected records %v, got %v", want, got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Fatalf("expected records %v, got %v", want, got)
+		}
+	}
+}
+
+func TestListTraces(t *testing.T) {
+	dir := t.TempDir()
+	mtime := time.Unix(150, 0)
+	for _, name := range []string{"mon.200.sf", "mon.100.sf.gz", "b.sf", "a.100.sf.zst", "notes.txt", "mon.50.sf.tmp"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filepath.Join(dir, name), mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "mon.10.sf"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	files, err := listTraces(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a.100.sf.zst", "mon.100.sf.gz", "b.sf", "mon.200.sf"}
+	if len(files) != len(want) {
+		t.Fatalf("expected %v, got %v", want, files)
+	}
+	for i, f := range files {
+		if f.name != want[i] {
+			t.Fatalf("expected %v, got %v", want, files)
+		}
+	}
+	if files[2].ts != 150 {
+		t.Fatalf("expected modification time for file without timestamp, got %d", files[2].ts)
+	}
+}
+
+func TestCheckpointSkip(t *testing.T) {
+	cp := &checkpoint{File: "mon.200.sf", Time: 200, Offset: 42}
+	tests := []struct {
+		file traceFile
+		skip int64
+	}{
+		{traceFile{name: "mon.100.sf", ts: 100}, -1},
+		{traceFile{name: "a.200.sf", ts: 200}, -1},
+		{traceFile{name: "mon.200.sf", ts: 200}, 42},
+		{traceFile{name: "z.200.sf", ts: 200}, 0},
+		{traceFile{name: "mon.300.sf", ts: 300}, 0},
+	}
+	for _, tt := range tests {
+		if skip := cp.skip(tt.file); skip != tt.skip {
+			t.Errorf("expected skip %d for %s, got %d", tt.skip, tt.file.name, skip)
+		}
+	}
+	cp.Done = true
+	if skip := cp.skip(traceFile{name: "mon.200.sf", ts: 200}); skip != -1 {
+		t.Errorf("expected done file to be skipped, got %d", skip)
+	}
+	if skip := (&checkpoint{}).skip(traceFile{name: "mon.100.sf", ts: 100}); skip != 0 {
+		t.Errorf("expected no skip without checkpoint, got %d", skip)
+	}
+}
+
+func TestCheckpointSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "processor.state")
+	cp, err := loadCheckpoint(path)
+	if err != nil || cp.File != "" {
+		t.Fatalf("expected empty checkpoint, got %v, %v", cp, err)
+	}
+	cp.update(traceFile{name: "mon.100.sf", ts: 100}, 10, true)
+	cp.update(traceFile{name: "mon.200.sf", ts: 200}, 5, false)
+	if cp, err = loadCheckpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	// progress within a file is saved at most once per checkpoint interval
+	if cp.File != "mon.100.sf" || cp.Offset != 10 || !cp.Done {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestReadTracesResume(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"mon.1.sf", "mon.2.sf", "mon.3.sf"} {
+		writeTestTrace(t, filepath.Join(dir, name), newTestTraceRecs(5))
+	}
+	path := filepath.Join(t.TempDir(), "processor.state")
+	cp := &checkpoint{File: "mon.2.sf", Time: 2, Offset: 3, path: path}
+	if err := cp.save(); err != nil {
+		t.Fatal(err)
+	}
+	cp, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestFileDriver(FileConfig{Follow: true})
+	records := make(chan *sfgo.SysFlow, 100)
+	running := true
+
+	// the first file is done, the header of the second file is replayed, and the newest file is not finalized
+	wait, err := s.readTraces(dir, cp, records, &running)
+	if err != nil || wait != 0 {
+		t.Fatalf("unexpected result %v, %v", wait, err)
+	}
+	assertRecords(t, readTestRecords(records), -1, 3, 4, 5)
+	if cp.File != "mon.2.sf" || cp.Offset != 6 || !cp.Done {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+	if cp, err = loadCheckpoint(path); err != nil || cp.File != "mon.2.sf" || !cp.Done {
+		t.Fatalf("expected checkpoint to be saved, got %+v, %v", cp, err)
+	}
+
+	// the newest file is finalized once it has been idle
+	s.config.FollowIdle = time.Hour
+	if wait, _ = s.readTraces(dir, cp, records, &running); wait <= 0 {
+		t.Fatalf("expected wait for newest file, got %v", wait)
+	}
+	assertRecords(t, readTestRecords(records))
+	s.config.FollowIdle = time.Millisecond
+	if wait, _ = s.readTraces(dir, cp, records, &running); wait != 0 {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	assertRecords(t, readTestRecords(records), -1, 1, 2, 3, 4, 5)
+	if cp.File != "mon.3.sf" || !cp.Done {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+}
+
+func TestReadTracesRetry(t *testing.T) {
+	dir := t.TempDir()
+	writeTestTrace(t, filepath.Join(dir, "mon.1.sf"), newTestTraceRecs(2))
+	if err := os.WriteFile(filepath.Join(dir, "mon.2.sf"), []byte("partial"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	writeTestTrace(t, filepath.Join(dir, "mon.3.sf"), newTestTraceRecs(2))
+	writeTestTrace(t, filepath.Join(dir, "mon.4.sf"), newTestTraceRecs(2))
+	cp := &checkpoint{File: "mon.1.sf", Time: 1, Offset: 3, Done: true}
+	s := newTestFileDriver(FileConfig{Follow: true})
+	records := make(chan *sfgo.SysFlow, 100)
+	running := true
+
+	// a file failing to be read is retried, and later files are not read meanwhile
+	wait, err := s.readTraces(dir, cp, records, &running)
+	if err != nil || wait != ReadRetryInterval {
+		t.Fatalf("unexpected result %v, %v", wait, err)
+	}
+	assertRecords(t, readTestRecords(records))
+	if cp.File != "mon.1.sf" || cp.skip(traceFile{name: "mon.2.sf", ts: 2}) != 0 {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+	if wait, _ = s.readTraces(dir, cp, records, &running); wait != 2*ReadRetryInterval {
+		t.Fatalf("expected increasing retry interval, got %v", wait)
+	}
+
+	// the file is read once it becomes readable
+	writeTestTrace(t, filepath.Join(dir, "mon.2.sf"), newTestTraceRecs(2))
+	if wait, _ = s.readTraces(dir, cp, records, &running); wait != 0 {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	assertRecords(t, readTestRecords(records), -1, 1, 2, -1, 1, 2)
+	if cp.File != "mon.3.sf" || !cp.Done || cp.failures != 0 {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+
+	// a file that keeps failing is skipped after the maximum number of attempts
+	writeTestTrace(t, filepath.Join(dir, "mon.5.sf"), newTestTraceRecs(2))
+	if err := os.WriteFile(filepath.Join(dir, "mon.4.sf"), []byte("corrupt"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i < MaxReadAttempts; i++ {
+		if wait, _ = s.readTraces(dir, cp, records, &running); wait == 0 {
+			t.Fatalf("expected retry on attempt %d", i)
+		}
+	}
+	if wait, _ = s.readTraces(dir, cp, records, &running); wait != 0 {
+		t.Fatalf("unexpected wait %v", wait)
+	}
+	if cp.File != "mon.4.sf" || !cp.Done {
+		t.Fatalf("unexpected checkpoint %+v", cp)
+	}
+}
@@ -0,0 +1,82 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// pacer delays records according to their original timestamps, so that a trace
+// is replayed at its original rate multiplied by a speed factor.
+type pacer struct {
+	speed float64
+	base  int64
+	start time.Time
+	stop  <-chan struct{}
+	now   func() time.Time
+	after func(d time.Duration) (<-chan time.Time, func() bool)
+}
+
+// newPacer creates a pacer replaying records at speed times their original rate, or
+// returns nil if speed is not positive, in which case records are not delayed.
+func newPacer(speed float64, stop <-chan struct{}) *pacer {
+	if speed <= 0 {
+		return nil
+	}
+	return &pacer{speed: speed, stop: stop, now: time.Now, after: newTimer}
+}
+
+// newTimer starts a timer expiring after duration d, and returns its channel and stop function.
+func newTimer(d time.Duration) (<-chan time.Time, func() bool) {
+	timer := time.NewTimer(d)
+	return timer.C, timer.Stop
+}
+
+// wait blocks until record sf is due. Header and entity records, and records older
+// than the replay position, are due immediately. It returns false if the driver stops
+// while waiting.
+func (p *pacer) wait(sf *sfgo.SysFlow) bool {
+	if p == nil {
+		return true
+	}
+	ts, _ := getRecordTimeAndProc(sf)
+	if ts == 0 {
+		return true
+	}
+	if p.start.IsZero() {
+		p.base, p.start = ts, p.now()
+		return true
+	}
+	d := p.start.Add(time.Duration(float64(ts-p.base) / p.speed)).Sub(p.now())
+	if d <= 0 {
+		return true
+	}
+	expired, stop := p.after(d)
+	defer stop()
+	select {
+	case <-expired:
+		return true
+	case <-p.stop:
+		return false
+	}
+}
@@ -0,0 +1,108 @@
+package sysflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// testClock is a fake clock for the pacer, advancing by the duration of each expired timer.
+type testClock struct {
+	now    time.Time
+	waits  []time.Duration
+	expire bool
+}
+
+func (c *testClock) after(d time.Duration) (<-chan time.Time, func() bool) {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if c.expire {
+		c.now = c.now.Add(d)
+		ch <- c.now
+	}
+	return ch, func() bool { return true }
+}
+
+func newTestPacer(speed float64, stop chan struct{}) (*pacer, *testClock) {
+	c := &testClock{now: time.Unix(1666015200, 0), expire: true}
+	p := newPacer(speed, stop)
+	if p != nil {
+		p.now = func() time.Time { return c.now }
+		p.after = c.after
+	}
+	return p, c
+}
+
+func TestPacerUnpaced(t *testing.T) {
+	for _, speed := range []float64{0, -1} {
+		p, _ := newTestPacer(speed, make(chan struct{}))
+		if p != nil {
+			t.Fatalf("expected no pacer for speed %v", speed)
+		}
+		for _, ts := range []int64{1, int64(time.Hour), int64(2 * time.Hour)} {
+			if !p.wait(newTestProcEvt(1, ts)) {
+				t.Fatal("expected unpaced record to be due")
+			}
+		}
+	}
+}
+
+func TestPacerScaling(t *testing.T) {
+	base := int64(time.Hour)
+	tests := []struct {
+		speed float64
+		waits []time.Duration
+	}{
+		{1, []time.Duration{time.Second, 2 * time.Second}},
+		{2, []time.Duration{500 * time.Millisecond, time.Second}},
+		{0.5, []time.Duration{2 * time.Second, 4 * time.Second}},
+	}
+	for _, tt := range tests {
+		p, c := newTestPacer(tt.speed, make(chan struct{}))
+		recs := []*sfgo.SysFlow{
+			newTestProcEvt(1, base),
+			{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: sfgo.NewSFHeader()}},
+			newTestProcEvt(1, base+int64(time.Second)),
+			newTestProcEvt(1, base),
+			newTestProcEvt(1, base+int64(3*time.Second)),
+		}
+		for _, sf := range recs {
+			if !p.wait(sf) {
+				t.Fatal("expected record to be due")
+			}
+		}
+		if len(c.waits) != len(tt.waits) {
+			t.Fatalf("speed %v: expected waits %v, got %v", tt.speed, tt.waits, c.waits)
+		}
+		for i := range c.waits {
+			if c.waits[i] != tt.waits[i] {
+				t.Fatalf("speed %v: expected waits %v, got %v", tt.speed, tt.waits, c.waits)
+			}
+		}
+	}
+}
+
+func TestPacerLagging(t *testing.T) {
+	p, c := newTestPacer(1, make(chan struct{}))
+	p.wait(newTestProcEvt(1, int64(time.Hour)))
+	// processing took longer than the gap between the records
+	c.now = c.now.Add(5 * time.Second)
+	if !p.wait(newTestProcEvt(1, int64(time.Hour+2*time.Second))) || len(c.waits) != 0 {
+		t.Fatalf("expected lagging record to be due immediately, got waits %v", c.waits)
+	}
+	if !p.wait(newTestProcEvt(1, int64(time.Hour+6*time.Second))) || len(c.waits) != 1 || c.waits[0] != time.Second {
+		t.Fatalf("expected wait relative to replay start, got waits %v", c.waits)
+	}
+}
+
+func TestPacerStop(t *testing.T) {
+	stop := make(chan struct{})
+	p, c := newTestPacer(1, stop)
+	c.expire = false
+	p.wait(newTestProcEvt(1, int64(time.Hour)))
+	close(stop)
+	if p.wait(newTestProcEvt(1, int64(time.Hour+time.Second))) {
+		t.Fatal("expected wait to be interrupted")
+	}
+}
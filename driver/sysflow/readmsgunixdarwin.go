@@ -22,8 +22,10 @@
 // Package sysflow implements pluggable drivers for SysFlow ingestion.
 package sysflow
 
+import "net"
+
 // readMsgUnix reads a message from a unix socket (compatible with the Darwin architecture).
-func (s *StreamingDriver) readMsgUnix(buf []byte, oobuf []byte) error {
-	_, _, _, _, err := s.conn.ReadMsgUnix(buf[:], oobuf[:])
+func readMsgUnix(conn *net.UnixConn, buf []byte, oobuf []byte) error {
+	_, _, _, _, err := conn.ReadMsgUnix(buf[:], oobuf[:])
 	return err
 }
@@ -24,12 +24,13 @@ package sysflow
 
 import (
 	"fmt"
+	"net"
 	"syscall"
 )
 
 // readMsgUnix reads a message from a unix socket (compatible with the Linux architecture).
-func (s *StreamingDriver) readMsgUnix(buf []byte, oobuf []byte) error {
-	_, _, flags, _, err := s.conn.ReadMsgUnix(buf[:], oobuf[:])
+func readMsgUnix(conn *net.UnixConn, buf []byte, oobuf []byte) error {
+	_, _, flags, _, err := conn.ReadMsgUnix(buf[:], oobuf[:])
 	if flags != syscall.MSG_CMSG_CLOEXEC {
 		return fmt.Errorf("ReadMsgUnix flags = %v, want %v (MSG_CMSG_CLOEXEC)", flags, syscall.MSG_CMSG_CLOEXEC)
 	}
@@ -0,0 +1,166 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// HostContainerID selects records of processes running outside of containers.
+const HostContainerID = "host"
+
+// selector selects the records replayed by the file driver. Header and entity records
+// are always selected, so that the reader keeps the context of the selected events.
+type selector struct {
+	start      int64
+	end        int64
+	types      map[sfgo.SFObjectType]bool
+	containers map[string]bool
+	procs      map[sfgo.OID]string
+}
+
+// newSelector creates a record selector from a file driver configuration, or returns nil if
+// the configuration selects all records.
+func newSelector(c FileConfig) *selector {
+	if c.SelectStart == 0 && c.SelectEnd == 0 && len(c.SelectTypes) == 0 && len(c.SelectContainers) == 0 {
+		return nil
+	}
+	s := &selector{start: c.SelectStart, end: c.SelectEnd, procs: make(map[sfgo.OID]string)}
+	if s.end == 0 {
+		s.end = math.MaxInt64
+	}
+	if len(c.SelectTypes) > 0 {
+		s.types = make(map[sfgo.SFObjectType]bool)
+		for _, t := range c.SelectTypes {
+			s.types[t] = true
+		}
+	}
+	if len(c.SelectContainers) > 0 {
+		s.containers = make(map[string]bool)
+		for _, id := range c.SelectContainers {
+			s.containers[id] = true
+		}
+	}
+	return s
+}
+
+// accept returns true if record sf is selected. A nil selector selects all records.
+func (s *selector) accept(sf *sfgo.SysFlow) bool {
+	if s == nil {
+		return true
+	}
+	rtype := sf.Rec.UnionType
+	switch rtype {
+	case sfgo.SF_PROCESS:
+		if s.containers != nil {
+			s.procs[*sf.Rec.Process.Oid] = getContainerID(sf.Rec.Process.ContainerId)
+		}
+		return true
+	case sfgo.SF_HEADER, sfgo.SF_CONT, sfgo.SF_POD, sfgo.SF_FILE:
+		return true
+	}
+	if s.types != nil && !s.types[rtype] {
+		return false
+	}
+	ts, oid := getRecordTimeAndProc(sf)
+	if ts < s.start || ts >= s.end {
+		return false
+	}
+	if s.containers != nil {
+		if oid == nil {
+			return false
+		}
+		id, ok := s.procs[*oid]
+		return ok && s.containers[id]
+	}
+	return true
+}
+
+// getContainerID returns the container ID of a process, or HostContainerID if the process does not run in a container.
+func getContainerID(id *sfgo.ContainerIdUnion) string {
+	if id != nil && id.UnionType == sfgo.ContainerIdUnionTypeEnumString {
+		return id.String
+	}
+	return HostContainerID
+}
+
+// getRecordTimeAndProc returns the timestamp and process OID of an event or flow record.
+func getRecordTimeAndProc(sf *sfgo.SysFlow) (int64, *sfgo.OID) {
+	switch sf.Rec.UnionType {
+	case sfgo.SF_PROC_EVT:
+		return sf.Rec.ProcessEvent.Ts, sf.Rec.ProcessEvent.ProcOID
+	case sfgo.SF_NET_FLOW:
+		return sf.Rec.NetworkFlow.Ts, sf.Rec.NetworkFlow.ProcOID
+	case sfgo.SF_FILE_FLOW:
+		return sf.Rec.FileFlow.Ts, sf.Rec.FileFlow.ProcOID
+	case sfgo.SF_FILE_EVT:
+		return sf.Rec.FileEvent.Ts, sf.Rec.FileEvent.ProcOID
+	case sfgo.SF_NET_EVT:
+		return sf.Rec.NetworkEvent.Ts, sf.Rec.NetworkEvent.ProcOID
+	case sfgo.SF_PROC_FLOW:
+		return sf.Rec.ProcessFlow.Ts, sf.Rec.ProcessFlow.ProcOID
+	case sfgo.SF_K8S_EVT:
+		return sf.Rec.K8sEvent.Ts, nil
+	}
+	return 0, nil
+}
+
+// parseTimestamp parses a timestamp given in RFC 3339 format, or as nanoseconds since the epoch.
+func parseTimestamp(s string) (int64, error) {
+	if ns, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ns, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return 0, fmt.Errorf("expected an RFC 3339 timestamp or nanoseconds since the epoch")
+	}
+	return t.UnixNano(), nil
+}
+
+// parseRecordTypes parses a comma-separated list of record types (e.g., PE,NF).
+func parseRecordTypes(s string) ([]sfgo.SFObjectType, error) {
+	var types []sfgo.SFObjectType
+	for _, v := range parseList(s) {
+		t, err := sfgo.ParseRecordTypeStr(v)
+		if err != nil {
+			return nil, fmt.Errorf("unknown record type '%s'", v)
+		}
+		types = append(types, t)
+	}
+	return types, nil
+}
+
+// parseList parses a comma-separated list of values.
+func parseList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
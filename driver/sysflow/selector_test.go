@@ -0,0 +1,99 @@
+package sysflow
+
+import (
+	"testing"
+
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+func newTestProc(hpid int64, containerID string) *sfgo.SysFlow {
+	p := sfgo.NewProcess()
+	p.Oid = &sfgo.OID{Hpid: hpid, CreateTS: 1}
+	if containerID != "" {
+		p.ContainerId = &sfgo.ContainerIdUnion{UnionType: sfgo.ContainerIdUnionTypeEnumString, String: containerID}
+	}
+	return &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcess, Process: p}}
+}
+
+func newTestProcEvt(hpid int64, ts int64) *sfgo.SysFlow {
+	pe := sfgo.NewProcessEvent()
+	pe.ProcOID = &sfgo.OID{Hpid: hpid, CreateTS: 1}
+	pe.Ts = ts
+	return &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcessEvent, ProcessEvent: pe}}
+}
+
+func newTestNetFlow(hpid int64, ts int64) *sfgo.SysFlow {
+	nf := sfgo.NewNetworkFlow()
+	nf.ProcOID = &sfgo.OID{Hpid: hpid, CreateTS: 1}
+	nf.Ts = ts
+	return &sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumNetworkFlow, NetworkFlow: nf}}
+}
+
+func TestSelectorAll(t *testing.T) {
+	if s := newSelector(FileConfig{}); s != nil || !s.accept(newTestProcEvt(1, 100)) {
+		t.Fatal("expected nil selector accepting all records")
+	}
+}
+
+func TestSelectorEntities(t *testing.T) {
+	s := newSelector(FileConfig{SelectStart: 100, SelectEnd: 200, SelectTypes: []sfgo.SFObjectType{sfgo.SF_NET_FLOW}, SelectContainers: []string{"c1"}})
+	entities := []*sfgo.SysFlow{
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: sfgo.NewSFHeader()}},
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumContainer, Container: sfgo.NewContainer()}},
+		{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumFile, File: sfgo.NewFile()}},
+		newTestProc(1, "c2"),
+		newTestProc(2, ""),
+	}
+	for _, sf := range entities {
+		if !s.accept(sf) {
+			t.Errorf("expected entity record of type %d to be selected", sf.Rec.UnionType)
+		}
+	}
+}
+
+func TestSelectorTimeAndType(t *testing.T) {
+	s := newSelector(FileConfig{SelectStart: 100, SelectEnd: 200, SelectTypes: []sfgo.SFObjectType{sfgo.SF_NET_FLOW}})
+	tests := []struct {
+		sf     *sfgo.SysFlow
+		accept bool
+	}{
+		{newTestNetFlow(1, 99), false},
+		{newTestNetFlow(1, 100), true},
+		{newTestNetFlow(1, 199), true},
+		{newTestNetFlow(1, 200), false},
+		{newTestProcEvt(1, 150), false},
+	}
+	for i, tt := range tests {
+		if s.accept(tt.sf) != tt.accept {
+			t.Errorf("test %d: expected %v", i, tt.accept)
+		}
+	}
+}
+
+func TestSelectorContainers(t *testing.T) {
+	s := newSelector(FileConfig{SelectContainers: []string{"c1", HostContainerID}})
+	s.accept(newTestProc(1, "c1"))
+	s.accept(newTestProc(2, "c2"))
+	s.accept(newTestProc(3, ""))
+	tests := []struct {
+		sf     *sfgo.SysFlow
+		accept bool
+	}{
+		{newTestProcEvt(1, 100), true},
+		{newTestNetFlow(1, 100), true},
+		{newTestProcEvt(2, 100), false},
+		{newTestProcEvt(3, 100), true},
+		{newTestProcEvt(4, 100), false},
+	}
+	for i, tt := range tests {
+		if s.accept(tt.sf) != tt.accept {
+			t.Errorf("test %d: expected %v", i, tt.accept)
+		}
+	}
+
+	// a process record updates the container of its OID
+	s.accept(newTestProc(2, "c1"))
+	if !s.accept(newTestProcEvt(2, 100)) {
+		t.Error("expected record of process moved to selected container to be selected")
+	}
+}
@@ -0,0 +1,207 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+)
+
+const (
+	// S3Scheme is the URL scheme of trace files stored in S3-compatible object stores.
+	S3Scheme = "s3"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// traceExts lists the extensions of trace files, from the most to the least specific.
+var traceExts = []string{".sf.gz", ".sf.zst", ".sf"}
+
+// trimTraceExt removes the trace file extension from name, if any.
+func trimTraceExt(name string) string {
+	for _, ext := range traceExts {
+		if strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// isTraceFile returns true if name has a trace file extension.
+func isTraceFile(name string) bool {
+	return trimTraceExt(name) != name
+}
+
+// compressedReader closes a decompressor along with its underlying reader.
+type compressedReader struct {
+	io.Reader
+	closers []func() error
+}
+
+// Close closes the decompressor and the underlying reader.
+func (r *compressedReader) Close() (err error) {
+	for _, c := range r.closers {
+		if cerr := c(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return
+}
+
+// decompress returns a reader decompressing rc if its content starts with gzip or zstd magic bytes,
+// or a buffered reader of rc otherwise.
+func decompress(rc io.ReadCloser) (io.ReadCloser, error) {
+	br := bufio.NewReader(rc)
+	magic, err := br.Peek(len(zstdMagic))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &compressedReader{Reader: zr, closers: []func() error{zr.Close, rc.Close}}, nil
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		return &compressedReader{Reader: zr, closers: []func() error{func() error { zr.Close(); return nil }, rc.Close}}, nil
+	}
+	return &compressedReader{Reader: br, closers: []func() error{rc.Close}}, nil
+}
+
+// isS3Path returns true if path is an object store URL.
+func isS3Path(path string) bool {
+	return strings.HasPrefix(path, S3Scheme+"://")
+}
+
+// s3Source lists and reads trace files from an S3-compatible object store.
+type s3Source struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// newS3Source creates an object store source for URL path (s3://bucket/prefix).
+func newS3Source(path string, config FileConfig) (*s3Source, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing bucket name in %s", path)
+	}
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.Static{Value: credentials.Value{AccessKeyID: config.S3AccessKey, SecretAccessKey: config.S3SecretKey, SignerType: credentials.SignatureV4}},
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+		&credentials.FileAWSCredentials{},
+		&credentials.IAM{Client: &http.Client{Transport: http.DefaultTransport}},
+	})
+	client, err := minio.New(config.S3Endpoint, &minio.Options{Creds: creds, Secure: config.S3Secure, Region: config.S3Region})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Source{client: client, bucket: u.Host, prefix: strings.TrimPrefix(u.Path, "/")}, nil
+}
+
+// list returns the URLs of the trace files under the source prefix, sorted by key.
+func (s *s3Source) list(ctx context.Context) ([]string, error) {
+	var keys []string
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return nil, obj.Err
+		}
+		if !isTraceFile(obj.Key) {
+			continue
+		}
+		keys = append(keys, obj.Key)
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("No trace files present in bucket " + s.bucket + " with prefix: " + s.prefix)
+	}
+	sort.Strings(keys)
+	urls := make([]string, len(keys))
+	for i, k := range keys {
+		urls[i] = fmt.Sprintf("%s://%s/%s", S3Scheme, s.bucket, k)
+	}
+	logger.Trace.Printf("Number of objects in list: %d\n", len(urls))
+	return urls, nil
+}
+
+// open streams the object at URL path.
+func (s *s3Source) open(ctx context.Context, path string) (io.ReadCloser, error) {
+	u, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	obj, err := s.client.GetObject(ctx, u.Host, strings.TrimPrefix(u.Path, "/"), minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy, so check that the object exists before reading it
+	if _, err = obj.Stat(); err != nil {
+		obj.Close()
+		return nil, err
+	}
+	return obj, nil
+}
+
+// openTrace opens a local or object store trace file, decompressing its content if needed.
+func (s *FileDriver) openTrace(path string) (io.ReadCloser, error) {
+	var rc io.ReadCloser
+	var err error
+	if s.s3 != nil {
+		rc, err = s.s3.open(context.Background(), path)
+	} else {
+		rc, err = os.Open(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	r, err := decompress(rc)
+	if err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return r, nil
+}
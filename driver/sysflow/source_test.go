@@ -0,0 +1,145 @@
+package sysflow
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+// s3Object describes an object in a listing of the S3 stand-in.
+type s3Object struct {
+	Key          string
+	LastModified string
+	ETag         string
+	Size         int
+}
+
+// s3Listing is the response of the S3 stand-in to ListObjectsV2 requests.
+type s3Listing struct {
+	XMLName     xml.Name `xml:"http://s3.amazonaws.com/doc/2006-03-01/ ListBucketResult"`
+	Name        string
+	Prefix      string
+	KeyCount    int
+	MaxKeys     int
+	IsTruncated bool
+	Contents    []s3Object
+}
+
+// newS3StandIn starts a minimal S3-compatible server holding objects of bucket.
+func newS3StandIn(t *testing.T, bucket string, objects map[string][]byte) *httptest.Server {
+	modTime := time.Unix(1666015200, 0).UTC()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimPrefix(r.URL.Path, "/")
+		if path == bucket || path == bucket+"/" {
+			if r.URL.Query().Get("list-type") != "2" {
+				http.Error(w, "unsupported bucket operation", http.StatusNotImplemented)
+				return
+			}
+			prefix := r.URL.Query().Get("prefix")
+			l := s3Listing{Name: bucket, Prefix: prefix, MaxKeys: 1000}
+			for k, v := range objects {
+				if strings.HasPrefix(k, prefix) {
+					l.Contents = append(l.Contents, s3Object{Key: k, LastModified: modTime.Format(time.RFC3339), ETag: `"0"`, Size: len(v)})
+				}
+			}
+			sort.Slice(l.Contents, func(i, j int) bool { return l.Contents[i].Key < l.Contents[j].Key })
+			l.KeyCount = len(l.Contents)
+			w.Header().Set("Content-Type", "application/xml")
+			if err := xml.NewEncoder(w).Encode(l); err != nil {
+				t.Error(err)
+			}
+			return
+		}
+		data, ok := objects[strings.TrimPrefix(path, bucket+"/")]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			return
+		}
+		w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+		w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+		w.Header().Set("ETag", `"0"`)
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// encodeTestTrace returns the content of a trace file holding records recs.
+func encodeTestTrace(t *testing.T, recs []*sfgo.SysFlow) []byte {
+	path := filepath.Join(t.TempDir(), "trace.sf")
+	writeTestTrace(t, path, recs)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestS3Source(t *testing.T) {
+	trace := encodeTestTrace(t, newTestTraceRecs(3))
+	var gz, zs bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	gw.Write(trace)
+	gw.Close()
+	zw, err := zstd.NewWriter(&zs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw.Write(trace)
+	zw.Close()
+	srv := newS3StandIn(t, "traces", map[string][]byte{
+		"node1/mon.1.sf":     trace,
+		"node1/mon.2.sf.gz":  gz.Bytes(),
+		"node1/mon.3.sf.zst": zs.Bytes(),
+		"node1/README.md":    []byte("not a trace"),
+		"node1/sub/":         nil,
+		"node2/mon.1.sf":     trace,
+	})
+	u, _ := url.Parse(srv.URL)
+	c := FileConfig{S3Endpoint: u.Host, S3Region: "us-east-1", S3AccessKey: "test", S3SecretKey: "testsecret"}
+
+	s := newTestFileDriver(c)
+	if s.s3, err = newS3Source("s3://traces/node1", c); err != nil {
+		t.Fatal(err)
+	}
+	files, err := s.s3.list(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"s3://traces/node1/mon.1.sf", "s3://traces/node1/mon.2.sf.gz", "s3://traces/node1/mon.3.sf.zst"}
+	if strings.Join(files, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected %v, got %v", want, files)
+	}
+
+	records := make(chan *sfgo.SysFlow, 100)
+	running := true
+	if err := s.readFiles("s3://traces/node1", records, &running); err != nil {
+		t.Fatal(err)
+	}
+	assertRecords(t, readTestRecords(records), -1, 1, 2, 3, -1, 1, 2, 3, -1, 1, 2, 3)
+
+	if s.s3, err = newS3Source("s3://traces/node3", c); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.s3.list(context.Background()); err == nil {
+		t.Fatal("expected error for prefix without trace files")
+	}
+}
@@ -23,16 +23,15 @@
 package sysflow
 
 import (
-	"bytes"
+	"errors"
+	"io"
 	"net"
 	"os"
 	"path/filepath"
 
-	"github.com/actgardner/gogen-avro/v7/compiler"
 	"github.com/actgardner/gogen-avro/v7/vm"
 	"github.com/sysflow-telemetry/sf-apis/go/logger"
 	"github.com/sysflow-telemetry/sf-apis/go/plugins"
-	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
 )
 
 const (
@@ -40,21 +39,21 @@ const (
 )
 
 const (
-	// BuffSize represents the buffer size of the stream
-	BuffSize = 16384
 	// OOBuffSize represents the OO buffer size of the stream
 	OOBuffSize = 1024
 )
 
-// StreamingDriver represents a streaming sysflow datasource
+// StreamingDriver represents a streaming sysflow datasource. It accepts any number of
+// concurrent collector connections on a unix socket, and multiplexes their records
+// into the pipeline.
 type StreamingDriver struct {
 	pipeline plugins.SFPipeline
-	conn     *net.UnixConn
+	conns    *connSet
 }
 
 // NewStreamingDriver creates a new streaming driver object
 func NewStreamingDriver() plugins.SFDriver {
-	return &StreamingDriver{}
+	return &StreamingDriver{conns: newConnSet()}
 }
 
 // GetName returns the driver name.
@@ -95,56 +94,68 @@ func (s *StreamingDriver) Run(path string, running *bool) error {
 		logger.Error.Println("Listen error: ", err)
 		return err
 	}
-	defer l.Close()
+	s.conns.listen(l)
 
-	sFlow := sfgo.NewSysFlow()
-	deser, err := compiler.CompileSchemaBytes([]byte(sFlow.Schema()), []byte(sFlow.Schema()))
+	deser, err := compileSchema()
 	if err != nil {
 		logger.Error.Println("Compilation error: ", err)
+		l.Close()
 		return err
 	}
 
+	m := newMux(records)
 	for *running {
-		health := false
-		buf := make([]byte, BuffSize)
-		oobuf := make([]byte, OOBuffSize)
-		reader := bytes.NewReader(buf)
-		s.conn, err = l.AcceptUnix()
+		conn, err := l.AcceptUnix()
 		if err != nil {
-			logger.Error.Println("Accept error: ", err)
-			break
-		}
-		logger.Health.Println("Successfully accepted new input stream")
-		for *running {
-			sFlow = sfgo.NewSysFlow()
-			err := s.readMsgUnix(buf[:], oobuf[:])
-			if err != nil {
-				logger.Error.Println("Read error: ", err)
-				break
-			}
-			reader.Reset(buf)
-			err = vm.Eval(reader, deser, sFlow)
-			if err != nil {
-				logger.Error.Println("Deserialization error: ", err)
-			}
-			if !health {
-				logger.Health.Println("Successfully read first record from input stream")
-				health = true
+			if *running && !errors.Is(err, net.ErrClosed) {
+				logger.Error.Println("Accept error: ", err)
 			}
-			records <- sFlow
+			break
 		}
-		s.conn.Close()
+		s.conns.add(conn)
+		go s.handle(conn, deser, m, running)
 	}
+	s.conns.wait()
 	logger.Trace.Println("Closing main channel")
 	close(records)
 	s.pipeline.Wait()
 	return nil
 }
 
+// handle reads records from a collector connection.
+func (s *StreamingDriver) handle(conn *net.UnixConn, deser *vm.Program, m *mux, running *bool) {
+	defer s.conns.remove(conn)
+	logger.Health.Printf("Successfully accepted new input stream (%d active)", s.conns.len())
+	health := false
+	st := m.open()
+	defer m.close(st)
+	buf := make([]byte, BuffSize)
+	oobuf := make([]byte, OOBuffSize)
+	dec := newDecoder(deser)
+	for *running {
+		err := readMsgUnix(conn, buf[:], oobuf[:])
+		if err != nil {
+			if *running && err != io.EOF && !errors.Is(err, net.ErrClosed) {
+				logger.Error.Println("Read error: ", err)
+			}
+			break
+		}
+		sFlow, err := dec.decode(buf)
+		if err != nil {
+			logger.Error.Println("Deserialization error: ", err)
+			continue
+		}
+		if !health {
+			logger.Health.Println("Successfully read first record from input stream")
+			health = true
+		}
+		m.send(st, sFlow)
+	}
+	logger.Info.Println("Closed input stream")
+}
+
 // Cleanup tears down the driver resources.
 func (s *StreamingDriver) Cleanup() {
 	logger.Trace.Println("Exiting ", streamDriverName)
-	if s.conn != nil {
-		s.conn.Close()
-	}
+	s.conns.close()
 }
@@ -0,0 +1,42 @@
+//go:build linux || darwin
+
+package sysflow
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestStreamingDriverConcurrentCollectors(t *testing.T) {
+	pl := newTestPipeline()
+	d := NewStreamingDriver().(*StreamingDriver)
+	if err := d.Init(pl); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "sysflow.sock")
+	running := true
+	done := make(chan error)
+	go func() { done <- d.Run(path, &running) }()
+	listenAddr(t, d.conns)
+
+	// collectors on the same host share an exporter ID and IP
+	const clients, n = 2, 1000
+	sendConcurrently(t, clients, n,
+		func() (net.Conn, error) {
+			return net.DialUnix("unixpacket", nil, &net.UnixAddr{Name: path, Net: "unixpacket"})
+		},
+		func(conn net.Conn, msg []byte) error {
+			_, err := conn.Write(msg)
+			return err
+		})
+	checkStreams(t, pl.ch, clients, n)
+
+	d.Cleanup()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-pl.ch.In; ok {
+		t.Fatal("expected root channel to be closed")
+	}
+}
@@ -0,0 +1,339 @@
+//
+// Copyright (C) 2022 IBM Corporation.
+//
+// Authors:
+// Frederico Araujo <frederico.araujo@ibm.com>
+// Teryl Taylor <terylt@ibm.com>
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sysflow implements pluggable drivers for SysFlow ingestion.
+package sysflow
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/actgardner/gogen-avro/v7/vm"
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+	"github.com/sysflow-telemetry/sf-processor/core/schema"
+)
+
+const (
+	tcpDriverName = "tcp"
+)
+
+// Configuration keys.
+const (
+	TLSCertConfigKey    string = "tls.cert"
+	TLSKeyConfigKey     string = "tls.key"
+	TLSCAConfigKey      string = "tls.ca"
+	PeerTagConfigKey    string = "peer.tag"
+	MaxMsgSizeConfigKey string = "maxmsgsize"
+)
+
+// TCPConfigSchema declares the configuration keys accepted by the TCP driver.
+var TCPConfigSchema = schema.New(tcpDriverName, []schema.Key{
+	{Name: TLSCertConfigKey, Type: schema.String},
+	{Name: TLSKeyConfigKey, Type: schema.String},
+	{Name: TLSCAConfigKey, Type: schema.String},
+	{Name: PeerTagConfigKey, Type: schema.Enum, Default: PeerTagIP.String(), Values: []string{PeerTagNone.String(), PeerTagIP.String(), PeerTagCert.String()}},
+	{Name: MaxMsgSizeConfigKey, Type: schema.Int, Default: "1048576"},
+})
+
+func init() {
+	schema.Register(TCPConfigSchema)
+}
+
+// PeerTag defines how records are tagged with the identity of the sending peer.
+type PeerTag int
+
+// PeerTag config options.
+const (
+	PeerTagNone PeerTag = iota // records are forwarded as sent by the collector
+	PeerTagIP                  // the header IP is set to the peer address
+	PeerTagCert                // the header IP is set to the peer address, and the exporter ID to the client certificate's common name
+)
+
+func (s PeerTag) String() string {
+	return [...]string{"none", "ip", "cert"}[s]
+}
+
+func parsePeerTag(s string) PeerTag {
+	switch s {
+	case PeerTagNone.String():
+		return PeerTagNone
+	case PeerTagCert.String():
+		return PeerTagCert
+	}
+	return PeerTagIP
+}
+
+// TCPConfig defines a configuration object for the TCP driver.
+type TCPConfig struct {
+	TLSCert    string
+	TLSKey     string
+	TLSCA      string
+	PeerTag    PeerTag
+	MaxMsgSize int
+}
+
+// CreateTCPConfig creates a new config object from config dictionary.
+func CreateTCPConfig(conf map[string]interface{}) (c TCPConfig, err error) {
+	// default values
+	c = TCPConfig{PeerTag: PeerTagIP, MaxMsgSize: 1 << 20}
+
+	// parse config map
+	if v, ok := conf[TLSCertConfigKey].(string); ok {
+		c.TLSCert = v
+	}
+	if v, ok := conf[TLSKeyConfigKey].(string); ok {
+		c.TLSKey = v
+	}
+	if v, ok := conf[TLSCAConfigKey].(string); ok {
+		c.TLSCA = v
+	}
+	if v, ok := conf[PeerTagConfigKey].(string); ok {
+		c.PeerTag = parsePeerTag(v)
+	}
+	if v, ok := conf[MaxMsgSizeConfigKey].(string); ok {
+		c.MaxMsgSize, err = strconv.Atoi(v)
+		if err != nil {
+			return c, fmt.Errorf("invalid value '%s' for key '%s': %v", v, MaxMsgSizeConfigKey, err)
+		}
+	}
+	if (c.TLSCert == "") != (c.TLSKey == "") {
+		return c, fmt.Errorf("both '%s' and '%s' must be set to enable TLS", TLSCertConfigKey, TLSKeyConfigKey)
+	}
+	if c.TLSCA != "" && c.TLSCert == "" {
+		return c, fmt.Errorf("'%s' requires TLS to be enabled", TLSCAConfigKey)
+	}
+	if c.PeerTag == PeerTagCert && c.TLSCA == "" {
+		return c, fmt.Errorf("peer tag '%s' requires client certificate verification ('%s')", PeerTagCert, TLSCAConfigKey)
+	}
+	return
+}
+
+// TCPDriver represents a streaming sysflow datasource receiving length-prefixed
+// Avro-encoded SysFlow records over TCP or TLS connections. Each message consists
+// of a 4-byte big-endian length followed by the Avro binary encoding of a record.
+type TCPDriver struct {
+	pipeline plugins.SFPipeline
+	config   TCPConfig
+	conns    *connSet
+}
+
+// NewTCPDriver creates a new TCP driver object
+func NewTCPDriver() plugins.SFDriver {
+	return &TCPDriver{conns: newConnSet()}
+}
+
+// GetName returns the driver name.
+func (s *TCPDriver) GetName() string {
+	return tcpDriverName
+}
+
+// Register registers driver to plugin cache
+func (s *TCPDriver) Register(pc plugins.SFPluginCache) {
+	pc.AddDriver(tcpDriverName, NewTCPDriver)
+}
+
+// Configure sets the driver configuration.
+func (s *TCPDriver) Configure(conf map[string]interface{}) (err error) {
+	s.config, err = CreateTCPConfig(conf)
+	return
+}
+
+// Init initializes the driver
+func (s *TCPDriver) Init(pipeline plugins.SFPipeline) error {
+	s.pipeline = pipeline
+	return nil
+}
+
+// Run runs the driver, listening for collector connections on address path.
+func (s *TCPDriver) Run(path string, running *bool) error {
+	channel := s.pipeline.GetRootChannel()
+	sfChannel := channel.(*plugins.SFChannel)
+	records := sfChannel.In
+
+	deser, err := compileSchema()
+	if err != nil {
+		logger.Error.Println("Compilation error: ", err)
+		return err
+	}
+
+	l, err := s.listen(path)
+	if err != nil {
+		logger.Error.Println("Listen error: ", err)
+		return err
+	}
+	s.conns.listen(l)
+	logger.Info.Printf("Listening for collector connections on %s", l.Addr())
+
+	m := newMux(records)
+	for *running {
+		conn, err := l.Accept()
+		if err != nil {
+			if !*running || errors.Is(err, net.ErrClosed) {
+				break
+			}
+			logger.Error.Println("Accept error: ", err)
+			continue
+		}
+		s.conns.add(conn)
+		go s.handle(conn, deser, m, running)
+	}
+	s.conns.wait()
+	logger.Trace.Println("Closing main channel")
+	close(records)
+	s.pipeline.Wait()
+	return nil
+}
+
+// listen creates a TCP or TLS listener for address addr.
+func (s *TCPDriver) listen(addr string) (net.Listener, error) {
+	if s.config.TLSCert == "" {
+		return net.Listen("tcp", addr)
+	}
+	cert, err := tls.LoadX509KeyPair(s.config.TLSCert, s.config.TLSKey)
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if s.config.TLSCA != "" {
+		pem, err := os.ReadFile(s.config.TLSCA)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificates found in %s", s.config.TLSCA)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return tls.Listen("tcp", addr, tlsConfig)
+}
+
+// handle reads records from a collector connection.
+func (s *TCPDriver) handle(conn net.Conn, deser *vm.Program, m *mux, running *bool) {
+	defer s.conns.remove(conn)
+
+	peer, err := s.getPeer(conn)
+	if err != nil {
+		logger.Error.Printf("Handshake error with %s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	logger.Health.Printf("Successfully accepted new input stream from %s", peer)
+
+	health := false
+	st := m.open()
+	defer m.close(st)
+	dec := newDecoder(deser)
+	reader := bufio.NewReaderSize(conn, BuffSize)
+	buf := make([]byte, BuffSize)
+	var prefix [4]byte
+	for *running {
+		if _, err := io.ReadFull(reader, prefix[:]); err != nil {
+			if err != io.EOF {
+				logger.Error.Printf("Read error from %s: %v", peer, err)
+			}
+			break
+		}
+		size := int(binary.BigEndian.Uint32(prefix[:]))
+		if size > s.config.MaxMsgSize {
+			logger.Error.Printf("Message of %d bytes from %s exceeds maximum message size %d", size, peer, s.config.MaxMsgSize)
+			break
+		}
+		if size > cap(buf) {
+			buf = make([]byte, size)
+		}
+		if _, err := io.ReadFull(reader, buf[:size]); err != nil {
+			logger.Error.Printf("Read error from %s: %v", peer, err)
+			break
+		}
+		sFlow, err := dec.decode(buf[:size])
+		if err != nil {
+			logger.Error.Printf("Deserialization error from %s: %v", peer, err)
+			continue
+		}
+		if !health {
+			logger.Health.Printf("Successfully read first record from input stream %s", peer)
+			health = true
+		}
+		if sFlow.Rec.UnionType == sfgo.SF_HEADER {
+			s.tag(sFlow.Rec.SFHeader, peer)
+		}
+		m.send(st, sFlow)
+	}
+	logger.Info.Printf("Closed input stream from %s", peer)
+}
+
+// peer describes the identity of a connected collector.
+type peer struct {
+	ip string
+	cn string
+}
+
+func (p peer) String() string {
+	if p.cn != "" {
+		return fmt.Sprintf("%s (%s)", p.ip, p.cn)
+	}
+	return p.ip
+}
+
+// getPeer returns the identity of the collector on the other end of conn, completing the TLS handshake if needed.
+func (s *TCPDriver) getPeer(conn net.Conn) (p peer, err error) {
+	p.ip = conn.RemoteAddr().String()
+	if host, _, err := net.SplitHostPort(p.ip); err == nil {
+		p.ip = host
+	}
+	if tc, ok := conn.(*tls.Conn); ok {
+		if err = tc.Handshake(); err != nil {
+			return
+		}
+		if certs := tc.ConnectionState().PeerCertificates; len(certs) > 0 {
+			p.cn = certs[0].Subject.CommonName
+		}
+	}
+	return
+}
+
+// tag sets the peer identity on a header record according to the peer tag configuration.
+func (s *TCPDriver) tag(hdr *sfgo.SFHeader, p peer) {
+	switch s.config.PeerTag {
+	case PeerTagIP:
+		hdr.Ip = p.ip
+	case PeerTagCert:
+		hdr.Ip = p.ip
+		if p.cn != "" {
+			hdr.Exporter = p.cn
+		}
+	}
+}
+
+// Cleanup tears down the driver resources.
+func (s *TCPDriver) Cleanup() {
+	logger.Trace.Println("Exiting ", tcpDriverName)
+	s.conns.close()
+}
@@ -0,0 +1,185 @@
+package sysflow
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sysflow-telemetry/sf-apis/go/logger"
+	"github.com/sysflow-telemetry/sf-apis/go/plugins"
+	"github.com/sysflow-telemetry/sf-apis/go/sfgo"
+)
+
+func init() {
+	logger.InitLoggers(logger.TRACE)
+}
+
+// testPipeline implements the pipeline interface used by drivers.
+type testPipeline struct {
+	plugins.SFPipeline
+	ch *plugins.SFChannel
+}
+
+func newTestPipeline() *testPipeline {
+	return &testPipeline{ch: &plugins.SFChannel{In: make(chan *sfgo.SysFlow, 10000)}}
+}
+
+func (p *testPipeline) GetRootChannel() interface{} { return p.ch }
+
+func (p *testPipeline) Wait() {}
+
+// encodeTestRecs returns the Avro encoding of a header record followed by n process
+// events of exporter client, with thread IDs identifying the client and sequence.
+func encodeTestRecs(t *testing.T, client int, n int) [][]byte {
+	var msgs [][]byte
+	encode := func(sf *sfgo.SysFlow) {
+		var buf bytes.Buffer
+		if err := sf.Serialize(&buf); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, buf.Bytes())
+	}
+	hdr := sfgo.NewSFHeader()
+	hdr.Exporter = "node"
+	encode(&sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumSFHeader, SFHeader: hdr}})
+	for i := 0; i < n; i++ {
+		pe := sfgo.NewProcessEvent()
+		pe.ProcOID = &sfgo.OID{Hpid: int64(client), CreateTS: 1}
+		pe.Tid = int64(client*n + i)
+		encode(&sfgo.SysFlow{Rec: &sfgo.RecUnion{UnionType: sfgo.RecUnionTypeEnumProcessEvent, ProcessEvent: pe}})
+	}
+	return msgs
+}
+
+// checkStreams reads the records multiplexed from clients until all streams ended, and checks
+// that each record is preceded by the header of its own stream and that streams are complete.
+func checkStreams(t *testing.T, ch *plugins.SFChannel, clients int, n int) {
+	owners := make(map[*sfgo.SFHeader]int)
+	next := make([]int, clients)
+	var cur *sfgo.SFHeader
+	ended := 0
+	timeout := time.After(10 * time.Second)
+	for ended < clients {
+		var sf *sfgo.SysFlow
+		select {
+		case sf = <-ch.In:
+		case <-timeout:
+			t.Fatalf("timed out, %d streams ended", ended)
+		}
+		switch sf.Rec.UnionType {
+		case sfgo.RecUnionTypeEnumSFHeader:
+			if sf.Rec.SFHeader.Exporter == "" {
+				ended++
+				continue
+			}
+			cur = sf.Rec.SFHeader
+		case sfgo.RecUnionTypeEnumProcessEvent:
+			pe := sf.Rec.ProcessEvent
+			client := int(pe.ProcOID.Hpid)
+			if cur == nil {
+				t.Fatal("record received before header")
+			}
+			if owner, ok := owners[cur]; !ok {
+				owners[cur] = client
+			} else if owner != client {
+				t.Fatalf("record of client %d follows header of client %d", client, owner)
+			}
+			if seq := int(pe.Tid) - client*n; seq != next[client] {
+				t.Fatalf("record %d of client %d received out of order, expected %d", seq, client, next[client])
+			}
+			next[client]++
+		}
+	}
+	if len(owners) != clients {
+		t.Fatalf("expected %d stream headers, got %d", clients, len(owners))
+	}
+	for c, cnt := range next {
+		if cnt != n {
+			t.Fatalf("expected %d records from client %d, got %d", n, c, cnt)
+		}
+	}
+}
+
+// sendConcurrently writes the test records of each client on its own connection created by dial.
+func sendConcurrently(t *testing.T, clients int, n int, dial func() (net.Conn, error), write func(net.Conn, []byte) error) {
+	var wg sync.WaitGroup
+	errs := make(chan error, clients)
+	for c := 0; c < clients; c++ {
+		msgs := encodeTestRecs(t, c, n)
+		conn, err := dial()
+		if err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer conn.Close()
+			for _, msg := range msgs {
+				if err := write(conn, msg); err != nil {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+// listenAddr waits until the driver listens for connections and returns its address.
+func listenAddr(t *testing.T, conns *connSet) net.Addr {
+	for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(time.Millisecond) {
+		conns.mu.Lock()
+		l := conns.listener
+		conns.mu.Unlock()
+		if l != nil {
+			return l.Addr()
+		}
+	}
+	t.Fatal("driver not listening")
+	return nil
+}
+
+func TestTCPDriverConcurrentExporters(t *testing.T) {
+	pl := newTestPipeline()
+	d := NewTCPDriver().(*TCPDriver)
+	if err := d.Configure(map[string]interface{}{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Init(pl); err != nil {
+		t.Fatal(err)
+	}
+	running := true
+	done := make(chan error)
+	go func() { done <- d.Run("127.0.0.1:0", &running) }()
+	addr := listenAddr(t, d.conns)
+
+	// both exporters share an exporter ID and are tagged with the same peer IP
+	const clients, n = 2, 1000
+	sendConcurrently(t, clients, n,
+		func() (net.Conn, error) { return net.Dial("tcp", addr.String()) },
+		func(conn net.Conn, msg []byte) error {
+			var prefix [4]byte
+			binary.BigEndian.PutUint32(prefix[:], uint32(len(msg)))
+			if _, err := conn.Write(prefix[:]); err != nil {
+				return err
+			}
+			_, err := conn.Write(msg)
+			return err
+		})
+	checkStreams(t, pl.ch, clients, n)
+
+	d.Cleanup()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := <-pl.ch.In; ok {
+		t.Fatal("expected root channel to be closed")
+	}
+}